@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/acmpolicy"
+)
+
+// generateACMPolicyCommand is the sub-command printing the ACM policy of a KataConfig
+const generateACMPolicyCommand = "generate-acm-policy"
+
+// generateACMPolicy reads a KataConfig and writes the ACM Policy, PlacementRule and
+// PlacementBinding pushing it to the managed clusters, to be applied on the hub
+func generateACMPolicy(args []string, stdin io.Reader, stdout io.Writer) error {
+	var file, clusterSelector string
+	var opts acmpolicy.Options
+	flags := flag.NewFlagSet(generateACMPolicyCommand, flag.ContinueOnError)
+	flags.StringVar(&file, "f", "-", "The file of the v1 KataConfig, - for the standard input.")
+	flags.StringVar(&opts.Name, "name", "", "The name of the policy. Defaults to kata-<KataConfig name>.")
+	flags.StringVar(&opts.Namespace, "namespace", "", "The namespace of the policy on the hub.")
+	flags.StringVar(&clusterSelector, "cluster-selector", "",
+		"The labels of the managed clusters, e.g. env=prod,region=eu. Defaults to all the available clusters.")
+	flags.BoolVar(&opts.Inform, "inform", false, "Only report the clusters without the KataConfig instead of creating it.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if clusterSelector != "" {
+		opts.ClusterSelector = map[string]string{}
+		for _, label := range strings.Split(clusterSelector, ",") {
+			kv := strings.SplitN(label, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return fmt.Errorf("invalid cluster selector label %q", label)
+			}
+			opts.ClusterSelector[kv[0]] = kv[1]
+		}
+	}
+
+	var raw []byte
+	var err error
+	if file == "-" {
+		raw, err = ioutil.ReadAll(stdin)
+	} else {
+		raw, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	if err := yaml.UnmarshalStrict(raw, kataConfig); err != nil {
+		return fmt.Errorf("unable to read the KataConfig: %v", err)
+	}
+	if kataConfig.APIVersion != kataconfigurationv1.GroupVersion.String() || kataConfig.Kind != "KataConfig" {
+		return fmt.Errorf("expected a %s KataConfig, got %s %s", kataconfigurationv1.GroupVersion, kataConfig.APIVersion, kataConfig.Kind)
+	}
+
+	objects, err := acmpolicy.Generate(kataConfig, opts)
+	if err != nil {
+		return err
+	}
+	for i, obj := range objects {
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(stdout, "---")
+		}
+		if _, err := stdout.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KataAnnotationPrefix is the prefix of the pod annotations tuning the kata sandboxes
+const KataAnnotationPrefix = "io.katacontainers.config."
+
+// KataAnnotationPolicySpec allows kata annotations to the pods of some namespaces
+type KataAnnotationPolicySpec struct {
+	// NamespaceSelector selects the namespaces whose pods may set the annotations, all of them
+	// when not set
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowedAnnotations are the kata annotations the pods may set, e.g.
+	// io.katacontainers.config.hypervisor.default_memory. As for CRI-O, an entry allows all the
+	// annotations it is a prefix of, e.g. io.katacontainers.config.hypervisor.
+	// +kubebuilder:validation:MinItems=1
+	AllowedAnnotations []KataAnnotation `json:"allowedAnnotations"`
+}
+
+// KataAnnotation is a kata pod annotation, or a prefix of kata pod annotations
+// +kubebuilder:validation:Pattern=`^io\.katacontainers\.config\.`
+type KataAnnotation string
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KataAnnotationPolicy lets the pods of some namespaces tune their kata sandboxes with the
+// io.katacontainers.config annotations. Once a policy exists, a pod can only set the kata
+// annotations allowed to its namespace, and CRI-O passes the annotations of all the policies
+// down to the kata runtime
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=kataannotationpolicies,scope=Cluster
+// +kubebuilder:printcolumn:name="Allowed Annotations",type=string,JSONPath=`.spec.allowedAnnotations`
+type KataAnnotationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KataAnnotationPolicySpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// KataAnnotationPolicyList contains a list of KataAnnotationPolicy
+type KataAnnotationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KataAnnotationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KataAnnotationPolicy{}, &KataAnnotationPolicyList{})
+}
@@ -15,7 +15,10 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -29,8 +32,217 @@ type KataConfigSpec struct {
 	// +nullable
 	KataConfigPoolSelector *metav1.LabelSelector `json:"kataConfigPoolSelector"`
 
+	// KataConfigPoolSelectors select the nodes matching any of them, e.g. the nodes of several
+	// roles. The operator sets kata.openshift.io/kata-pool=true on the selected nodes and the
+	// kata pool selects that label. Can't be used with the KataConfigPoolSelector
+	// +optional
+	KataConfigPoolSelectors []metav1.LabelSelector `json:"kataConfigPoolSelectors,omitempty"`
+
+	// AllowControlPlaneNodes lets the KataConfigPoolSelector match control plane nodes, which
+	// are rebooted to install kata. Only needed when the cluster has other nodes
+	// +optional
+	AllowControlPlaneNodes bool `json:"allowControlPlaneNodes,omitempty"`
+
+	// DryRun previews the installation instead of doing it: the selected nodes, their
+	// eligibility, the rendered MachineConfig and the expected reboots are reported in
+	// status.dryRun and nothing is created or changed on the cluster
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// InstallMode is how kata is provided: MachineConfig installs it on the nodes through the
+	// machine config operator, PeerPods runs the kata pods in VMs of the cloud provider. Auto,
+	// the default, selects it from the platform of the cluster
+	// +optional
+	// +kubebuilder:validation:Enum=Auto;MachineConfig;PeerPods
+	InstallMode KataInstallMode `json:"installMode,omitempty"`
+
+	// PeerPods configures the kata pods running in VMs of the cloud provider, in the PeerPods
+	// install mode
+	// +optional
+	// +nullable
+	PeerPods *KataPeerPodsConfig `json:"peerPods,omitempty"`
+
+	// KubeVirt sets how kata shares the nodes with OpenShift Virtualization
+	// +optional
+	// +nullable
+	KubeVirt *KataKubeVirtConfig `json:"kubeVirt,omitempty"`
+
+	// Render has the operator write the MachineConfigs, the MachineConfigPool and the
+	// RuntimeClasses it manages into a ConfigMap instead of applying them, for Argo CD or ACM
+	// to apply on clusters only changed through GitOps
+	// +optional
+	Render *KataRenderConfig `json:"render,omitempty"`
+
+	// SchedulingNodeSelector is the node selector of the kata RuntimeClass, the kata pods are
+	// only scheduled on the nodes matching it. Defaults to kata.openshift.io/kata-runtime=true,
+	// which the operator sets on the nodes that completed the installation. Changing it doesn't
+	// change the nodes kata is installed on
+	// +optional
+	SchedulingNodeSelector map[string]string `json:"schedulingNodeSelector,omitempty"`
+
+	// AdoptRuntimeClass takes over the existing RuntimeClasses with the names of the kata ones,
+	// e.g. left over by kata-deploy: they get the KataConfig as owner and their overhead and
+	// scheduling follow the spec. One with another handler is re-created. Without it they are
+	// left as is and reported in the RuntimeClassConflict condition
+	// +optional
+	AdoptRuntimeClass bool `json:"adoptRuntimeClass,omitempty"`
+
+	// RuntimeHandler is the name of the kata runtime handler of CRI-O, which the kata
+	// RuntimeClass refers to. kata by default, the handler of the confidential sandboxes gets
+	// the -cc suffix. Changing it updates the kata MachineConfig
+	// +optional
+	// +kubebuilder:validation:MaxLength=60
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	RuntimeHandler string `json:"runtimeHandler,omitempty"`
+
+	// Shim is the kata shim CRI-O runs for the kata runtime handlers, for payloads installing
+	// it elsewhere than /usr/bin or under another name. The fields not set are taken from the
+	// KataPayload resolved from the channel, then from the defaults. Changing it updates the
+	// kata MachineConfig
+	// +optional
+	Shim *KataShimConfig `json:"shim,omitempty"`
+
+	// Devmapper configures the devmapper snapshotter of containerd for the kata shims needing
+	// a block device rootfs, e.g. Firecracker. Only used on Kubernetes, with containerd
+	// +optional
+	Devmapper *KataDevmapperConfig `json:"devmapper,omitempty"`
+
 	// +optional
 	Config KataInstallConfig `json:"config"`
+
+	// PayloadImages maps a node architecture (amd64, arm64, ppc64le, s390x) to the kata payload image
+	// installed on the nodes of that architecture. When set, a separate installation daemonset is
+	// created for every architecture found in the kata pool
+	// +optional
+	PayloadImages map[string]string `json:"payloadImages,omitempty"`
+
+	// Channel installs the payloads of the KataPayload catalog released in the channel: the
+	// newest kata version supporting the OpenShift version of the cluster, for every architecture
+	// of the kata pool. The nodes are upgraded when a newer payload is released in the channel.
+	// PayloadImages take precedence over the channel
+	// +optional
+	Channel KataChannel `json:"channel,omitempty"`
+
+	// PayloadDelivery selects how the kata binaries reach the nodes, DaemonSet by default
+	// +optional
+	PayloadDelivery PayloadDelivery `json:"payloadDelivery,omitempty"`
+
+	// SELinux configures the kata SELinux policy installed on the nodes
+	// +optional
+	// +nullable
+	SELinux *KataSELinuxConfig `json:"selinux,omitempty"`
+
+	// Confidential enables kata sandboxes backed by a hardware trusted execution environment
+	// +optional
+	// +nullable
+	Confidential *KataConfidentialConfig `json:"confidential,omitempty"`
+
+	// SGX adds the kata-sgx RuntimeClass for the kata pods using the SGX enclaves advertised by
+	// the Intel SGX device plugin
+	// +optional
+	// +nullable
+	SGX *KataSGXConfig `json:"sgx,omitempty"`
+
+	// Hypervisor sizes the kata guests. Changing it updates the kata MachineConfig and the
+	// overhead of the RuntimeClass
+	// +optional
+	// +nullable
+	Hypervisor *KataHypervisorConfig `json:"hypervisor,omitempty"`
+
+	// QEMU selects the machine type and the CPU features of the guests. Changing it updates the
+	// kata MachineConfig
+	// +optional
+	// +nullable
+	QEMU *KataQEMUConfig `json:"qemu,omitempty"`
+
+	// BlockVolumes hotplugs the raw block volumes of the kata pods into the guests as block
+	// devices. Changing it updates the kata MachineConfig
+	// +optional
+	BlockVolumes *KataBlockVolumesConfig `json:"blockVolumes,omitempty"`
+
+	// SandboxCgroupOnly places the guest, the shim and the other kata threads of a pod in the
+	// pod cgroup, where they are accounted for against the pod overhead. Kata requires it on
+	// the cgroup v2 nodes, it defaults to true when all the kata nodes run cgroup v2 and to
+	// the kata default otherwise. Changing it updates the kata MachineConfig
+	// +optional
+	SandboxCgroupOnly *bool `json:"sandboxCgroupOnly,omitempty"`
+
+	// DevicePassthrough configures the kata guests for the VFIO devices assigned to the kata
+	// pods, e.g. GPUs or SR-IOV functions. The nodes need their IOMMU enabled. Changing it
+	// updates the kata MachineConfig
+	// +optional
+	// +nullable
+	DevicePassthrough *KataDevicePassthroughConfig `json:"devicePassthrough,omitempty"`
+
+	// Overhead is the pod overhead of the kata RuntimeClass, the resources the guest and the
+	// shim of a kata pod use on top of its containers. Computed from the hypervisor settings
+	// if unset
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// ForceUninstall evicts the pods using the kata runtime when the KataConfig is deleted,
+	// instead of waiting for them to be deleted manually
+	// +optional
+	// +nullable
+	ForceUninstall *KataForceUninstallConfig `json:"forceUninstall,omitempty"`
+
+	// NodeEligibility makes the operator label the worker nodes able to run kata
+	// +optional
+	// +nullable
+	NodeEligibility *KataNodeEligibilityConfig `json:"nodeEligibility,omitempty"`
+
+	// InstallTimeout is how long a node may take to install the kata binaries, e.g. 30m. Nodes
+	// exceeding it are reported as failed and the KataConfig as Degraded. No timeout if unset
+	// +optional
+	// +nullable
+	InstallTimeout *metav1.Duration `json:"installTimeout,omitempty"`
+
+	// DaemonUpdateStrategy is how the pods of the install daemonset are replaced when it
+	// changes, e.g. on a daemon image bump. RollingUpdate of one node at a time by default
+	// +optional
+	// +nullable
+	DaemonUpdateStrategy *KataDaemonUpdateStrategy `json:"daemonUpdateStrategy,omitempty"`
+
+	// Crio holds the CRI-O settings of the kata runtime that CRI-O reloads on the fly. Changing
+	// them doesn't update the MachineConfig and doesn't reboot the nodes
+	// +optional
+	// +nullable
+	Crio *KataCrioConfig `json:"crio,omitempty"`
+
+	// Enabled set to false deactivates the kata runtime without uninstalling it: the RuntimeClass
+	// and the kata CRI-O handler are removed, the binaries are kept so that kata can be enabled
+	// again without reinstalling it. Enabled by default
+	// +optional
+	// +nullable
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// ProvisionWorkers makes the operator create a MachineSet whose machines join the kata pool,
+	// dedicated kata capacity on the clouds supported by the machine API
+	// +optional
+	// +nullable
+	ProvisionWorkers *KataProvisionWorkersConfig `json:"provisionWorkers,omitempty"`
+
+	// Rollout controls when the disruptive changes are rolled out to the nodes
+	// +optional
+	// +nullable
+	Rollout *KataRolloutConfig `json:"rollout,omitempty"`
+
+	// Logging controls the logs the kata shim, agent and guests write to the journal of the
+	// nodes, which the OpenShift cluster logging collects
+	// +optional
+	// +nullable
+	Logging *KataLoggingConfig `json:"logging,omitempty"`
+
+	// Tracing has the kata shim and agent trace the sandbox lifecycle, e.g. the sandbox startup
+	// +optional
+	// +nullable
+	Tracing *KataTracingConfig `json:"tracing,omitempty"`
+
+	// SmokeTest runs a short-lived kata pod on every node once it is installed, to catch the
+	// nodes where the kata sandboxes don't start
+	// +optional
+	// +nullable
+	SmokeTest *KataSmokeTestConfig `json:"smokeTest,omitempty"`
 }
 
 // KataConfigStatus defines the observed state of KataConfig
@@ -44,6 +256,19 @@ type KataConfigStatus struct {
 	// TotalNodesCounts is the total number of worker nodes targeted by this CR
 	TotalNodesCount int `json:"totalNodesCount"`
 
+	// Platform is the infrastructure platform of the cluster, e.g. AWS or BareMetal
+	// +optional
+	Platform string `json:"platform,omitempty"`
+
+	// InstallMode is the install mode in use, selected from the platform when spec.installMode
+	// is Auto. It is kept once kata is installed
+	// +optional
+	InstallMode KataInstallMode `json:"installMode,omitempty"`
+
+	// ObservedGeneration is the KataConfig generation last rolled out to the nodes
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// InstallationStatus reflects the status of the ongoing kata installation
 	// +optional
 	InstallationStatus KataInstallationStatus `json:"installationStatus,omitempty"`
@@ -55,13 +280,261 @@ type KataConfigStatus struct {
 	// Upgradestatus reflects the status of the ongoing kata upgrade
 	// +optional
 	Upgradestatus KataUpgradeStatus `json:"upgradeStatus,omitempty"`
+
+	// History is a bounded audit log of the significant actions taken by the operator,
+	// the oldest entries are dropped first
+	// +optional
+	History []KataHistoryEvent `json:"history,omitempty"`
+
+	// DryRun is the preview of the installation computed while spec.dryRun is set
+	// +optional
+	DryRun *KataDryRunReport `json:"dryRun,omitempty"`
+
+	// TEECapabilities are the trusted execution environment capabilities the kata nodes
+	// reported, for the capacity planning of the confidential sandboxes
+	// +optional
+	TEECapabilities []KataNodeTEECapabilities `json:"teeCapabilities,omitempty"`
+
+	// PeerPods is the state of the peer pods, in the PeerPods install mode
+	// +optional
+	PeerPods *KataPeerPodsStatus `json:"peerPods,omitempty"`
+
+	// Conditions reflect the latest observations of the KataConfig state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// KataRenderConfig is the ConfigMap the managed objects are rendered into, one key per object
+type KataRenderConfig struct {
+	// Namespace of the ConfigMap, the namespace of the operator by default
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the ConfigMap, kata-manifests by default
+	// +optional
+	Name string `json:"name,omitempty"`
 }
 
+// KataKubeVirtConfig sets how kata shares the nodes with OpenShift Virtualization
+type KataKubeVirtConfig struct {
+	// VMNodeLabels are the labels of the nodes dedicated to the OpenShift Virtualization VMs.
+	// The nodes having any of them are left out of the kata pool
+	// +optional
+	VMNodeLabels map[string]string `json:"vmNodeLabels,omitempty"`
+}
+
+// KataInstallMode is how kata is provided to the cluster
+type KataInstallMode string
+
+const (
+	// InstallModeAuto selects the install mode from the platform of the cluster
+	InstallModeAuto KataInstallMode = "Auto"
+
+	// InstallModeMachineConfig installs kata on the nodes through the machine config operator,
+	// the nodes need hardware or nested virtualization
+	InstallModeMachineConfig KataInstallMode = "MachineConfig"
+
+	// InstallModePeerPods runs the kata pods in VMs created by the cloud provider
+	InstallModePeerPods KataInstallMode = "PeerPods"
+)
+
+// DaemonUpdateStrategyType is how the pods of the install daemonset are replaced
+type DaemonUpdateStrategyType string
+
+const (
+	// DaemonRollingUpdate replaces at most MaxUnavailable pods at a time
+	DaemonRollingUpdate DaemonUpdateStrategyType = "RollingUpdate"
+
+	// DaemonOnDelete only replaces the pods once deleted, the install pods running on the nodes
+	// are left alone until the operator restarts them, e.g. to reinstall a replaced machine
+	DaemonOnDelete DaemonUpdateStrategyType = "OnDelete"
+)
+
+// KataDaemonUpdateStrategy is the update strategy of the install daemonset
+type KataDaemonUpdateStrategy struct {
+	// Type is RollingUpdate or OnDelete, RollingUpdate by default
+	// +optional
+	// +kubebuilder:validation:Enum=RollingUpdate;OnDelete
+	Type DaemonUpdateStrategyType `json:"type,omitempty"`
+
+	// MaxUnavailable is the number or percentage of install pods replaced at the same time
+	// with RollingUpdate, 1 by default
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// KataPeerPodsStatus is the state of the peer pods
+type KataPeerPodsStatus struct {
+	// PodVMImage is the image the peer pods VMs boot
+	// +optional
+	PodVMImage *KataPodVMImageStatus `json:"podVMImage,omitempty"`
+
+	// StaleImages are the images the operator built from former payloads, deleted from the
+	// cloud provider once replaced
+	// +optional
+	StaleImages []string `json:"staleImages,omitempty"`
+
+	// LastVMCollection is when the VMs left behind by the peer pods were last collected
+	// +optional
+	LastVMCollection *metav1.Time `json:"lastVMCollection,omitempty"`
+
+	// OrphanedVMs is how many VMs of the cloud provider had no peer pod left at the last
+	// collection
+	// +optional
+	OrphanedVMs int32 `json:"orphanedVMs,omitempty"`
+}
+
+// KataPodVMImageStatus is an image of the cloud provider the peer pods VMs boot
+type KataPodVMImageStatus struct {
+	// ID is the image ID of the cloud provider, e.g. an AMI ID
+	ID string `json:"id"`
+
+	// Provider is the cloud provider of the image, e.g. aws
+	Provider string `json:"provider"`
+
+	// Payload is the payload the operator built the image from, empty for the image of
+	// spec.peerPods.podVMImage.imageID
+	// +optional
+	Payload string `json:"payload,omitempty"`
+
+	// Created is when the operator built the image
+	// +optional
+	Created *metav1.Time `json:"created,omitempty"`
+}
+
+// KataNodeTEECapabilities are the trusted execution environment capabilities of a node
+type KataNodeTEECapabilities struct {
+	// NodeName is the name of the node
+	NodeName string `json:"nodeName"`
+
+	// SNPEnabled is whether kvm_amd runs the SEV-SNP guests on the node
+	SNPEnabled bool `json:"snpEnabled"`
+
+	// FirmwareVersion is the version of the SEV firmware API of the node
+	// +optional
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+
+	// MaxGuests is how many confidential guests the node runs at once, the number of the
+	// encryption ASIDs of its processor
+	// +optional
+	MaxGuests int32 `json:"maxGuests,omitempty"`
+}
+
+// KataDryRunReport is what the installation would do on the cluster
+type KataDryRunReport struct {
+	// Time the preview was computed
+	Time metav1.Time `json:"time"`
+
+	// Generation of the KataConfig the preview was computed from
+	Generation int64 `json:"generation"`
+
+	// NodesCount is the number of nodes kata would be installed on
+	NodesCount int `json:"nodesCount"`
+
+	// Nodes kata would be installed on
+	// +optional
+	Nodes []string `json:"nodes,omitempty"`
+
+	// IneligibleNodes are the selected nodes failing the eligibility checks, with the reason
+	// +optional
+	IneligibleNodes []FailedNodeStatus `json:"ineligibleNodes,omitempty"`
+
+	// MachineConfigPool the kata MachineConfig would be rendered into
+	MachineConfigPool string `json:"machineConfigPool"`
+
+	// Files written on the nodes by the kata MachineConfig, by path
+	// +optional
+	Files map[string]string `json:"files,omitempty"`
+
+	// Disruption describes the reboots of the rollout
+	Disruption string `json:"disruption"`
+}
+
+const (
+	// KataConfigFIPSIncompatible is set when a node runs in FIPS mode and the kata payload
+	// selected for it is not FIPS compliant
+	KataConfigFIPSIncompatible = "FIPSIncompatible"
+
+	// KataConfigDeletionBlocked is set while the deletion of the KataConfig waits for the
+	// pods using the kata runtime to be deleted
+	KataConfigDeletionBlocked = "DeletionBlocked"
+
+	// KataConfigDegraded is set when some nodes failed to install kata in time, or when
+	// installed nodes fail their health probes
+	KataConfigDegraded = "Degraded"
+
+	// KataConfigConfigConflict is set when other MachineConfigs of the kata pool define CRI-O
+	// settings conflicting with the kata drop-in, which is held back meanwhile
+	KataConfigConfigConflict = "ConfigConflict"
+
+	// KataConfigPotentialConflict is set when MachineConfigs of other sources touch the CRI-O
+	// runtime settings or the kernel arguments of the kata pool, merged with the kata ones
+	KataConfigPotentialConflict = "PotentialConflict"
+
+	// KataConfigRuntimeClassConflict is set when a RuntimeClass with the name of a kata one
+	// exists already and is not adopted
+	KataConfigRuntimeClassConflict = "RuntimeClassConflict"
+
+	// KataConfigCgroupMismatch is set when the kata nodes run different cgroup versions, or
+	// when sandboxCgroupOnly is disabled on cgroup v2 nodes
+	KataConfigCgroupMismatch = "CgroupMismatch"
+
+	// KataConfigSGXUnavailable is set when SGX is enabled but no kata node advertises SGX EPC
+	// memory
+	KataConfigSGXUnavailable = "SGXUnavailable"
+
+	// KataConfigDisabled is set while the kata runtime is deactivated by spec.enabled
+	KataConfigDisabled = "Disabled"
+
+	// KataConfigPayloadUnresolved is set when the channel has no payload for the OpenShift
+	// version of the cluster and an architecture of the kata pool
+	KataConfigPayloadUnresolved = "PayloadUnresolved"
+
+	// KataConfigIncompatibleClusterVersion is set, and the installation held, when a payload of
+	// the KataConfig doesn't support the OpenShift version of the cluster
+	KataConfigIncompatibleClusterVersion = "IncompatibleClusterVersion"
+
+	// KataConfigNestedVirtualization is set when kata is installed on nodes that are VMs
+	// themselves, it then depends on the nested virtualization of the platform
+	KataConfigNestedVirtualization = "NestedVirtualization"
+
+	// KataConfigVirtualizationDisabled is set when the VMs of kata nodes, e.g. on vSphere, don't
+	// expose the hardware assisted virtualization
+	KataConfigVirtualizationDisabled = "VirtualizationDisabled"
+
+	// KataConfigAttestationUnavailable is set when kata nodes can't reach the key broker
+	// service of the confidential guests, or the quote generation service of the TDX ones
+	KataConfigAttestationUnavailable = "AttestationUnavailable"
+
+	// KataConfigKubeVirtCoexistence is set when OpenShift Virtualization runs VMs on kata nodes,
+	// both then share /dev/kvm and the memory of the nodes
+	KataConfigKubeVirtCoexistence = "KubeVirtCoexistence"
+
+	// KataConfigPodVMImageFailed is set when the image of the peer pods VMs can't be built
+	// from the payload
+	KataConfigPodVMImageFailed = "PodVMImageFailed"
+
+	// KataConfigCredentialsInvalid is set when the cloud credentials of the peer pods are
+	// missing or rejected by the cloud provider
+	KataConfigCredentialsInvalid = "CredentialsInvalid"
+
+	// KataConfigPendingWindow is set while a MachineConfig change, and the reboots it causes,
+	// waits for the next maintenance window
+	KataConfigPendingWindow = "PendingWindow"
+
+	// KataConfigRolloutStalled is set when a machine config pool the KataConfig waits for has
+	// not been ready for longer than the stall threshold of the operator
+	KataConfigRolloutStalled = "RolloutStalled"
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // KataConfig is the Schema for the kataconfigs API
 // +kubebuilder:object:root=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=kataconfigs,scope=Cluster
 type KataConfig struct {
@@ -93,6 +566,562 @@ type KataInstallConfig struct {
 	SourceImage string `json:"sourceImage"`
 }
 
+// SELinuxMode is the SELinux mode applied to the kata shim domain
+// +kubebuilder:validation:Enum=enforcing;permissive
+type SELinuxMode string
+
+const (
+	// SELinuxEnforcing keeps the kata shim domain confined by the kata policy
+	SELinuxEnforcing SELinuxMode = "enforcing"
+
+	// SELinuxPermissive only logs the denials of the kata shim domain
+	SELinuxPermissive SELinuxMode = "permissive"
+)
+
+// PayloadDelivery is the way the kata binaries are delivered to the nodes
+// +kubebuilder:validation:Enum=DaemonSet;Extension
+type PayloadDelivery string
+
+const (
+	// PayloadDeliveryDaemonSet installs the payload image from a daemonset writing to the host
+	PayloadDeliveryDaemonSet PayloadDelivery = "DaemonSet"
+	// PayloadDeliveryExtension layers the sandboxed-containers extension of the OS image onto the
+	// nodes through the kata MachineConfig, no pod touches the host
+	PayloadDeliveryExtension PayloadDelivery = "Extension"
+)
+
+// KataSELinuxConfig holds the SELinux settings for the kata shim
+type KataSELinuxConfig struct {
+	// ShimMode is the SELinux mode of the kata shim domain, enforcing by default
+	// +optional
+	ShimMode SELinuxMode `json:"shimMode,omitempty"`
+}
+
+// TEE is a hardware trusted execution environment technology
+// +kubebuilder:validation:Enum=pef;snp;tdx;se
+type TEE string
+
+const (
+	// TEEPEF is the Protected Execution Facility of IBM Power (ppc64le) systems
+	TEEPEF TEE = "pef"
+	// TEESNP is the Secure Nested Paging of the AMD SEV (x86_64) systems
+	TEESNP TEE = "snp"
+	// TEETDX is the Trust Domain Extensions of the Intel (x86_64) systems
+	TEETDX TEE = "tdx"
+	// TEESE is the Secure Execution of the IBM Z and LinuxONE (s390x) systems
+	TEESE TEE = "se"
+)
+
+// KataPeerPodsConfig configures the peer pods, the kata pods running in VMs of the cloud
+// provider created by the cloud-api-adaptor
+type KataPeerPodsConfig struct {
+	// PodVMImage is the image the peer pods VMs boot
+	// +optional
+	// +nullable
+	PodVMImage *KataPodVMImageConfig `json:"podVMImage,omitempty"`
+
+	// InstanceTypes are the instance types of the VMs, e.g. t3.medium on AWS or the bx2-2x8
+	// (x86_64) and bz2-2x8 (s390x) instance profiles on IBM Cloud. The first one is the default,
+	// the pods pick another one of the list with the
+	// io.katacontainers.config.hypervisor.machine_type annotation
+	// +optional
+	InstanceTypes []string `json:"instanceTypes,omitempty"`
+
+	// VPC is the VPC of the VMs, the network on GCP
+	// +optional
+	VPC string `json:"vpc,omitempty"`
+
+	// Subnet is the subnet of the VMs
+	// +optional
+	Subnet string `json:"subnet,omitempty"`
+
+	// SecurityGroups are the security groups of the VMs, the network security group on Azure
+	// +optional
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+
+	// Limit is how many peer pods VMs each node runs at once, advertised as the
+	// kata.peerpods.io/vm resource of the nodes. 10 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Limit *int32 `json:"limit,omitempty"`
+
+	// Zone is the zone of the VMs on IBM Cloud, e.g. us-south-1
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// Tags are set on the VMs in the cloud provider
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// CredentialsSecret is a Secret of the operator namespace with the cloud credentials of the
+	// peer pods, as the environment variables of the cloud-api-adaptor, e.g. AWS_ACCESS_KEY_ID
+	// and AWS_SECRET_ACCESS_KEY, or the SSH private key of the libvirt host as id_rsa. The cloud
+	// credentials are requested from the cloud credential operator when unset
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// Libvirt creates the VMs on a libvirt hypervisor host rather than in the cloud provider
+	// of the cluster, e.g. for the clusters on premises whose nodes can't nest virtualization.
+	// The Auto install mode selects PeerPods when set
+	// +optional
+	// +nullable
+	Libvirt *KataLibvirtConfig `json:"libvirt,omitempty"`
+}
+
+// KataLibvirtConfig is the libvirt hypervisor host of the peer pods VMs
+type KataLibvirtConfig struct {
+	// URI is the libvirt connection URI of the host, e.g. qemu+ssh://root@10.0.0.2/system. The
+	// SSH private key is the id_rsa key of the credentialsSecret
+	// +kubebuilder:validation:Pattern=`^qemu(\+[a-z]+)?://`
+	URI string `json:"uri"`
+
+	// StoragePool is the storage pool of the volumes of the VMs, default by default
+	// +optional
+	StoragePool string `json:"storagePool,omitempty"`
+
+	// Network is the libvirt network of the VMs, default by default
+	// +optional
+	Network string `json:"network,omitempty"`
+}
+
+// KataPodVMImageConfig is the image of the peer pods VMs: an image of the cloud provider, or a
+// payload the operator builds it from
+type KataPodVMImageConfig struct {
+	// ImageID is an image of the cloud provider the VMs boot as is, e.g. an AMI ID
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// Payload is the container image with the pod VM disk the operator uploads to the cloud
+	// provider: as an AMI on AWS, an image of the compute gallery on Azure, or a custom image on
+	// IBM Cloud. The images built from the former payloads are deleted. Ignored with imageID
+	// +optional
+	Payload string `json:"payload,omitempty"`
+}
+
+// KataConfidentialConfig holds the settings for confidential kata sandboxes. The confidential
+// sandboxes get a kata-cc RuntimeClass and CRI-O handler of their own, next to the kata ones
+type KataConfidentialConfig struct {
+	// Enabled turns on confidential guests on the selected nodes
+	Enabled bool `json:"enabled"`
+
+	// TEE is the trusted execution environment used for confidential guests
+	TEE TEE `json:"tee"`
+
+	// Overhead is the pod overhead of the kata-cc RuntimeClass. Computed from the hypervisor
+	// settings if unset
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// SchedulingNodeSelector is the node selector of the kata-cc RuntimeClass. Defaults to
+	// kata.openshift.io/kata-runtime=true
+	// +optional
+	SchedulingNodeSelector map[string]string `json:"schedulingNodeSelector,omitempty"`
+
+	// GuestPull pulls the images of the confidential sandboxes inside their guests, so that
+	// their layers never land on the untrusted host. The nodes get the guest components
+	// pulling the images, and CRI-O leaves the pulls of the kata-cc handler to kata
+	// +optional
+	GuestPull bool `json:"guestPull,omitempty"`
+
+	// KBSURL is the key broker service the guests fetch the kbs:// resources from once
+	// attested, e.g. http://kbs.trustee.svc:8080
+	// +optional
+	// +kubebuilder:validation:Pattern=`^https?://`
+	KBSURL string `json:"kbsURL,omitempty"`
+
+	// ImageSecurityPolicy makes the guests verify the signatures of the images they pull, only
+	// the images the policy accepts run in the confidential sandboxes. It needs guestPull
+	// +optional
+	// +nullable
+	ImageSecurityPolicy *KataImageSecurityPolicy `json:"imageSecurityPolicy,omitempty"`
+
+	// EncryptedImages lets the guests decrypt the encrypted images they pull, with the keys
+	// the key broker service releases to them once attested. It needs guestPull and kbsURL
+	// +optional
+	// +nullable
+	EncryptedImages *KataEncryptedImagesConfig `json:"encryptedImages,omitempty"`
+
+	// Initdata is the default initdata document of the confidential guests, the TOML document
+	// of the confidential containers initdata specification configuring their guest
+	// components. It is given to the kata-cc pods that don't bring their own
+	// +optional
+	Initdata string `json:"initdata,omitempty"`
+
+	// AllowPodInitdata lets the kata-cc pods bring their own initdata document, gzipped and
+	// in base64, in the io.katacontainers.config.runtime.cc_init_data annotation
+	// +optional
+	AllowPodInitdata bool `json:"allowPodInitdata,omitempty"`
+
+	// QuoteGeneration configures the quote generation service of the TDX nodes, which turns
+	// the reports of the guests into the quotes they get attested with. Only used with tdx
+	// +optional
+	// +nullable
+	QuoteGeneration *KataQuoteGenerationConfig `json:"quoteGeneration,omitempty"`
+}
+
+// KataQuoteGenerationConfig is the quote generation service (QGS) of the TDX nodes and the
+// Provisioning Certificate Caching Service (PCCS) it gets the certificates of the platforms from
+type KataQuoteGenerationConfig struct {
+	// Image is the QGS image the operator runs on the kata nodes. The QGS is expected to run on
+	// the nodes already when unset, e.g. as the qgsd service of the host
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// PCCSURL is the PCCS the platforms are registered with, e.g. https://pccs.example.com:8081
+	// +kubebuilder:validation:Pattern=`^https://`
+	PCCSURL string `json:"pccsURL"`
+
+	// Port is the vsock port of the host the guests reach the QGS on, 4050 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+}
+
+// KataEncryptedImagesConfig is how the confidential guests get the keys of the encrypted images
+type KataEncryptedImagesConfig struct {
+	// KeyProvider is the ocicrypt key provider of the guests unwrapping the layer keys,
+	// attestation-agent by default. It requests the key IDs set in the layers of the
+	// images, e.g. kbs:///default/image-keys/production, from the key broker service
+	// +optional
+	// +kubebuilder:validation:Enum=attestation-agent
+	KeyProvider string `json:"keyProvider,omitempty"`
+}
+
+// KataImageSecurityPolicy is the containers-policy.json the confidential guests enforce on the
+// images they pull. The simple signing and cosign public keys the policy requires are referred
+// to in the policy, as kbs:// resources as well
+type KataImageSecurityPolicy struct {
+	// PolicyURI is the policy, a resource of the key broker service such as
+	// kbs:///default/security-policy/production
+	// +kubebuilder:validation:Pattern=`^kbs:///[^/]+/[^/]+/[^/]+$`
+	PolicyURI string `json:"policyURI"`
+
+	// SigstoreConfigURI is the sigstore configuration telling where the simple signing
+	// signatures of the registries are, a resource of the key broker service
+	// +optional
+	// +kubebuilder:validation:Pattern=`^kbs:///[^/]+/[^/]+/[^/]+$`
+	SigstoreConfigURI string `json:"sigstoreConfigURI,omitempty"`
+}
+
+// KataSGXConfig configures the kata sandboxes running SGX enclaves. The pods request the
+// sgx.intel.com/epc resource of the Intel SGX device plugin, whose webhook annotates them with
+// the EPC size their guest gets
+type KataSGXConfig struct {
+	// Enabled creates the kata-sgx RuntimeClass and lets CRI-O pass the EPC size down to kata
+	Enabled bool `json:"enabled"`
+
+	// Overhead is the pod overhead of the kata-sgx RuntimeClass. Computed from the hypervisor
+	// settings if unset
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// SchedulingNodeSelector is the node selector of the kata-sgx RuntimeClass. Defaults to
+	// kata.openshift.io/kata-runtime=true and feature.node.kubernetes.io/cpu-sgx.enabled=true,
+	// the label node feature discovery sets on the SGX nodes
+	// +optional
+	SchedulingNodeSelector map[string]string `json:"schedulingNodeSelector,omitempty"`
+}
+
+// KataHypervisorConfig sizes the kata guests, before the resources of the containers are hot
+// plugged in
+type KataHypervisorConfig struct {
+	// DefaultMemory of the guests in MiB, 2048 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=256
+	DefaultMemory int32 `json:"defaultMemory,omitempty"`
+
+	// DefaultVCPUs of the guests, 1 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	DefaultVCPUs int32 `json:"defaultVCPUs,omitempty"`
+}
+
+// KataQEMUConfig is the virtual hardware QEMU emulates for the kata guests
+type KataQEMUConfig struct {
+	// MachineType of the guests, which must be the one of the architecture of the kata nodes:
+	// q35 on x86_64, virt on aarch64, pseries on ppc64le and s390-ccw-virtio on s390x. The
+	// default of the architecture if not set
+	// +optional
+	// +kubebuilder:validation:Enum=q35;virt;pseries;s390-ccw-virtio
+	MachineType string `json:"machineType,omitempty"`
+
+	// CPUFeatures are enabled or disabled on top of the CPU model of the host the guests get,
+	// e.g. avx512f=on or vmx=on for the nested virtualization. Not available on ppc64le
+	// +optional
+	CPUFeatures []KataCPUFeature `json:"cpuFeatures,omitempty"`
+}
+
+// KataCPUFeature is a QEMU CPU feature and whether the guests get it, as <feature>=on|off
+// +kubebuilder:validation:Pattern=`^[a-z0-9_.-]+=(on|off)$`
+type KataCPUFeature string
+
+// VFIOMode is how the VFIO devices assigned to a kata pod are presented in its guest
+type VFIOMode string
+
+const (
+	// VFIOModeGuestKernel binds the devices to their driver in the guest kernel
+	VFIOModeGuestKernel VFIOMode = "guest-kernel"
+	// VFIOModeVFIO exposes the devices to the containers as VFIO devices, for the user space
+	// drivers such as DPDK
+	VFIOModeVFIO VFIOMode = "vfio"
+)
+
+// KataDevicePassthroughConfig is how the VFIO devices are assigned to the kata guests
+type KataDevicePassthroughConfig struct {
+	// EnableIOMMU exposes a virtual IOMMU to the guests, needed by the guest drivers doing
+	// DMA remapping
+	// +optional
+	EnableIOMMU bool `json:"enableIOMMU,omitempty"`
+
+	// VFIOMode is how the devices are presented in the guests, guest-kernel by default
+	// +optional
+	// +kubebuilder:validation:Enum=guest-kernel;vfio
+	VFIOMode VFIOMode `json:"vfioMode,omitempty"`
+
+	// ColdPlug attaches the devices to the guests when they are created instead of hotplugging
+	// them once they run, which the devices with large BARs need
+	// +optional
+	ColdPlug bool `json:"coldPlug,omitempty"`
+
+	// KernelArguments makes the operator enable the IOMMU of the x86_64 nodes with the kata
+	// MachineConfig. Otherwise the nodes must have it enabled already, the others fail
+	// their installation
+	// +optional
+	KernelArguments bool `json:"kernelArguments,omitempty"`
+}
+
+// KataBlockVolumesConfig is how the block volumes are assigned to the kata guests
+type KataBlockVolumesConfig struct {
+	// Enabled hotplugs the block devices of the volumes into the guests, instead of sharing
+	// them through virtio-fs
+	Enabled bool `json:"enabled"`
+
+	// Driver of the block devices in the guests, virtio-blk by default (virtio-blk-ccw on
+	// s390x). virtio-scsi scales to more volumes per pod
+	// +optional
+	// +kubebuilder:validation:Enum=virtio-blk;virtio-scsi
+	Driver string `json:"driver,omitempty"`
+
+	// CacheDirect opens the block devices with O_DIRECT on the host, bypassing the page cache of
+	// the host as the databases expect
+	// +optional
+	CacheDirect bool `json:"cacheDirect,omitempty"`
+
+	// AllowPodAnnotations lets the pods choose the driver and the caching of their block
+	// devices with the io.katacontainers.config.hypervisor.block_device_* annotations
+	// +optional
+	AllowPodAnnotations bool `json:"allowPodAnnotations,omitempty"`
+}
+
+// KataHistoryAction is a significant action taken by the operator on the cluster
+type KataHistoryAction string
+
+const (
+	// HistoryMachineConfigPoolCreated is recorded when the kata MachineConfigPool is created
+	HistoryMachineConfigPoolCreated KataHistoryAction = "MachineConfigPoolCreated"
+
+	// HistoryMachineConfigPoolUpdated is recorded when the node selector of the kata MachineConfigPool changes
+	HistoryMachineConfigPoolUpdated KataHistoryAction = "MachineConfigPoolUpdated"
+
+	// HistoryMachineConfigPoolDeleted is recorded when the kata MachineConfigPool is deleted as the
+	// kataConfigPoolSelector selects a whole pool
+	HistoryMachineConfigPoolDeleted KataHistoryAction = "MachineConfigPoolDeleted"
+
+	// HistoryMachineConfigCreated is recorded when the kata MachineConfig is created
+	HistoryMachineConfigCreated KataHistoryAction = "MachineConfigCreated"
+
+	// HistoryMachineConfigUpdated is recorded when the kata MachineConfig is re-rendered
+	HistoryMachineConfigUpdated KataHistoryAction = "MachineConfigUpdated"
+
+	// HistoryRuntimeClassUpdated is recorded when the kata RuntimeClass is re-rendered
+	HistoryRuntimeClassUpdated KataHistoryAction = "RuntimeClassUpdated"
+
+	// HistoryCrioReloaded is recorded when the CRI-O settings are rolled out by reloading CRI-O
+	HistoryCrioReloaded KataHistoryAction = "CrioReloaded"
+
+	// HistoryNodeConfigsApplied is recorded when the KataNodeConfigs are rolled out to the nodes
+	HistoryNodeConfigsApplied KataHistoryAction = "NodeConfigsApplied"
+
+	// HistoryPayloadApplied is recorded when a kata payload is rolled out to the nodes
+	HistoryPayloadApplied KataHistoryAction = "PayloadApplied"
+
+	// HistoryPayloadResolved is recorded when the channel resolves to a new payload
+	HistoryPayloadResolved KataHistoryAction = "PayloadResolved"
+
+	// HistoryNodesAdded is recorded when the installation is extended to nodes that joined the kata pool
+	HistoryNodesAdded KataHistoryAction = "NodesAdded"
+
+	// HistoryNodesRemoved is recorded when nodes that left the kata pool are dropped from the status
+	HistoryNodesRemoved KataHistoryAction = "NodesRemoved"
+
+	// HistoryNodeRepaired is recorded when the kata daemon repaired the drift of a node
+	HistoryNodeRepaired KataHistoryAction = "NodeRepaired"
+
+	// HistoryNodeReinstalled is recorded when kata is installed again on nodes whose machine was replaced
+	HistoryNodeReinstalled KataHistoryAction = "NodeReinstalled"
+
+	// HistoryMachineSetApplied is recorded when the MachineSet of the kata workers is created or changed
+	HistoryMachineSetApplied KataHistoryAction = "MachineSetApplied"
+
+	// HistoryMachineSetDeleted is recorded when the MachineSet of the kata workers is deleted
+	HistoryMachineSetDeleted KataHistoryAction = "MachineSetDeleted"
+
+	// HistoryUninstallStarted is recorded when the uninstallation of kata begins
+	HistoryUninstallStarted KataHistoryAction = "UninstallStarted"
+)
+
+// KataHistoryEvent is an entry of the KataConfig audit log
+type KataHistoryEvent struct {
+	// Time the action was taken
+	Time metav1.Time `json:"time"`
+
+	// Action taken by the operator
+	Action KataHistoryAction `json:"action"`
+
+	// Generation of the KataConfig spec that caused the action
+	Generation int64 `json:"generation"`
+
+	// Message gives the details of the action
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// KataNodeEligibilityConfig controls the labeling of the nodes able to run kata
+type KataNodeEligibilityConfig struct {
+	// AutoLabel labels the worker nodes passing the eligibility checks with
+	// kata.openshift.io/eligible=true
+	AutoLabel bool `json:"autoLabel"`
+
+	// ExtendPool also adds to the eligible nodes the labels of the KataConfigPoolSelector, or
+	// of the first of the KataConfigPoolSelectors they can match, so that they join the kata
+	// pool. The labels come from the match labels and the In and Exists expressions
+	// +optional
+	ExtendPool bool `json:"extendPool,omitempty"`
+}
+
+// KataCrioConfig are the CRI-O settings applied by reloading CRI-O on the kata nodes
+type KataCrioConfig struct {
+	// LogLevel of CRI-O on the kata nodes
+	// +optional
+	// +kubebuilder:validation:Enum=fatal;panic;error;warn;info;debug;trace
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// AllowedAnnotations are the pod annotations passed down to the kata runtime,
+	// e.g. io.katacontainers.config.hypervisor.default_memory
+	// +optional
+	AllowedAnnotations []string `json:"allowedAnnotations,omitempty"`
+}
+
+// KataProvisionWorkersConfig describes the MachineSet of the kata workers. The MachineSet is
+// copied from a worker MachineSet of the cluster, its nodes get the labels of the
+// KataConfigPoolSelector
+type KataProvisionWorkersConfig struct {
+	// Replicas is the number of kata workers
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// InstanceType of the kata workers, e.g. m5.metal on AWS. Defaults to the instance type
+	// of the template MachineSet, or to a bare-metal instance type when BareMetal is set
+	// +optional
+	InstanceType string `json:"instanceType,omitempty"`
+
+	// BareMetal provisions bare-metal kata workers, so that the kata guests don't run
+	// under nested virtualization
+	// +optional
+	BareMetal bool `json:"bareMetal,omitempty"`
+
+	// TemplateMachineSet is the worker MachineSet, in the openshift-machine-api namespace,
+	// the kata MachineSet is copied from. Defaults to the first worker MachineSet
+	// +optional
+	TemplateMachineSet string `json:"templateMachineSet,omitempty"`
+}
+
+// KataLogLevel is the level of the logs of the kata shim
+// +kubebuilder:validation:Enum=info;debug
+type KataLogLevel string
+
+const (
+	// KataLogLevelInfo is the default level of the kata shim
+	KataLogLevelInfo KataLogLevel = "info"
+
+	// KataLogLevelDebug turns on the debug logs of the kata shim
+	KataLogLevelDebug KataLogLevel = "debug"
+)
+
+// KataLoggingConfig controls the logs of the kata components on the nodes
+type KataLoggingConfig struct {
+	// GuestLogs forwards the logs of the kata agent and of the guest kernel of every sandbox to
+	// the journal of the node, along with the ones of the shim
+	// +optional
+	GuestLogs bool `json:"guestLogs,omitempty"`
+
+	// Level of the logs of the kata shim, info by default
+	// +optional
+	Level KataLogLevel `json:"level,omitempty"`
+}
+
+// KataTracingConfig points the tracing of the kata components at a trace collector
+type KataTracingConfig struct {
+	// Enabled turns on the tracing of the kata shim and of the agent of the guests
+	Enabled bool `json:"enabled"`
+
+	// Endpoint receiving the spans with the Jaeger thrift HTTP protocol, e.g. the jaeger receiver
+	// of an OpenTelemetry collector: http://collector.example.com:14268/api/traces. The shim runs
+	// on the nodes, the endpoint must be reachable from the host network
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint"`
+}
+
+// KataSmokeTestConfig controls the smoke test pods run on the installed nodes
+type KataSmokeTestConfig struct {
+	// Enabled runs the smoke test pods
+	Enabled bool `json:"enabled"`
+
+	// Image of the smoke test pods, registry.access.redhat.com/ubi8/ubi-minimal by default
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// KataRolloutConfig controls when the disruptive changes are rolled out to the nodes
+type KataRolloutConfig struct {
+	// Schedule restricts the creation and the updates of the kata MachineConfig, which reboot
+	// the nodes, to a recurring maintenance window. No restriction if unset
+	// +optional
+	// +nullable
+	Schedule *KataMaintenanceWindow `json:"schedule,omitempty"`
+}
+
+// KataMaintenanceWindow is a recurring window in which the disruptive changes may start
+type KataMaintenanceWindow struct {
+	// Start is the cron expression of the opening of the window, e.g. "0 2 * * 6" for
+	// Saturdays at 2:00
+	Start string `json:"start"`
+
+	// Duration of the window, e.g. 4h
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone of Start, e.g. Europe/Paris. UTC by default
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// KataForceUninstallConfig controls how the kata pods are removed when the KataConfig is deleted
+type KataForceUninstallConfig struct {
+	// Enabled evicts the kata pods instead of blocking the uninstallation
+	Enabled bool `json:"enabled"`
+
+	// GracePeriodSeconds overrides the termination grace period of the evicted pods
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// IgnorePodDisruptionBudgets deletes the kata pods instead of evicting them, so that
+	// the PodDisruptionBudgets protecting them don't delay the uninstallation
+	// +optional
+	IgnorePodDisruptionBudgets bool `json:"ignorePodDisruptionBudgets,omitempty"`
+}
+
 // KataInstallationStatus reflects the status of the ongoing kata installation
 type KataInstallationStatus struct {
 	// InProgress reflects the status of nodes that are in the process of kata installation
@@ -103,6 +1132,54 @@ type KataInstallationStatus struct {
 
 	// Failed reflects the status of nodes that have failed kata installation
 	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+
+	// SmokeTest reflects the outcome of the smoke test pods run on the installed nodes
+	// +optional
+	SmokeTest KataSmokeTestStatus `json:"smokeTest,omitempty"`
+
+	// Degraded reflects the installed nodes failing the periodic health probes of the kata
+	// shim, its configuration and /dev/kvm. They are still listed as completed
+	// +optional
+	Degraded KataFailedNodeStatus `json:"degraded,omitempty"`
+
+	// Artifacts are the checksums of the kata binaries installed on every node, verified
+	// before they are replaced or removed
+	// +optional
+	Artifacts []KataNodeArtifacts `json:"artifacts,omitempty"`
+
+	// InstallDurations are the wall times the installation of the installed nodes took, from
+	// the start of the installation daemon to the node being installed
+	// +optional
+	InstallDurations []KataNodeInstallDuration `json:"installDurations,omitempty"`
+}
+
+// KataNodeInstallDuration is how long the installation of a node took
+type KataNodeInstallDuration struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Duration of the installation, reboot included
+	Duration metav1.Duration `json:"duration"`
+}
+
+// KataNodeArtifacts are the checksums of the kata binaries installed on a node
+type KataNodeArtifacts struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Checksums are the SHA256 checksums of the binaries, by path
+	Checksums map[string]string `json:"checksums"`
+}
+
+// KataSmokeTestStatus reflects the outcome of the smoke test pods
+type KataSmokeTestStatus struct {
+	// VerifiedNodesList are the nodes where the smoke test pod ran
+	// +optional
+	VerifiedNodesList []string `json:"verifiedNodesList,omitempty"`
+
+	// FailedNodesList are the nodes where the smoke test pod failed, and why
+	// +optional
+	FailedNodesList []FailedNodeStatus `json:"failedNodesList,omitempty"`
 }
 
 // KataInstallationInProgressStatus reflects the status of nodes that are in the process of kata installation
@@ -143,6 +1220,30 @@ type KataUnInstallationStatus struct {
 
 	// Failed reflects the status of nodes that have failed kata uninstallation
 	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+
+	// DepartedNodesList are the nodes that no longer match the kataConfigPoolSelector, the kata
+	// binaries are being removed from them
+	// +optional
+	DepartedNodesList []string `json:"departedNodesList,omitempty"`
+
+	// Report is the outcome of the verification run once kata is removed from the nodes,
+	// published right before the KataConfig is deleted
+	// +optional
+	Report *KataUninstallReport `json:"report,omitempty"`
+}
+
+// KataUninstallReport lists what the uninstallation left behind
+type KataUninstallReport struct {
+	// RuntimeClassRemoved tells whether the kata RuntimeClass is gone
+	RuntimeClassRemoved bool `json:"runtimeClassRemoved"`
+
+	// VerifiedNodesList are the nodes found without any kata CRI-O drop-in, handler or binary
+	// +optional
+	VerifiedNodesList []string `json:"verifiedNodesList,omitempty"`
+
+	// LeftoverNodesList are the nodes where kata leftovers were found, and which ones
+	// +optional
+	LeftoverNodesList []FailedNodeStatus `json:"leftoverNodesList,omitempty"`
 }
 
 // KataUnInstallationInProgressStatus reflects the status of nodes that are in the process of kata installation
@@ -154,6 +1255,76 @@ type KataUnInstallationInProgressStatus struct {
 
 // KataUpgradeStatus reflects the status of the ongoing kata upgrade
 type KataUpgradeStatus struct {
+	// Channel the payloads were resolved from
+	// +optional
+	Channel KataChannel `json:"channel,omitempty"`
+
+	// Payloads are the payloads resolved from the channel, by architecture
+	// +optional
+	Payloads []KataResolvedPayload `json:"payloads,omitempty"`
+
+	// InstalledPayloads are the payloads the nodes run. They are replaced by the resolved
+	// payloads once the upgraded nodes have staged them, which reboots the nodes
+	// +optional
+	InstalledPayloads []KataResolvedPayload `json:"installedPayloads,omitempty"`
+
+	// UpgradingNodesList are the nodes staging the resolved payloads
+	// +optional
+	UpgradingNodesList []string `json:"upgradingNodesList,omitempty"`
+}
+
+// KataShimConfig is the kata shim of the CRI-O runtime handlers
+type KataShimConfig struct {
+	// Path of the shim binary on the nodes, /usr/bin/containerd-shim-kata-v2 by default
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path,omitempty"`
+
+	// Type is the CRI-O runtime type of the shim, vm by default
+	// +optional
+	// +kubebuilder:validation:Enum=vm;oci
+	Type string `json:"type,omitempty"`
+
+	// Root is the directory the runtime keeps its state in, /run/vc by default
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Root string `json:"root,omitempty"`
+}
+
+// KataDevmapperConfig is the devmapper snapshotter of containerd
+type KataDevmapperConfig struct {
+	// PoolName is the devmapper thin-pool of the nodes, devpool by default. It must exist on
+	// the nodes, the installation fails on the nodes without it
+	// +optional
+	PoolName string `json:"poolName,omitempty"`
+
+	// BaseImageSize is the size of the block devices holding the rootfs of the containers,
+	// 10Gi by default. The thin-pool must be at least that big
+	// +optional
+	BaseImageSize *resource.Quantity `json:"baseImageSize,omitempty"`
+
+	// Shims are the kata shims of kata-deploy using the devmapper snapshotter, fc by default
+	// +optional
+	Shims []string `json:"shims,omitempty"`
+}
+
+// KataResolvedPayload is the KataPayload resolved from a channel for an architecture
+type KataResolvedPayload struct {
+	// Architecture of the nodes
+	Architecture string `json:"architecture"`
+
+	// Name of the KataPayload
+	Name string `json:"name"`
+
+	// KataVersion of the payload
+	KataVersion string `json:"kataVersion"`
+
+	// Image of the payload, pinned by digest
+	Image string `json:"image"`
+
+	// Shim of the payload, the default one if not set
+	// +optional
+	Shim *KataShimConfig `json:"shim,omitempty"`
 }
 
 // FailedNodeStatus holds the name and the error message of the failed node
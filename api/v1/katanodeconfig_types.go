@@ -0,0 +1,57 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KataNodeConfigSpec overrides the kata settings of the KataConfig on some of the kata nodes
+type KataNodeConfigSpec struct {
+	// NodeSelector selects the nodes the settings apply to. A node selected by several
+	// KataNodeConfigs gets the settings of all of them, the last one by name winning
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+
+	// Hypervisor overrides the size of the kata guests on the nodes
+	// +optional
+	Hypervisor *KataHypervisorConfig `json:"hypervisor,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KataNodeConfig overrides kata settings on individual nodes or groups of nodes, e.g. a bigger
+// default guest memory on the large memory hosts. The daemon merges them on top of the settings
+// of the KataConfig
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=katanodeconfigs,scope=Cluster
+type KataNodeConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KataNodeConfigSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// KataNodeConfigList contains a list of KataNodeConfig
+type KataNodeConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KataNodeConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KataNodeConfig{}, &KataNodeConfigList{})
+}
@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KataChannel is a release channel of the kata payloads
+// +kubebuilder:validation:Enum=stable;candidate
+type KataChannel string
+
+const (
+	// KataChannelStable carries the payloads supported in production
+	KataChannelStable KataChannel = "stable"
+
+	// KataChannelCandidate carries the payloads released ahead of stable, for testing
+	KataChannelCandidate KataChannel = "candidate"
+)
+
+// KataPayloadSpec describes a kata payload release
+type KataPayloadSpec struct {
+	// Image is the payload image, pinned by digest, e.g. quay.io/isolatedcontainers/kata-operator-payload@sha256:...
+	// +kubebuilder:validation:Pattern=`@sha256:[a-f0-9]{64}$`
+	Image string `json:"image"`
+
+	// KataVersion is the version of kata shipped by the payload, e.g. 2.0.1
+	KataVersion string `json:"kataVersion"`
+
+	// OpenShiftVersions are the OpenShift releases the payload supports, as major.minor, e.g. 4.6
+	// +kubebuilder:validation:MinItems=1
+	OpenShiftVersions []string `json:"openShiftVersions"`
+
+	// Architecture of the nodes the payload is built for, amd64 by default
+	// +optional
+	// +kubebuilder:validation:Enum=amd64;arm64;ppc64le;s390x
+	Architecture string `json:"architecture,omitempty"`
+
+	// Channels the payload is released in
+	// +kubebuilder:validation:MinItems=1
+	Channels []KataChannel `json:"channels"`
+
+	// Shim is where the payload installs the kata shim, for payloads installing it elsewhere
+	// than /usr/bin/containerd-shim-kata-v2
+	// +optional
+	Shim *KataShimConfig `json:"shim,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KataPayload is a kata payload release of the catalog the KataConfig channels are resolved from
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=katapayloads,scope=Cluster
+// +kubebuilder:printcolumn:name="Kata Version",type=string,JSONPath=`.spec.kataVersion`
+// +kubebuilder:printcolumn:name="Architecture",type=string,JSONPath=`.spec.architecture`
+// +kubebuilder:printcolumn:name="Channels",type=string,JSONPath=`.spec.channels`
+type KataPayload struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KataPayloadSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// KataPayloadList contains a list of KataPayload
+type KataPayloadList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KataPayload `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KataPayload{}, &KataPayloadList{})
+}
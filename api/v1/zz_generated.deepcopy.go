@@ -21,8 +21,10 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -40,6 +42,148 @@ func (in *FailedNodeStatus) DeepCopy() *FailedNodeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataAnnotationPolicy) DeepCopyInto(out *KataAnnotationPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataAnnotationPolicy.
+func (in *KataAnnotationPolicy) DeepCopy() *KataAnnotationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KataAnnotationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataAnnotationPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataAnnotationPolicyList) DeepCopyInto(out *KataAnnotationPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KataAnnotationPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataAnnotationPolicyList.
+func (in *KataAnnotationPolicyList) DeepCopy() *KataAnnotationPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(KataAnnotationPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataAnnotationPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataAnnotationPolicySpec) DeepCopyInto(out *KataAnnotationPolicySpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedAnnotations != nil {
+		in, out := &in.AllowedAnnotations, &out.AllowedAnnotations
+		*out = make([]KataAnnotation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataAnnotationPolicySpec.
+func (in *KataAnnotationPolicySpec) DeepCopy() *KataAnnotationPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KataAnnotationPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataBlockVolumesConfig) DeepCopyInto(out *KataBlockVolumesConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataBlockVolumesConfig.
+func (in *KataBlockVolumesConfig) DeepCopy() *KataBlockVolumesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataBlockVolumesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataConfidentialConfig) DeepCopyInto(out *KataConfidentialConfig) {
+	*out = *in
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.SchedulingNodeSelector != nil {
+		in, out := &in.SchedulingNodeSelector, &out.SchedulingNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ImageSecurityPolicy != nil {
+		in, out := &in.ImageSecurityPolicy, &out.ImageSecurityPolicy
+		*out = new(KataImageSecurityPolicy)
+		**out = **in
+	}
+	if in.EncryptedImages != nil {
+		in, out := &in.EncryptedImages, &out.EncryptedImages
+		*out = new(KataEncryptedImagesConfig)
+		**out = **in
+	}
+	if in.QuoteGeneration != nil {
+		in, out := &in.QuoteGeneration, &out.QuoteGeneration
+		*out = new(KataQuoteGenerationConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfidentialConfig.
+func (in *KataConfidentialConfig) DeepCopy() *KataConfidentialConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataConfidentialConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataConfig) DeepCopyInto(out *KataConfig) {
 	*out = *in
@@ -127,7 +271,155 @@ func (in *KataConfigSpec) DeepCopyInto(out *KataConfigSpec) {
 		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.KataConfigPoolSelectors != nil {
+		in, out := &in.KataConfigPoolSelectors, &out.KataConfigPoolSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PeerPods != nil {
+		in, out := &in.PeerPods, &out.PeerPods
+		*out = new(KataPeerPodsConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubeVirt != nil {
+		in, out := &in.KubeVirt, &out.KubeVirt
+		*out = new(KataKubeVirtConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Render != nil {
+		in, out := &in.Render, &out.Render
+		*out = new(KataRenderConfig)
+		**out = **in
+	}
+	if in.SchedulingNodeSelector != nil {
+		in, out := &in.SchedulingNodeSelector, &out.SchedulingNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Shim != nil {
+		in, out := &in.Shim, &out.Shim
+		*out = new(KataShimConfig)
+		**out = **in
+	}
+	if in.Devmapper != nil {
+		in, out := &in.Devmapper, &out.Devmapper
+		*out = new(KataDevmapperConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	out.Config = in.Config
+	if in.PayloadImages != nil {
+		in, out := &in.PayloadImages, &out.PayloadImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SELinux != nil {
+		in, out := &in.SELinux, &out.SELinux
+		*out = new(KataSELinuxConfig)
+		**out = **in
+	}
+	if in.Confidential != nil {
+		in, out := &in.Confidential, &out.Confidential
+		*out = new(KataConfidentialConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SGX != nil {
+		in, out := &in.SGX, &out.SGX
+		*out = new(KataSGXConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hypervisor != nil {
+		in, out := &in.Hypervisor, &out.Hypervisor
+		*out = new(KataHypervisorConfig)
+		**out = **in
+	}
+	if in.QEMU != nil {
+		in, out := &in.QEMU, &out.QEMU
+		*out = new(KataQEMUConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlockVolumes != nil {
+		in, out := &in.BlockVolumes, &out.BlockVolumes
+		*out = new(KataBlockVolumesConfig)
+		**out = **in
+	}
+	if in.SandboxCgroupOnly != nil {
+		in, out := &in.SandboxCgroupOnly, &out.SandboxCgroupOnly
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DevicePassthrough != nil {
+		in, out := &in.DevicePassthrough, &out.DevicePassthrough
+		*out = new(KataDevicePassthroughConfig)
+		**out = **in
+	}
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ForceUninstall != nil {
+		in, out := &in.ForceUninstall, &out.ForceUninstall
+		*out = new(KataForceUninstallConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeEligibility != nil {
+		in, out := &in.NodeEligibility, &out.NodeEligibility
+		*out = new(KataNodeEligibilityConfig)
+		**out = **in
+	}
+	if in.InstallTimeout != nil {
+		in, out := &in.InstallTimeout, &out.InstallTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.DaemonUpdateStrategy != nil {
+		in, out := &in.DaemonUpdateStrategy, &out.DaemonUpdateStrategy
+		*out = new(KataDaemonUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Crio != nil {
+		in, out := &in.Crio, &out.Crio
+		*out = new(KataCrioConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProvisionWorkers != nil {
+		in, out := &in.ProvisionWorkers, &out.ProvisionWorkers
+		*out = new(KataProvisionWorkersConfig)
+		**out = **in
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(KataRolloutConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logging != nil {
+		in, out := &in.Logging, &out.Logging
+		*out = new(KataLoggingConfig)
+		**out = **in
+	}
+	if in.Tracing != nil {
+		in, out := &in.Tracing, &out.Tracing
+		*out = new(KataTracingConfig)
+		**out = **in
+	}
+	if in.SmokeTest != nil {
+		in, out := &in.SmokeTest, &out.SmokeTest
+		*out = new(KataSmokeTestConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigSpec.
@@ -145,7 +437,36 @@ func (in *KataConfigStatus) DeepCopyInto(out *KataConfigStatus) {
 	*out = *in
 	in.InstallationStatus.DeepCopyInto(&out.InstallationStatus)
 	in.UnInstallationStatus.DeepCopyInto(&out.UnInstallationStatus)
-	out.Upgradestatus = in.Upgradestatus
+	in.Upgradestatus.DeepCopyInto(&out.Upgradestatus)
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]KataHistoryEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(KataDryRunReport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TEECapabilities != nil {
+		in, out := &in.TEECapabilities, &out.TEECapabilities
+		*out = make([]KataNodeTEECapabilities, len(*in))
+		copy(*out, *in)
+	}
+	if in.PeerPods != nil {
+		in, out := &in.PeerPods, &out.PeerPods
+		*out = new(KataPeerPodsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigStatus.
@@ -159,121 +480,1015 @@ func (in *KataConfigStatus) DeepCopy() *KataConfigStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataFailedNodeStatus) DeepCopyInto(out *KataFailedNodeStatus) {
+func (in *KataCrioConfig) DeepCopyInto(out *KataCrioConfig) {
 	*out = *in
-	if in.FailedNodesList != nil {
-		in, out := &in.FailedNodesList, &out.FailedNodesList
-		*out = make([]FailedNodeStatus, len(*in))
+	if in.AllowedAnnotations != nil {
+		in, out := &in.AllowedAnnotations, &out.AllowedAnnotations
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataFailedNodeStatus.
-func (in *KataFailedNodeStatus) DeepCopy() *KataFailedNodeStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataCrioConfig.
+func (in *KataCrioConfig) DeepCopy() *KataCrioConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(KataFailedNodeStatus)
+	out := new(KataCrioConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataInstallConfig) DeepCopyInto(out *KataInstallConfig) {
+func (in *KataDaemonUpdateStrategy) DeepCopyInto(out *KataDaemonUpdateStrategy) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataDaemonUpdateStrategy.
+func (in *KataDaemonUpdateStrategy) DeepCopy() *KataDaemonUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(KataDaemonUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataDevicePassthroughConfig) DeepCopyInto(out *KataDevicePassthroughConfig) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallConfig.
-func (in *KataInstallConfig) DeepCopy() *KataInstallConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataDevicePassthroughConfig.
+func (in *KataDevicePassthroughConfig) DeepCopy() *KataDevicePassthroughConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(KataInstallConfig)
+	out := new(KataDevicePassthroughConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataInstallationInProgressStatus) DeepCopyInto(out *KataInstallationInProgressStatus) {
+func (in *KataDevmapperConfig) DeepCopyInto(out *KataDevmapperConfig) {
 	*out = *in
-	if in.BinariesInstalledNodesList != nil {
-		in, out := &in.BinariesInstalledNodesList, &out.BinariesInstalledNodesList
+	if in.BaseImageSize != nil {
+		in, out := &in.BaseImageSize, &out.BaseImageSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Shims != nil {
+		in, out := &in.Shims, &out.Shims
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallationInProgressStatus.
-func (in *KataInstallationInProgressStatus) DeepCopy() *KataInstallationInProgressStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataDevmapperConfig.
+func (in *KataDevmapperConfig) DeepCopy() *KataDevmapperConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(KataInstallationInProgressStatus)
+	out := new(KataDevmapperConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataInstallationStatus) DeepCopyInto(out *KataInstallationStatus) {
+func (in *KataDryRunReport) DeepCopyInto(out *KataDryRunReport) {
 	*out = *in
-	in.InProgress.DeepCopyInto(&out.InProgress)
-	in.Completed.DeepCopyInto(&out.Completed)
-	in.Failed.DeepCopyInto(&out.Failed)
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IneligibleNodes != nil {
+		in, out := &in.IneligibleNodes, &out.IneligibleNodes
+		*out = make([]FailedNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallationStatus.
-func (in *KataInstallationStatus) DeepCopy() *KataInstallationStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataDryRunReport.
+func (in *KataDryRunReport) DeepCopy() *KataDryRunReport {
 	if in == nil {
 		return nil
 	}
-	out := new(KataInstallationStatus)
+	out := new(KataDryRunReport)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataUnInstallationInProgressStatus) DeepCopyInto(out *KataUnInstallationInProgressStatus) {
+func (in *KataEncryptedImagesConfig) DeepCopyInto(out *KataEncryptedImagesConfig) {
 	*out = *in
-	if in.BinariesUnInstalledNodesList != nil {
-		in, out := &in.BinariesUnInstalledNodesList, &out.BinariesUnInstalledNodesList
-		*out = make([]string, len(*in))
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataEncryptedImagesConfig.
+func (in *KataEncryptedImagesConfig) DeepCopy() *KataEncryptedImagesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataEncryptedImagesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataFailedNodeStatus) DeepCopyInto(out *KataFailedNodeStatus) {
+	*out = *in
+	if in.FailedNodesList != nil {
+		in, out := &in.FailedNodesList, &out.FailedNodesList
+		*out = make([]FailedNodeStatus, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationInProgressStatus.
-func (in *KataUnInstallationInProgressStatus) DeepCopy() *KataUnInstallationInProgressStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataFailedNodeStatus.
+func (in *KataFailedNodeStatus) DeepCopy() *KataFailedNodeStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(KataUnInstallationInProgressStatus)
+	out := new(KataFailedNodeStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataUnInstallationStatus) DeepCopyInto(out *KataUnInstallationStatus) {
+func (in *KataForceUninstallConfig) DeepCopyInto(out *KataForceUninstallConfig) {
 	*out = *in
-	in.InProgress.DeepCopyInto(&out.InProgress)
-	in.Completed.DeepCopyInto(&out.Completed)
-	in.Failed.DeepCopyInto(&out.Failed)
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationStatus.
-func (in *KataUnInstallationStatus) DeepCopy() *KataUnInstallationStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataForceUninstallConfig.
+func (in *KataForceUninstallConfig) DeepCopy() *KataForceUninstallConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(KataUnInstallationStatus)
+	out := new(KataForceUninstallConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KataUpgradeStatus) DeepCopyInto(out *KataUpgradeStatus) {
+func (in *KataHistoryEvent) DeepCopyInto(out *KataHistoryEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataHistoryEvent.
+func (in *KataHistoryEvent) DeepCopy() *KataHistoryEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(KataHistoryEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataHypervisorConfig) DeepCopyInto(out *KataHypervisorConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataHypervisorConfig.
+func (in *KataHypervisorConfig) DeepCopy() *KataHypervisorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataHypervisorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataImageSecurityPolicy) DeepCopyInto(out *KataImageSecurityPolicy) {
 	*out = *in
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataImageSecurityPolicy.
+func (in *KataImageSecurityPolicy) DeepCopy() *KataImageSecurityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KataImageSecurityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataInstallConfig) DeepCopyInto(out *KataInstallConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallConfig.
+func (in *KataInstallConfig) DeepCopy() *KataInstallConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataInstallConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataInstallationInProgressStatus) DeepCopyInto(out *KataInstallationInProgressStatus) {
+	*out = *in
+	if in.BinariesInstalledNodesList != nil {
+		in, out := &in.BinariesInstalledNodesList, &out.BinariesInstalledNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallationInProgressStatus.
+func (in *KataInstallationInProgressStatus) DeepCopy() *KataInstallationInProgressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataInstallationInProgressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataInstallationStatus) DeepCopyInto(out *KataInstallationStatus) {
+	*out = *in
+	in.InProgress.DeepCopyInto(&out.InProgress)
+	in.Completed.DeepCopyInto(&out.Completed)
+	in.Failed.DeepCopyInto(&out.Failed)
+	in.SmokeTest.DeepCopyInto(&out.SmokeTest)
+	in.Degraded.DeepCopyInto(&out.Degraded)
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]KataNodeArtifacts, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstallDurations != nil {
+		in, out := &in.InstallDurations, &out.InstallDurations
+		*out = make([]KataNodeInstallDuration, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallationStatus.
+func (in *KataInstallationStatus) DeepCopy() *KataInstallationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataInstallationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataKubeVirtConfig) DeepCopyInto(out *KataKubeVirtConfig) {
+	*out = *in
+	if in.VMNodeLabels != nil {
+		in, out := &in.VMNodeLabels, &out.VMNodeLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataKubeVirtConfig.
+func (in *KataKubeVirtConfig) DeepCopy() *KataKubeVirtConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataKubeVirtConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataLibvirtConfig) DeepCopyInto(out *KataLibvirtConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataLibvirtConfig.
+func (in *KataLibvirtConfig) DeepCopy() *KataLibvirtConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataLibvirtConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataLoggingConfig) DeepCopyInto(out *KataLoggingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataLoggingConfig.
+func (in *KataLoggingConfig) DeepCopy() *KataLoggingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataLoggingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataMaintenanceWindow) DeepCopyInto(out *KataMaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataMaintenanceWindow.
+func (in *KataMaintenanceWindow) DeepCopy() *KataMaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(KataMaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeArtifacts) DeepCopyInto(out *KataNodeArtifacts) {
+	*out = *in
+	if in.Checksums != nil {
+		in, out := &in.Checksums, &out.Checksums
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeArtifacts.
+func (in *KataNodeArtifacts) DeepCopy() *KataNodeArtifacts {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeArtifacts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeConfig) DeepCopyInto(out *KataNodeConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeConfig.
+func (in *KataNodeConfig) DeepCopy() *KataNodeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataNodeConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeConfigList) DeepCopyInto(out *KataNodeConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KataNodeConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeConfigList.
+func (in *KataNodeConfigList) DeepCopy() *KataNodeConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataNodeConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeConfigSpec) DeepCopyInto(out *KataNodeConfigSpec) {
+	*out = *in
+	in.NodeSelector.DeepCopyInto(&out.NodeSelector)
+	if in.Hypervisor != nil {
+		in, out := &in.Hypervisor, &out.Hypervisor
+		*out = new(KataHypervisorConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeConfigSpec.
+func (in *KataNodeConfigSpec) DeepCopy() *KataNodeConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeEligibilityConfig) DeepCopyInto(out *KataNodeEligibilityConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeEligibilityConfig.
+func (in *KataNodeEligibilityConfig) DeepCopy() *KataNodeEligibilityConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeEligibilityConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeInstallDuration) DeepCopyInto(out *KataNodeInstallDuration) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeInstallDuration.
+func (in *KataNodeInstallDuration) DeepCopy() *KataNodeInstallDuration {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeInstallDuration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeTEECapabilities) DeepCopyInto(out *KataNodeTEECapabilities) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeTEECapabilities.
+func (in *KataNodeTEECapabilities) DeepCopy() *KataNodeTEECapabilities {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeTEECapabilities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPayload) DeepCopyInto(out *KataPayload) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPayload.
+func (in *KataPayload) DeepCopy() *KataPayload {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPayload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataPayload) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPayloadList) DeepCopyInto(out *KataPayloadList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KataPayload, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPayloadList.
+func (in *KataPayloadList) DeepCopy() *KataPayloadList {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPayloadList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KataPayloadList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPayloadSpec) DeepCopyInto(out *KataPayloadSpec) {
+	*out = *in
+	if in.OpenShiftVersions != nil {
+		in, out := &in.OpenShiftVersions, &out.OpenShiftVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Channels != nil {
+		in, out := &in.Channels, &out.Channels
+		*out = make([]KataChannel, len(*in))
+		copy(*out, *in)
+	}
+	if in.Shim != nil {
+		in, out := &in.Shim, &out.Shim
+		*out = new(KataShimConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPayloadSpec.
+func (in *KataPayloadSpec) DeepCopy() *KataPayloadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPayloadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPeerPodsConfig) DeepCopyInto(out *KataPeerPodsConfig) {
+	*out = *in
+	if in.PodVMImage != nil {
+		in, out := &in.PodVMImage, &out.PodVMImage
+		*out = new(KataPodVMImageConfig)
+		**out = **in
+	}
+	if in.InstanceTypes != nil {
+		in, out := &in.InstanceTypes, &out.InstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Libvirt != nil {
+		in, out := &in.Libvirt, &out.Libvirt
+		*out = new(KataLibvirtConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPeerPodsConfig.
+func (in *KataPeerPodsConfig) DeepCopy() *KataPeerPodsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPeerPodsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPeerPodsStatus) DeepCopyInto(out *KataPeerPodsStatus) {
+	*out = *in
+	if in.PodVMImage != nil {
+		in, out := &in.PodVMImage, &out.PodVMImage
+		*out = new(KataPodVMImageStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StaleImages != nil {
+		in, out := &in.StaleImages, &out.StaleImages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastVMCollection != nil {
+		in, out := &in.LastVMCollection, &out.LastVMCollection
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPeerPodsStatus.
+func (in *KataPeerPodsStatus) DeepCopy() *KataPeerPodsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPeerPodsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPodVMImageConfig) DeepCopyInto(out *KataPodVMImageConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPodVMImageConfig.
+func (in *KataPodVMImageConfig) DeepCopy() *KataPodVMImageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPodVMImageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPodVMImageStatus) DeepCopyInto(out *KataPodVMImageStatus) {
+	*out = *in
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPodVMImageStatus.
+func (in *KataPodVMImageStatus) DeepCopy() *KataPodVMImageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPodVMImageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataProvisionWorkersConfig) DeepCopyInto(out *KataProvisionWorkersConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataProvisionWorkersConfig.
+func (in *KataProvisionWorkersConfig) DeepCopy() *KataProvisionWorkersConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataProvisionWorkersConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataQEMUConfig) DeepCopyInto(out *KataQEMUConfig) {
+	*out = *in
+	if in.CPUFeatures != nil {
+		in, out := &in.CPUFeatures, &out.CPUFeatures
+		*out = make([]KataCPUFeature, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataQEMUConfig.
+func (in *KataQEMUConfig) DeepCopy() *KataQEMUConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataQEMUConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataQuoteGenerationConfig) DeepCopyInto(out *KataQuoteGenerationConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataQuoteGenerationConfig.
+func (in *KataQuoteGenerationConfig) DeepCopy() *KataQuoteGenerationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataQuoteGenerationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRenderConfig) DeepCopyInto(out *KataRenderConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRenderConfig.
+func (in *KataRenderConfig) DeepCopy() *KataRenderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRenderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataResolvedPayload) DeepCopyInto(out *KataResolvedPayload) {
+	*out = *in
+	if in.Shim != nil {
+		in, out := &in.Shim, &out.Shim
+		*out = new(KataShimConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataResolvedPayload.
+func (in *KataResolvedPayload) DeepCopy() *KataResolvedPayload {
+	if in == nil {
+		return nil
+	}
+	out := new(KataResolvedPayload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRolloutConfig) DeepCopyInto(out *KataRolloutConfig) {
+	*out = *in
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(KataMaintenanceWindow)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRolloutConfig.
+func (in *KataRolloutConfig) DeepCopy() *KataRolloutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRolloutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataSELinuxConfig) DeepCopyInto(out *KataSELinuxConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataSELinuxConfig.
+func (in *KataSELinuxConfig) DeepCopy() *KataSELinuxConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataSELinuxConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataSGXConfig) DeepCopyInto(out *KataSGXConfig) {
+	*out = *in
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.SchedulingNodeSelector != nil {
+		in, out := &in.SchedulingNodeSelector, &out.SchedulingNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataSGXConfig.
+func (in *KataSGXConfig) DeepCopy() *KataSGXConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataSGXConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataShimConfig) DeepCopyInto(out *KataShimConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataShimConfig.
+func (in *KataShimConfig) DeepCopy() *KataShimConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataShimConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataSmokeTestConfig) DeepCopyInto(out *KataSmokeTestConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataSmokeTestConfig.
+func (in *KataSmokeTestConfig) DeepCopy() *KataSmokeTestConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataSmokeTestConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataSmokeTestStatus) DeepCopyInto(out *KataSmokeTestStatus) {
+	*out = *in
+	if in.VerifiedNodesList != nil {
+		in, out := &in.VerifiedNodesList, &out.VerifiedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailedNodesList != nil {
+		in, out := &in.FailedNodesList, &out.FailedNodesList
+		*out = make([]FailedNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataSmokeTestStatus.
+func (in *KataSmokeTestStatus) DeepCopy() *KataSmokeTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataSmokeTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataTracingConfig) DeepCopyInto(out *KataTracingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataTracingConfig.
+func (in *KataTracingConfig) DeepCopy() *KataTracingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataTracingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUnInstallationInProgressStatus) DeepCopyInto(out *KataUnInstallationInProgressStatus) {
+	*out = *in
+	if in.BinariesUnInstalledNodesList != nil {
+		in, out := &in.BinariesUnInstalledNodesList, &out.BinariesUnInstalledNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationInProgressStatus.
+func (in *KataUnInstallationInProgressStatus) DeepCopy() *KataUnInstallationInProgressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataUnInstallationInProgressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUnInstallationStatus) DeepCopyInto(out *KataUnInstallationStatus) {
+	*out = *in
+	in.InProgress.DeepCopyInto(&out.InProgress)
+	in.Completed.DeepCopyInto(&out.Completed)
+	in.Failed.DeepCopyInto(&out.Failed)
+	if in.DepartedNodesList != nil {
+		in, out := &in.DepartedNodesList, &out.DepartedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Report != nil {
+		in, out := &in.Report, &out.Report
+		*out = new(KataUninstallReport)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationStatus.
+func (in *KataUnInstallationStatus) DeepCopy() *KataUnInstallationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataUnInstallationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUninstallReport) DeepCopyInto(out *KataUninstallReport) {
+	*out = *in
+	if in.VerifiedNodesList != nil {
+		in, out := &in.VerifiedNodesList, &out.VerifiedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LeftoverNodesList != nil {
+		in, out := &in.LeftoverNodesList, &out.LeftoverNodesList
+		*out = make([]FailedNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUninstallReport.
+func (in *KataUninstallReport) DeepCopy() *KataUninstallReport {
+	if in == nil {
+		return nil
+	}
+	out := new(KataUninstallReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataUpgradeStatus) DeepCopyInto(out *KataUpgradeStatus) {
+	*out = *in
+	if in.Payloads != nil {
+		in, out := &in.Payloads, &out.Payloads
+		*out = make([]KataResolvedPayload, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstalledPayloads != nil {
+		in, out := &in.InstalledPayloads, &out.InstalledPayloads
+		*out = make([]KataResolvedPayload, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UpgradingNodesList != nil {
+		in, out := &in.UpgradingNodesList, &out.UpgradingNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUpgradeStatus.
 func (in *KataUpgradeStatus) DeepCopy() *KataUpgradeStatus {
 	if in == nil {
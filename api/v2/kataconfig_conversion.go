@@ -0,0 +1,395 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"encoding/json"
+	"reflect"
+
+	v1 "github.com/openshift/kata-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	// v1SpecAnnotation keeps the v1 spec on v2 objects so that v1 fields without
+	// a v2 counterpart survive a round trip through v2
+	v1SpecAnnotation = "kataconfiguration.openshift.io/v1-spec"
+
+	// rolloutAnnotation keeps the v2 rollout policy on v1 objects
+	rolloutAnnotation = "kataconfiguration.openshift.io/v2-rollout"
+
+	// v1StatusAnnotation keeps the v1 status fields without a v2 counterpart on v2 objects, e.g.
+	// the dry run preview, so that the status writes through v2 don't drop them
+	v1StatusAnnotation = "kataconfiguration.openshift.io/v1-status"
+)
+
+var _ conversion.Convertible = &KataConfig{}
+
+// ConvertTo converts this KataConfig to the v1 hub version
+func (src *KataConfig) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.KataConfig)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Annotations = copyAnnotations(src.Annotations)
+
+	if stored, ok := dst.Annotations[v1SpecAnnotation]; ok {
+		if err := json.Unmarshal([]byte(stored), &dst.Spec); err != nil {
+			return err
+		}
+		delete(dst.Annotations, v1SpecAnnotation)
+	}
+
+	dst.Spec.KataConfigPoolSelector = src.Spec.KataConfigPoolSelector
+	dst.Spec.KataConfigPoolSelectors = src.Spec.KataConfigPoolSelectors
+	dst.Spec.AllowControlPlaneNodes = src.Spec.AllowControlPlaneNodes
+	dst.Spec.DryRun = src.Spec.DryRun
+	dst.Spec.Config.SourceImage = src.Spec.Payload.SourceImage
+	dst.Spec.PayloadImages = src.Spec.Payload.Images
+	dst.Spec.PayloadDelivery = v1.PayloadDelivery(src.Spec.Payload.Delivery)
+	dst.Spec.Channel = v1.KataChannel(src.Spec.Payload.Channel)
+
+	dst.Spec.SELinux = nil
+	if src.Spec.Hypervisor != nil && src.Spec.Hypervisor.SELinuxShimMode != "" {
+		dst.Spec.SELinux = &v1.KataSELinuxConfig{
+			ShimMode: v1.SELinuxMode(src.Spec.Hypervisor.SELinuxShimMode),
+		}
+	}
+
+	dst.Spec.Hypervisor = nil
+	if h := src.Spec.Hypervisor; h != nil && (h.DefaultMemory != 0 || h.DefaultVCPUs != 0) {
+		dst.Spec.Hypervisor = &v1.KataHypervisorConfig{
+			DefaultMemory: h.DefaultMemory,
+			DefaultVCPUs:  h.DefaultVCPUs,
+		}
+	}
+
+	// the v1 confidential settings without a v2 counterpart are kept from the stored spec
+	stored := dst.Spec.Confidential
+	dst.Spec.Confidential = nil
+	if src.Spec.Confidential != nil {
+		dst.Spec.Confidential = &v1.KataConfidentialConfig{}
+		if stored != nil {
+			*dst.Spec.Confidential = *stored
+		}
+		dst.Spec.Confidential.Enabled = src.Spec.Confidential.Enabled
+		dst.Spec.Confidential.TEE = v1.TEE(src.Spec.Confidential.TEE)
+		dst.Spec.Confidential.Overhead = src.Spec.Confidential.Overhead
+		dst.Spec.Confidential.SchedulingNodeSelector = src.Spec.Confidential.SchedulingNodeSelector
+	}
+
+	dst.Spec.Rollout = nil
+	if src.Spec.Rollout != nil && src.Spec.Rollout.Schedule != nil {
+		dst.Spec.Rollout = &v1.KataRolloutConfig{
+			Schedule: (*v1.KataMaintenanceWindow)(src.Spec.Rollout.Schedule),
+		}
+	}
+
+	// the schedule has a v1 counterpart, only the rest of the policy is kept aside
+	delete(dst.Annotations, rolloutAnnotation)
+	if src.Spec.Rollout != nil && !reflect.DeepEqual(*src.Spec.Rollout, KataRolloutPolicy{Schedule: src.Spec.Rollout.Schedule}) {
+		policy := *src.Spec.Rollout
+		policy.Schedule = nil
+		rollout, err := json.Marshal(&policy)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[rolloutAnnotation] = string(rollout)
+	}
+
+	storedStatus := v1.KataConfigStatus{}
+	if raw, ok := dst.Annotations[v1StatusAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &storedStatus); err != nil {
+			return err
+		}
+		delete(dst.Annotations, v1StatusAnnotation)
+	}
+	convertStatusToV1(&src.Status, &dst.Status)
+	restoreUnmappedStatus(&storedStatus, &dst.Status)
+	return nil
+}
+
+// ConvertFrom converts the v1 hub version to this KataConfig
+func (dst *KataConfig) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.KataConfig)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Annotations = copyAnnotations(src.Annotations)
+
+	dst.Spec.KataConfigPoolSelector = src.Spec.KataConfigPoolSelector
+	dst.Spec.KataConfigPoolSelectors = src.Spec.KataConfigPoolSelectors
+	dst.Spec.AllowControlPlaneNodes = src.Spec.AllowControlPlaneNodes
+	dst.Spec.DryRun = src.Spec.DryRun
+	dst.Spec.Payload = KataPayloadConfig{
+		SourceImage: src.Spec.Config.SourceImage,
+		Images:      src.Spec.PayloadImages,
+		Delivery:    PayloadDelivery(src.Spec.PayloadDelivery),
+		Channel:     KataChannel(src.Spec.Channel),
+	}
+
+	if src.Spec.SELinux != nil || src.Spec.Hypervisor != nil {
+		dst.Spec.Hypervisor = &KataHypervisorConfig{}
+		if src.Spec.SELinux != nil {
+			dst.Spec.Hypervisor.SELinuxShimMode = SELinuxMode(src.Spec.SELinux.ShimMode)
+		}
+		if src.Spec.Hypervisor != nil {
+			dst.Spec.Hypervisor.DefaultMemory = src.Spec.Hypervisor.DefaultMemory
+			dst.Spec.Hypervisor.DefaultVCPUs = src.Spec.Hypervisor.DefaultVCPUs
+		}
+	}
+
+	if src.Spec.Confidential != nil {
+		dst.Spec.Confidential = &KataConfidentialConfig{
+			Enabled:                src.Spec.Confidential.Enabled,
+			TEE:                    TEE(src.Spec.Confidential.TEE),
+			Overhead:               src.Spec.Confidential.Overhead,
+			SchedulingNodeSelector: src.Spec.Confidential.SchedulingNodeSelector,
+		}
+	}
+
+	if stored, ok := dst.Annotations[rolloutAnnotation]; ok {
+		dst.Spec.Rollout = &KataRolloutPolicy{}
+		if err := json.Unmarshal([]byte(stored), dst.Spec.Rollout); err != nil {
+			return err
+		}
+		delete(dst.Annotations, rolloutAnnotation)
+	}
+	if src.Spec.Rollout != nil && src.Spec.Rollout.Schedule != nil {
+		if dst.Spec.Rollout == nil {
+			dst.Spec.Rollout = &KataRolloutPolicy{}
+		}
+		dst.Spec.Rollout.Schedule = (*KataMaintenanceWindow)(src.Spec.Rollout.Schedule)
+	} else if dst.Spec.Rollout != nil {
+		dst.Spec.Rollout.Schedule = nil
+	}
+
+	spec, err := json.Marshal(src.Spec)
+	if err != nil {
+		return err
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[v1SpecAnnotation] = string(spec)
+
+	if unmapped := unmappedStatus(&src.Status); !reflect.DeepEqual(*unmapped, v1.KataConfigStatus{}) {
+		status, err := json.Marshal(unmapped)
+		if err != nil {
+			return err
+		}
+		dst.Annotations[v1StatusAnnotation] = string(status)
+	}
+	convertStatusFromV1(&src.Status, &dst.Status)
+	return nil
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		return nil
+	}
+	c := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		c[k] = v
+	}
+	return c
+}
+
+// unmappedStatus returns the v1 status fields without a v2 counterpart
+func unmappedStatus(status *v1.KataConfigStatus) *v1.KataConfigStatus {
+	return &v1.KataConfigStatus{
+		Platform:        status.Platform,
+		InstallMode:     status.InstallMode,
+		Upgradestatus:   status.Upgradestatus,
+		DryRun:          status.DryRun,
+		TEECapabilities: status.TEECapabilities,
+		PeerPods:        status.PeerPods,
+		UnInstallationStatus: v1.KataUnInstallationStatus{
+			Report: status.UnInstallationStatus.Report,
+		},
+	}
+}
+
+// restoreUnmappedStatus sets the v1 status fields without a v2 counterpart from the stored ones
+func restoreUnmappedStatus(stored *v1.KataConfigStatus, dst *v1.KataConfigStatus) {
+	dst.Platform = stored.Platform
+	dst.InstallMode = stored.InstallMode
+	dst.Upgradestatus = stored.Upgradestatus
+	dst.DryRun = stored.DryRun
+	dst.TEECapabilities = stored.TEECapabilities
+	dst.PeerPods = stored.PeerPods
+	dst.UnInstallationStatus.Report = stored.UnInstallationStatus.Report
+}
+
+// convertStatusFromV1 folds the v1 per-operation node lists into one entry per node.
+// Uninstallation progress takes precedence over the installation one
+func convertStatusFromV1(src *v1.KataConfigStatus, dst *KataConfigStatus) {
+	dst.RuntimeClass = src.RuntimeClass
+	dst.KataImage = src.KataImage
+	dst.TotalNodesCount = src.TotalNodesCount
+	dst.ObservedGeneration = src.ObservedGeneration
+	dst.Conditions = src.Conditions
+
+	dst.History = nil
+	for _, event := range src.History {
+		dst.History = append(dst.History, KataHistoryEvent{
+			Time:       event.Time,
+			Action:     string(event.Action),
+			Generation: event.Generation,
+			Message:    event.Message,
+		})
+	}
+
+	var nodes []KataNodeStatus
+	index := map[string]int{}
+	setPhase := func(name string, phase KataNodePhase, errMsg string) {
+		if name == "" {
+			return
+		}
+		if i, ok := index[name]; ok {
+			nodes[i].Phase = phase
+			nodes[i].Error = errMsg
+			return
+		}
+		index[name] = len(nodes)
+		nodes = append(nodes, KataNodeStatus{Name: name, Phase: phase, Error: errMsg})
+	}
+
+	install := src.InstallationStatus
+	for _, name := range install.InProgress.BinariesInstalledNodesList {
+		setPhase(name, NodeInstalling, "")
+	}
+	for _, name := range install.Completed.CompletedNodesList {
+		setPhase(name, NodeInstalled, "")
+	}
+	for _, fn := range install.Failed.FailedNodesList {
+		setPhase(fn.Name, NodeInstallFailed, fn.Error)
+	}
+	for _, fn := range install.Degraded.FailedNodesList {
+		if i, ok := index[fn.Name]; ok && nodes[i].Phase == NodeInstalled {
+			setPhase(fn.Name, NodeDegraded, fn.Error)
+		}
+	}
+
+	uninstall := src.UnInstallationStatus
+	for _, name := range uninstall.InProgress.BinariesUnInstalledNodesList {
+		setPhase(name, NodeUninstalling, "")
+	}
+	for _, name := range uninstall.Completed.CompletedNodesList {
+		setPhase(name, NodeUninstalled, "")
+	}
+	for _, fn := range uninstall.Failed.FailedNodesList {
+		setPhase(fn.Name, NodeUninstallFailed, fn.Error)
+	}
+	for _, name := range uninstall.DepartedNodesList {
+		setPhase(name, NodeDeparted, "")
+	}
+
+	verified := true
+	for _, name := range install.SmokeTest.VerifiedNodesList {
+		if i, ok := index[name]; ok {
+			nodes[i].Verified = &verified
+		}
+	}
+	failed := false
+	for _, fn := range install.SmokeTest.FailedNodesList {
+		if i, ok := index[fn.Name]; ok {
+			nodes[i].Verified = &failed
+			nodes[i].VerificationError = fn.Error
+		}
+	}
+
+	for _, node := range install.Artifacts {
+		if i, ok := index[node.Name]; ok {
+			nodes[i].Checksums = node.Checksums
+		}
+	}
+
+	for _, node := range install.InstallDurations {
+		if i, ok := index[node.Name]; ok {
+			duration := node.Duration
+			nodes[i].InstallDuration = &duration
+		}
+	}
+
+	dst.Nodes = nodes
+}
+
+// convertStatusToV1 rebuilds the v1 node lists and counters from the per-node status
+func convertStatusToV1(src *KataConfigStatus, dst *v1.KataConfigStatus) {
+	dst.RuntimeClass = src.RuntimeClass
+	dst.KataImage = src.KataImage
+	dst.TotalNodesCount = src.TotalNodesCount
+	dst.ObservedGeneration = src.ObservedGeneration
+	dst.Conditions = src.Conditions
+
+	dst.History = nil
+	for _, event := range src.History {
+		dst.History = append(dst.History, v1.KataHistoryEvent{
+			Time:       event.Time,
+			Action:     v1.KataHistoryAction(event.Action),
+			Generation: event.Generation,
+			Message:    event.Message,
+		})
+	}
+
+	dst.InstallationStatus = v1.KataInstallationStatus{}
+	dst.UnInstallationStatus = v1.KataUnInstallationStatus{}
+
+	install := &dst.InstallationStatus
+	uninstall := &dst.UnInstallationStatus
+	for _, node := range src.Nodes {
+		switch node.Phase {
+		case NodeInstalling:
+			install.InProgress.BinariesInstalledNodesList = append(install.InProgress.BinariesInstalledNodesList, node.Name)
+		case NodeInstalled:
+			install.Completed.CompletedNodesList = append(install.Completed.CompletedNodesList, node.Name)
+		case NodeInstallFailed:
+			install.Failed.FailedNodesList = append(install.Failed.FailedNodesList, v1.FailedNodeStatus{Name: node.Name, Error: node.Error})
+		case NodeDegraded:
+			install.Completed.CompletedNodesList = append(install.Completed.CompletedNodesList, node.Name)
+			install.Degraded.FailedNodesList = append(install.Degraded.FailedNodesList, v1.FailedNodeStatus{Name: node.Name, Error: node.Error})
+		case NodeUninstalling:
+			uninstall.InProgress.BinariesUnInstalledNodesList = append(uninstall.InProgress.BinariesUnInstalledNodesList, node.Name)
+		case NodeUninstalled:
+			uninstall.Completed.CompletedNodesList = append(uninstall.Completed.CompletedNodesList, node.Name)
+		case NodeUninstallFailed:
+			uninstall.Failed.FailedNodesList = append(uninstall.Failed.FailedNodesList, v1.FailedNodeStatus{Name: node.Name, Error: node.Error})
+		case NodeDeparted:
+			uninstall.DepartedNodesList = append(uninstall.DepartedNodesList, node.Name)
+		}
+
+		if node.Verified != nil && *node.Verified {
+			install.SmokeTest.VerifiedNodesList = append(install.SmokeTest.VerifiedNodesList, node.Name)
+		} else if node.Verified != nil {
+			install.SmokeTest.FailedNodesList = append(install.SmokeTest.FailedNodesList, v1.FailedNodeStatus{Name: node.Name, Error: node.VerificationError})
+		}
+		if len(node.Checksums) > 0 {
+			install.Artifacts = append(install.Artifacts, v1.KataNodeArtifacts{Name: node.Name, Checksums: node.Checksums})
+		}
+		if node.InstallDuration != nil {
+			install.InstallDurations = append(install.InstallDurations, v1.KataNodeInstallDuration{Name: node.Name, Duration: *node.InstallDuration})
+		}
+	}
+
+	install.InProgress.InProgressNodesCount = len(install.InProgress.BinariesInstalledNodesList)
+	install.Completed.CompletedNodesCount = len(install.Completed.CompletedNodesList)
+	install.Failed.FailedNodesCount = len(install.Failed.FailedNodesList)
+	install.Degraded.FailedNodesCount = len(install.Degraded.FailedNodesList)
+	uninstall.InProgress.InProgressNodesCount = len(uninstall.InProgress.BinariesUnInstalledNodesList)
+	uninstall.Completed.CompletedNodesCount = len(uninstall.Completed.CompletedNodesList)
+	uninstall.Failed.FailedNodesCount = len(uninstall.Failed.FailedNodesList)
+}
@@ -0,0 +1,283 @@
+package v2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "github.com/openshift/kata-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestKataConfigRoundTripFromV1(t *testing.T) {
+	original := &v1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Spec: v1.KataConfigSpec{
+			KataConfigPoolSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			KataConfigPoolSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"node-role.kubernetes.io/metal-a": ""}},
+				{MatchLabels: map[string]string{"node-role.kubernetes.io/metal-b": ""}},
+			},
+			DryRun:          true,
+			Config:          v1.KataInstallConfig{SourceImage: "quay.io/kata/deploy:latest"},
+			PayloadImages:   map[string]string{"s390x": "quay.io/kata/payload:s390x"},
+			PayloadDelivery: v1.PayloadDeliveryExtension,
+			Channel:         v1.KataChannelCandidate,
+			SELinux:         &v1.KataSELinuxConfig{ShimMode: v1.SELinuxPermissive},
+			Hypervisor:      &v1.KataHypervisorConfig{DefaultMemory: 4096, DefaultVCPUs: 2},
+			Confidential: &v1.KataConfidentialConfig{Enabled: true, TEE: v1.TEEPEF,
+				SchedulingNodeSelector: map[string]string{"kata-cc": "true"}, GuestPull: true},
+			Rollout: &v1.KataRolloutConfig{Schedule: &v1.KataMaintenanceWindow{
+				Start: "0 2 * * 6", Duration: metav1.Duration{Duration: 4 * time.Hour},
+			}},
+		},
+	}
+
+	converted := &KataConfig{}
+	if err := converted.ConvertFrom(original.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if converted.Spec.Hypervisor == nil || converted.Spec.Hypervisor.SELinuxShimMode != "permissive" ||
+		converted.Spec.Hypervisor.DefaultMemory != 4096 || converted.Spec.Hypervisor.DefaultVCPUs != 2 {
+		t.Errorf("unexpected hypervisor config %+v", converted.Spec.Hypervisor)
+	}
+	if converted.Spec.Payload.Channel != "candidate" {
+		t.Errorf("unexpected payload channel %q", converted.Spec.Payload.Channel)
+	}
+
+	back := &v1.KataConfig{}
+	if err := converted.ConvertTo(back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(original.Spec, back.Spec) {
+		t.Errorf("spec changed in the round trip:\n%+v\n%+v", original.Spec, back.Spec)
+	}
+	if len(back.Annotations) != 0 {
+		t.Errorf("conversion annotations leaked: %v", back.Annotations)
+	}
+}
+
+func TestKataConfigRolloutSurvivesV1(t *testing.T) {
+	maxUnavailable := intstr.FromInt(2)
+	original := &KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Spec: KataConfigSpec{
+			Rollout: &KataRolloutPolicy{MaxUnavailable: &maxUnavailable, Paused: true, Schedule: &KataMaintenanceWindow{
+				Start: "0 2 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Europe/Paris",
+			}},
+		},
+	}
+
+	hub := &v1.KataConfig{}
+	if err := original.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.Rollout == nil || !reflect.DeepEqual(hub.Spec.Rollout.Schedule, (*v1.KataMaintenanceWindow)(original.Spec.Rollout.Schedule)) {
+		t.Errorf("unexpected v1 rollout %+v", hub.Spec.Rollout)
+	}
+
+	back := &KataConfig{}
+	if err := back.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(original.Spec.Rollout, back.Spec.Rollout) {
+		t.Errorf("rollout changed in the round trip: %+v", back.Spec.Rollout)
+	}
+}
+
+func TestKataConfigStatusNodes(t *testing.T) {
+	status := v1.KataConfigStatus{TotalNodesCount: 4}
+	status.InstallationStatus.Completed.CompletedNodesList = []string{"worker-0", "worker-1", "worker-3"}
+	status.InstallationStatus.Degraded.FailedNodesList = []v1.FailedNodeStatus{{Name: "worker-3", Error: "/dev/kvm not found"}}
+	status.InstallationStatus.Failed.FailedNodesList = []v1.FailedNodeStatus{{Name: "worker-2", Error: "boom"}}
+	status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList = []string{"worker-1"}
+	status.UnInstallationStatus.DepartedNodesList = []string{"worker-4"}
+	status.InstallationStatus.SmokeTest.VerifiedNodesList = []string{"worker-0"}
+	checksums := map[string]string{"/usr/bin/containerd-shim-kata-v2": "abc"}
+	status.InstallationStatus.Artifacts = []v1.KataNodeArtifacts{{Name: "worker-0", Checksums: checksums}}
+	duration := metav1.Duration{Duration: 7 * time.Minute}
+	status.InstallationStatus.InstallDurations = []v1.KataNodeInstallDuration{{Name: "worker-0", Duration: duration}}
+
+	converted := KataConfigStatus{}
+	convertStatusFromV1(&status, &converted)
+
+	verified := true
+	expected := []KataNodeStatus{
+		{Name: "worker-0", Phase: NodeInstalled, Verified: &verified, Checksums: checksums, InstallDuration: &duration},
+		{Name: "worker-1", Phase: NodeUninstalling},
+		{Name: "worker-3", Phase: NodeDegraded, Error: "/dev/kvm not found"},
+		{Name: "worker-2", Phase: NodeInstallFailed, Error: "boom"},
+		{Name: "worker-4", Phase: NodeDeparted},
+	}
+	if !reflect.DeepEqual(expected, converted.Nodes) {
+		t.Errorf("unexpected nodes %+v", converted.Nodes)
+	}
+
+	back := v1.KataConfigStatus{}
+	convertStatusToV1(&converted, &back)
+	if !reflect.DeepEqual(status.InstallationStatus.Degraded.FailedNodesList, back.InstallationStatus.Degraded.FailedNodesList) ||
+		!reflect.DeepEqual([]string{"worker-0", "worker-3"}, back.InstallationStatus.Completed.CompletedNodesList) ||
+		!reflect.DeepEqual(status.InstallationStatus.Artifacts, back.InstallationStatus.Artifacts) ||
+		!reflect.DeepEqual(status.InstallationStatus.InstallDurations, back.InstallationStatus.InstallDurations) ||
+		!reflect.DeepEqual(status.UnInstallationStatus.DepartedNodesList, back.UnInstallationStatus.DepartedNodesList) {
+		t.Errorf("node status lost in the round trip: %+v", back.InstallationStatus)
+	}
+}
+
+func TestKataConfigStatusRoundTripFromV1(t *testing.T) {
+	now := metav1.NewTime(time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local))
+	failed := []v1.FailedNodeStatus{{Name: "worker-2", Error: "boom"}}
+	payload := v1.KataResolvedPayload{Architecture: "amd64", Name: "kata-3.0", KataVersion: "3.0.0", Image: "quay.io/kata/payload:3.0"}
+	original := &v1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status: v1.KataConfigStatus{
+			RuntimeClass:       "kata",
+			KataImage:          "quay.io/kata/deploy:latest",
+			TotalNodesCount:    6,
+			Platform:           "AWS",
+			InstallMode:        v1.InstallModePeerPods,
+			ObservedGeneration: 3,
+			InstallationStatus: v1.KataInstallationStatus{
+				InProgress: v1.KataInstallationInProgressStatus{InProgressNodesCount: 1, BinariesInstalledNodesList: []string{"worker-5"}},
+				Completed:  v1.KataConfigCompletedStatus{CompletedNodesCount: 2, CompletedNodesList: []string{"worker-0", "worker-1"}},
+				Failed:     v1.KataFailedNodeStatus{FailedNodesCount: 1, FailedNodesList: failed},
+				SmokeTest: v1.KataSmokeTestStatus{
+					VerifiedNodesList: []string{"worker-0"},
+					FailedNodesList:   []v1.FailedNodeStatus{{Name: "worker-1", Error: "sandbox didn't start"}},
+				},
+				Degraded:         v1.KataFailedNodeStatus{FailedNodesCount: 1, FailedNodesList: []v1.FailedNodeStatus{{Name: "worker-1", Error: "/dev/kvm not found"}}},
+				Artifacts:        []v1.KataNodeArtifacts{{Name: "worker-0", Checksums: map[string]string{"/usr/bin/containerd-shim-kata-v2": "abc"}}},
+				InstallDurations: []v1.KataNodeInstallDuration{{Name: "worker-0", Duration: metav1.Duration{Duration: 7 * time.Minute}}},
+			},
+			UnInstallationStatus: v1.KataUnInstallationStatus{
+				InProgress:        v1.KataUnInstallationInProgressStatus{InProgressNodesCount: 1, BinariesUnInstalledNodesList: []string{"worker-3"}},
+				Completed:         v1.KataConfigCompletedStatus{CompletedNodesCount: 1, CompletedNodesList: []string{"worker-4"}},
+				Failed:            v1.KataFailedNodeStatus{FailedNodesCount: 1, FailedNodesList: []v1.FailedNodeStatus{{Name: "worker-6", Error: "busy"}}},
+				DepartedNodesList: []string{"worker-7"},
+				Report: &v1.KataUninstallReport{
+					RuntimeClassRemoved: true,
+					VerifiedNodesList:   []string{"worker-4"},
+					LeftoverNodesList:   []v1.FailedNodeStatus{{Name: "worker-6", Error: "/etc/crio/crio.conf.d/50-kata"}},
+				},
+			},
+			Upgradestatus: v1.KataUpgradeStatus{
+				Channel:            v1.KataChannelCandidate,
+				Payloads:           []v1.KataResolvedPayload{payload},
+				InstalledPayloads:  []v1.KataResolvedPayload{payload},
+				UpgradingNodesList: []string{"worker-0"},
+			},
+			History: []v1.KataHistoryEvent{{Time: now, Action: v1.HistoryMachineConfigCreated, Generation: 3, Message: "50-enable-sandboxed-containers-extension"}},
+			DryRun: &v1.KataDryRunReport{
+				Time: now, Generation: 3, NodesCount: 1, Nodes: []string{"worker-0"},
+				IneligibleNodes:   []v1.FailedNodeStatus{{Name: "worker-8", Error: "no /dev/kvm"}},
+				MachineConfigPool: "kata-oc",
+				Files:             map[string]string{"/etc/crio/crio.conf.d/50-kata": "[crio.runtime.runtimes.kata]"},
+				Disruption:        "1 node rebooted",
+			},
+			TEECapabilities: []v1.KataNodeTEECapabilities{{NodeName: "worker-0", SNPEnabled: true, FirmwareVersion: "1.55", MaxGuests: 509}},
+			PeerPods: &v1.KataPeerPodsStatus{
+				PodVMImage:       &v1.KataPodVMImageStatus{ID: "ami-0123", Provider: "aws", Payload: "quay.io/kata/podvm:3.0", Created: &now},
+				StaleImages:      []string{"ami-0042"},
+				LastVMCollection: &now,
+				OrphanedVMs:      2,
+			},
+			Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionTrue, Reason: "AsExpected", LastTransitionTime: now}},
+		},
+	}
+
+	converted := &KataConfig{}
+	if err := converted.ConvertFrom(original.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	// the status written through v2 keeps the v1 fields it can't see
+	converted.Status.ObservedGeneration = 4
+	back := &v1.KataConfig{}
+	if err := converted.ConvertTo(back); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := original.Status.DeepCopy()
+	expected.ObservedGeneration = 4
+	if !reflect.DeepEqual(*expected, back.Status) {
+		t.Errorf("status changed in the round trip:\n%+v\n%+v", *expected, back.Status)
+	}
+	if len(back.Annotations) != 0 {
+		t.Errorf("conversion annotations leaked: %v", back.Annotations)
+	}
+}
+
+func TestKataConfigUnmappedStatusRoundTrip(t *testing.T) {
+	now := metav1.NewTime(time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local))
+	payload := v1.KataResolvedPayload{Architecture: "amd64", Name: "kata-3.0", KataVersion: "3.0.0", Image: "quay.io/kata/payload:3.0"}
+
+	tests := []struct {
+		name   string
+		status func(*v1.KataConfigStatus)
+	}{
+		{name: "platform", status: func(s *v1.KataConfigStatus) { s.Platform = "AWS" }},
+		{name: "install mode", status: func(s *v1.KataConfigStatus) { s.InstallMode = v1.InstallModePeerPods }},
+		{name: "upgrade status", status: func(s *v1.KataConfigStatus) {
+			s.Upgradestatus = v1.KataUpgradeStatus{
+				Channel:            v1.KataChannelCandidate,
+				Payloads:           []v1.KataResolvedPayload{payload},
+				InstalledPayloads:  []v1.KataResolvedPayload{payload},
+				UpgradingNodesList: []string{"worker-0"},
+			}
+		}},
+		{name: "dry run", status: func(s *v1.KataConfigStatus) {
+			s.DryRun = &v1.KataDryRunReport{Time: now, Generation: 3, NodesCount: 1, Nodes: []string{"worker-0"},
+				MachineConfigPool: "kata-oc", Disruption: "1 node rebooted"}
+		}},
+		{name: "TEE capabilities", status: func(s *v1.KataConfigStatus) {
+			s.TEECapabilities = []v1.KataNodeTEECapabilities{{NodeName: "worker-0", SNPEnabled: true, FirmwareVersion: "1.55", MaxGuests: 509}}
+		}},
+		{name: "peer pods", status: func(s *v1.KataConfigStatus) {
+			s.PeerPods = &v1.KataPeerPodsStatus{
+				PodVMImage:  &v1.KataPodVMImageStatus{ID: "ami-0123", Provider: "aws", Payload: "quay.io/kata/podvm:3.0", Created: &now},
+				StaleImages: []string{"ami-0042"},
+				OrphanedVMs: 2,
+			}
+		}},
+		{name: "uninstall report", status: func(s *v1.KataConfigStatus) {
+			s.UnInstallationStatus.Report = &v1.KataUninstallReport{RuntimeClassRemoved: true, VerifiedNodesList: []string{"worker-4"}}
+		}},
+	}
+
+	for _, test := range tests {
+		original := &v1.KataConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+			Status:     v1.KataConfigStatus{RuntimeClass: "kata", ObservedGeneration: 3},
+		}
+		test.status(&original.Status)
+
+		converted := &KataConfig{}
+		if err := converted.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, ok := converted.Annotations[v1StatusAnnotation]; !ok {
+			t.Errorf("%s: expected the v1 status to be kept in the %s annotation", test.name, v1StatusAnnotation)
+		}
+		// the status written through v2 keeps the v1 fields it can't see
+		converted.Status.ObservedGeneration = 4
+		back := &v1.KataConfig{}
+		if err := converted.ConvertTo(back); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		expected := original.Status.DeepCopy()
+		expected.ObservedGeneration = 4
+		if !reflect.DeepEqual(*expected, back.Status) {
+			t.Errorf("%s: status changed in the round trip:\n%+v\n%+v", test.name, *expected, back.Status)
+		}
+	}
+
+	// nothing is stored without an unmapped field
+	converted := &KataConfig{}
+	if err := converted.ConvertFrom(&v1.KataConfig{Status: v1.KataConfigStatus{RuntimeClass: "kata"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := converted.Annotations[v1StatusAnnotation]; ok {
+		t.Errorf("unexpected %s annotation without an unmapped status field", v1StatusAnnotation)
+	}
+}
@@ -0,0 +1,299 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// KataConfigSpec defines the desired state of KataConfig
+type KataConfigSpec struct {
+	// KataConfigPoolSelector is used to filter the worker nodes
+	// if not specified, all worker nodes are selected
+	// +optional
+	// +nullable
+	KataConfigPoolSelector *metav1.LabelSelector `json:"kataConfigPoolSelector,omitempty"`
+
+	// KataConfigPoolSelectors select the nodes matching any of them, e.g. the nodes of several
+	// roles. Can't be used with the KataConfigPoolSelector
+	// +optional
+	KataConfigPoolSelectors []metav1.LabelSelector `json:"kataConfigPoolSelectors,omitempty"`
+
+	// AllowControlPlaneNodes lets the KataConfigPoolSelector match control plane nodes, which
+	// are rebooted to install kata. Only needed when the cluster has other nodes
+	// +optional
+	AllowControlPlaneNodes bool `json:"allowControlPlaneNodes,omitempty"`
+
+	// DryRun previews the installation instead of doing it, the preview is reported in the
+	// status of the v1 KataConfig
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Payload selects the images delivering the kata binaries
+	// +optional
+	Payload KataPayloadConfig `json:"payload,omitempty"`
+
+	// Hypervisor holds the settings of the hypervisor and the shim on the nodes
+	// +optional
+	// +nullable
+	Hypervisor *KataHypervisorConfig `json:"hypervisor,omitempty"`
+
+	// Confidential enables kata sandboxes backed by a hardware trusted execution environment
+	// +optional
+	// +nullable
+	Confidential *KataConfidentialConfig `json:"confidential,omitempty"`
+
+	// Rollout controls how the installation is rolled out across the nodes
+	// +optional
+	// +nullable
+	Rollout *KataRolloutPolicy `json:"rollout,omitempty"`
+}
+
+// KataPayloadConfig selects the images delivering the kata binaries
+type KataPayloadConfig struct {
+	// SourceImage is the name of the kata-deploy image
+	// +optional
+	SourceImage string `json:"sourceImage,omitempty"`
+
+	// Images maps a node architecture to the kata payload image installed on it
+	// +optional
+	Images map[string]string `json:"images,omitempty"`
+
+	// Delivery selects how the kata binaries reach the nodes, DaemonSet by default
+	// +optional
+	Delivery PayloadDelivery `json:"delivery,omitempty"`
+
+	// Channel installs the newest payload of the KataPayload catalog released in the channel,
+	// Images take precedence over it
+	// +optional
+	Channel KataChannel `json:"channel,omitempty"`
+}
+
+// KataChannel is a release channel of the kata payloads
+// +kubebuilder:validation:Enum=stable;candidate
+type KataChannel string
+
+// PayloadDelivery is the way the kata binaries are delivered to the nodes
+// +kubebuilder:validation:Enum=DaemonSet;Extension
+type PayloadDelivery string
+
+// SELinuxMode is the SELinux mode applied to the kata shim domain
+// +kubebuilder:validation:Enum=enforcing;permissive
+type SELinuxMode string
+
+// KataHypervisorConfig holds the settings of the hypervisor and the shim on the nodes
+type KataHypervisorConfig struct {
+	// SELinuxShimMode is the SELinux mode of the kata shim domain, enforcing by default
+	// +optional
+	SELinuxShimMode SELinuxMode `json:"selinuxShimMode,omitempty"`
+
+	// DefaultMemory of the guests in MiB, 2048 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=256
+	DefaultMemory int32 `json:"defaultMemory,omitempty"`
+
+	// DefaultVCPUs of the guests, 1 by default
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	DefaultVCPUs int32 `json:"defaultVCPUs,omitempty"`
+}
+
+// TEE is a hardware trusted execution environment technology
+// +kubebuilder:validation:Enum=pef;snp;tdx;se
+type TEE string
+
+// KataConfidentialConfig holds the settings for confidential kata sandboxes, which get a
+// kata-cc RuntimeClass of their own
+type KataConfidentialConfig struct {
+	// Enabled turns on confidential guests on the selected nodes
+	Enabled bool `json:"enabled"`
+
+	// TEE is the trusted execution environment used for confidential guests
+	TEE TEE `json:"tee"`
+
+	// Overhead is the pod overhead of the kata-cc RuntimeClass. Computed from the hypervisor
+	// settings if unset
+	// +optional
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+
+	// SchedulingNodeSelector is the node selector of the kata-cc RuntimeClass
+	// +optional
+	SchedulingNodeSelector map[string]string `json:"schedulingNodeSelector,omitempty"`
+}
+
+// KataRolloutPolicy controls how the installation is rolled out across the nodes
+type KataRolloutPolicy struct {
+	// MaxUnavailable is the number or percentage of nodes updated at the same time
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// Paused holds any further node disruption until unset
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Schedule restricts the creation and the updates of the kata MachineConfig, which reboot
+	// the nodes, to a recurring maintenance window. No restriction if unset
+	// +optional
+	// +nullable
+	Schedule *KataMaintenanceWindow `json:"schedule,omitempty"`
+}
+
+// KataMaintenanceWindow is a recurring window in which the disruptive changes may start
+type KataMaintenanceWindow struct {
+	// Start is the cron expression of the opening of the window, e.g. "0 2 * * 6" for
+	// Saturdays at 2:00
+	Start string `json:"start"`
+
+	// Duration of the window, e.g. 4h
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone of Start, e.g. Europe/Paris. UTC by default
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// KataNodePhase is the phase of the kata lifecycle a node is in
+type KataNodePhase string
+
+const (
+	// NodeInstalling is set while the kata binaries are being installed on the node
+	NodeInstalling KataNodePhase = "Installing"
+	// NodeInstalled is set once kata is installed and configured on the node
+	NodeInstalled KataNodePhase = "Installed"
+	// NodeInstallFailed is set when the installation failed on the node
+	NodeInstallFailed KataNodePhase = "InstallFailed"
+	// NodeDegraded is set when an installed node fails its health probes, the error tells why
+	NodeDegraded KataNodePhase = "Degraded"
+	// NodeUninstalling is set while kata is being removed from the node
+	NodeUninstalling KataNodePhase = "Uninstalling"
+	// NodeUninstalled is set once kata is removed from the node
+	NodeUninstalled KataNodePhase = "Uninstalled"
+	// NodeUninstallFailed is set when the uninstallation failed on the node
+	NodeUninstallFailed KataNodePhase = "UninstallFailed"
+	// NodeDeparted is set while kata is being removed from a node that left the kata pool
+	NodeDeparted KataNodePhase = "Departed"
+)
+
+// KataNodeStatus is the kata status of a single node
+type KataNodeStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Phase of the kata lifecycle the node is in
+	Phase KataNodePhase `json:"phase"`
+
+	// Error reported by the daemon for a failed or degraded node
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// Verified is the outcome of the smoke test pod run on the node once installed, unset
+	// until it completes
+	// +optional
+	Verified *bool `json:"verified,omitempty"`
+
+	// VerificationError tells why the smoke test pod failed
+	// +optional
+	VerificationError string `json:"verificationError,omitempty"`
+
+	// Checksums are the SHA256 checksums of the kata binaries installed on the node, by path
+	// +optional
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// InstallDuration is how long the installation of the node took, reboot included
+	// +optional
+	InstallDuration *metav1.Duration `json:"installDuration,omitempty"`
+}
+
+// KataHistoryEvent is an entry of the KataConfig audit log
+type KataHistoryEvent struct {
+	// Time the action was taken
+	Time metav1.Time `json:"time"`
+
+	// Action taken by the operator
+	Action string `json:"action"`
+
+	// Generation of the KataConfig spec that caused the action
+	Generation int64 `json:"generation"`
+
+	// Message gives the details of the action
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// KataConfigStatus defines the observed state of KataConfig
+type KataConfigStatus struct {
+	// RuntimeClass is the name of the runtime class used in CRIO configuration
+	// +optional
+	RuntimeClass string `json:"runtimeClass,omitempty"`
+
+	// KataImage is the image used for delivering kata binaries
+	// +optional
+	KataImage string `json:"kataImage,omitempty"`
+
+	// TotalNodesCount is the total number of worker nodes targeted by this CR
+	// +optional
+	TotalNodesCount int `json:"totalNodesCount,omitempty"`
+
+	// ObservedGeneration is the KataConfig generation last rolled out to the nodes
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Nodes is the kata status of every node targeted by this CR
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Nodes []KataNodeStatus `json:"nodes,omitempty"`
+
+	// History is a bounded audit log of the significant actions taken by the operator,
+	// the oldest entries are dropped first
+	// +optional
+	History []KataHistoryEvent `json:"history,omitempty"`
+
+	// Conditions reflect the latest observations of the KataConfig state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// KataConfig is the Schema for the kataconfigs API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=kataconfigs,scope=Cluster
+type KataConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec   KataConfigSpec   `json:"spec,omitempty"`
+	Status KataConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KataConfigList contains a list of KataConfig
+type KataConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KataConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KataConfig{}, &KataConfigList{})
+}
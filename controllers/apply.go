@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fieldManager is the server-side apply field manager of the objects rendered by the operator
+const fieldManager = "kata-operator"
+
+// applyObject creates or updates obj with server-side apply. The fields set by the operator
+// are taken back when someone else changed them, fields the operator doesn't set are left
+// alone. obj must have its TypeMeta set, it is updated with the object stored by the API server.
+// In render mode the MachineConfigs, MachineConfigPools and RuntimeClasses are written into the
+// render ConfigMap instead, and obj is left as is
+func (r *KataConfigOpenShiftReconciler) applyObject(obj runtime.Object) error {
+	if key, ok := renderKey(obj); ok && r.rendering() {
+		manifest, err := r.renderManifest(obj)
+		if err != nil {
+			return err
+		}
+		return r.updateRenderConfigMap(key, manifest)
+	}
+	return r.Client.Patch(r.ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// equalRawJSON compares two JSON documents regardless of their formatting and key order
+func equalRawJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+	return equality.Semantic.DeepEqual(va, vb)
+}
+
+// hasLabels returns true if labels contains all the wanted labels
+func hasLabels(labels, wanted map[string]string) bool {
+	for k, v := range wanted {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
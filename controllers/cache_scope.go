@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// podListPageSize bounds the pods held in memory when the kata pods are listed from the API server
+const podListPageSize = 500
+
+// operatorScoped tells whether obj is of the kinds only cached in the operator namespace with the
+// scoped cache. There can be hundreds of thousands of them cluster-wide
+func operatorScoped(obj runtime.Object) bool {
+	switch obj.(type) {
+	case *corev1.Pod, *corev1.PodList,
+		*appsv1.DaemonSet, *appsv1.DaemonSetList,
+		*corev1.ConfigMap, *corev1.ConfigMapList,
+		*corev1.Secret, *corev1.SecretList:
+		return true
+	}
+	return false
+}
+
+// NewOperatorCache returns the cache of the operator namespace backing the scoped cache. It must
+// be added to the manager
+func NewOperatorCache(config *rest.Config, scheme *runtime.Scheme) (cache.Cache, error) {
+	return cache.New(config, cache.Options{Scheme: scheme, Namespace: operatorNamespace})
+}
+
+// NewScopedClientFunc returns the client of the manager for the scoped cache. The pods,
+// daemonsets, configmaps and secrets of the operator namespace are read from operatorCache, the
+// ones of the other namespaces straight from the API server, so that the manager cache never
+// holds them cluster-wide. The other objects are read from the manager cache as usual
+func NewScopedClientFunc(operatorCache cache.Cache) manager.NewClientFunc {
+	return func(managerCache cache.Cache, config *rest.Config, options client.Options) (client.Client, error) {
+		apiClient, err := client.New(config, options)
+		if err != nil {
+			return nil, err
+		}
+		return &scopedClient{
+			Client: &client.DelegatingClient{
+				Reader:       &client.DelegatingReader{CacheReader: managerCache, ClientReader: apiClient},
+				Writer:       apiClient,
+				StatusClient: apiClient,
+			},
+			operatorCache: operatorCache,
+			api:           apiClient,
+		}, nil
+	}
+}
+
+// scopedClient reads the operator scoped kinds from the operator cache or the API server
+type scopedClient struct {
+	client.Client
+	operatorCache cache.Cache
+	api           client.Reader
+}
+
+// reader returns the reader of the operator scoped kinds in the namespace
+func (c *scopedClient) reader(namespace string) client.Reader {
+	if namespace == operatorNamespace {
+		return c.operatorCache
+	}
+	return c.api
+}
+
+// Get reads obj from the operator cache or the API server if it is of an operator scoped kind
+func (c *scopedClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if !operatorScoped(obj) {
+		return c.Client.Get(ctx, key, obj)
+	}
+	return c.reader(key.Namespace).Get(ctx, key, obj)
+}
+
+// List lists from the operator cache or the API server if list is of an operator scoped kind
+func (c *scopedClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if !operatorScoped(list) {
+		return c.Client.List(ctx, list, opts...)
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	return c.reader(listOpts.Namespace).List(ctx, list, opts...)
+}
+
+// listPodsByRuntimeClass lists the pods of the runtime class from the API server, page by page.
+// The API server can't select the pods by runtime class, they are filtered here
+func (r *KataConfigOpenShiftReconciler) listPodsByRuntimeClass(runtimeClassName string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	listOpts := &client.ListOptions{Limit: podListPageSize}
+	for {
+		podList := &corev1.PodList{}
+		if err := r.Client.List(r.ctx, podList, listOpts); err != nil {
+			return nil, err
+		}
+		for _, pod := range podList.Items {
+			if pod.Spec.RuntimeClassName != nil && *pod.Spec.RuntimeClassName == runtimeClassName {
+				pods = append(pods, pod)
+			}
+		}
+		if podList.Continue == "" {
+			return pods, nil
+		}
+		listOpts.Continue = podList.Continue
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// incompatiblePayloads returns the payload images of the catalog that don't support the
+// OpenShift version of the cluster, with the versions they support. The images missing from
+// the catalog are not checked
+func incompatiblePayloads(catalog []kataconfigurationv1.KataPayload, images []string, clusterVersion *version.Version) []string {
+	var incompatible []string
+	for _, image := range images {
+		for i := range catalog {
+			payload := &catalog[i]
+			if payload.Spec.Image != image || supportsOpenShift(payload, clusterVersion) {
+				continue
+			}
+			incompatible = append(incompatible, fmt.Sprintf("%s (%s) supports OpenShift %s",
+				payload.Name, image, strings.Join(payload.Spec.OpenShiftVersions, ", ")))
+			break
+		}
+	}
+	sort.Strings(incompatible)
+	return incompatible
+}
+
+// checkClusterVersion holds the installation, and the changes to the installed nodes, while
+// a payload of the KataConfig doesn't support the OpenShift version of the cluster, e.g. a
+// payload resolved before the cluster was updated, or pinned by spec.payloadImages. The
+// incompatible payloads are reported in the IncompatibleClusterVersion condition. It returns
+// false while the installation is held
+func (r *KataConfigOpenShiftReconciler) checkClusterVersion() (bool, error) {
+	clusterVersion, err := r.clusterOpenShiftVersion()
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	catalog := &kataconfigurationv1.KataPayloadList{}
+	if err := r.Client.List(r.ctx, catalog); err != nil {
+		return false, err
+	}
+
+	var images []string
+	for _, image := range r.payloadImages() {
+		images = append(images, image)
+	}
+	if source := r.kataConfig.Spec.Config.SourceImage; source != "" {
+		images = append(images, source)
+	}
+	incompatible := incompatiblePayloads(catalog.Items, images, clusterVersion)
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigIncompatibleClusterVersion,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: fmt.Sprintf("the kata payloads support OpenShift %d.%d", clusterVersion.Major(), clusterVersion.Minor()),
+	}
+	if len(incompatible) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "UnsupportedPayload"
+		condition.Message = fmt.Sprintf("the cluster runs OpenShift %d.%d, the installation is held: %s",
+			clusterVersion.Major(), clusterVersion.Minor(), strings.Join(incompatible, "; "))
+	}
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Log.Info("The kata payloads don't support the OpenShift version of the cluster", "incompatible", incompatible)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, kataconfigurationv1.KataConfigIncompatibleClusterVersion, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return len(incompatible) == 0, nil
+}
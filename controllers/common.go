@@ -1,14 +1,27 @@
 package controllers
 
 import (
+	"context"
+	"time"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 // DaemonOperation represents the operation kata daemon is going to perform
 type DaemonOperation string
 
 const (
+	// PrePullOperation denotes the pull of the kata payload into the node cache
+	PrePullOperation DaemonOperation = "prepull"
+
 	// InstallOperation denotes kata installation operation
 	InstallOperation DaemonOperation = "install"
 
@@ -18,7 +31,35 @@ const (
 	// UpgradeOperation denotes kata upgrade operation
 	UpgradeOperation DaemonOperation = "upgrade"
 
+	// ReloadOperation denotes the update of the CRI-O settings applied without rebooting
+	ReloadOperation DaemonOperation = "reload"
+
+	// NodeConfigOperation denotes the merge of the KataNodeConfigs into the kata configuration
+	// of the node
+	NodeConfigOperation DaemonOperation = "nodeconfig"
+
+	// VerifyOperation denotes the check for kata leftovers once kata is uninstalled
+	VerifyOperation DaemonOperation = "verify"
+
+	// MonitorOperation denotes running kata-monitor, exposing the metrics of the kata sandboxes
+	MonitorOperation DaemonOperation = "monitor"
+
+	// daemonOperationLabel is the operation of the kata daemonsets, the install and uninstall
+	// ones have a daemonset per architecture
+	daemonOperationLabel = "kataconfiguration.openshift.io/daemon-operation"
+
 	kataConfigFinalizer = "finalizer.kataconfiguration.openshift.io"
+
+	// podRuntimeClassNameField indexes the pods by the name of their runtime class
+	podRuntimeClassNameField = "spec.runtimeClassName"
+
+	// nodeKataConfigField indexes the nodes by the KataConfig their daemon reports the progress
+	// for, and nodeKataRuntimeField the nodes labeled with the kata runtime
+	nodeKataConfigField  = "metadata.annotations.kataconfig"
+	nodeKataRuntimeField = "metadata.labels.kata-runtime"
+
+	// machineListPageSize bounds the machines held in memory when listed from the API server
+	machineListPageSize = 200
 )
 
 func contains(list []string, s string) bool {
@@ -30,6 +71,15 @@ func contains(list []string, s string) bool {
 	return false
 }
 
+// runtimeClassNodeSelector returns the node selector a kata RuntimeClass schedules the kata
+// pods with: the given one set in the KataConfig, or else the default
+func runtimeClassNodeSelector(nodeSelector, defaultSelector map[string]string) map[string]string {
+	if len(nodeSelector) > 0 {
+		return nodeSelector
+	}
+	return defaultSelector
+}
+
 func getClientSet() (*kubernetes.Clientset, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", "")
 	if err != nil {
@@ -43,3 +93,103 @@ func getClientSet() (*kubernetes.Clientset, error) {
 
 	return clientset, nil
 }
+
+// shutdownContext returns a context that is cancelled once the manager is asked to stop,
+// e.g. on SIGTERM, so that long running reconciliations give up instead of being killed
+// half way through a status update
+func shutdownContext(mgr ctrl.Manager) (context.Context, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		<-stop
+		cancel()
+		return nil
+	}))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// sleepWithContext pauses for the given duration, returning the context error early if
+// the context is cancelled in the meantime
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// indexPodRuntimeClassName adds an index of the pods by runtime class name to the manager
+// cache, so that the pods running kata can be found without listing every pod of the cluster
+func indexPodRuntimeClassName(mgr ctrl.Manager) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podRuntimeClassNameField,
+		func(obj runtime.Object) []string {
+			pod := obj.(*corev1.Pod)
+			if pod.Spec.RuntimeClassName == nil {
+				return nil
+			}
+			return []string{*pod.Spec.RuntimeClassName}
+		})
+}
+
+// indexNodes adds the indexes of the kata nodes to the manager cache, so that every reconcile
+// handles the nodes of the KataConfig rather than every node of the cluster
+func indexNodes(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Node{}, nodeKataConfigField, nodeKataConfigIndex)
+	if err != nil {
+		return err
+	}
+	return mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Node{}, nodeKataRuntimeField, nodeKataRuntimeIndex)
+}
+
+// nodeKataConfigIndex indexes a node by the KataConfig its daemon reports the progress for
+func nodeKataConfigIndex(obj runtime.Object) []string {
+	name, ok := obj.(*corev1.Node).Annotations[nodeprogress.KataConfigAnnotation]
+	if !ok {
+		return nil
+	}
+	return []string{name}
+}
+
+// nodeKataRuntimeIndex indexes the nodes labeled with the kata runtime
+func nodeKataRuntimeIndex(obj runtime.Object) []string {
+	if _, ok := obj.(*corev1.Node).Labels[kataRuntimeLabel]; !ok {
+		return nil
+	}
+	return []string{"true"}
+}
+
+// listReportingNodes lists the nodes whose daemon reports its progress for the KataConfig
+func (r *KataConfigOpenShiftReconciler) listReportingNodes() ([]corev1.Node, error) {
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList, client.MatchingFields{nodeKataConfigField: r.kataConfig.Name}); err != nil {
+		return nil, err
+	}
+	return nodesList.Items, nil
+}
+
+// listPages lists the objects of list from the API server page by page, for the objects the
+// manager cache doesn't hold
+func (r *KataConfigOpenShiftReconciler) listPages(list *unstructured.UnstructuredList, opts ...client.ListOption) ([]unstructured.Unstructured, error) {
+	var items []unstructured.Unstructured
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	listOpts.Limit = machineListPageSize
+	for {
+		page := list.DeepCopy()
+		if err := r.Client.List(r.ctx, page, listOpts); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.GetContinue() == "" {
+			return items, nil
+		}
+		listOpts.Continue = page.GetContinue()
+	}
+}
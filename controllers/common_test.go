@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// indexedClient lists the nodes by field as the manager cache indexes them, and the machines
+// page by page as the API server does, which the fake client doesn't. It counts the objects
+// the operator reads and the patches it writes
+type indexedClient struct {
+	client.Client
+	machines []unstructured.Unstructured
+
+	read, pages, patches int
+}
+
+var nodeIndexes = map[string]func(runtime.Object) []string{
+	nodeKataConfigField:  nodeKataConfigIndex,
+	nodeKataRuntimeField: nodeKataRuntimeIndex,
+}
+
+func (c *indexedClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.read++
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *indexedClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	if machines, ok := list.(*unstructured.UnstructuredList); ok {
+		start, _ := strconv.Atoi(listOpts.Continue)
+		end := len(c.machines)
+		machines.SetContinue("")
+		if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+			end = start + int(listOpts.Limit)
+			machines.SetContinue(strconv.Itoa(end))
+		}
+		machines.Items = append([]unstructured.Unstructured{}, c.machines[start:end]...)
+		c.pages++
+		c.read += end - start
+		return nil
+	}
+
+	selector := listOpts.FieldSelector
+	listOpts.FieldSelector = nil
+	if err := c.Client.List(ctx, list, listOpts); err != nil {
+		return err
+	}
+	if nodes, ok := list.(*corev1.NodeList); ok && selector != nil {
+		var indexed []corev1.Node
+		for i := range nodes.Items {
+			if nodeIndexed(&nodes.Items[i], selector) {
+				indexed = append(indexed, nodes.Items[i])
+			}
+		}
+		nodes.Items = indexed
+	}
+	c.read += meta.LenList(list)
+	return nil
+}
+
+func (c *indexedClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// nodeIndexed tells whether the indexes of the node match all the field requirements
+func nodeIndexed(node *corev1.Node, selector fields.Selector) bool {
+	for _, requirement := range selector.Requirements() {
+		if !contains(nodeIndexes[requirement.Field](node), requirement.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// newScaleReconciler returns a reconciler for a cluster of clusterSize workers, the first
+// poolSize of which completed the kata installation. All of them but the changed ones carry
+// the kata runtime label already
+func newScaleReconciler(b *testing.B, clusterSize, poolSize, changed int) (*KataConfigOpenShiftReconciler, *indexedClient) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+
+	kataConfig := &kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	var objects []runtime.Object
+	for i := 0; i < clusterSize; i++ {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("worker-%d", i),
+			Labels: map[string]string{"node-role.kubernetes.io/worker": ""},
+		}}
+		if i < poolSize {
+			kataConfig.Status.InstallationStatus.Completed.CompletedNodesList = append(
+				kataConfig.Status.InstallationStatus.Completed.CompletedNodesList, node.Name)
+			if i >= changed {
+				node.Labels[kataRuntimeLabel] = "true"
+			}
+		}
+		objects = append(objects, node)
+	}
+
+	c := &indexedClient{Client: fake.NewFakeClientWithScheme(scheme, objects...)}
+	return &KataConfigOpenShiftReconciler{
+		Client:     c,
+		Log:        ctrl.Log.WithName("benchmark"),
+		kataConfig: kataConfig,
+		ctx:        context.Background(),
+	}, c
+}
+
+// BenchmarkLabelKataRuntimeNodes reports the nodes read and patched to keep the kata runtime
+// label of a pool in sync: the reads follow the size of the pool, the patches the nodes that
+// changed, neither the size of the cluster. The time also grows with the cluster as the fake
+// client scans all the nodes to serve an indexed list, the manager cache doesn't
+func BenchmarkLabelKataRuntimeNodes(b *testing.B) {
+	for _, size := range []struct{ cluster, pool, changed int }{
+		{1000, 500, 0},
+		{1000, 500, 50},
+		{1000, 500, 500},
+		{5000, 500, 50},
+	} {
+		b.Run(fmt.Sprintf("cluster=%d/pool=%d/changed=%d", size.cluster, size.pool, size.changed), func(b *testing.B) {
+			var read, patches int
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				r, c := newScaleReconciler(b, size.cluster, size.pool, size.changed)
+				b.StartTimer()
+
+				if err := r.labelKataRuntimeNodes(); err != nil {
+					b.Fatal(err)
+				}
+				read, patches = c.read, c.patches
+			}
+			if patches != size.changed {
+				b.Errorf("expected %d nodes to be patched, got %d", size.changed, patches)
+			}
+			if read != size.pool {
+				b.Errorf("expected the %d nodes of the pool to be read, got %d", size.pool, read)
+			}
+			b.ReportMetric(float64(read), "nodes-read/op")
+			b.ReportMetric(float64(patches), "patches/op")
+		})
+	}
+}
+
+// BenchmarkListPages reports the pages the machines are listed in, bounding the machines held
+// in memory by a single response
+func BenchmarkListPages(b *testing.B) {
+	for _, count := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("machines=%d", count), func(b *testing.B) {
+			c := &indexedClient{}
+			for i := 0; i < count; i++ {
+				machine := unstructured.Unstructured{}
+				machine.SetGroupVersionKind(machineGVK)
+				machine.SetName(fmt.Sprintf("machine-%d", i))
+				machine.SetNamespace(machineAPINamespace)
+				c.machines = append(c.machines, machine)
+			}
+			r := &KataConfigOpenShiftReconciler{Client: c, ctx: context.Background()}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.pages = 0
+				list := &unstructured.UnstructuredList{}
+				list.SetGroupVersionKind(machineGVK.GroupVersion().WithKind("MachineList"))
+				machines, err := r.listPages(list, client.InNamespace(machineAPINamespace))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(machines) != count {
+					b.Fatalf("expected %d machines, got %d", count, len(machines))
+				}
+			}
+			if expected := (count + machineListPageSize - 1) / machineListPageSize; c.pages != expected {
+				b.Errorf("expected %d pages, got %d", expected, c.pages)
+			}
+			b.ReportMetric(float64(c.pages), "pages/op")
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	ignTypes "github.com/coreos/ignition/config/v2_2/types"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/vincent-petithory/dataurl"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// crioDropinDir holds the CRI-O drop-ins, merged in the lexical order of their names
+const crioDropinDir = "/etc/crio/crio.conf.d/"
+
+// kataCrioSettings returns the [crio.runtime] settings of the kata drop-ins, other drop-ins
+// may only set them to the same value
+func kataCrioSettings(kataConfig *kataconfigurationv1.KataConfig) map[string]interface{} {
+	settings := map[string]interface{}{
+		"manage_ns_lifecycle": true,
+	}
+	if conf := kataConfig.Spec.Crio; conf != nil && conf.LogLevel != "" {
+		settings["log_level"] = conf.LogLevel
+	}
+	return settings
+}
+
+// crioDropinConflicts returns the conflicts between a CRI-O drop-in and the kata drop-ins:
+// a kata runtime handler of its own, or different values for the kata [crio.runtime] settings
+func crioDropinConflicts(content string, handlers []string, settings map[string]interface{}) ([]string, error) {
+	var dropin struct {
+		Crio struct {
+			Runtime map[string]interface{} `toml:"runtime"`
+		} `toml:"crio"`
+	}
+	if _, err := toml.Decode(content, &dropin); err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	if runtimes, ok := dropin.Crio.Runtime["runtimes"].(map[string]interface{}); ok {
+		for _, handler := range handlers {
+			if _, ok := runtimes[handler]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("defines the %s runtime handler", handler))
+			}
+		}
+	}
+	for key, value := range settings {
+		if other, ok := dropin.Crio.Runtime[key]; ok && !reflect.DeepEqual(other, value) {
+			conflicts = append(conflicts, fmt.Sprintf("sets %s to %v", key, other))
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// machineConfigCrioConflicts returns the conflicts of the CRI-O drop-ins written by the MachineConfig
+func machineConfigCrioConflicts(mc *mcfgv1.MachineConfig, handlers []string, settings map[string]interface{}) ([]string, error) {
+	if len(mc.Spec.Config.Raw) == 0 {
+		return nil, nil
+	}
+
+	var ic ignTypes.Config
+	if err := json.Unmarshal(mc.Spec.Config.Raw, &ic); err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, file := range ic.Storage.Files {
+		if !strings.HasPrefix(file.Path, crioDropinDir) || file.Contents.Source == "" {
+			continue
+		}
+		content, err := dataurl.DecodeString(file.Contents.Source)
+		if err != nil {
+			// not inline, the content can't be checked
+			continue
+		}
+		fileConflicts, err := crioDropinConflicts(string(content.Data), handlers, settings)
+		if err != nil {
+			return nil, fmt.Errorf("machine config %s: invalid CRI-O drop-in %s: %v", mc.Name, file.Path, err)
+		}
+		for _, conflict := range fileConflicts {
+			conflicts = append(conflicts, fmt.Sprintf("machine config %s: %s %s", mc.Name, file.Path, conflict))
+		}
+	}
+	return conflicts, nil
+}
+
+// checkCrioConflicts looks for the MachineConfigs rendered into the kata pool that define CRI-O
+// settings conflicting with the kata drop-in, and reports them in the ConfigConflict condition.
+// It returns true when the kata drop-in must be held back
+func (r *KataConfigOpenShiftReconciler) checkCrioConflicts(machinePool string) (bool, error) {
+	mcs := &mcfgv1.MachineConfigList{}
+	if err := r.Client.List(r.ctx, mcs); err != nil {
+		return false, err
+	}
+
+	roles := []string{machinePool, r.kataPoolName(machinePool)}
+	settings := kataCrioSettings(r.kataConfig)
+	var conflicts []string
+	for i := range mcs.Items {
+		mc := &mcs.Items[i]
+		if mc.Name == kataMachineConfigName || !contains(roles, mc.Labels["machineconfiguration.openshift.io/role"]) {
+			continue
+		}
+		mcConflicts, err := machineConfigCrioConflicts(mc, handlerNames(kataHandlers(r.kataConfig)), settings)
+		if err != nil {
+			conflicts = append(conflicts, err.Error())
+			continue
+		}
+		conflicts = append(conflicts, mcConflicts...)
+	}
+
+	if len(conflicts) == 0 {
+		if meta.IsStatusConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigConfigConflict) {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+					Type:    kataconfigurationv1.KataConfigConfigConflict,
+					Status:  metav1.ConditionFalse,
+					Reason:  "AsExpected",
+					Message: "no CRI-O drop-in conflicts with the kata drop-in",
+				})
+			})
+		}
+		return false, nil
+	}
+
+	message := "the kata CRI-O drop-in is held back: " + strings.Join(conflicts, "; ")
+	r.Log.Info("Conflicting CRI-O drop-ins found", "conflicts", conflicts)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigConfigConflict,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ConflictingCrioDropin",
+			Message: message,
+		})
+	})
+	return true, nil
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Diagnostics serves the pprof endpoints and periodically logs the memory and goroutine stats
+// of the operator, to debug leaks during the long machine config pool waits of large clusters.
+// Both are off unless configured. It runs on every replica
+type Diagnostics struct {
+	// PprofAddr is the address of the pprof endpoints, disabled if empty
+	PprofAddr string
+
+	// StatsInterval is the interval of the runtime stats lines, disabled if zero
+	StatsInterval time.Duration
+
+	Log logr.Logger
+}
+
+var _ manager.Runnable = &Diagnostics{}
+var _ manager.LeaderElectionRunnable = &Diagnostics{}
+
+// NeedLeaderElection tells the manager to run the diagnostics on every replica
+func (d *Diagnostics) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the pprof endpoints and logs the stats until stop is closed. The pprof server
+// failing to listen doesn't stop the manager
+func (d *Diagnostics) Start(stop <-chan struct{}) error {
+	if d.PprofAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		server := &http.Server{Addr: d.PprofAddr, Handler: mux}
+		go func() {
+			d.Log.Info("Serving pprof", "addr", d.PprofAddr)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				d.Log.Error(err, "unable to serve pprof")
+			}
+		}()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+		}()
+	}
+
+	if d.StatsInterval <= 0 {
+		<-stop
+		return nil
+	}
+	ticker := time.NewTicker(d.StatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			d.logStats()
+		}
+	}
+}
+
+// logStats logs the heap, the memory obtained from the OS, the garbage collections and the
+// goroutines, a steady growth across the lines points at a leak
+func (d *Diagnostics) logStats() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	d.Log.Info("Runtime stats",
+		"goroutines", runtime.NumGoroutine(),
+		"heapAllocBytes", stats.HeapAlloc,
+		"heapObjects", stats.HeapObjects,
+		"sysBytes", stats.Sys,
+		"numGC", stats.NumGC,
+		"pauseTotal", time.Duration(stats.PauseTotalNs).String())
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const (
+	// mcoStateAnnotation is the state of the machine config daemon of a node, Working while it
+	// updates the node
+	mcoStateAnnotation = "machineconfiguration.openshift.io/state"
+
+	mcoCurrentConfigAnnotation = "machineconfiguration.openshift.io/currentConfig"
+	mcoDesiredConfigAnnotation = "machineconfiguration.openshift.io/desiredConfig"
+)
+
+// mcoUpdating tells whether the machine config operator is updating the node. The node is then
+// drained, rebooted and uncordoned, it flaps NotReady and unschedulable on the way
+func mcoUpdating(node *corev1.Node) bool {
+	annotations := node.GetAnnotations()
+	return annotations[mcoStateAnnotation] == "Working" ||
+		annotations[mcoCurrentConfigAnnotation] != annotations[mcoDesiredConfigAnnotation]
+}
+
+// coalescingHandler enqueues the requests mapped from the events once the window has passed.
+// The workqueue keeps a single request waiting, so the storm of events of a machine config
+// pool rollout, every node flapping, is coalesced into one reconcile per window
+type coalescingHandler struct {
+	mapper handler.Mapper
+	// window is the time.Duration to wait, read atomically as the operator config changes it
+	window *int64
+}
+
+var _ handler.EventHandler = &coalescingHandler{}
+
+// Create implements EventHandler
+func (h *coalescingHandler) Create(evt event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(q, handler.MapObject{Meta: evt.Meta, Object: evt.Object})
+}
+
+// Update implements EventHandler
+func (h *coalescingHandler) Update(evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(q, handler.MapObject{Meta: evt.MetaOld, Object: evt.ObjectOld})
+	h.enqueue(q, handler.MapObject{Meta: evt.MetaNew, Object: evt.ObjectNew})
+}
+
+// Delete implements EventHandler
+func (h *coalescingHandler) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(q, handler.MapObject{Meta: evt.Meta, Object: evt.Object})
+}
+
+// Generic implements EventHandler
+func (h *coalescingHandler) Generic(evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(q, handler.MapObject{Meta: evt.Meta, Object: evt.Object})
+}
+
+func (h *coalescingHandler) enqueue(q workqueue.RateLimitingInterface, object handler.MapObject) {
+	window := time.Duration(atomic.LoadInt64(h.window))
+	for _, req := range h.mapper.Map(object) {
+		if window > 0 {
+			q.AddAfter(req, window)
+		} else {
+			q.Add(req)
+		}
+	}
+}
+
+// setNodeEventWindow sets the window the node events are coalesced over
+func (r *KataConfigOpenShiftReconciler) setNodeEventWindow() {
+	atomic.StoreInt64(&r.nodeEventWindow, int64(r.Intervals.NodeEventCoalescing))
+}
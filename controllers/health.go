@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+const (
+	// DefaultFailureThreshold is the duration the reconciles, or the sync of the informers, must
+	// keep failing before the liveness probe fails and the operator is restarted
+	DefaultFailureThreshold = 15 * time.Minute
+
+	// informerSyncTimeout bounds the wait for an informer in a probe
+	informerSyncTimeout = time.Second
+)
+
+// ReconcileHealth tracks whether the reconciles keep failing. A reconcile that succeeds resets
+// it, the failures of a pass that is retried don't make the operator unhealthy by themselves
+type ReconcileHealth struct {
+	// FailureThreshold is the duration the reconciles must keep failing
+	FailureThreshold time.Duration
+
+	mu           sync.Mutex
+	failingSince time.Time
+	lastErr      error
+}
+
+// Observe records the outcome of a reconcile
+func (h *ReconcileHealth) Observe(err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.failingSince = time.Time{}
+		h.lastErr = nil
+		return
+	}
+	if h.failingSince.IsZero() {
+		h.failingSince = time.Now()
+	}
+	h.lastErr = err
+}
+
+// Check is the healthz check failing once the reconciles have kept failing for longer than
+// the threshold
+func (h *ReconcileHealth) Check(_ *http.Request) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failingSince.IsZero() || time.Since(h.failingSince) < h.FailureThreshold {
+		return nil
+	}
+	return fmt.Errorf("the reconciles keep failing since %s: %v", h.failingSince.Format(time.RFC3339), h.lastErr)
+}
+
+// InformerHealth checks that the informers of the objects the operator relies on, e.g. the
+// nodes and the machine config pools, are synced
+type InformerHealth struct {
+	Cache   cache.Cache
+	Objects []runtime.Object
+
+	// FailureThreshold is the duration the informers may stay out of sync before the
+	// healthz check fails, the readyz check fails right away
+	FailureThreshold time.Duration
+
+	mu             sync.Mutex
+	unsyncedSince  time.Time
+	unsyncedReason error
+}
+
+// synced returns an error naming the first informer that isn't synced
+func (h *InformerHealth) synced(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, informerSyncTimeout)
+	defer cancel()
+	for _, obj := range h.Objects {
+		informer, err := h.Cache.GetInformer(ctx, obj)
+		if err != nil {
+			return fmt.Errorf("the %T informer is not available: %v", obj, err)
+		}
+		if !informer.HasSynced() {
+			return fmt.Errorf("the %T informer is not synced", obj)
+		}
+	}
+	return nil
+}
+
+// Ready is the readyz check, failing while the informers are not synced
+func (h *InformerHealth) Ready(req *http.Request) error {
+	err := h.synced(req.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.unsyncedSince = time.Time{}
+	} else if h.unsyncedSince.IsZero() {
+		h.unsyncedSince = time.Now()
+	}
+	h.unsyncedReason = err
+	return err
+}
+
+// Healthy is the healthz check, failing once the informers have been out of sync for longer
+// than the threshold
+func (h *InformerHealth) Healthy(req *http.Request) error {
+	if err := h.Ready(req); err == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.unsyncedSince) < h.FailureThreshold {
+		return nil
+	}
+	return fmt.Errorf("out of sync since %s: %v", h.unsyncedSince.Format(time.RFC3339), h.unsyncedReason)
+}
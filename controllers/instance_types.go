@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// azureSize is the family and version of an Azure VM size, e.g. Standard_D8s_v3
+	azureSize = regexp.MustCompile(`^(?:Standard|Basic)_([A-Za-z]+)\d+([a-z-]*)(?:_v(\d+))?`)
+
+	// gcpNoNestedSeries are the GCE machine series without nested virtualization: the shared
+	// core E2 and the AMD and Arm ones
+	gcpNoNestedSeries = []string{"e2", "n2d", "c2d", "t2a", "t2d"}
+)
+
+// checkInstanceType tells whether the cloud instance type of a node exposes the hardware
+// virtualization kata needs: the AWS bare metal instances, the Azure sizes with nested
+// virtualization (the D and E series from v3, Fsv2 and M) and the GCE Intel machine series.
+// The instance types of the other platforms are not checked
+func checkInstanceType(platform configv1.PlatformType, instanceType string) error {
+	if instanceType == "" {
+		return nil
+	}
+
+	switch {
+	case platformIn(platform, []configv1.PlatformType{configv1.AWSPlatformType}):
+		if !strings.Contains(instanceType, ".metal") {
+			return fmt.Errorf("AWS instance type %s doesn't expose hardware virtualization, only the *.metal instances do", instanceType)
+		}
+	case platformIn(platform, []configv1.PlatformType{configv1.AzurePlatformType}):
+		match := azureSize.FindStringSubmatch(instanceType)
+		if match == nil {
+			return nil
+		}
+		family := strings.ToUpper(match[1][:1])
+		version, _ := strconv.Atoi(match[3])
+		switch {
+		case family == "M":
+		case family == "F" && strings.Contains(match[2], "s") && version >= 2:
+		case (family == "D" || family == "E") && version >= 3:
+		default:
+			return fmt.Errorf("Azure size %s doesn't support nested virtualization, use a Dv3, Ev3 or newer, Fsv2 or M size", instanceType)
+		}
+	case platformIn(platform, []configv1.PlatformType{configv1.GCPPlatformType}):
+		series := strings.SplitN(instanceType, "-", 2)[0]
+		for _, s := range gcpNoNestedSeries {
+			if series == s {
+				return fmt.Errorf("GCE machine type %s doesn't support nested virtualization, use an Intel machine series, e.g. n2", instanceType)
+			}
+		}
+	}
+	return nil
+}
+
+// failUnsupportedInstanceTypes reports the kata nodes whose instance type doesn't expose the
+// hardware virtualization as failed, with the UnsupportedInstanceType reason, before the
+// installation starts on them. The daemon doesn't install kata on such a node. The nodes
+// found with the VMX or SVM CPU flag by Node Feature Discovery are not checked
+func (r *KataConfigOpenShiftReconciler) failUnsupportedInstanceTypes(platform configv1.PlatformType, nodes []corev1.Node) error {
+	for i := range nodes {
+		node := &nodes[i]
+		if nodeprogress.Get(node, r.kataConfig.Name).State != "" || bareMetalNode(node) {
+			continue
+		}
+		checkErr := checkInstanceType(platform, node.GetLabels()[instanceTypeLabel])
+		if checkErr == nil {
+			continue
+		}
+
+		r.Log.Info("The instance type of the node can't run kata", "node", node.Name, "reason", checkErr.Error())
+		r.Recorder.Event(node, corev1.EventTypeWarning, nodeprogress.ReasonUnsupportedInstanceType, checkErr.Error())
+		patch, err := nodeprogress.Patch(nodeprogress.Progress{
+			KataConfig: r.kataConfig.Name,
+			State:      nodeprogress.InstallFailed,
+			Error:      checkErr.Error(),
+			Reason:     nodeprogress.ReasonUnsupportedInstanceType,
+		})
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
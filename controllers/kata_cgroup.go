@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeCgroupVersions returns the cgroup versions the nodes reported for the KataConfig, sorted
+func nodeCgroupVersions(kataConfigName string, nodes []corev1.Node) []string {
+	var versions []string
+	for i := range nodes {
+		version := nodeprogress.Get(&nodes[i], kataConfigName).Cgroup
+		if version != "" && !contains(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// sandboxCgroupOnly returns the sandbox_cgroup_only setting to render, nil to keep the kata
+// default. Unless set in the spec, it is enabled when all the nodes reported cgroup v2
+func sandboxCgroupOnly(kataConfig *kataconfigurationv1.KataConfig, cgroups []string) *bool {
+	if kataConfig.Spec.SandboxCgroupOnly != nil {
+		return kataConfig.Spec.SandboxCgroupOnly
+	}
+	if len(cgroups) == 1 && cgroups[0] == nodeprogress.CgroupV2 {
+		enabled := true
+		return &enabled
+	}
+	return nil
+}
+
+// checkCgroupCompatibility reports the kata nodes of the pool whose cgroup version doesn't
+// match the rendered configuration. A single drop-in is rendered for the pool, it can't
+// suit both cgroup versions, and without sandbox_cgroup_only kata can't account for the pods
+// on cgroup v2
+func (r *KataConfigOpenShiftReconciler) checkCgroupCompatibility(machinePool string) error {
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return err
+	}
+
+	byVersion := map[string][]string{}
+	for i := range nodes {
+		if version := nodeprogress.Get(&nodes[i], r.kataConfig.Name).Cgroup; version != "" {
+			byVersion[version] = append(byVersion[version], nodes[i].Name)
+		}
+	}
+	for _, names := range byVersion {
+		sort.Strings(names)
+	}
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigCgroupMismatch,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: "the kata configuration matches the cgroup version of the kata nodes",
+	}
+	cgroupOnly := r.kataConfig.Spec.SandboxCgroupOnly
+	switch {
+	case len(byVersion) > 1:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "MixedCgroupVersions"
+		condition.Message = fmt.Sprintf("the kata nodes run different cgroup versions, cgroup v1: %s; cgroup v2: %s. "+
+			"Move them to the same version for the pod resources to be accounted for the same way",
+			strings.Join(byVersion[nodeprogress.CgroupV1], ", "), strings.Join(byVersion[nodeprogress.CgroupV2], ", "))
+	case cgroupOnly != nil && !*cgroupOnly && len(byVersion[nodeprogress.CgroupV2]) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SandboxCgroupOnlyRequired"
+		condition.Message = fmt.Sprintf("sandboxCgroupOnly is disabled but %s run cgroup v2, which kata only supports with it enabled",
+			strings.Join(byVersion[nodeprogress.CgroupV2], ", "))
+	}
+
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Log.Info("The kata configuration doesn't match the cgroup version of the nodes", "reason", condition.Reason)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return nil
+}
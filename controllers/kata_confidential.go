@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	kataRuntimeClassName = "kata"
+
+	// kataCCRuntimeClassName is the RuntimeClass of the confidential kata sandboxes, next to
+	// the kata one of the regular sandboxes
+	kataCCRuntimeClassName = "kata-cc"
+
+	// kataCCConfigPath is the kata configuration of the confidential handler. The shim looks
+	// for the drop-ins in the config.d directory next to it
+	kataCCConfigPath       = "/etc/kata-containers/cc/configuration.toml"
+	kataCCConfigDropinPath = "/etc/kata-containers/cc/config.d/50-kata-operator.toml"
+
+	kataDefaultConfigPath = "/usr/share/kata-containers/defaults/configuration.toml"
+
+	kataCCConfigUnitName = "kata-cc-configuration.service"
+
+	// defaultKeyProvider is the ocicrypt key provider of the confidential guests, the
+	// attestation agent gets the keys from the key broker service
+	defaultKeyProvider = "attestation-agent"
+)
+
+// kataCCConfigUnit lays the default kata configuration under the configuration of the
+// confidential handler on every boot, so that it follows the updates of the kata packages
+const kataCCConfigUnit = `
+[Unit]
+Description=Lay the default kata configuration for the confidential kata handler
+ConditionPathExists=` + kataDefaultConfigPath + `
+Before=crio.service
+[Service]
+Type=oneshot
+ExecStart=/usr/bin/cp -f ` + kataDefaultConfigPath + ` ` + kataCCConfigPath + `
+[Install]
+WantedBy=multi-user.target
+`
+
+// crioHandler is a kata runtime handler of CRI-O
+type crioHandler struct {
+	Name string
+	// ConfigPath is the kata configuration of the handler, the default one if empty
+	ConfigPath string
+	// Shim is the shim binary, runtime type and runtime root of the handler
+	Shim kataconfigurationv1.KataShimConfig
+	// PullImage leaves the image pulls of the handler to kata, which pulls them in the guest
+	PullImage bool
+}
+
+// confidentialEnabled tells whether the KataConfig asks for confidential sandboxes
+func confidentialEnabled(kataConfig *kataconfigurationv1.KataConfig) bool {
+	return kataConfig.Spec.Confidential != nil && kataConfig.Spec.Confidential.Enabled
+}
+
+// guestPullEnabled tells whether the confidential sandboxes pull their images in the guest
+func guestPullEnabled(kataConfig *kataconfigurationv1.KataConfig) bool {
+	return confidentialEnabled(kataConfig) && kataConfig.Spec.Confidential.GuestPull
+}
+
+// agentKernelParams returns the kernel parameters configuring the kata agent of the
+// confidential guests: where their key broker service is, how they decrypt the images, and
+// the image security policy they enforce
+func agentKernelParams(kataConfig *kataconfigurationv1.KataConfig) []string {
+	conf := kataConfig.Spec.Confidential
+	var params []string
+	if conf.KBSURL != "" {
+		params = append(params, "agent.aa_kbc_params=cc_kbc::"+conf.KBSURL)
+	}
+	if encrypted := conf.EncryptedImages; encrypted != nil {
+		provider := encrypted.KeyProvider
+		if provider == "" {
+			provider = defaultKeyProvider
+		}
+		params = append(params, "agent.decrypt_config=provider:"+provider+":cc_kbc::"+conf.KBSURL)
+	}
+	if policy := conf.ImageSecurityPolicy; policy != nil {
+		params = append(params, "agent.enable_signature_verification=true", "agent.image_policy_file="+policy.PolicyURI)
+		if policy.SigstoreConfigURI != "" {
+			params = append(params, "agent.simple_signing_sigstore_config="+policy.SigstoreConfigURI)
+		}
+	}
+	return params
+}
+
+// confidentialRuntimeHandler returns the name of the kata runtime handler of CRI-O for the
+// confidential sandboxes
+func confidentialRuntimeHandler(kataConfig *kataconfigurationv1.KataConfig) string {
+	return runtimeHandler(kataConfig) + "-cc"
+}
+
+// kataHandlers returns the kata runtime handlers of CRI-O: the regular one, and the
+// confidential one when confidential sandboxes are enabled
+func kataHandlers(kataConfig *kataconfigurationv1.KataConfig) []crioHandler {
+	shim := kataShim(kataConfig)
+	handlers := []crioHandler{{Name: runtimeHandler(kataConfig), Shim: shim}}
+	if confidentialEnabled(kataConfig) {
+		handlers = append(handlers, crioHandler{
+			Name:       confidentialRuntimeHandler(kataConfig),
+			ConfigPath: kataCCConfigPath,
+			Shim:       shim,
+			PullImage:  guestPullEnabled(kataConfig),
+		})
+	}
+	return handlers
+}
+
+// handlerNames returns the names of the given handlers
+func handlerNames(handlers []crioHandler) []string {
+	var names []string
+	for _, handler := range handlers {
+		names = append(names, handler.Name)
+	}
+	return names
+}
+
+// newConfidentialRuntimeClassForCR returns the kata-cc RuntimeClass, with an overhead and a
+// node selector independent of the kata ones
+func (r *KataConfigOpenShiftReconciler) newConfidentialRuntimeClassForCR() *nodeapi.RuntimeClass {
+	conf := r.kataConfig.Spec.Confidential
+	if conf == nil {
+		conf = &kataconfigurationv1.KataConfidentialConfig{}
+	}
+
+	return &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: kataCCRuntimeClassName,
+		},
+		Handler: confidentialRuntimeHandler(r.kataConfig),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: podOverhead(r.kataConfig, conf.Overhead),
+		},
+		Scheduling: &nodeapi.Scheduling{
+			NodeSelector: runtimeClassNodeSelector(conf.SchedulingNodeSelector, map[string]string{kataRuntimeLabel: "true"}),
+		},
+	}
+}
+
+// newRuntimeClassesForCR returns the kata RuntimeClass, along with the kata-cc one when
+// confidential sandboxes are enabled and the kata-sgx one when the SGX sandboxes are
+func (r *KataConfigOpenShiftReconciler) newRuntimeClassesForCR() []*nodeapi.RuntimeClass {
+	rcs := []*nodeapi.RuntimeClass{r.newRuntimeClassForCR()}
+	if confidentialEnabled(r.kataConfig) {
+		rcs = append(rcs, r.newConfidentialRuntimeClassForCR())
+	}
+	if sgxEnabled(r.kataConfig) {
+		rcs = append(rcs, r.newSGXRuntimeClassForCR())
+	}
+	return rcs
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/initdata"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// crioReloadableDropinEnv passes the rendered CRI-O drop-in to the reload daemon, which
+	// writes it next to the 50-kata.conf drop-in of the MachineConfig and reloads CRI-O.
+	// The file is kept out of the MachineConfig as the MCO reboots the nodes on every change
+	// and flags the files it owns that are modified on the node
+	crioReloadableDropinEnv = "CRIO_RELOADABLE_DROPIN"
+
+	// crioReloadedMarker is created by the reload daemon once CRI-O has been reloaded, the
+	// pod is ready from then on
+	crioReloadedMarker = "/tmp/crio-reloaded"
+)
+
+// generateReloadableCrioDropin renders the CRI-O settings of the KataConfig that CRI-O reloads
+// on the fly. It returns an empty string when none is set. The allowed annotations, the ones of
+// the spec, of the block volumes and of the KataAnnotationPolicies, go with the kata handler
+// and are left out while the handler is removed
+func generateReloadableCrioDropin(kataConfig *kataconfigurationv1.KataConfig, policyAnnotations []string, handler bool) (string, error) {
+	conf := kataConfig.Spec.Crio
+	if conf == nil {
+		conf = &kataconfigurationv1.KataCrioConfig{}
+	}
+	var allowedAnnotations []string
+	if handler {
+		allowedAnnotations = append(allowedAnnotations, conf.AllowedAnnotations...)
+		var extra []string
+		if block := kataConfig.Spec.BlockVolumes; block != nil && block.Enabled && block.AllowPodAnnotations {
+			extra = append(extra, blockVolumeAnnotations...)
+		}
+		if sgxEnabled(kataConfig) {
+			extra = append(extra, sgxEPCResource)
+		}
+		if conf := kataConfig.Spec.Confidential; confidentialEnabled(kataConfig) && (conf.Initdata != "" || conf.AllowPodInitdata) {
+			extra = append(extra, initdata.Annotation)
+		}
+		for _, annotation := range append(extra, policyAnnotations...) {
+			if !contains(allowedAnnotations, annotation) {
+				allowedAnnotations = append(allowedAnnotations, annotation)
+			}
+		}
+	}
+	if conf.LogLevel == "" && len(allowedAnnotations) == 0 {
+		return "", nil
+	}
+
+	type ReloadableConfig struct {
+		Handlers           []crioHandler
+		LogLevel           string
+		AllowedAnnotations []string
+	}
+	// The kata handlers are repeated in full as CRI-O replaces the runtime handlers
+	// defined by several drop-ins instead of merging them
+	const b = `
+{{- if .LogLevel}}
+[crio.runtime]
+  log_level = "{{.LogLevel}}"
+{{end}}
+{{- if .AllowedAnnotations}}{{range .Handlers}}
+[crio.runtime.runtimes.{{.Name}}]
+  runtime_path = "{{.Shim.Path}}"
+  runtime_type = "{{.Shim.Type}}"
+  runtime_root = "{{.Shim.Root}}"
+{{- if .ConfigPath}}
+  runtime_config_path = "{{.ConfigPath}}"
+{{- end}}
+{{- if .PullImage}}
+  runtime_pull_image = true
+{{- end}}
+  allowed_annotations = [{{range $i, $a := $.AllowedAnnotations}}{{if $i}}, {{end}}{{printf "%q" $a}}{{end}}]
+{{end}}{{end}}`
+	c := ReloadableConfig{
+		Handlers:           kataHandlers(kataConfig),
+		LogLevel:           conf.LogLevel,
+		AllowedAnnotations: allowedAnnotations,
+	}
+
+	buf := new(bytes.Buffer)
+	t := template.Must(template.New("reload").Parse(b))
+	if err := t.Execute(buf, c); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// newCrioReloadDaemonset returns the daemonset writing the reloadable CRI-O drop-in on the kata
+// nodes. A change of the drop-in rolls the daemonset out node by node, every pod reloading CRI-O
+func (r *KataConfigOpenShiftReconciler) newCrioReloadDaemonset(dropin string) (*appsv1.DaemonSet, error) {
+	ds := r.processDaemonsetForCR(ReloadOperation, "")
+	container := &ds.Spec.Template.Spec.Containers[0]
+	container.Name = "kata-reload-pod"
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  crioReloadableDropinEnv,
+		Value: dropin,
+	})
+	container.ReadinessProbe = &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"test", "-f", crioReloadedMarker},
+			},
+		},
+		PeriodSeconds: 5,
+	}
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// crioReloadDropin returns the drop-in currently rolled out by the reload daemonset
+func crioReloadDropin(ds *appsv1.DaemonSet) string {
+	return daemonsetEnv(ds, crioReloadableDropinEnv)
+}
+
+// policyAnnotations returns the kata annotations allowed by the KataAnnotationPolicies, sorted.
+// CRI-O passes them down to the kata runtime, the webhook restricts them to the namespaces
+// of the policies
+func (r *KataConfigOpenShiftReconciler) policyAnnotations() ([]string, error) {
+	policies := &kataconfigurationv1.KataAnnotationPolicyList{}
+	if err := r.Client.List(r.ctx, policies); err != nil {
+		return nil, err
+	}
+	return unionPolicyAnnotations(policies.Items), nil
+}
+
+// unionPolicyAnnotations returns the kata annotations allowed by any of the policies, sorted
+func unionPolicyAnnotations(policies []kataconfigurationv1.KataAnnotationPolicy) []string {
+	var annotations []string
+	for _, policy := range policies {
+		for _, annotation := range policy.Spec.AllowedAnnotations {
+			if !contains(annotations, string(annotation)) {
+				annotations = append(annotations, string(annotation))
+			}
+		}
+	}
+	sort.Strings(annotations)
+	return annotations
+}
+
+// reconcileCrioReload rolls out the reloadable CRI-O settings. The reload daemonset is created
+// the first time some are set and kept until the uninstallation, so that settings removed
+// later are removed from the nodes too
+func (r *KataConfigOpenShiftReconciler) reconcileCrioReload() error {
+	handler, err := r.kataHandlerEnabled()
+	if err != nil {
+		return err
+	}
+
+	policyAnnotations, err := r.policyAnnotations()
+	if err != nil {
+		return err
+	}
+	dropin, err := generateReloadableCrioDropin(r.kataConfig, policyAnnotations, handler)
+	if err != nil {
+		return err
+	}
+
+	ds, err := r.newCrioReloadDaemonset(dropin)
+	if err != nil {
+		return err
+	}
+
+	foundDs := &appsv1.DaemonSet{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) && dropin == "" {
+		return nil
+	}
+	if err == nil && crioReloadDropin(foundDs) == dropin {
+		return nil
+	}
+
+	if err := r.applyDaemonSCC(); err != nil {
+		return err
+	}
+
+	r.Log.Info("Reloading CRI-O with the new settings, the nodes are not rebooted", "ds.Name", ds.Name)
+	r.recordHistory(kataconfigurationv1.HistoryCrioReloaded,
+		fmt.Sprintf("CRI-O settings rolled out by daemonset %s", ds.Name))
+	return r.applyObject(ds)
+}
+
+// removeCrioReload removes the reloadable CRI-O drop-in from the nodes and then deletes the
+// reload daemonset. It returns false while the nodes are being reverted
+func (r *KataConfigOpenShiftReconciler) removeCrioReload() (bool, error) {
+	ds, err := r.newCrioReloadDaemonset("")
+	if err != nil {
+		return false, err
+	}
+
+	foundDs := &appsv1.DaemonSet{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err != nil && errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if crioReloadDropin(foundDs) != "" {
+		r.Log.Info("Removing the reloadable CRI-O settings from the nodes", "ds.Name", ds.Name)
+		return false, r.applyObject(ds)
+	}
+
+	if foundDs.Status.ObservedGeneration < foundDs.Generation ||
+		foundDs.Status.UpdatedNumberScheduled != foundDs.Status.DesiredNumberScheduled ||
+		foundDs.Status.NumberReady != foundDs.Status.DesiredNumberScheduled {
+		r.Log.Info("Waiting for the reloadable CRI-O settings to be removed from the nodes", "ds.Name", ds.Name,
+			"updated", foundDs.Status.UpdatedNumberScheduled, "desired", foundDs.Status.DesiredNumberScheduled)
+		return false, nil
+	}
+
+	if err := r.Client.Delete(r.ctx, foundDs); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+)
+
+func TestGenerateReloadableCrioDropin(t *testing.T) {
+	const memory = kataconfigurationv1.KataAnnotationPrefix + "hypervisor.default_memory"
+	const vcpus = kataconfigurationv1.KataAnnotationPrefix + "hypervisor.default_vcpus"
+	const kataHandler = `
+[crio.runtime.runtimes.kata]
+  runtime_path = "/usr/bin/containerd-shim-kata-v2"
+  runtime_type = "vm"
+  runtime_root = "/run/vc"
+`
+
+	tests := []struct {
+		name              string
+		spec              kataconfigurationv1.KataConfigSpec
+		policyAnnotations []string
+		handler           bool
+		expected          string
+	}{
+		{name: "no CRI-O config", handler: true},
+		{
+			name:     "log level",
+			spec:     kataconfigurationv1.KataConfigSpec{Crio: &kataconfigurationv1.KataCrioConfig{LogLevel: "debug"}},
+			expected: "\n[crio.runtime]\n  log_level = \"debug\"\n",
+		},
+		{
+			name: "annotations without the kata handler",
+			spec: kataconfigurationv1.KataConfigSpec{Crio: &kataconfigurationv1.KataCrioConfig{
+				AllowedAnnotations: []string{memory},
+			}},
+			policyAnnotations: []string{vcpus},
+		},
+		{
+			name: "allowed annotations",
+			spec: kataconfigurationv1.KataConfigSpec{Crio: &kataconfigurationv1.KataCrioConfig{
+				AllowedAnnotations: []string{memory},
+			}},
+			handler:  true,
+			expected: kataHandler + "  allowed_annotations = [\"" + memory + "\"]\n",
+		},
+		{
+			name: "block volume annotations",
+			spec: kataconfigurationv1.KataConfigSpec{
+				BlockVolumes: &kataconfigurationv1.KataBlockVolumesConfig{Enabled: true, AllowPodAnnotations: true},
+			},
+			handler: true,
+			expected: kataHandler + "  allowed_annotations = [\"" + blockVolumeAnnotations[0] + "\", \"" +
+				blockVolumeAnnotations[1] + "\"]\n",
+		},
+		{
+			name: "policy annotations",
+			spec: kataconfigurationv1.KataConfigSpec{Crio: &kataconfigurationv1.KataCrioConfig{
+				LogLevel:           "info",
+				AllowedAnnotations: []string{memory},
+			}},
+			policyAnnotations: []string{memory, vcpus},
+			handler:           true,
+			expected: "\n[crio.runtime]\n  log_level = \"info\"\n" + kataHandler +
+				"  allowed_annotations = [\"" + memory + "\", \"" + vcpus + "\"]\n",
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{Spec: test.spec}
+		dropin, err := generateReloadableCrioDropin(kataConfig, test.policyAnnotations, test.handler)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if dropin != test.expected {
+			t.Errorf("%s: expected the drop-in %q, got %q", test.name, test.expected, dropin)
+		}
+	}
+}
+
+func TestUnionPolicyAnnotations(t *testing.T) {
+	policy := func(annotations ...kataconfigurationv1.KataAnnotation) kataconfigurationv1.KataAnnotationPolicy {
+		return kataconfigurationv1.KataAnnotationPolicy{
+			Spec: kataconfigurationv1.KataAnnotationPolicySpec{AllowedAnnotations: annotations},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policies []kataconfigurationv1.KataAnnotationPolicy
+		expected []string
+	}{
+		{name: "no policy"},
+		{
+			name:     "single policy",
+			policies: []kataconfigurationv1.KataAnnotationPolicy{policy("io.katacontainers.config.hypervisor.")},
+			expected: []string{"io.katacontainers.config.hypervisor."},
+		},
+		{
+			name: "overlapping policies",
+			policies: []kataconfigurationv1.KataAnnotationPolicy{
+				policy("io.katacontainers.config.hypervisor.default_vcpus", "io.katacontainers.config.agent."),
+				policy("io.katacontainers.config.hypervisor.default_memory", "io.katacontainers.config.agent."),
+			},
+			expected: []string{
+				"io.katacontainers.config.agent.",
+				"io.katacontainers.config.hypervisor.default_memory",
+				"io.katacontainers.config.hypervisor.default_vcpus",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if annotations := unionPolicyAnnotations(test.policies); !reflect.DeepEqual(annotations, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, annotations)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	securityv1 "github.com/openshift/api/security/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	daemonNamespace          = "kata-operator-system"
+	daemonServiceAccountName = "kata-operator-daemon"
+
+	// daemonSCCName is the SecurityContextConstraints admitting the daemon pods, and only them
+	daemonSCCName = "kata-operator-daemon"
+
+	// daemonSELinuxType lets the daemon write the host files and load the kata SELinux policy
+	daemonSELinuxType = "spc_t"
+)
+
+// daemonCapabilities are the only capabilities of the daemon: chroot into the host, write the
+// host files as root whatever their owner, and unpack the payload layers
+var daemonCapabilities = []corev1.Capability{
+	"CHOWN",
+	"DAC_OVERRIDE",
+	"FOWNER",
+	"FSETID",
+	"MKNOD",
+	"SETFCAP",
+	"SYS_ADMIN",
+	"SYS_CHROOT",
+}
+
+// daemonSecurityContext is the security context of the daemon containers
+func daemonSecurityContext() *corev1.SecurityContext {
+	var (
+		privileged                     = false
+		allowPrivilegeEscalation       = false
+		runAsUser                int64 = 0
+	)
+
+	return &corev1.SecurityContext{
+		Privileged:               &privileged,
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		RunAsUser:                &runAsUser,
+		Capabilities: &corev1.Capabilities{
+			Add:  daemonCapabilities,
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SELinuxOptions: &corev1.SELinuxOptions{
+			Type: daemonSELinuxType,
+		},
+	}
+}
+
+// newDaemonSCC returns the SecurityContextConstraints granting the daemon service account
+// the capabilities, SELinux type and host root mount of daemonSecurityContext, without
+// running privileged or in the host namespaces
+func (r *KataConfigOpenShiftReconciler) newDaemonSCC() *securityv1.SecurityContextConstraints {
+	allowPrivilegeEscalation := false
+
+	return &securityv1.SecurityContextConstraints{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "security.openshift.io/v1",
+			Kind:       "SecurityContextConstraints",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: daemonSCCName,
+		},
+		AllowPrivilegedContainer:        false,
+		AllowPrivilegeEscalation:        &allowPrivilegeEscalation,
+		DefaultAllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		AllowedCapabilities:             daemonCapabilities,
+		AllowHostDirVolumePlugin:        true,
+		Volumes: []securityv1.FSType{
+			securityv1.FSTypeHostPath,
+			securityv1.FSTypeConfigMap,
+			securityv1.FSTypeDownwardAPI,
+			securityv1.FSProjected,
+			securityv1.FSTypeSecret,
+		},
+		SELinuxContext: securityv1.SELinuxContextStrategyOptions{
+			Type: securityv1.SELinuxStrategyRunAsAny,
+		},
+		RunAsUser: securityv1.RunAsUserStrategyOptions{
+			Type: securityv1.RunAsUserStrategyRunAsAny,
+		},
+		SupplementalGroups: securityv1.SupplementalGroupsStrategyOptions{
+			Type: securityv1.SupplementalGroupsStrategyRunAsAny,
+		},
+		FSGroup: securityv1.FSGroupStrategyOptions{
+			Type: securityv1.FSGroupStrategyRunAsAny,
+		},
+		Users: []string{"system:serviceaccount:" + daemonNamespace + ":" + daemonServiceAccountName},
+	}
+}
+
+// applyDaemonSCC makes sure the daemon pods can be admitted before creating a daemonset.
+// The SCC is owned by the KataConfig and goes away with it
+func (r *KataConfigOpenShiftReconciler) applyDaemonSCC() error {
+	scc := r.newDaemonSCC()
+	if err := controllerutil.SetControllerReference(r.kataConfig, scc, r.Scheme); err != nil {
+		return err
+	}
+	return r.applyObject(scc)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxBlockingPodsReported bounds the number of pods named in the DeletionBlocked condition
+const maxBlockingPodsReported = 10
+
+// setDeletionBlockedCondition reports the kata pods preventing the uninstallation. The
+// condition is cleared once the pods are gone
+func setDeletionBlockedCondition(status *kataconfigurationv1.KataConfigStatus, pods []corev1.Pod) {
+	if len(pods) == 0 {
+		if meta.FindStatusCondition(status.Conditions, kataconfigurationv1.KataConfigDeletionBlocked) != nil {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    kataconfigurationv1.KataConfigDeletionBlocked,
+				Status:  metav1.ConditionFalse,
+				Reason:  "NoKataPods",
+				Message: "No pods are using the kata runtime anymore",
+			})
+		}
+		return
+	}
+
+	var names []string
+	for i, pod := range pods {
+		if i == maxBlockingPodsReported {
+			names = append(names, fmt.Sprintf("and %d more", len(pods)-maxBlockingPodsReported))
+			break
+		}
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:   kataconfigurationv1.KataConfigDeletionBlocked,
+		Status: metav1.ConditionTrue,
+		Reason: "KataPodsRunning",
+		Message: fmt.Sprintf("%d pods are using the kata runtime and must be deleted first: %s",
+			len(pods), strings.Join(names, ", ")),
+	})
+}
+
+// evictKataPods removes the pods using the kata runtime so that the uninstallation can
+// proceed. The pods are evicted, honoring their PodDisruptionBudgets, unless the
+// KataConfig asks to ignore them, in which case they are deleted
+func (r *KataConfigOpenShiftReconciler) evictKataPods(pods []corev1.Pod) error {
+	force := r.kataConfig.Spec.ForceUninstall
+
+	if r.clientset == nil {
+		clientset, err := getClientSet()
+		if err != nil {
+			return err
+		}
+		r.clientset = clientset
+	}
+
+	var failed []string
+	for i := range pods {
+		pod := &pods[i]
+		if pod.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		var err error
+		if force.IgnorePodDisruptionBudgets {
+			r.Log.Info("Deleting kata pod", "pod", pod.Namespace+"/"+pod.Name)
+			err = r.Client.Delete(r.ctx, pod, &client.DeleteOptions{GracePeriodSeconds: force.GracePeriodSeconds})
+		} else {
+			r.Log.Info("Evicting kata pod", "pod", pod.Namespace+"/"+pod.Name)
+			err = r.clientset.CoreV1().Pods(pod.Namespace).Evict(r.ctx, &policyv1beta1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pod.Name,
+					Namespace: pod.Namespace,
+				},
+				DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: force.GracePeriodSeconds},
+			})
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			// Evictions refused by a PodDisruptionBudget are retried on the next reconcile
+			r.Log.Info("Failed to remove kata pod", "pod", pod.Namespace+"/"+pod.Name, "error", err)
+			failed = append(failed, pod.Namespace+"/"+pod.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to evict %d kata pods: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultDevmapperPool = "devpool"
+
+	// devmapperSetupScript checks that the thin-pool exists on the node and is big enough for
+	// the base image, then adds the devmapper snapshotter to the containerd configuration
+	// unless it is configured already. The thin-pool is looked up by its device-mapper name in
+	// sysfs, /dev/mapper is not populated in the containers
+	devmapperSetupScript = `set -e
+dm=""
+for d in /sys/class/block/dm-*; do
+  if [ "$(cat "$d/dm/name" 2>/dev/null)" = "$DEVMAPPER_POOL" ]; then dm="$d"; fi
+done
+if [ -z "$dm" ]; then
+  echo "devmapper thin-pool $DEVMAPPER_POOL not found on node $NODE_NAME" >&2
+  exit 1
+fi
+size=$(( $(cat "$dm/size") * 512 ))
+if [ "$size" -lt "$DEVMAPPER_BASE_IMAGE_SIZE" ]; then
+  echo "devmapper thin-pool $DEVMAPPER_POOL of node $NODE_NAME has $size bytes, less than the base image size $DEVMAPPER_BASE_IMAGE_SIZE" >&2
+  exit 1
+fi
+conf=/etc/containerd/config.toml
+if ! grep -qs 'io.containerd.snapshotter.v1.devmapper' "$conf"; then
+  cat >> "$conf" <<EOF
+
+[plugins."io.containerd.snapshotter.v1.devmapper"]
+  pool_name = "$DEVMAPPER_POOL"
+  root_path = "/var/lib/containerd/devmapper"
+  base_image_size = "$DEVMAPPER_BASE_IMAGE_SIZE"
+  discard_blocks = true
+EOF
+fi`
+)
+
+// defaultDevmapperShims are the kata-deploy shims needing a block device rootfs
+var defaultDevmapperShims = []string{"fc"}
+
+// addDevmapperConfig sets the install daemonset of kata-deploy up for the devmapper
+// snapshotter: an init container validates the thin-pool of the node and configures
+// containerd, and kata-deploy maps the shims to the snapshotter
+func addDevmapperConfig(ds *appsv1.DaemonSet, devmapper *kataconfigurationv1.KataDevmapperConfig) {
+	if devmapper == nil {
+		return
+	}
+
+	pool := devmapper.PoolName
+	if pool == "" {
+		pool = defaultDevmapperPool
+	}
+	baseImageSize := resource.MustParse("10Gi")
+	if devmapper.BaseImageSize != nil {
+		baseImageSize = *devmapper.BaseImageSize
+	}
+	shims := devmapper.Shims
+	if len(shims) == 0 {
+		shims = defaultDevmapperShims
+	}
+
+	spec := &ds.Spec.Template.Spec
+	install := &spec.Containers[0]
+	runPrivileged := true
+	spec.InitContainers = append(spec.InitContainers, corev1.Container{
+		Name:            "kata-devmapper-setup",
+		Image:           install.Image,
+		ImagePullPolicy: install.ImagePullPolicy,
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &runPrivileged,
+		},
+		Command: []string{"bash", "-c", devmapperSetupScript},
+		Env: append([]corev1.EnvVar{
+			{Name: "DEVMAPPER_POOL", Value: pool},
+			{Name: "DEVMAPPER_BASE_IMAGE_SIZE", Value: strconv.FormatInt(baseImageSize.Value(), 10)},
+		}, install.Env...),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "containerd-conf",
+				MountPath: "/etc/containerd/",
+			},
+		},
+	})
+
+	mapping := make([]string, 0, len(shims))
+	for _, shim := range shims {
+		mapping = append(mapping, shim+":devmapper")
+	}
+	install.Env = append(install.Env, corev1.EnvVar{
+		Name:  "SNAPSHOTTER_HANDLER_MAPPING",
+		Value: strings.Join(mapping, ","),
+	})
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// dryRunNodes returns the nodes the installation would select. The nodes matching the
+// KataConfigPoolSelectors aren't labeled during a dry run, they are matched directly
+func (r *KataConfigOpenShiftReconciler) dryRunNodes(machinePool string) ([]corev1.Node, error) {
+	if len(r.kataConfig.Spec.KataConfigPoolSelectors) == 0 {
+		return r.listKataNodes(machinePool)
+	}
+
+	selectors, err := kataPoolSelectors(r.kataConfig)
+	if err != nil {
+		return nil, err
+	}
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList); err != nil {
+		return nil, err
+	}
+	var nodes []corev1.Node
+	for _, node := range nodesList.Items {
+		if !excludedNode(r.kataConfig, node.GetLabels()) && matchesAny(selectors, node.GetLabels()) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// reconcileDryRun previews the installation in status.dryRun: the selected nodes and their
+// eligibility, the machine config pool and the MachineConfig the installation would render, and
+// the reboots of the rollout. Nothing is created or changed on the cluster
+func (r *KataConfigOpenShiftReconciler) reconcileDryRun() (ctrl.Result, error) {
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil && len(r.kataConfig.Spec.KataConfigPoolSelectors) > 0 {
+		return ctrl.Result{}, fmt.Errorf("the KataConfigPoolSelector and the KataConfigPoolSelectors can't be set together")
+	}
+
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	nodes, err := r.dryRunNodes(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	report := &kataconfigurationv1.KataDryRunReport{
+		Time:              metav1.Now(),
+		Generation:        r.kataConfig.Generation,
+		NodesCount:        len(nodes),
+		MachineConfigPool: r.kataPoolName(machinePool),
+	}
+	for i := range nodes {
+		report.Nodes = append(report.Nodes, nodes[i].Name)
+		if eligible, reason := checkNodeEligibility(&nodes[i]); !eligible {
+			report.IneligibleNodes = append(report.IneligibleNodes, kataconfigurationv1.FailedNodeStatus{Name: nodes[i].Name, Error: reason})
+		}
+	}
+	sort.Strings(report.Nodes)
+
+	mc, err := r.newMCForCR(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if report.Files, err = machineConfigFiles(mc); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	maxUnavailable := 1
+	mcp := &mcfgv1.MachineConfigPool{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: report.MachineConfigPool}, mcp)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && mcp.Spec.MaxUnavailable != nil {
+		value, err := intstr.GetValueFromIntOrPercent(mcp.Spec.MaxUnavailable, len(nodes), false)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if value > 0 {
+			maxUnavailable = value
+		}
+	}
+	report.Disruption = fmt.Sprintf("%d nodes rebooted by the rollout of the %s machine config pool, up to %d at a time",
+		len(nodes), report.MachineConfigPool, maxUnavailable)
+
+	// the preview only changes, and the KataConfig is only requeued, when the outcome changes
+	if previous := r.kataConfig.Status.DryRun; previous != nil {
+		report.Time = previous.Time
+		if equality.Semantic.DeepEqual(previous, report) {
+			return ctrl.Result{}, nil
+		}
+		report.Time = metav1.Now()
+	}
+
+	r.Log.Info("Dry run of the KataConfig", "nodes", report.NodesCount, "ineligible", len(report.IneligibleNodes),
+		"pool", report.MachineConfigPool)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.DryRun = report
+	})
+	return ctrl.Result{}, nil
+}
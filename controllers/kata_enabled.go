@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kataEnabled tells whether the kata runtime is to be offered on the installed nodes
+func (r *KataConfigOpenShiftReconciler) kataEnabled() bool {
+	return r.kataConfig.Spec.Enabled == nil || *r.kataConfig.Spec.Enabled
+}
+
+// kataHandlerEnabled tells whether the kata CRI-O handler is rendered. Once kata is disabled
+// the handler is kept until no pod uses the kata runtime anymore, removing it takes a rollout
+// of the MachineConfig and the pods left would not be able to restart
+func (r *KataConfigOpenShiftReconciler) kataHandlerEnabled() (bool, error) {
+	if r.kataEnabled() {
+		return true, nil
+	}
+	pods, err := r.listKataPods()
+	if err != nil {
+		return false, err
+	}
+	return len(pods) > 0, nil
+}
+
+// reconcileDisabled reports in the Disabled condition whether kata is deactivated and, if
+// so, whether the kata handler is still waiting for the kata pods to be deleted. It returns
+// true while waiting
+func (r *KataConfigOpenShiftReconciler) reconcileDisabled() (bool, error) {
+	if r.kataEnabled() {
+		if meta.IsStatusConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigDisabled) {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+					Type:    kataconfigurationv1.KataConfigDisabled,
+					Status:  metav1.ConditionFalse,
+					Reason:  "AsExpected",
+					Message: "the kata runtime is enabled",
+				})
+			})
+		}
+		return false, nil
+	}
+
+	pods, err := r.listKataPods()
+	if err != nil {
+		return false, err
+	}
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigDisabled,
+		Status:  metav1.ConditionTrue,
+		Reason:  "KataDisabled",
+		Message: "the kata RuntimeClass and CRI-O handler are removed, the kata binaries are kept",
+	}
+	if len(pods) > 0 {
+		condition.Reason = "WaitingForKataPods"
+		condition.Message = fmt.Sprintf("the kata RuntimeClass is removed, the kata CRI-O handler is kept until the %d pods using it are deleted", len(pods))
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		meta.SetStatusCondition(&status.Conditions, condition)
+	})
+	return len(pods) > 0, nil
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataExtensionName is the OS extension shipping the kata binaries, layered by the MCO
+	kataExtensionName = "sandboxed-containers"
+
+	kataMachineConfigName = "50-kata-crio-dropin"
+)
+
+// extensionDelivery tells whether the kata binaries are delivered as an OS extension
+// instead of being installed by the daemon
+func (r *KataConfigOpenShiftReconciler) extensionDelivery() bool {
+	return r.kataConfig.Spec.PayloadDelivery == kataconfigurationv1.PayloadDeliveryExtension
+}
+
+// kataPoolName returns the machine config pool the kata MachineConfig is rendered into
+func (r *KataConfigOpenShiftReconciler) kataPoolName(machinePool string) string {
+	return kataConfigPoolName(r.kataConfig, machinePool)
+}
+
+// kataConfigPoolName returns the machine config pool the kata MachineConfig of the KataConfig
+// is rendered into
+func kataConfigPoolName(kataConfig *kataconfigurationv1.KataConfig, machinePool string) string {
+	selector := kataPoolSelector(kataConfig)
+	if selector == nil {
+		return machinePool
+	}
+	if _, ok := selector.MatchLabels["node-role.kubernetes.io/"+machinePool]; ok {
+		return machinePool
+	}
+	return "kata-oc"
+}
+
+// machineConfigRolledOut tells whether all the machines of the pool run a rendered config
+// that includes, or doesn't include, the given MachineConfig
+func machineConfigRolledOut(mcp *mcfgv1.MachineConfigPool, mcName string, included bool) bool {
+	if mcp.Status.MachineCount == 0 || mcp.Status.UpdatedMachineCount != mcp.Status.MachineCount ||
+		mcp.Status.Configuration.Name != mcp.Spec.Configuration.Name {
+		return false
+	}
+
+	found := false
+	for _, source := range mcp.Status.Configuration.Source {
+		if source.Name == mcName {
+			found = true
+			break
+		}
+	}
+	return found == included
+}
+
+// reconcileExtensionInstallation creates the kata MachineConfig layering the extension onto the
+// pool, and marks the nodes installed once the MCO has rolled it out
+func (r *KataConfigOpenShiftReconciler) reconcileExtensionInstallation() (ctrl.Result, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	poolName := r.kataPoolName(machinePool)
+
+	if poolName != machinePool {
+		mcp := r.newMCPforCR()
+		err = r.Client.Get(r.ctx, types.NamespacedName{Name: mcp.Name}, &mcfgv1.MachineConfigPool{})
+		if err != nil && errors.IsNotFound(err) {
+			r.Log.Info("Creating a new Machine Config Pool ", "mcp.Name", mcp.Name)
+			if err := r.applyObject(mcp); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.recordHistory(kataconfigurationv1.HistoryMachineConfigPoolCreated,
+				fmt.Sprintf("machine config pool %s created", mcp.Name))
+			return r.pollMCP(nil), nil
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if conflict, err := r.checkCrioConflicts(machinePool); err != nil || conflict {
+		return r.requeue(), err
+	}
+
+	mc, err := r.newMCForCR(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: mc.Name}, &mcfgv1.MachineConfig{})
+	if err != nil && errors.IsNotFound(err) {
+		if wait, err := r.waitForMaintenanceWindow(); err != nil || wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, err
+		}
+		r.Log.Info("Creating a new Machine Config with the kata extension", "mc.Name", mc.Name, "extension", kataExtensionName)
+		if err := r.applyObject(mc); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.recordHistory(kataconfigurationv1.HistoryMachineConfigCreated,
+			fmt.Sprintf("machine config %s created for the %s pool with the %s extension", mc.Name, poolName, kataExtensionName))
+		return r.pollMCP(nil), nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	mcp := &mcfgv1.MachineConfigPool{}
+	if err := r.Client.Get(r.ctx, types.NamespacedName{Name: poolName}, mcp); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !machineConfigRolledOut(mcp, mc.Name, true) {
+		r.Log.Info("Waiting for the kata extension to be rolled out", "mcp.Name", mcp.Name,
+			"updated machines", mcp.Status.UpdatedMachineCount, "total machines", mcp.Status.MachineCount)
+		return r.pollMCP(mcp), nil
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var nodeNames []string
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.InstallationStatus.Completed.CompletedNodesCount = len(nodeNames)
+		status.InstallationStatus.Completed.CompletedNodesList = nodeNames
+		status.InstallationStatus.InProgress.InProgressNodesCount = 0
+	})
+	r.recordHistory(kataconfigurationv1.HistoryPayloadApplied,
+		fmt.Sprintf("extension %s rolled out to the %s pool", kataExtensionName, poolName))
+
+	// the runtime class is created on the next reconcile
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// processExtensionDeleteRequest removes the kata MachineConfig, and with it the extension,
+// hands the nodes back to their parent pool and verifies the uninstallation once the MCO is done
+func (r *KataConfigOpenShiftReconciler) processExtensionDeleteRequest(machinePool string) (ctrl.Result, error) {
+	poolName := r.kataPoolName(machinePool)
+
+	mc := &mcfgv1.MachineConfig{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: kataMachineConfigName}, mc)
+	if err == nil {
+		r.Log.Info("Deleting the kata Machine Config, the nodes of the pool will be rebooted", "mc.Name", mc.Name)
+		if err := r.deleteObject(mc); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		r.recordHistory(kataconfigurationv1.HistoryUninstallStarted,
+			fmt.Sprintf("machine config %s deleted, removing the %s extension", kataMachineConfigName, kataExtensionName))
+		return r.pollMCP(nil), nil
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	mcp := &mcfgv1.MachineConfigPool{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: poolName}, mcp)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && !machineConfigRolledOut(mcp, kataMachineConfigName, false) {
+		r.Log.Info("Waiting for the kata extension to be removed", "mcp.Name", mcp.Name,
+			"updated machines", mcp.Status.UpdatedMachineCount, "total machines", mcp.Status.MachineCount)
+		return r.pollMCP(mcp), nil
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if poolName != machinePool {
+		// remove the pool selector labels so that the nodes go back to the parent pool
+		for i := range nodes {
+			node := &nodes[i]
+			original := node.DeepCopy()
+			labels := node.GetLabels()
+			for k := range kataPoolSelector(r.kataConfig).MatchLabels {
+				delete(labels, k)
+			}
+			if equality.Semantic.DeepEqual(original.GetLabels(), labels) {
+				continue
+			}
+			r.Log.Info("Removing the kata pool selector label from the node", "node name ", node.Name)
+			node.SetLabels(labels)
+			if err := r.Client.Patch(r.ctx, node, client.MergeFrom(original)); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		parentMcp := &mcfgv1.MachineConfigPool{}
+		if err := r.Client.Get(r.ctx, types.NamespacedName{Name: machinePool}, parentMcp); err != nil {
+			return ctrl.Result{}, err
+		}
+		if parentMcp.Status.ReadyMachineCount != parentMcp.Status.MachineCount {
+			r.Log.Info("Monitoring parent mcp", "parent mcp name", parentMcp.Name, "ready machines", parentMcp.Status.ReadyMachineCount,
+				"total machines", parentMcp.Status.MachineCount)
+			return r.pollMCP(parentMcp), nil
+		}
+
+		if err := r.deleteObject(r.newMCPforCR()); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var nodeNames []string
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.UnInstallationStatus.Completed.CompletedNodesCount = len(nodeNames)
+		status.UnInstallationStatus.Completed.CompletedNodesList = nodeNames
+		status.UnInstallationStatus.InProgress.InProgressNodesCount = 0
+	})
+
+	r.Log.Info("Kata extension removed from all nodes")
+	return r.startUninstallVerification()
+}
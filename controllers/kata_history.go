@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxHistoryEvents bounds the number of entries kept in status.history
+const maxHistoryEvents = 20
+
+// addHistoryEvent appends an entry to the KataConfig audit log, dropping the oldest
+// entries once the log is full
+func addHistoryEvent(status *kataconfigurationv1.KataConfigStatus, generation int64, action kataconfigurationv1.KataHistoryAction, message string) {
+	history := append(status.History, kataconfigurationv1.KataHistoryEvent{
+		Time:       metav1.Now(),
+		Action:     action,
+		Generation: generation,
+		Message:    message,
+	})
+	if len(history) > maxHistoryEvents {
+		history = history[len(history)-maxHistoryEvents:]
+	}
+	status.History = history
+}
+
+// recordHistory queues an entry of the audit log for the KataConfig being reconciled
+func (r *KataConfigOpenShiftReconciler) recordHistory(action kataconfigurationv1.KataHistoryAction, message string) {
+	generation := r.kataConfig.Generation
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		addHistoryEvent(status, generation, action, message)
+	})
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// installStartTime returns when the installation started, i.e. when the first installation
+// daemonset was created. It is zero while no installation daemonset exists
+func (r *KataConfigOpenShiftReconciler) installStartTime() (time.Time, error) {
+	archs, err := r.daemonsetArchitectures()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var start time.Time
+	for _, arch := range archs {
+		ds := r.processDaemonsetForCR(InstallOperation, arch)
+		foundDs := &appsv1.DaemonSet{}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+		if err != nil && errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return time.Time{}, err
+		}
+		if created := foundDs.CreationTimestamp.Time; start.IsZero() || created.Before(start) {
+			start = created
+		}
+	}
+	return start, nil
+}
+
+// installTimedOutNodes returns the nodes of the pool that didn't install the kata binaries
+// within the spec.installTimeout, either because they never started or because they are
+// still installing. The nodes of the pool are only listed while the timeout can be exceeded
+func (r *KataConfigOpenShiftReconciler) installTimedOutNodes() ([]kataconfigurationv1.FailedNodeStatus, error) {
+	timeout := r.kataConfig.Spec.InstallTimeout
+	if timeout == nil || r.extensionDelivery() || r.kataConfig.GetDeletionTimestamp() != nil ||
+		kataPoolSelector(r.kataConfig) == nil || r.kataConfig.Status.TotalNodesCount == 0 ||
+		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == r.kataConfig.Status.TotalNodesCount {
+		return nil, nil
+	}
+
+	start, err := r.installStartTime()
+	if err != nil || start.IsZero() {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(kataPoolSelector(r.kataConfig))
+	if err != nil {
+		return nil, err
+	}
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var timedOut []kataconfigurationv1.FailedNodeStatus
+	for i := range nodesList.Items {
+		node := &nodesList.Items[i]
+
+		since := start
+		progress := nodeprogress.Get(node, r.kataConfig.Name)
+		switch progress.State {
+		case "":
+		case nodeprogress.Installing:
+			if !progress.Since.IsZero() {
+				since = progress.Since
+			}
+		default:
+			// installed, failed, or waiting for the other nodes before the CRI-O drop-in is rolled out
+			continue
+		}
+
+		if now.Sub(since) < timeout.Duration {
+			continue
+		}
+
+		reason := "the installation never started on the node"
+		if progress.State == nodeprogress.Installing {
+			reason = "the node is still installing the kata binaries"
+		}
+		timedOut = append(timedOut, kataconfigurationv1.FailedNodeStatus{
+			Name:  node.Name,
+			Error: fmt.Sprintf("installation timed out after %s: %s", timeout.Duration, reason),
+		})
+	}
+	return timedOut, nil
+}
+
+// markInstallTimedOut reports the timed out nodes as failed
+func markInstallTimedOut(status *kataconfigurationv1.KataConfigStatus, timedOut []kataconfigurationv1.FailedNodeStatus) {
+	failed := &status.InstallationStatus.Failed
+	for _, node := range timedOut {
+		alreadyFailed := false
+		for _, fn := range failed.FailedNodesList {
+			if fn.Name == node.Name {
+				alreadyFailed = true
+				break
+			}
+		}
+		if alreadyFailed {
+			continue
+		}
+		failed.FailedNodesList = append(failed.FailedNodesList, node)
+	}
+	failed.FailedNodesCount = len(failed.FailedNodesList)
+}
+
+// setDegradedCondition sets the Degraded condition while nodes exceed the installation timeout
+// or fail their health probes, and clears it once none does anymore
+func setDegradedCondition(status *kataconfigurationv1.KataConfigStatus, timedOut []kataconfigurationv1.FailedNodeStatus) {
+	unhealthy := status.InstallationStatus.Degraded.FailedNodesList
+	if len(timedOut) == 0 && len(unhealthy) == 0 {
+		if meta.IsStatusConditionTrue(status.Conditions, kataconfigurationv1.KataConfigDegraded) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    kataconfigurationv1.KataConfigDegraded,
+				Status:  metav1.ConditionFalse,
+				Reason:  "AsExpected",
+				Message: "no node exceeded the installation timeout or failed its health probes",
+			})
+		}
+		return
+	}
+
+	var reason string
+	var messages []string
+	if len(timedOut) > 0 {
+		reason = "InstallTimeout"
+		var names []string
+		for _, node := range timedOut {
+			names = append(names, node.Name)
+		}
+		messages = append(messages, fmt.Sprintf("%d nodes did not install kata in time: %s", len(names), strings.Join(names, ", ")))
+	}
+	if len(unhealthy) > 0 {
+		if reason == "" {
+			reason = "NodeUnhealthy"
+		}
+		var failures []string
+		for _, node := range unhealthy {
+			failures = append(failures, fmt.Sprintf("%s: %s", node.Name, node.Error))
+		}
+		messages = append(messages, fmt.Sprintf("%d nodes failed their health probes: %s", len(unhealthy), strings.Join(failures, "; ")))
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: strings.Join(messages, ". "),
+	})
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMarkInstallTimedOut(t *testing.T) {
+	status := &kataconfigurationv1.KataConfigStatus{}
+	status.InstallationStatus.Failed = failedNodes("worker-0")
+
+	markInstallTimedOut(status, []kataconfigurationv1.FailedNodeStatus{
+		{Name: "worker-0", Error: "installation timed out"},
+		{Name: "worker-1", Error: "installation timed out"},
+	})
+
+	failed := status.InstallationStatus.Failed
+	if failed.FailedNodesCount != 2 || len(failed.FailedNodesList) != 2 {
+		t.Fatalf("expected 2 failed nodes, got %+v", failed)
+	}
+	if failed.FailedNodesList[0].Error != "boom" {
+		t.Errorf("expected the failure of worker-0 to be kept, got %q", failed.FailedNodesList[0].Error)
+	}
+	if failed.FailedNodesList[1].Name != "worker-1" {
+		t.Errorf("expected worker-1 to be reported as failed, got %s", failed.FailedNodesList[1].Name)
+	}
+}
+
+func TestSetDegradedCondition(t *testing.T) {
+	timedOut := []kataconfigurationv1.FailedNodeStatus{{Name: "worker-0", Error: "installation timed out"}}
+	unhealthy := failedNodes("worker-1")
+
+	tests := []struct {
+		name      string
+		degraded  bool
+		timedOut  []kataconfigurationv1.FailedNodeStatus
+		unhealthy kataconfigurationv1.KataFailedNodeStatus
+		status    metav1.ConditionStatus
+		reason    string
+	}{
+		{name: "healthy"},
+		{name: "timed out", timedOut: timedOut, status: metav1.ConditionTrue, reason: "InstallTimeout"},
+		{name: "unhealthy", unhealthy: unhealthy, status: metav1.ConditionTrue, reason: "NodeUnhealthy"},
+		{
+			name:      "timed out and unhealthy",
+			timedOut:  timedOut,
+			unhealthy: unhealthy,
+			status:    metav1.ConditionTrue,
+			reason:    "InstallTimeout",
+		},
+		{name: "recovered", degraded: true, status: metav1.ConditionFalse, reason: "AsExpected"},
+	}
+
+	for _, test := range tests {
+		status := &kataconfigurationv1.KataConfigStatus{}
+		status.InstallationStatus.Degraded = test.unhealthy
+		if test.degraded {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:   kataconfigurationv1.KataConfigDegraded,
+				Status: metav1.ConditionTrue,
+				Reason: "InstallTimeout",
+			})
+		}
+
+		setDegradedCondition(status, test.timedOut)
+
+		condition := meta.FindStatusCondition(status.Conditions, kataconfigurationv1.KataConfigDegraded)
+		if test.status == "" {
+			if condition != nil {
+				t.Errorf("%s: expected no Degraded condition, got %+v", test.name, condition)
+			}
+			continue
+		}
+		if condition == nil || condition.Status != test.status || condition.Reason != test.reason {
+			t.Errorf("%s: expected the Degraded condition %s with reason %s, got %+v",
+				test.name, test.status, test.reason, condition)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	machineAPINamespace = "openshift-machine-api"
+
+	machineSetLabel  = "machine.openshift.io/cluster-api-machineset"
+	machineRoleLabel = "machine.openshift.io/cluster-api-machine-role"
+)
+
+// machineSetGVK is the MachineSet of the OpenShift machine API. Its types are not vendored, the
+// MachineSets are handled as unstructured objects
+var machineSetGVK = schema.GroupVersionKind{
+	Group:   "machine.openshift.io",
+	Version: "v1beta1",
+	Kind:    "MachineSet",
+}
+
+// instanceTypeFields is the field of the provider spec holding the instance type, by provider
+// spec kind
+var instanceTypeFields = map[string]string{
+	"AWSMachineProviderConfig": "instanceType",
+	"AzureMachineProviderSpec": "vmSize",
+	"GCPMachineProviderSpec":   "machineType",
+}
+
+// bareMetalInstanceTypes are the default bare-metal instance types, by provider spec kind
+var bareMetalInstanceTypes = map[string]string{
+	"AWSMachineProviderConfig": "m5.metal",
+}
+
+// kataMachineSetName is the name of the MachineSet of the kata workers
+func (r *KataConfigOpenShiftReconciler) kataMachineSetName() string {
+	return "kata-" + r.kataConfig.Name
+}
+
+// getMachineSet returns the MachineSet, nil if it doesn't exist
+func (r *KataConfigOpenShiftReconciler) getMachineSet(name string) (*unstructured.Unstructured, error) {
+	ms := &unstructured.Unstructured{}
+	ms.SetGroupVersionKind(machineSetGVK)
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: name, Namespace: machineAPINamespace}, ms)
+	if err != nil && errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return ms, err
+}
+
+// templateMachineSet returns the MachineSet the kata MachineSet is copied from
+func (r *KataConfigOpenShiftReconciler) templateMachineSet() (*unstructured.Unstructured, error) {
+	provision := r.kataConfig.Spec.ProvisionWorkers
+	if provision.TemplateMachineSet != "" {
+		ms, err := r.getMachineSet(provision.TemplateMachineSet)
+		if err == nil && ms == nil {
+			err = fmt.Errorf("template MachineSet %s not found in %s", provision.TemplateMachineSet, machineAPINamespace)
+		}
+		return ms, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(machineSetGVK.GroupVersion().WithKind("MachineSetList"))
+	machineSets, err := r.listPages(list, client.InNamespace(machineAPINamespace))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(machineSets, func(i, j int) bool {
+		return machineSets[i].GetName() < machineSets[j].GetName()
+	})
+	for i := range machineSets {
+		ms := &machineSets[i]
+		if _, ok := ms.GetLabels()[kataConfigOwnerLabel]; ok {
+			continue
+		}
+		role, _, _ := unstructured.NestedString(ms.Object, "spec", "template", "metadata", "labels", machineRoleLabel)
+		if role == "worker" {
+			return ms, nil
+		}
+	}
+	return nil, fmt.Errorf("no worker MachineSet found in %s to copy the kata MachineSet from", machineAPINamespace)
+}
+
+// newKataMachineSet renders the MachineSet of the kata workers out of the template spec. The
+// machines keep the provider spec of the template, but the instance type, and their nodes get
+// the labels of the kata pool
+func (r *KataConfigOpenShiftReconciler) newKataMachineSet(template *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	provision := r.kataConfig.Spec.ProvisionWorkers
+	name := r.kataMachineSetName()
+
+	spec, ok, err := unstructured.NestedMap(template.Object, "spec")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("MachineSet %s has no spec: %v", template.GetName(), err)
+	}
+
+	if err := unstructured.SetNestedField(spec, int64(provision.Replicas), "replicas"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedStringMap(spec, map[string]string{machineSetLabel: name}, "selector", "matchLabels"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(spec, name, "template", "metadata", "labels", machineSetLabel); err != nil {
+		return nil, err
+	}
+
+	var poolLabels map[string]string
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+		poolLabels = r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels
+	}
+	// the new nodes match the first of the KataConfigPoolSelectors
+	if selectors := r.kataConfig.Spec.KataConfigPoolSelectors; len(selectors) > 0 {
+		poolLabels = selectors[0].MatchLabels
+	}
+	if len(poolLabels) > 0 {
+		nodeLabels, _, err := unstructured.NestedStringMap(spec, "template", "spec", "metadata", "labels")
+		if err != nil {
+			return nil, err
+		}
+		if nodeLabels == nil {
+			nodeLabels = map[string]string{}
+		}
+		for k, v := range poolLabels {
+			nodeLabels[k] = v
+		}
+		if err := unstructured.SetNestedStringMap(spec, nodeLabels, "template", "spec", "metadata", "labels"); err != nil {
+			return nil, err
+		}
+	}
+
+	providerKind, _, _ := unstructured.NestedString(spec, "template", "spec", "providerSpec", "value", "kind")
+	instanceType := provision.InstanceType
+	if instanceType == "" && provision.BareMetal {
+		instanceType = bareMetalInstanceTypes[providerKind]
+		if instanceType == "" {
+			return nil, fmt.Errorf("no bare-metal instance type known for %s, set the instanceType of provisionWorkers", providerKind)
+		}
+	}
+	if instanceType != "" {
+		field, ok := instanceTypeFields[providerKind]
+		if !ok {
+			return nil, fmt.Errorf("the instance type of %s machines can't be set", providerKind)
+		}
+		if err := unstructured.SetNestedField(spec, instanceType, "template", "spec", "providerSpec", "value", field); err != nil {
+			return nil, err
+		}
+	}
+
+	ms := &unstructured.Unstructured{}
+	ms.SetGroupVersionKind(machineSetGVK)
+	ms.SetName(name)
+	ms.SetNamespace(machineAPINamespace)
+	ms.SetLabels(map[string]string{kataConfigOwnerLabel: r.kataConfig.Name})
+	ms.SetOwnerReferences([]metav1.OwnerReference{r.kataConfigOwnerReference()})
+	ms.Object["spec"] = spec
+	return ms, nil
+}
+
+// reconcileProvisionedWorkers creates, updates or deletes the MachineSet of the kata workers
+// following spec.provisionWorkers. The existing MachineSet is its own template, the template
+// MachineSet is only needed to create it. The MachineSet is owned by the KataConfig and goes
+// away with it
+func (r *KataConfigOpenShiftReconciler) reconcileProvisionedWorkers() error {
+	current, err := r.getMachineSet(r.kataMachineSetName())
+	if meta.IsNoMatchError(err) && r.kataConfig.Spec.ProvisionWorkers == nil {
+		// no machine API on this cluster
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if r.kataConfig.Spec.ProvisionWorkers == nil {
+		if current == nil {
+			return nil
+		}
+		r.Log.Info("Deleting the MachineSet of the kata workers", "ms.Name", current.GetName())
+		if err := r.Client.Delete(r.ctx, current); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.recordHistory(kataconfigurationv1.HistoryMachineSetDeleted,
+			fmt.Sprintf("machine set %s deleted", current.GetName()))
+		return nil
+	}
+
+	template := current
+	if template == nil {
+		if template, err = r.templateMachineSet(); err != nil {
+			return err
+		}
+	}
+
+	ms, err := r.newKataMachineSet(template)
+	if err != nil {
+		return err
+	}
+	if current != nil && equality.Semantic.DeepEqual(current.Object["spec"], ms.Object["spec"]) &&
+		hasLabels(current.GetLabels(), ms.GetLabels()) {
+		return nil
+	}
+
+	r.Log.Info("Applying the MachineSet of the kata workers", "ms.Name", ms.GetName(), "template", template.GetName(),
+		"replicas", r.kataConfig.Spec.ProvisionWorkers.Replicas)
+	if err := r.applyObject(ms); err != nil {
+		return err
+	}
+	r.recordHistory(kataconfigurationv1.HistoryMachineSetApplied,
+		fmt.Sprintf("machine set %s applied with %d replicas", ms.GetName(), r.kataConfig.Spec.ProvisionWorkers.Replicas))
+	return nil
+}
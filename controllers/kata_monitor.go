@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// kataMonitorMetricsName names the Service and the ServiceMonitor of the kata-monitor endpoints
+const kataMonitorMetricsName = "kata-monitor-metrics"
+
+// newKataMonitorDaemonset returns the daemonset running kata-monitor on the kata nodes. Its
+// metrics are only served over https, by a kube-rbac-proxy sidecar
+func (r *KataConfigOpenShiftReconciler) newKataMonitorDaemonset() (*appsv1.DaemonSet, error) {
+	ds := r.processDaemonsetForCR(MonitorOperation, "")
+	podSpec := &ds.Spec.Template.Spec
+	podSpec.Containers[0].Name = "kata-monitor"
+	podSpec.Containers = append(podSpec.Containers, kubeRBACProxyContainer("http://127.0.0.1:8090/"))
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "tls",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: kataMonitorMetricsName + "-tls",
+			},
+		},
+	})
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// reconcileKataMonitor runs kata-monitor on the kata nodes and has the OpenShift cluster
+// monitoring scrape it. The objects are owned by the KataConfig
+func (r *KataConfigOpenShiftReconciler) reconcileKataMonitor() error {
+	if err := r.applyDaemonSCC(); err != nil {
+		return err
+	}
+
+	svc := newMetricsService(kataMonitorMetricsName, map[string]string{"name": "kata-operator-daemon-" + string(MonitorOperation)})
+	if err := controllerutil.SetControllerReference(r.kataConfig, svc, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.applyObject(svc); err != nil {
+		return err
+	}
+
+	ds, err := r.newKataMonitorDaemonset()
+	if err != nil {
+		return err
+	}
+	if err := r.applyObject(ds); err != nil {
+		return err
+	}
+
+	sm := newServiceMonitor(kataMonitorMetricsName)
+	if err := controllerutil.SetControllerReference(r.kataConfig, sm, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.applyObject(sm); err != nil && !meta.IsNoMatchError(err) {
+		return err
+	}
+	return nil
+}
+
+// removeKataMonitor stops kata-monitor before kata is removed from the nodes
+func (r *KataConfigOpenShiftReconciler) removeKataMonitor() error {
+	ds := &appsv1.DaemonSet{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: "kata-operator-daemon-" + string(MonitorOperation), Namespace: daemonNamespace}, ds)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.Log.Info("Deleting the kata-monitor Daemonset", "ds.Name", ds.Name)
+	if err := r.Client.Delete(r.ctx, ds); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
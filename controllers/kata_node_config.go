@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// kataNodeConfigsEnv passes the KataNodeConfigs to the node config daemon, which merges the
+	// ones selecting its node into a kata drop-in next to the one of the MachineConfig. The
+	// drop-in is kept out of the MachineConfig, a MachineConfig applies to a whole pool
+	kataNodeConfigsEnv = "KATA_NODE_CONFIGS"
+
+	// nodeConfiguredMarker is created by the node config daemon once the drop-in is written, the
+	// pod is ready from then on
+	nodeConfiguredMarker = "/tmp/kata-node-configured"
+)
+
+// renderNodeConfigs returns the specs of the KataNodeConfigs, ordered by name, as passed to the
+// node config daemon. It returns an empty string when there is none
+func (r *KataConfigOpenShiftReconciler) renderNodeConfigs() (string, error) {
+	nodeConfigs := &kataconfigurationv1.KataNodeConfigList{}
+	if err := r.Client.List(r.ctx, nodeConfigs); err != nil {
+		return "", err
+	}
+	if len(nodeConfigs.Items) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(nodeConfigs.Items, func(i, j int) bool {
+		return nodeConfigs.Items[i].Name < nodeConfigs.Items[j].Name
+	})
+	var specs []kataconfigurationv1.KataNodeConfigSpec
+	for _, nodeConfig := range nodeConfigs.Items {
+		specs = append(specs, nodeConfig.Spec)
+	}
+	rendered, err := json.Marshal(specs)
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// newNodeConfigDaemonset returns the daemonset applying the KataNodeConfigs on the kata nodes.
+// A change of the KataNodeConfigs rolls the daemonset out node by node
+func (r *KataConfigOpenShiftReconciler) newNodeConfigDaemonset(nodeConfigs string) (*appsv1.DaemonSet, error) {
+	ds := r.processDaemonsetForCR(NodeConfigOperation, "")
+	container := &ds.Spec.Template.Spec.Containers[0]
+	container.Name = "kata-nodeconfig-pod"
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  kataNodeConfigsEnv,
+		Value: nodeConfigs,
+	})
+	container.ReadinessProbe = &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"test", "-f", nodeConfiguredMarker},
+			},
+		},
+		PeriodSeconds: 5,
+	}
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// daemonsetEnv returns the value of the environment variable set on the daemonset
+func daemonsetEnv(ds *appsv1.DaemonSet, name string) string {
+	for _, container := range ds.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// reconcileNodeConfigs rolls out the KataNodeConfigs. The node config daemonset is created the
+// first time a KataNodeConfig exists and kept until the uninstallation, so that the settings of
+// the KataNodeConfigs deleted later are removed from the nodes too
+func (r *KataConfigOpenShiftReconciler) reconcileNodeConfigs() error {
+	nodeConfigs, err := r.renderNodeConfigs()
+	if err != nil {
+		return err
+	}
+
+	ds, err := r.newNodeConfigDaemonset(nodeConfigs)
+	if err != nil {
+		return err
+	}
+
+	foundDs := &appsv1.DaemonSet{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) && nodeConfigs == "" {
+		return nil
+	}
+	if err == nil && daemonsetEnv(foundDs, kataNodeConfigsEnv) == nodeConfigs {
+		return nil
+	}
+
+	if err := r.applyDaemonSCC(); err != nil {
+		return err
+	}
+
+	r.Log.Info("Applying the KataNodeConfigs on the nodes", "ds.Name", ds.Name)
+	r.recordHistory(kataconfigurationv1.HistoryNodeConfigsApplied,
+		fmt.Sprintf("KataNodeConfigs rolled out by daemonset %s", ds.Name))
+	return r.applyObject(ds)
+}
+
+// removeNodeConfigs deletes the node config daemonset before kata is removed from the nodes, the
+// uninstallation removes the drop-in
+func (r *KataConfigOpenShiftReconciler) removeNodeConfigs() error {
+	ds := &appsv1.DaemonSet{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: "kata-operator-daemon-" + string(NodeConfigOperation), Namespace: daemonNamespace}, ds)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.Log.Info("Deleting the node config Daemonset", "ds.Name", ds.Name)
+	if err := r.Client.Delete(r.ctx, ds); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
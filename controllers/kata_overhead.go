@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// kataHypervisor is the hypervisor the kata payloads configure by default
+	kataHypervisor = "qemu"
+
+	// defaultGuestMemory and defaultGuestVCPUs are the kata defaults for the size of the guests,
+	// in MiB
+	defaultGuestMemory = 2048
+	defaultGuestVCPUs  = 1
+)
+
+// hypervisorFootprint is what a kata sandbox costs the node besides the memory and the vCPUs
+// of its guest
+type hypervisorFootprint struct {
+	// memory of the shim and of the hypervisor process, in MiB
+	memory int64
+	// guestMemoryRatio is the share of the guest memory the hypervisor uses on top of it, for
+	// the page tables and the device emulation
+	guestMemoryRatio int64
+	// cpu of the shim and of the hypervisor process, and cpuPerVCPU for every vCPU of the
+	// guest, in millicores
+	cpu        int64
+	cpuPerVCPU int64
+}
+
+// hypervisorFootprints are measured on idle sandboxes. With the default guest size the qemu
+// footprint adds up to the overhead of the upstream kata-deploy RuntimeClass, see
+// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
+var hypervisorFootprints = map[string]hypervisorFootprint{
+	kataHypervisor: {memory: 128, guestMemoryRatio: 64, cpu: 150, cpuPerVCPU: 100},
+}
+
+// guestSize returns the memory in MiB and the vCPUs the kata guests start with
+func guestSize(kataConfig *kataconfigurationv1.KataConfig) (memory int64, vcpus int64) {
+	memory, vcpus = defaultGuestMemory, defaultGuestVCPUs
+	if h := kataConfig.Spec.Hypervisor; h != nil {
+		if h.DefaultMemory != 0 {
+			memory = int64(h.DefaultMemory)
+		}
+		if h.DefaultVCPUs != 0 {
+			vcpus = int64(h.DefaultVCPUs)
+		}
+	}
+	return memory, vcpus
+}
+
+// podOverhead returns the pod overhead of a kata RuntimeClass: the given one set in the
+// KataConfig, or else the footprint of the hypervisor for the configured guest size
+func podOverhead(kataConfig *kataconfigurationv1.KataConfig, overhead corev1.ResourceList) corev1.ResourceList {
+	if len(overhead) > 0 {
+		return overhead.DeepCopy()
+	}
+
+	footprint := hypervisorFootprints[kataHypervisor]
+	memory, vcpus := guestSize(kataConfig)
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(footprint.cpu+vcpus*footprint.cpuPerVCPU, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity((footprint.memory+memory/footprint.guestMemoryRatio)*1024*1024, resource.BinarySI),
+	}
+}
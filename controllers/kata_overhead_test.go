@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodOverhead(t *testing.T) {
+	tests := []struct {
+		name       string
+		hypervisor *kataconfigurationv1.KataHypervisorConfig
+		overhead   corev1.ResourceList
+		cpu        string
+		memory     string
+	}{
+		// the overhead of the upstream kata-deploy RuntimeClass
+		{name: "default guest", cpu: "250m", memory: "160Mi"},
+		{
+			name:       "larger guest",
+			hypervisor: &kataconfigurationv1.KataHypervisorConfig{DefaultMemory: 8192, DefaultVCPUs: 4},
+			cpu:        "550m",
+			memory:     "256Mi",
+		},
+		{
+			name:       "memory only",
+			hypervisor: &kataconfigurationv1.KataHypervisorConfig{DefaultMemory: 4096},
+			cpu:        "250m",
+			memory:     "192Mi",
+		},
+		{
+			name:       "overhead set in the KataConfig",
+			hypervisor: &kataconfigurationv1.KataHypervisorConfig{DefaultMemory: 8192, DefaultVCPUs: 4},
+			overhead: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+			cpu:    "1",
+			memory: "1Gi",
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{Spec: kataconfigurationv1.KataConfigSpec{Hypervisor: test.hypervisor}}
+		overhead := podOverhead(kataConfig, test.overhead)
+		cpu, memory := overhead[corev1.ResourceCPU], overhead[corev1.ResourceMemory]
+		if cpu.Cmp(resource.MustParse(test.cpu)) != 0 || memory.Cmp(resource.MustParse(test.memory)) != 0 {
+			t.Errorf("%s: expected an overhead of %s CPU and %s memory, got %s and %s",
+				test.name, test.cpu, test.memory, cpu.String(), memory.String())
+		}
+	}
+}
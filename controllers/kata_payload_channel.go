@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultPayloadArchitecture is the architecture of the KataPayloads that don't set one
+	defaultPayloadArchitecture = "amd64"
+
+	// kataPayloadsPath records on the nodes the channel payloads they run. A new payload
+	// changes the MachineConfig, which reboots the nodes into the deployment it was staged in
+	kataPayloadsPath = "/etc/kata-containers/payloads"
+)
+
+// clusterOpenShiftVersion returns the OpenShift version the cluster runs, or is updating to.
+// The ClusterVersion is read from the API server, the operator may only get it. It is read
+// once per reconcile, every payload of the reconcile is checked against it
+func (r *KataConfigOpenShiftReconciler) clusterOpenShiftVersion() (*version.Version, error) {
+	if r.clusterVersion != nil {
+		return r.clusterVersion, nil
+	}
+	clusterVersion := &configv1.ClusterVersion{}
+	if err := r.APIReader.Get(r.ctx, types.NamespacedName{Name: "version"}, clusterVersion); err != nil {
+		return nil, err
+	}
+	v, err := version.ParseGeneric(clusterVersion.Status.Desired.Version)
+	if err != nil {
+		return nil, err
+	}
+	r.clusterVersion = v
+	return v, nil
+}
+
+// supportsOpenShift tells whether the payload supports the major.minor of the cluster version
+func supportsOpenShift(payload *kataconfigurationv1.KataPayload, clusterVersion *version.Version) bool {
+	for _, supported := range payload.Spec.OpenShiftVersions {
+		v, err := version.ParseGeneric(supported)
+		if err == nil && v.Major() == clusterVersion.Major() && v.Minor() == clusterVersion.Minor() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveChannelPayload returns the payload with the newest kata version released in the
+// channel for the cluster version and the architecture, nil if there is none
+func resolveChannelPayload(payloads []kataconfigurationv1.KataPayload, channel kataconfigurationv1.KataChannel,
+	clusterVersion *version.Version, arch string) *kataconfigurationv1.KataResolvedPayload {
+	var newest *kataconfigurationv1.KataPayload
+	var newestVersion *version.Version
+	for i := range payloads {
+		payload := &payloads[i]
+		payloadArch := payload.Spec.Architecture
+		if payloadArch == "" {
+			payloadArch = defaultPayloadArchitecture
+		}
+		released := false
+		for _, c := range payload.Spec.Channels {
+			released = released || c == channel
+		}
+		if payloadArch != arch || !released || !supportsOpenShift(payload, clusterVersion) {
+			continue
+		}
+
+		kataVersion, err := version.ParseGeneric(payload.Spec.KataVersion)
+		if err != nil {
+			continue
+		}
+		if newest == nil || newestVersion.LessThan(kataVersion) {
+			newest, newestVersion = payload, kataVersion
+		}
+	}
+
+	if newest == nil {
+		return nil
+	}
+	return &kataconfigurationv1.KataResolvedPayload{
+		Architecture: arch,
+		Name:         newest.Name,
+		KataVersion:  newest.Spec.KataVersion,
+		Image:        newest.Spec.Image,
+		Shim:         newest.Spec.Shim,
+	}
+}
+
+// payloadImages returns the payload image of every architecture: the one of
+// spec.payloadImages, or else the one resolved from the channel
+func (r *KataConfigOpenShiftReconciler) payloadImages() map[string]string {
+	images := map[string]string{}
+	for _, payload := range r.kataConfig.Status.Upgradestatus.Payloads {
+		images[payload.Architecture] = payload.Image
+	}
+	for arch, image := range r.kataConfig.Spec.PayloadImages {
+		images[arch] = image
+	}
+	return images
+}
+
+// reconcileChannel resolves the payloads of the channel of the KataConfig out of the
+// KataPayload catalog. When the channel advances past the payloads the nodes run, the installed
+// nodes are reported as installing again, which reruns the install daemonset on them with the
+// new payload. Once they have all staged it the MachineConfig records the new payloads and the
+// nodes reboot into them. It returns how long to wait when the MachineConfig update is held by
+// the maintenance window
+func (r *KataConfigOpenShiftReconciler) reconcileChannel() (time.Duration, error) {
+	channel := r.kataConfig.Spec.Channel
+	if channel == "" || r.extensionDelivery() {
+		if !reflect.DeepEqual(r.kataConfig.Status.Upgradestatus, kataconfigurationv1.KataUpgradeStatus{}) {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				status.Upgradestatus = kataconfigurationv1.KataUpgradeStatus{}
+				meta.RemoveStatusCondition(&status.Conditions, kataconfigurationv1.KataConfigPayloadUnresolved)
+			})
+		}
+		return 0, nil
+	}
+
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return 0, err
+	}
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved, err := r.resolveChannel(channel, nodes)
+	if err != nil {
+		return 0, err
+	}
+
+	upgrade := r.kataConfig.Status.Upgradestatus
+	if upgrade.Channel != channel || !reflect.DeepEqual(upgrade.Payloads, resolved) {
+		r.recordResolvedPayloads(channel, upgrade.Payloads, resolved)
+		installed := r.kataConfig.Status.RuntimeClass != ""
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.Upgradestatus.Channel = channel
+			status.Upgradestatus.Payloads = resolved
+			// the nodes not installed yet install the resolved payloads right away
+			if !installed {
+				status.Upgradestatus.InstalledPayloads = resolved
+			}
+		})
+		if installed {
+			if err := r.startPayloadUpgrade(nodes); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return r.rollOutStagedPayloads(machinePool)
+}
+
+// resolveChannel resolves the channel for every architecture of the kata pool, the ones of
+// spec.payloadImages aside. An architecture without any payload keeps the payload resolved
+// before, if any, and is reported in the PayloadUnresolved condition
+func (r *KataConfigOpenShiftReconciler) resolveChannel(channel kataconfigurationv1.KataChannel, nodes []corev1.Node) ([]kataconfigurationv1.KataResolvedPayload, error) {
+	clusterVersion, err := r.clusterOpenShiftVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := &kataconfigurationv1.KataPayloadList{}
+	if err := r.Client.List(r.ctx, payloads); err != nil {
+		return nil, err
+	}
+
+	archs := nodeArchitectures(nodes)
+	if len(archs) == 0 {
+		archs = []string{defaultPayloadArchitecture}
+	}
+
+	previous := map[string]kataconfigurationv1.KataResolvedPayload{}
+	for _, payload := range r.kataConfig.Status.Upgradestatus.Payloads {
+		previous[payload.Architecture] = payload
+	}
+
+	var resolved []kataconfigurationv1.KataResolvedPayload
+	var unresolved []string
+	for _, arch := range archs {
+		if _, ok := r.kataConfig.Spec.PayloadImages[arch]; ok {
+			continue
+		}
+		if payload := resolveChannelPayload(payloads.Items, channel, clusterVersion, arch); payload != nil {
+			resolved = append(resolved, *payload)
+			continue
+		}
+		unresolved = append(unresolved, arch)
+		if payload, ok := previous[arch]; ok {
+			resolved = append(resolved, payload)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].Architecture < resolved[j].Architecture })
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigPayloadUnresolved,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: fmt.Sprintf("the %s channel has payloads for OpenShift %d.%d", channel, clusterVersion.Major(), clusterVersion.Minor()),
+	}
+	if len(unresolved) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NoPayloadInChannel"
+		condition.Message = fmt.Sprintf("no KataPayload in the %s channel for OpenShift %d.%d on %s",
+			channel, clusterVersion.Major(), clusterVersion.Minor(), strings.Join(unresolved, ", "))
+	}
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Reason, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return resolved, nil
+}
+
+// recordResolvedPayloads records the payloads the channel newly resolved to in the history
+// and in events
+func (r *KataConfigOpenShiftReconciler) recordResolvedPayloads(channel kataconfigurationv1.KataChannel,
+	previous, resolved []kataconfigurationv1.KataResolvedPayload) {
+	for _, payload := range resolved {
+		unchanged := false
+		for _, p := range previous {
+			unchanged = unchanged || p == payload
+		}
+		if unchanged {
+			continue
+		}
+		message := fmt.Sprintf("the %s channel resolved to kata %s on %s, payload %s (%s)",
+			channel, payload.KataVersion, payload.Architecture, payload.Name, payload.Image)
+		r.Log.Info("Kata payload resolved", "channel", channel, "arch", payload.Architecture,
+			"payload", payload.Name, "kataVersion", payload.KataVersion)
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "PayloadResolved", message)
+		r.recordHistory(kataconfigurationv1.HistoryPayloadResolved, message)
+	}
+}
+
+// startPayloadUpgrade reports the installed nodes whose payload changed as installing again,
+// with the Upgrade reason for the daemon to stage the new payload
+func (r *KataConfigOpenShiftReconciler) startPayloadUpgrade(nodes []corev1.Node) error {
+	installedImages := map[string]string{}
+	for _, payload := range r.kataConfig.Status.Upgradestatus.InstalledPayloads {
+		installedImages[payload.Architecture] = payload.Image
+	}
+	images := r.payloadImages()
+
+	patch, err := nodeprogress.Patch(nodeprogress.Progress{
+		KataConfig: r.kataConfig.Name,
+		State:      nodeprogress.Installing,
+		Reason:     nodeprogress.ReasonUpgrade,
+	})
+	if err != nil {
+		return err
+	}
+
+	var upgrading []string
+	for i := range nodes {
+		node := &nodes[i]
+		arch := node.Labels[nodeArchLabel]
+		if _, ok := r.kataConfig.Spec.PayloadImages[arch]; ok || images[arch] == installedImages[arch] ||
+			!contains(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList, node.Name) {
+			continue
+		}
+
+		r.Log.Info("Upgrading the kata payload of the node", "node", node.Name, "image", images[arch])
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+		upgrading = append(upgrading, node.Name)
+	}
+
+	if len(upgrading) == 0 {
+		return nil
+	}
+	r.recordHistory(kataconfigurationv1.HistoryPayloadApplied,
+		fmt.Sprintf("payload upgrade started on %s", strings.Join(upgrading, ", ")))
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		for _, name := range upgrading {
+			if !contains(status.Upgradestatus.UpgradingNodesList, name) {
+				status.Upgradestatus.UpgradingNodesList = append(status.Upgradestatus.UpgradingNodesList, name)
+			}
+		}
+	})
+	return nil
+}
+
+// rollOutStagedPayloads updates the MachineConfig with the resolved payloads once the upgraded
+// nodes have all staged them, or failed to. The update reboots the nodes into the new payload
+func (r *KataConfigOpenShiftReconciler) rollOutStagedPayloads(machinePool string) (time.Duration, error) {
+	upgrading := r.kataConfig.Status.Upgradestatus.UpgradingNodesList
+	if len(upgrading) == 0 {
+		return 0, nil
+	}
+
+	installation := r.kataConfig.Status.InstallationStatus
+	for _, name := range upgrading {
+		failed := false
+		for _, node := range installation.Failed.FailedNodesList {
+			failed = failed || node.Name == name
+		}
+		if !failed && !contains(installation.InProgress.BinariesInstalledNodesList, name) {
+			r.Log.Info("Waiting for the nodes to stage the new kata payload", "node", name)
+			return 0, nil
+		}
+	}
+
+	resolved := r.kataConfig.Status.Upgradestatus.Payloads
+	if !reflect.DeepEqual(r.kataConfig.Status.Upgradestatus.InstalledPayloads, resolved) {
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.Upgradestatus.InstalledPayloads = resolved
+		})
+	}
+	if wait, err := r.updateMachineConfig(machinePool); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.Upgradestatus.UpgradingNodesList = nil
+	})
+	return 0, nil
+}
+
+// generateInstalledPayloads returns the base64 content of kataPayloadsPath, empty when kata
+// is not installed from a channel
+func generateInstalledPayloads(payloads []kataconfigurationv1.KataResolvedPayload) string {
+	if len(payloads) == 0 {
+		return ""
+	}
+	var content strings.Builder
+	for _, payload := range payloads {
+		fmt.Fprintf(&content, "%s %s %s %s\n", payload.Architecture, payload.Name, payload.KataVersion, payload.Image)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(content.String()))
+}
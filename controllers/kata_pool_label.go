@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kataPoolLabel is set by the operator on the nodes matching any of the KataConfigPoolSelectors.
+// A machine config pool only has one node selector, the kata pool selects this label instead
+const kataPoolLabel = "kata.openshift.io/kata-pool"
+
+// kataExcludeLabel keeps a node out of the kata pool when set to true, e.g. a flaky machine,
+// even if it matches the pool selectors
+const kataExcludeLabel = "kata.openshift.io/exclude"
+
+// notExcluded selects the nodes that aren't excluded from the kata pool
+var notExcluded = metav1.LabelSelectorRequirement{
+	Key:      kataExcludeLabel,
+	Operator: metav1.LabelSelectorOpNotIn,
+	Values:   []string{"true"},
+}
+
+// poolExclusions returns the requirements keeping the excluded nodes, and the nodes dedicated
+// to the OpenShift Virtualization VMs, out of the kata pool
+func poolExclusions(kataConfig *kataconfigurationv1.KataConfig) []metav1.LabelSelectorRequirement {
+	exclusions := []metav1.LabelSelectorRequirement{notExcluded}
+	if kataConfig.Spec.KubeVirt == nil {
+		return exclusions
+	}
+	keys := make([]string, 0, len(kataConfig.Spec.KubeVirt.VMNodeLabels))
+	for k := range kataConfig.Spec.KubeVirt.VMNodeLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		exclusions = append(exclusions, metav1.LabelSelectorRequirement{
+			Key:      k,
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   []string{kataConfig.Spec.KubeVirt.VMNodeLabels[k]},
+		})
+	}
+	return exclusions
+}
+
+// excludedNode tells whether the node labels keep it out of the kata pool
+func excludedNode(kataConfig *kataconfigurationv1.KataConfig, nodeLabels map[string]string) bool {
+	for _, requirement := range poolExclusions(kataConfig) {
+		if value, ok := nodeLabels[requirement.Key]; ok && value == requirement.Values[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// kataPoolSelector returns the selector of the kata pool: the KataConfigPoolSelector, or the
+// kata pool label when the KataConfig sets KataConfigPoolSelectors, without the excluded nodes.
+// Nil selects the whole worker pool
+func kataPoolSelector(kataConfig *kataconfigurationv1.KataConfig) *metav1.LabelSelector {
+	var selector *metav1.LabelSelector
+	if len(kataConfig.Spec.KataConfigPoolSelectors) > 0 {
+		selector = &metav1.LabelSelector{MatchLabels: map[string]string{kataPoolLabel: "true"}}
+	} else if kataConfig.Spec.KataConfigPoolSelector != nil {
+		selector = kataConfig.Spec.KataConfigPoolSelector.DeepCopy()
+	} else {
+		return nil
+	}
+
+	selector.MatchExpressions = append(selector.MatchExpressions, poolExclusions(kataConfig)...)
+	return selector
+}
+
+// kataNodesSelector returns the selector of the nodes kata is installed on: the ones of the
+// kata pool, or all the nodes of the given machine pool role, without the excluded nodes
+func kataNodesSelector(kataConfig *kataconfigurationv1.KataConfig, machinePool string) (labels.Selector, error) {
+	selector := kataPoolSelector(kataConfig)
+	if selector == nil {
+		selector = &metav1.LabelSelector{
+			MatchLabels:      map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+			MatchExpressions: poolExclusions(kataConfig),
+		}
+	}
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// kataPoolSelectors returns the KataConfigPoolSelectors as selectors
+func kataPoolSelectors(kataConfig *kataconfigurationv1.KataConfig) ([]labels.Selector, error) {
+	var selectors []labels.Selector
+	for i := range kataConfig.Spec.KataConfigPoolSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&kataConfig.Spec.KataConfigPoolSelectors[i])
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// matchesAny tells whether the node labels match any of the selectors
+func matchesAny(selectors []labels.Selector, nodeLabels map[string]string) bool {
+	for _, selector := range selectors {
+		if selector.Matches(labels.Set(nodeLabels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// labelKataPoolNodes sets the kata pool label on the nodes matching any of the
+// KataConfigPoolSelectors and removes it from the other nodes, from all of them once the
+// KataConfig no longer sets KataConfigPoolSelectors. The labels are left alone while kata is
+// uninstalled, the uninstallation removes them from the nodes it is done with
+func (r *KataConfigOpenShiftReconciler) labelKataPoolNodes() error {
+	if r.kataConfig.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil && len(r.kataConfig.Spec.KataConfigPoolSelectors) > 0 {
+		return fmt.Errorf("the KataConfigPoolSelector and the KataConfigPoolSelectors can't be set together")
+	}
+
+	selectors, err := kataPoolSelectors(r.kataConfig)
+	if err != nil {
+		return err
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList); err != nil {
+		return err
+	}
+
+	for i := range nodesList.Items {
+		node := &nodesList.Items[i]
+		selected := matchesAny(selectors, node.GetLabels())
+		_, labeled := node.GetLabels()[kataPoolLabel]
+		if labeled == selected {
+			continue
+		}
+
+		original := node.DeepCopy()
+		nodeLabels := node.GetLabels()
+		if labeled {
+			delete(nodeLabels, kataPoolLabel)
+		} else {
+			if nodeLabels == nil {
+				nodeLabels = map[string]string{}
+			}
+			nodeLabels[kataPoolLabel] = "true"
+		}
+
+		r.Log.Info("Updating the kata pool label of the node", "node", node.Name, "selected", selected)
+		node.SetLabels(nodeLabels)
+		if err := r.Client.Patch(r.ctx, node, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestKataPoolSelectors(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{Spec: kataconfigurationv1.KataConfigSpec{
+		KataConfigPoolSelectors: []metav1.LabelSelector{
+			{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "custom-kata2", Operator: metav1.LabelSelectorOpIn, Values: []string{"test", "prod"}},
+			}},
+		},
+	}}
+	selectors, err := kataPoolSelectors(kataConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		nodeLabels map[string]string
+		expected   bool
+	}{
+		{name: "first selector", nodeLabels: map[string]string{"custom-kata1": "test"}, expected: true},
+		{name: "second selector", nodeLabels: map[string]string{"custom-kata2": "prod"}, expected: true},
+		{name: "no selector", nodeLabels: map[string]string{"custom-kata1": "prod"}},
+	}
+	for _, test := range tests {
+		if matched := matchesAny(selectors, test.nodeLabels); matched != test.expected {
+			t.Errorf("%s: expected the pool selectors to match %v, got %v", test.name, test.expected, matched)
+		}
+	}
+
+	// the kata pool is the nodes labeled for any of the selectors
+	selector, err := metav1.LabelSelectorAsSelector(kataPoolSelector(kataConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !selector.Matches(labels.Set{kataPoolLabel: "true"}) || selector.Matches(labels.Set{"custom-kata1": "test"}) {
+		t.Errorf("expected the kata pool to select the %s label, got %s", kataPoolLabel, selector)
+	}
+}
+
+func TestKataNodesSelectorExclusions(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     kataconfigurationv1.KataConfigSpec
+		selects  map[string]string
+		excludes map[string]string
+	}{
+		{
+			name:     "whole worker pool",
+			selects:  map[string]string{"node-role.kubernetes.io/worker": ""},
+			excludes: map[string]string{"node-role.kubernetes.io/worker": "", kataExcludeLabel: "true"},
+		},
+		{
+			name: "pool selector",
+			spec: kataconfigurationv1.KataConfigSpec{KataConfigPoolSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"custom-kata1": "test"},
+			}},
+			selects:  map[string]string{"custom-kata1": "test", kataExcludeLabel: "false"},
+			excludes: map[string]string{"custom-kata1": "test", kataExcludeLabel: "true"},
+		},
+		{
+			name: "pool selectors",
+			spec: kataconfigurationv1.KataConfigSpec{KataConfigPoolSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			}},
+			selects:  map[string]string{kataPoolLabel: "true"},
+			excludes: map[string]string{kataPoolLabel: "true", kataExcludeLabel: "true"},
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{Spec: test.spec}
+		selector, err := kataNodesSelector(kataConfig, "worker")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !selector.Matches(labels.Set(test.selects)) || excludedNode(kataConfig, test.selects) {
+			t.Errorf("%s: expected %s to select %v", test.name, selector, test.selects)
+		}
+		if selector.Matches(labels.Set(test.excludes)) || !excludedNode(kataConfig, test.excludes) {
+			t.Errorf("%s: expected %s to exclude %v", test.name, selector, test.excludes)
+		}
+	}
+}
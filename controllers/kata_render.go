@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// defaultRenderConfigMapName is the ConfigMap the managed objects are rendered into when
+// spec.render doesn't name one
+const defaultRenderConfigMapName = "kata-manifests"
+
+// renderKey returns the key of obj in the render ConfigMap, e.g.
+// machineconfig-50-kata-crio-dropin.json. Only the MachineConfigs, the MachineConfigPools and the
+// RuntimeClasses are rendered, the other objects are applied in every mode
+func renderKey(obj runtime.Object) (string, bool) {
+	var kind string
+	switch obj.(type) {
+	case *mcfgv1.MachineConfig:
+		kind = "machineconfig"
+	case *mcfgv1.MachineConfigPool:
+		kind = "machineconfigpool"
+	case *nodeapi.RuntimeClass:
+		kind = "runtimeclass"
+	default:
+		return "", false
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", false
+	}
+	return kind + "-" + accessor.GetName() + ".json", true
+}
+
+// rendering returns true when the managed objects are rendered instead of applied
+func (r *KataConfigOpenShiftReconciler) rendering() bool {
+	return r.kataConfig != nil && r.kataConfig.Spec.Render != nil
+}
+
+// renderConfigMapName returns the ConfigMap set in spec.render, with its defaults
+func (r *KataConfigOpenShiftReconciler) renderConfigMapName() types.NamespacedName {
+	name := types.NamespacedName{Namespace: operatorNamespace, Name: defaultRenderConfigMapName}
+	if render := r.kataConfig.Spec.Render; render != nil {
+		if render.Namespace != "" {
+			name.Namespace = render.Namespace
+		}
+		if render.Name != "" {
+			name.Name = render.Name
+		}
+	}
+	return name
+}
+
+// renderManifest returns obj the way the GitOps tool applies it: with its TypeMeta and without
+// the fields set by the API server. The owner references are dropped too, the uid of the
+// KataConfig is only valid on this cluster
+func (r *KataConfigOpenShiftReconciler) renderManifest(obj runtime.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return "", err
+	}
+	rendered := obj.DeepCopyObject()
+	rendered.GetObjectKind().SetGroupVersionKind(gvk)
+	accessor, err := meta.Accessor(rendered)
+	if err != nil {
+		return "", err
+	}
+	accessor.SetOwnerReferences(nil)
+	accessor.SetManagedFields(nil)
+	accessor.SetResourceVersion("")
+	accessor.SetUID("")
+	accessor.SetGeneration(0)
+	accessor.SetCreationTimestamp(metav1.Time{})
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rendered)
+	if err != nil {
+		return "", err
+	}
+	delete(content, "status")
+	unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+
+	manifest, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(manifest), nil
+}
+
+// updateRenderConfigMap sets the manifest of key in the render ConfigMap, or removes the key
+// when the manifest is empty. The ConfigMap is only written when it changes
+func (r *KataConfigOpenShiftReconciler) updateRenderConfigMap(key string, manifest string) error {
+	name := r.renderConfigMapName()
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(r.ctx, name, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	current, found := cm.Data[key]
+	if (manifest == "" && !found) || (found && current == manifest) {
+		return nil
+	}
+
+	data := map[string]string{}
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	if manifest == "" {
+		r.Log.Info("Removing the manifest from the render ConfigMap", "key", key, "cm.Name", name.Name)
+		delete(data, key)
+	} else {
+		r.Log.Info("Rendering the manifest into the render ConfigMap", "key", key, "cm.Name", name.Name)
+		data[key] = manifest
+	}
+
+	return r.applyObject(&corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+		Data: data,
+	})
+}
+
+// deleteObject deletes obj, or removes its manifest from the render ConfigMap in render mode.
+// It returns the error of the API server unchanged, NotFound included
+func (r *KataConfigOpenShiftReconciler) deleteObject(obj runtime.Object) error {
+	if key, ok := renderKey(obj); ok && r.rendering() {
+		return r.updateRenderConfigMap(key, "")
+	}
+	return r.Client.Delete(r.ctx, obj)
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ignTypes "github.com/coreos/ignition/config/v2_2/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// renderedConfigMapName is the ConfigMap the operator publishes the configuration it renders
+	// for the nodes in
+	renderedConfigMapName = "kata-rendered-config"
+
+	// renderedGenerationAnnotation is the generation of the KataConfig the configuration is
+	// rendered from
+	renderedGenerationAnnotation = "kataconfiguration.openshift.io/rendered-generation"
+
+	// ignitionDataURLPrefix prefixes the contents of the files of the kata MachineConfig
+	ignitionDataURLPrefix = "data:text/plain;charset=utf-8;base64,"
+)
+
+// RenderedConfigMap returns the name of the ConfigMap the configuration rendered for the nodes is
+// published in
+func RenderedConfigMap() types.NamespacedName {
+	return types.NamespacedName{Namespace: operatorNamespace, Name: renderedConfigMapName}
+}
+
+// renderedConfigKey returns the ConfigMap key of a file rendered for the nodes, e.g.
+// etc_crio_crio.conf.d_50-kata.conf for /etc/crio/crio.conf.d/50-kata.conf
+func renderedConfigKey(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_")
+}
+
+// machineConfigFiles returns the files written by the MachineConfig, by path
+func machineConfigFiles(mc *mcfgv1.MachineConfig) (map[string]string, error) {
+	ic := ignTypes.Config{}
+	if err := json.Unmarshal(mc.Spec.Config.Raw, &ic); err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, file := range ic.Storage.Files {
+		contents, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(file.Contents.Source, ignitionDataURLPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %s: %v", file.Path, err)
+		}
+		files[file.Path] = string(contents)
+	}
+	return files, nil
+}
+
+// newRenderedConfigMap returns the ConfigMap holding the kata MachineConfig the operator
+// intends to apply, the files and units of its ignition config, the reloadable CRI-O drop-in
+// and the KataNodeConfigs, so that they can be reviewed before the machine config pool rolls
+// them out and compared across versions
+func (r *KataConfigOpenShiftReconciler) newRenderedConfigMap(machinePool string) (*corev1.ConfigMap, error) {
+	mc, err := r.newMCForCR(machinePool)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{}
+	rendered, err := json.MarshalIndent(mc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data["machineconfig.json"] = string(rendered)
+
+	files, err := machineConfigFiles(mc)
+	if err != nil {
+		return nil, err
+	}
+	for path, contents := range files {
+		data[renderedConfigKey(path)] = contents
+	}
+	ic := ignTypes.Config{}
+	if err := json.Unmarshal(mc.Spec.Config.Raw, &ic); err != nil {
+		return nil, err
+	}
+	for _, unit := range ic.Systemd.Units {
+		data[renderedConfigKey("/etc/systemd/system/"+unit.Name)] = unit.Contents
+	}
+
+	handler, err := r.kataHandlerEnabled()
+	if err != nil {
+		return nil, err
+	}
+	policyAnnotations, err := r.policyAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	reloadable, err := generateReloadableCrioDropin(r.kataConfig, policyAnnotations, handler)
+	if err != nil {
+		return nil, err
+	}
+	if reloadable != "" {
+		data[renderedConfigKey("/etc/crio/crio.conf.d/51-kata-reloadable.conf")] = reloadable
+	}
+
+	nodeConfigs, err := r.renderNodeConfigs()
+	if err != nil {
+		return nil, err
+	}
+	if nodeConfigs != "" {
+		data["katanodeconfigs.json"] = nodeConfigs
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      renderedConfigMapName,
+			Namespace: operatorNamespace,
+			Annotations: map[string]string{
+				renderedGenerationAnnotation: fmt.Sprintf("%d", r.kataConfig.Generation),
+			},
+		},
+		Data: data,
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// publishRenderedConfig publishes the configuration rendered from the current KataConfig spec,
+// ahead of the rollout
+func (r *KataConfigOpenShiftReconciler) publishRenderedConfig() error {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return err
+	}
+
+	cm, err := r.newRenderedConfigMap(machinePool)
+	if err != nil {
+		return err
+	}
+	return r.applyObject(cm)
+}
@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/initdata"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// kataConfigDropinPath is where the operator rendered kata configuration is placed on the nodes.
+	// kata-runtime merges the files found in config.d on top of its configuration.toml
+	kataConfigDropinPath = "/etc/kata-containers/config.d/50-kata-operator.toml"
+
+	nodeArchLabel = "kubernetes.io/arch"
+
+	archAMD64   = "amd64"
+	archARM64   = "arm64"
+	archPPC64LE = "ppc64le"
+	archS390X   = "s390x"
+
+	// defaultRuntimeHandler is the name of the kata runtime handler of CRI-O unless the
+	// KataConfig sets one
+	defaultRuntimeHandler = "kata"
+
+	// the kata shim of the runtime handlers unless the KataConfig or the payload sets another
+	defaultShimPath    = "/usr/bin/containerd-shim-kata-v2"
+	defaultRuntimeType = "vm"
+	defaultRuntimeRoot = "/run/vc"
+)
+
+// runtimeHandler returns the name of the kata runtime handler of CRI-O
+func runtimeHandler(kataConfig *kataconfigurationv1.KataConfig) string {
+	if kataConfig.Spec.RuntimeHandler != "" {
+		return kataConfig.Spec.RuntimeHandler
+	}
+	return defaultRuntimeHandler
+}
+
+// kataShim returns the kata shim of the runtime handlers: every field is taken from the
+// KataConfig, or else from the first resolved payload setting it, or else from the defaults
+func kataShim(kataConfig *kataconfigurationv1.KataConfig) kataconfigurationv1.KataShimConfig {
+	sources := []*kataconfigurationv1.KataShimConfig{kataConfig.Spec.Shim}
+	payloads := append([]kataconfigurationv1.KataResolvedPayload{}, kataConfig.Status.Upgradestatus.Payloads...)
+	sort.Slice(payloads, func(i, j int) bool {
+		return payloads[i].Architecture < payloads[j].Architecture
+	})
+	for _, payload := range payloads {
+		sources = append(sources, payload.Shim)
+	}
+	sources = append(sources, &kataconfigurationv1.KataShimConfig{
+		Path: defaultShimPath,
+		Type: defaultRuntimeType,
+		Root: defaultRuntimeRoot,
+	})
+
+	var shim kataconfigurationv1.KataShimConfig
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		if shim.Path == "" {
+			shim.Path = source.Path
+		}
+		if shim.Type == "" {
+			shim.Type = source.Type
+		}
+		if shim.Root == "" {
+			shim.Root = source.Root
+		}
+	}
+	return shim
+}
+
+// nodeArchitectures returns the sorted list of distinct architectures of the given nodes
+func nodeArchitectures(nodes []corev1.Node) []string {
+	var archs []string
+	for _, node := range nodes {
+		arch, ok := node.GetLabels()[nodeArchLabel]
+		if !ok {
+			arch = node.Status.NodeInfo.Architecture
+		}
+		if arch != "" && !contains(archs, arch) {
+			archs = append(archs, arch)
+		}
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// validateConfidentialConfig makes sure the requested trusted execution environment
+// is available on every architecture found in the kata pool, and that the guests can enforce
+// the image security policy, decrypt the images and measure the default initdata
+func validateConfidentialConfig(kataConfig *kataconfigurationv1.KataConfig, archs []string) error {
+	conf := kataConfig.Spec.Confidential
+	if conf == nil || !conf.Enabled {
+		return nil
+	}
+
+	switch conf.TEE {
+	case kataconfigurationv1.TEEPEF:
+		for _, arch := range archs {
+			if arch != archPPC64LE {
+				return fmt.Errorf("Protected Execution Facility is only available on %s nodes, but the KataConfigPoolSelector matches %s nodes", archPPC64LE, arch)
+			}
+		}
+	case kataconfigurationv1.TEESNP:
+		for _, arch := range archs {
+			if arch != archAMD64 {
+				return fmt.Errorf("SEV-SNP is only available on %s nodes, but the KataConfigPoolSelector matches %s nodes", archAMD64, arch)
+			}
+		}
+	case kataconfigurationv1.TEETDX:
+		for _, arch := range archs {
+			if arch != archAMD64 {
+				return fmt.Errorf("TDX is only available on %s nodes, but the KataConfigPoolSelector matches %s nodes", archAMD64, arch)
+			}
+		}
+	case kataconfigurationv1.TEESE:
+		for _, arch := range archs {
+			if arch != archS390X {
+				return fmt.Errorf("Secure Execution is only available on %s nodes, but the KataConfigPoolSelector matches %s nodes", archS390X, arch)
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported trusted execution environment %q for confidential kata sandboxes", conf.TEE)
+	}
+
+	if conf.QuoteGeneration != nil && conf.TEE != kataconfigurationv1.TEETDX {
+		return fmt.Errorf("The quote generation service only attests the TDX guests, it needs tee tdx")
+	}
+	if conf.ImageSecurityPolicy != nil {
+		if !conf.GuestPull {
+			return fmt.Errorf("The image security policy is enforced by the guests pulling the images, it needs guestPull")
+		}
+		if conf.KBSURL == "" {
+			return fmt.Errorf("The image security policy is fetched from the key broker service, it needs kbsURL")
+		}
+	}
+	if conf.Initdata != "" {
+		if err := initdata.Validate(conf.Initdata); err != nil {
+			return err
+		}
+	}
+	if conf.EncryptedImages != nil {
+		if !conf.GuestPull {
+			return fmt.Errorf("The encrypted images are decrypted by the guests pulling them, it needs guestPull")
+		}
+		if conf.KBSURL == "" {
+			return fmt.Errorf("The keys of the encrypted images are released by the key broker service, it needs kbsURL")
+		}
+	}
+
+	return nil
+}
+
+// qemuMachineTypes are the machine types of the guests, by architecture
+var qemuMachineTypes = map[string]string{
+	archAMD64:   "q35",
+	archARM64:   "virt",
+	archPPC64LE: "pseries",
+	archS390X:   "s390-ccw-virtio",
+}
+
+// validateQEMUConfig makes sure the requested machine type and CPU features are available on
+// every architecture found in the kata pool
+func validateQEMUConfig(kataConfig *kataconfigurationv1.KataConfig, archs []string) error {
+	q := kataConfig.Spec.QEMU
+	if q == nil {
+		return nil
+	}
+
+	for _, arch := range archs {
+		if q.MachineType != "" && qemuMachineTypes[arch] != q.MachineType {
+			return fmt.Errorf("QEMU machine type %s is not available on %s nodes, but the KataConfigPoolSelector matches %s nodes",
+				q.MachineType, arch, arch)
+		}
+		if len(q.CPUFeatures) > 0 && arch == archPPC64LE {
+			return fmt.Errorf("QEMU CPU features are not available on %s nodes, but the KataConfigPoolSelector matches %s nodes", arch, arch)
+		}
+	}
+	return nil
+}
+
+// blockVolumeAnnotations are the pod annotations choosing how the block volumes are assigned
+var blockVolumeAnnotations = []string{
+	kataconfigurationv1.KataAnnotationPrefix + "hypervisor.block_device_driver",
+	kataconfigurationv1.KataAnnotationPrefix + "hypervisor.block_device_cache_direct",
+}
+
+// blockDeviceDriver returns the driver of the block devices in the guests: the s390x guests
+// have no PCI bus, their virtio-blk devices are on the channel subsystem
+func blockDeviceDriver(block *kataconfigurationv1.KataBlockVolumesConfig, archs []string) string {
+	driver := block.Driver
+	if driver == "" {
+		driver = "virtio-blk"
+	}
+	if driver == "virtio-blk" && len(archs) == 1 && archs[0] == archS390X {
+		driver = "virtio-blk-ccw"
+	}
+	return driver
+}
+
+// iommuKernelArguments are the kernel arguments enabling the IOMMU of the x86_64 nodes, in
+// pass-through mode for the devices not assigned to the guests
+var iommuKernelArguments = []string{"intel_iommu=on", "iommu=pt"}
+
+// kernelArguments returns the kernel arguments the kata MachineConfig sets on the pool
+func kernelArguments(kataConfig *kataconfigurationv1.KataConfig, archs []string) []string {
+	passthrough := kataConfig.Spec.DevicePassthrough
+	if passthrough == nil || !passthrough.KernelArguments || !contains(archs, archAMD64) {
+		return nil
+	}
+	return iommuKernelArguments
+}
+
+// generateKataConfigDropin renders the kata configuration fragment for the pool, the one of the
+// confidential handler if asked to. It returns an empty string when the kata defaults are
+// sufficient and no drop-in is needed
+func generateKataConfigDropin(kataConfig *kataconfigurationv1.KataConfig, archs, cgroups []string, confidential bool) (string, error) {
+	type HypervisorConfig struct {
+		Power       bool
+		PEF         bool
+		SNP         bool
+		TDX         bool
+		SE          bool
+		QGSPort     int32
+		MachineType string
+		CPUFeatures string
+		GuestLogs   bool
+		Debug       bool
+		Tracing     string
+		Memory      int32
+		VCPUs       int32
+
+		BlockDriver      string
+		BlockCacheDirect bool
+		BlockAnnotations bool
+
+		CgroupOnlySet     bool
+		SandboxCgroupOnly bool
+
+		GuestPull    bool
+		KernelParams string
+
+		Passthrough bool
+		IOMMU       bool
+		ColdPlug    bool
+		VFIOMode    string
+	}
+	const b = `
+{{- if or .MachineType .CPUFeatures .SNP .TDX .SE .GuestLogs .Memory .VCPUs .BlockDriver .Passthrough .KernelParams}}
+[hypervisor.qemu]
+{{- if .Memory}}
+  default_memory = {{.Memory}}
+{{- end}}
+{{- if .VCPUs}}
+  default_vcpus = {{.VCPUs}}
+{{- end}}
+{{- if .MachineType}}
+  machine_type = "{{.MachineType}}"
+{{- end}}
+{{- if .CPUFeatures}}
+  cpu_features = "{{.CPUFeatures}}"
+{{- end}}
+{{- if .Power}}
+  machine_accelerators = "cap-cfpc=broken,cap-sbbc=broken,cap-ibs=broken,cap-large-decr=off,cap-ccf-assist=off"
+{{- if .PEF}}
+  confidential_guest = true
+{{- end}}
+{{- end}}
+{{- if .SNP}}
+  confidential_guest = true
+  sev_snp_guest = true
+{{- end}}
+{{- if .TDX}}
+  confidential_guest = true
+  tdx_quote_generation_service_socket_port = {{.QGSPort}}
+{{- end}}
+{{- if .SE}}
+  confidential_guest = true
+{{- end}}
+{{- if .KernelParams}}
+  kernel_params = "{{.KernelParams}}"
+{{- end}}
+{{- if .GuestLogs}}
+  enable_debug = true
+{{- end}}
+{{- if .BlockDriver}}
+  disable_block_device_use = false
+  block_device_driver = "{{.BlockDriver}}"
+  block_device_cache_direct = {{.BlockCacheDirect}}
+{{- if .BlockAnnotations}}
+  enable_annotations = ["block_device_driver", "block_device_cache_direct"]
+{{- end}}
+{{- end}}
+{{- if .Passthrough}}
+  enable_iommu = {{.IOMMU}}
+{{- if .ColdPlug}}
+  cold_plug_vfio = "root-port"
+{{- else}}
+  hotplug_vfio_on_root_bus = true
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if or .GuestLogs .Tracing}}
+[agent.kata]
+{{- if .GuestLogs}}
+  enable_debug = true
+{{- end}}
+{{- if .Tracing}}
+  enable_tracing = true
+{{- end}}
+{{- end}}
+{{- if or .Debug .Tracing .CgroupOnlySet .Passthrough .GuestPull}}
+[runtime]
+{{- if .CgroupOnlySet}}
+  sandbox_cgroup_only = {{.SandboxCgroupOnly}}
+{{- end}}
+{{- if .Passthrough}}
+  vfio_mode = "{{.VFIOMode}}"
+{{- end}}
+{{- if .GuestPull}}
+  experimental_force_guest_pull = true
+{{- end}}
+{{- if .Debug}}
+  enable_debug = true
+{{- end}}
+{{- if .Tracing}}
+  enable_tracing = true
+  jaeger_endpoint = {{printf "%q" .Tracing}}
+{{- end}}
+{{- end}}
+`
+	c := HypervisorConfig{
+		Power: len(archs) == 1 && archs[0] == archPPC64LE,
+	}
+	if err := validateQEMUConfig(kataConfig, archs); err != nil {
+		return "", err
+	}
+	if c.Power {
+		c.MachineType = qemuMachineTypes[archPPC64LE]
+	}
+	if q := kataConfig.Spec.QEMU; q != nil {
+		if q.MachineType != "" {
+			c.MachineType = q.MachineType
+		}
+		features := make([]string, 0, len(q.CPUFeatures))
+		for _, feature := range q.CPUFeatures {
+			features = append(features, string(feature))
+		}
+		c.CPUFeatures = strings.Join(features, ",")
+	}
+	if confidential {
+		if err := validateConfidentialConfig(kataConfig, archs); err != nil {
+			return "", err
+		}
+		c.PEF = kataConfig.Spec.Confidential.TEE == kataconfigurationv1.TEEPEF
+		c.SNP = kataConfig.Spec.Confidential.TEE == kataconfigurationv1.TEESNP
+		c.TDX = kataConfig.Spec.Confidential.TEE == kataconfigurationv1.TEETDX
+		c.SE = kataConfig.Spec.Confidential.TEE == kataconfigurationv1.TEESE
+		c.QGSPort = qgsPort(kataConfig)
+		c.GuestPull = kataConfig.Spec.Confidential.GuestPull
+		c.KernelParams = strings.Join(agentKernelParams(kataConfig), " ")
+	}
+	if h := kataConfig.Spec.Hypervisor; h != nil {
+		c.Memory = h.DefaultMemory
+		c.VCPUs = h.DefaultVCPUs
+	}
+	if block := kataConfig.Spec.BlockVolumes; block != nil && block.Enabled {
+		c.BlockDriver = blockDeviceDriver(block, archs)
+		c.BlockCacheDirect = block.CacheDirect
+		c.BlockAnnotations = block.AllowPodAnnotations
+	}
+	if passthrough := kataConfig.Spec.DevicePassthrough; passthrough != nil {
+		c.Passthrough = true
+		c.IOMMU = passthrough.EnableIOMMU
+		c.ColdPlug = passthrough.ColdPlug
+		c.VFIOMode = string(passthrough.VFIOMode)
+		if c.VFIOMode == "" {
+			c.VFIOMode = string(kataconfigurationv1.VFIOModeGuestKernel)
+		}
+	}
+	if cgroupOnly := sandboxCgroupOnly(kataConfig, cgroups); cgroupOnly != nil {
+		c.CgroupOnlySet = true
+		c.SandboxCgroupOnly = *cgroupOnly
+	}
+	// the shim logs to the journal, with the agent and guest kernel logs when they are enabled
+	if logging := kataConfig.Spec.Logging; logging != nil {
+		c.GuestLogs = logging.GuestLogs
+		c.Debug = logging.Level == kataconfigurationv1.KataLogLevelDebug
+	}
+	if tracing := kataConfig.Spec.Tracing; tracing != nil && tracing.Enabled {
+		c.Tracing = tracing.Endpoint
+	}
+
+	buf := new(bytes.Buffer)
+	t := template.Must(template.New("kata").Parse(b))
+	if err := t.Execute(buf, c); err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		return "", nil
+	}
+
+	return b64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
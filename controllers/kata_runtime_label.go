@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kataRuntimeLabel is set by the operator on the nodes able to run kata pods. The kata
+// RuntimeClass schedules on it unless the KataConfig sets a SchedulingNodeSelector, so that the
+// scheduling doesn't depend on the labels chosen for the kata pool
+const kataRuntimeLabel = "kata.openshift.io/kata-runtime"
+
+// labelKataRuntimeNodes sets the kata runtime label on the nodes that completed the installation,
+// or are being upgraded and run the previous payload meanwhile, and removes it from the other
+// nodes. No node keeps it once kata is disabled or being uninstalled
+func (r *KataConfigOpenShiftReconciler) labelKataRuntimeNodes() error {
+	runtimeNodes := map[string]bool{}
+	if r.kataConfig.GetDeletionTimestamp() == nil && r.kataEnabled() {
+		status := r.kataConfig.Status
+		for _, name := range status.InstallationStatus.Completed.CompletedNodesList {
+			runtimeNodes[name] = true
+		}
+		for _, name := range status.Upgradestatus.UpgradingNodesList {
+			runtimeNodes[name] = true
+		}
+	}
+
+	// the labeled nodes and the runtime nodes, rather than every node of the cluster
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList, client.MatchingFields{nodeKataRuntimeField: "true"}); err != nil {
+		return err
+	}
+	nodes := nodesList.Items
+	labeledNodes := map[string]bool{}
+	for i := range nodes {
+		labeledNodes[nodes[i].Name] = true
+	}
+	for name := range runtimeNodes {
+		if labeledNodes[name] {
+			continue
+		}
+		node := corev1.Node{}
+		if err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, &node); errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		nodes = append(nodes, node)
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		_, labeled := node.GetLabels()[kataRuntimeLabel]
+		if labeled == runtimeNodes[node.Name] {
+			continue
+		}
+
+		original := node.DeepCopy()
+		labels := node.GetLabels()
+		if labeled {
+			delete(labels, kataRuntimeLabel)
+		} else {
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[kataRuntimeLabel] = "true"
+		}
+
+		r.Log.Info("Updating the kata runtime label of the node", "node", node.Name, "runtime", !labeled)
+		node.SetLabels(labels)
+		if err := r.Client.Patch(r.ctx, node, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// kataSGXRuntimeClassName is the RuntimeClass of the kata sandboxes running SGX enclaves,
+	// it uses the regular kata handler
+	kataSGXRuntimeClassName = "kata-sgx"
+
+	// sgxEPCResource is the SGX EPC memory the Intel SGX device plugin advertises on the
+	// nodes. Its webhook annotates the pods requesting it with the same name, kata sizes the
+	// EPC section of the guest from the annotation
+	sgxEPCResource = "sgx.intel.com/epc"
+
+	// sgxNodeLabel is set by node feature discovery on the nodes with SGX enabled
+	sgxNodeLabel = "feature.node.kubernetes.io/cpu-sgx.enabled"
+)
+
+// sgxEnabled tells whether the KataConfig asks for the SGX sandboxes
+func sgxEnabled(kataConfig *kataconfigurationv1.KataConfig) bool {
+	return kataConfig.Spec.SGX != nil && kataConfig.Spec.SGX.Enabled
+}
+
+// newSGXRuntimeClassForCR returns the kata-sgx RuntimeClass, scheduling the pods on the kata
+// nodes with SGX enabled
+func (r *KataConfigOpenShiftReconciler) newSGXRuntimeClassForCR() *nodeapi.RuntimeClass {
+	conf := r.kataConfig.Spec.SGX
+	if conf == nil {
+		conf = &kataconfigurationv1.KataSGXConfig{}
+	}
+
+	return &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: kataSGXRuntimeClassName,
+		},
+		Handler: runtimeHandler(r.kataConfig),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: podOverhead(r.kataConfig, conf.Overhead),
+		},
+		Scheduling: &nodeapi.Scheduling{
+			NodeSelector: runtimeClassNodeSelector(conf.SchedulingNodeSelector,
+				map[string]string{kataRuntimeLabel: "true", sgxNodeLabel: "true"}),
+		},
+	}
+}
+
+// checkSGXCapacity reports when SGX is enabled but none of the kata nodes of the pool
+// advertises SGX EPC memory, the kata-sgx pods can't be scheduled then
+func (r *KataConfigOpenShiftReconciler) checkSGXCapacity(machinePool string) error {
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigSGXUnavailable)
+	if !sgxEnabled(r.kataConfig) {
+		if current != nil {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				meta.RemoveStatusCondition(&status.Conditions, kataconfigurationv1.KataConfigSGXUnavailable)
+			})
+		}
+		return nil
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return err
+	}
+	var withEPC int
+	for i := range nodes {
+		if epc, ok := nodes[i].Status.Allocatable[sgxEPCResource]; ok && !epc.IsZero() {
+			withEPC++
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigSGXUnavailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: fmt.Sprintf("%d of the %d kata nodes advertise %s", withEPC, len(nodes), sgxEPCResource),
+	}
+	if withEPC == 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NoEPCCapacity"
+		condition.Message = fmt.Sprintf("none of the %d kata nodes advertises %s, enable SGX in their firmware "+
+			"and deploy the Intel SGX device plugin", len(nodes), sgxEPCResource)
+	}
+	if current == nil || current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return nil
+}
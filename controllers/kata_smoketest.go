@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	defaultSmokeTestImage = "registry.access.redhat.com/ubi8/ubi-minimal"
+
+	// smokeTestLabel marks the smoke test pods
+	smokeTestLabel = "kataconfiguration.openshift.io/smoke-test"
+
+	// smokeTestDeadline is how long a smoke test pod may take, image pull included
+	smokeTestDeadline int64 = 300
+)
+
+// smokeTestPodName returns the name of the smoke test pod of a node
+func smokeTestPodName(nodeName string) string {
+	return "kata-smoke-test-" + nodeName
+}
+
+// newSmokeTestPod returns a pod starting a kata sandbox on the node and exiting right away
+func (r *KataConfigOpenShiftReconciler) newSmokeTestPod(nodeName string) (*corev1.Pod, error) {
+	image := r.kataConfig.Spec.SmokeTest.Image
+	if image == "" {
+		image = r.settings.smokeTestImage()
+	}
+	runtimeClassName := kataRuntimeClassName
+	deadline := smokeTestDeadline
+
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      smokeTestPodName(nodeName),
+			Namespace: daemonNamespace,
+			Labels:    map[string]string{smokeTestLabel: r.kataConfig.Name},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:              nodeName,
+			RuntimeClassName:      &runtimeClassName,
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Containers: []corev1.Container{
+				{
+					Name:    "smoke-test",
+					Image:   image,
+					Command: []string{"uname", "-r"},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, pod, r.Scheme); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// smokeTestFailure returns why a failed smoke test pod failed
+func smokeTestFailure(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			return fmt.Sprintf("smoke test pod %s terminated with exit code %d: %s %s", pod.Name,
+				terminated.ExitCode, terminated.Reason, terminated.Message)
+		}
+	}
+	return fmt.Sprintf("smoke test pod %s failed: %s %s", pod.Name, pod.Status.Reason, pod.Status.Message)
+}
+
+// reconcileSmokeTests runs a smoke test pod with the kata runtime class on every installed node
+// that was not tested yet, records the outcome in the installation status and deletes the pod.
+// The smoke test pods are owned by the KataConfig, their changes trigger a reconcile
+func (r *KataConfigOpenShiftReconciler) reconcileSmokeTests() error {
+	smokeTest := r.kataConfig.Spec.SmokeTest
+	if smokeTest == nil || !smokeTest.Enabled || !r.kataEnabled() || r.kataConfig.Status.RuntimeClass == "" {
+		return r.removeSmokeTests()
+	}
+
+	status := r.kataConfig.Status.InstallationStatus.SmokeTest
+	tested := append([]string{}, status.VerifiedNodesList...)
+	for _, node := range status.FailedNodesList {
+		tested = append(tested, node.Name)
+	}
+
+	var verified []string
+	var failed []kataconfigurationv1.FailedNodeStatus
+	for _, nodeName := range r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList {
+		if contains(tested, nodeName) {
+			continue
+		}
+
+		pod, err := r.newSmokeTestPod(nodeName)
+		if err != nil {
+			return err
+		}
+		foundPod := &corev1.Pod{}
+		err = r.Client.Get(r.ctx, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, foundPod)
+		if err != nil && errors.IsNotFound(err) {
+			r.Log.Info("Running the kata smoke test on the node", "node", nodeName, "pod", pod.Name)
+			if err := r.Client.Create(r.ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		switch foundPod.Status.Phase {
+		case corev1.PodSucceeded:
+			r.Log.Info("Kata smoke test passed", "node", nodeName)
+			verified = append(verified, nodeName)
+		case corev1.PodFailed:
+			message := smokeTestFailure(foundPod)
+			r.Log.Info("Kata smoke test failed", "node", nodeName, "error", message)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "SmokeTestFailed", fmt.Sprintf("node %s: %s", nodeName, message))
+			failed = append(failed, kataconfigurationv1.FailedNodeStatus{Name: nodeName, Error: message})
+		default:
+			continue
+		}
+		if err := r.Client.Delete(r.ctx, foundPod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if len(verified) == 0 && len(failed) == 0 {
+		return nil
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		smokeTest := &status.InstallationStatus.SmokeTest
+		smokeTest.VerifiedNodesList = append(smokeTest.VerifiedNodesList, verified...)
+		smokeTest.FailedNodesList = append(smokeTest.FailedNodesList, failed...)
+	})
+	return nil
+}
+
+// removeSmokeTests deletes the smoke test pods left, they use the kata runtime and would hold
+// the uninstallation
+func (r *KataConfigOpenShiftReconciler) removeSmokeTests() error {
+	pods := &corev1.PodList{}
+	err := r.Client.List(r.ctx, pods, client.InNamespace(daemonNamespace),
+		client.MatchingLabels{smokeTestLabel: r.kataConfig.Name})
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if err := r.Client.Delete(r.ctx, &pods.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
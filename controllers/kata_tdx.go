@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// qgsName names the daemonset running the quote generation service on the TDX nodes and
+	// the ConfigMap of its PCCS client
+	qgsName = "tdx-qgs"
+
+	// defaultQGSPort is the vsock port the QGS listens on, the one kata gives to the guests
+	// unless told otherwise
+	defaultQGSPort = 4050
+
+	// qcnlConfigFile is the configuration of the quote provider library of the QGS, telling
+	// where the PCCS is
+	qcnlConfigFile = "sgx_default_qcnl.conf"
+
+	// pccsCertificationPath is the API of the PCCS the quote provider library uses
+	pccsCertificationPath = "/sgx/certification/v4/"
+
+	// sgxEnclaveResource and sgxProvisionResource are the SGX devices of the nodes, advertised
+	// by the Intel SGX device plugin, the QGS runs its quoting enclaves with
+	sgxEnclaveResource   = "sgx.intel.com/enclave"
+	sgxProvisionResource = "sgx.intel.com/provision"
+)
+
+// quoteGeneration returns the quote generation settings of the TDX guests, nil unless the
+// KataConfig asks for TDX confidential sandboxes
+func quoteGeneration(kataConfig *kataconfigurationv1.KataConfig) *kataconfigurationv1.KataQuoteGenerationConfig {
+	if !confidentialEnabled(kataConfig) || kataConfig.Spec.Confidential.TEE != kataconfigurationv1.TEETDX {
+		return nil
+	}
+	return kataConfig.Spec.Confidential.QuoteGeneration
+}
+
+// qgsPort returns the vsock port of the QGS the TDX guests get their quotes from
+func qgsPort(kataConfig *kataconfigurationv1.KataConfig) int32 {
+	if q := quoteGeneration(kataConfig); q != nil && q.Port != 0 {
+		return q.Port
+	}
+	return defaultQGSPort
+}
+
+// qgsDeployed tells whether the operator runs the QGS on the kata nodes
+func qgsDeployed(kataConfig *kataconfigurationv1.KataConfig) bool {
+	q := quoteGeneration(kataConfig)
+	return q != nil && q.Image != ""
+}
+
+// newQGSConfigMap returns the configuration of the quote provider library of the QGS, pointing
+// it to the PCCS
+func (r *KataConfigOpenShiftReconciler) newQGSConfigMap() (*corev1.ConfigMap, error) {
+	q := quoteGeneration(r.kataConfig)
+	qcnl, err := json.MarshalIndent(map[string]interface{}{
+		"pccs_url":        strings.TrimSuffix(q.PCCSURL, "/") + pccsCertificationPath,
+		"use_secure_cert": true,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      qgsName,
+			Namespace: daemonNamespace,
+		},
+		Data: map[string]string{
+			qcnlConfigFile: string(qcnl),
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// newQGSDaemonset returns the daemonset running the QGS on the kata nodes. The vsock sockets
+// are not namespaced, the guests of the node reach the QGS from the pod network
+func (r *KataConfigOpenShiftReconciler) newQGSDaemonset() (*appsv1.DaemonSet, error) {
+	q := quoteGeneration(r.kataConfig)
+	labels := map[string]string{"name": qgsName}
+	allowPrivilegeEscalation := false
+	var runAsUser int64 = 0
+
+	ds := &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      qgsName,
+			Namespace: daemonNamespace,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: daemonServiceAccountName,
+					NodeSelector:       map[string]string{kataRuntimeLabel: "true"},
+					Containers: []corev1.Container{
+						{
+							Name:  "qgs",
+							Image: q.Image,
+							Args:  []string{"--no-daemon", fmt.Sprintf("-p=%d", qgsPort(r.kataConfig))},
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+								RunAsUser:                &runAsUser,
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Limits: corev1.ResourceList{
+									sgxEnclaveResource:   resource.MustParse("1"),
+									sgxProvisionResource: resource.MustParse("1"),
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "qcnl",
+									MountPath: "/etc/" + qcnlConfigFile,
+									SubPath:   qcnlConfigFile,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "qcnl",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: qgsName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// reconcileQuoteGeneration runs the QGS on the kata nodes when the KataConfig provides its
+// image, and removes it otherwise. The nodes running their own QGS are checked by the daemon
+func (r *KataConfigOpenShiftReconciler) reconcileQuoteGeneration() error {
+	if !qgsDeployed(r.kataConfig) {
+		return r.removeQuoteGeneration()
+	}
+
+	if err := r.applyDaemonSCC(); err != nil {
+		return err
+	}
+	cm, err := r.newQGSConfigMap()
+	if err != nil {
+		return err
+	}
+	if err := r.applyObject(cm); err != nil {
+		return err
+	}
+	ds, err := r.newQGSDaemonset()
+	if err != nil {
+		return err
+	}
+	return r.applyObject(ds)
+}
+
+// removeQuoteGeneration stops the QGS run by the operator, if any
+func (r *KataConfigOpenShiftReconciler) removeQuoteGeneration() error {
+	objs := []runtime.Object{
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: qgsName, Namespace: daemonNamespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: qgsName, Namespace: daemonNamespace}},
+	}
+	for _, obj := range objs {
+		key, err := client.ObjectKeyFromObject(obj)
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Get(r.ctx, key, obj); errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		r.Log.Info("Deleting the quote generation service", "name", key.Name)
+		if err := r.Client.Delete(r.ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
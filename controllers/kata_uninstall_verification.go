@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// startUninstallVerification ends the removal of kata from the nodes. The next reconciles
+// verify that nothing is left behind before letting the KataConfig go
+func (r *KataConfigOpenShiftReconciler) startUninstallVerification() (ctrl.Result, error) {
+	r.Log.Info("Kata removed from all nodes, verifying the uninstallation")
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.UnInstallationStatus.Report = &kataconfigurationv1.KataUninstallReport{}
+	})
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// newVerifyDaemonset returns the daemonset checking the uninstalled nodes for kata leftovers.
+// The nodes are picked by name as they may have left the kata pool already
+func (r *KataConfigOpenShiftReconciler) newVerifyDaemonset(nodeNames []string) (*appsv1.DaemonSet, error) {
+	ds := r.processDaemonsetForCR(VerifyOperation, "")
+	ds.Spec.Template.Spec.Containers[0].Name = "kata-verify-pod"
+	ds.Spec.Template.Spec.NodeSelector = nil
+	ds.Spec.Template.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchFields: []corev1.NodeSelectorRequirement{
+							{
+								Key:      "metadata.name",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   nodeNames,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// verifyUninstallation removes the kata RuntimeClasses, has every uninstalled node checked for
+// kata CRI-O drop-ins, handlers and binaries, and publishes the outcome in the uninstallation
+// report. The finalizer is removed once the report is complete, leftovers don't block it
+func (r *KataConfigOpenShiftReconciler) verifyUninstallation() (ctrl.Result, error) {
+	report := r.kataConfig.Status.UnInstallationStatus.Report.DeepCopy()
+
+	report.RuntimeClassRemoved = true
+	for _, name := range []string{kataRuntimeClassName, kataCCRuntimeClassName, kataSGXRuntimeClassName} {
+		rc := &nodeapi.RuntimeClass{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if err := r.deleteObject(rc); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, &nodeapi.RuntimeClass{})
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		report.RuntimeClassRemoved = report.RuntimeClassRemoved && errors.IsNotFound(err)
+	}
+
+	var nodes []corev1.Node
+	var nodeNames []string
+	for _, name := range r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList {
+		node := &corev1.Node{}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, node)
+		if err != nil && errors.IsNotFound(err) {
+			// removed from the cluster, nothing left to check
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		nodes = append(nodes, *node)
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	var ds *appsv1.DaemonSet
+	var err error
+	if len(nodes) > 0 {
+		ds, err = r.newVerifyDaemonset(nodeNames)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		err = r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, &appsv1.DaemonSet{})
+		if err != nil && errors.IsNotFound(err) {
+			r.Log.Info("Creating the uninstallation verification Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
+			if err := r.applyObject(ds); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	pending := nodeprogress.AggregateVerification(report, nodes, r.kataConfig.Name)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.UnInstallationStatus.Report = report
+	})
+	if !report.RuntimeClassRemoved || len(pending) > 0 {
+		r.Log.Info("Waiting for the uninstallation to be verified", "runtimeClassRemoved", report.RuntimeClassRemoved,
+			"pending nodes", pending)
+		return r.requeue(), nil
+	}
+
+	// The KataConfig is gone once the finalizer is removed, write the status now
+	if err := r.flushStatus(); err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(report.LeftoverNodesList) > 0 {
+		var leftovers []string
+		for _, node := range report.LeftoverNodesList {
+			leftovers = append(leftovers, fmt.Sprintf("%s: %s", node.Name, node.Error))
+		}
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "UninstallLeftovers",
+			"kata leftovers found on the nodes: "+strings.Join(leftovers, "; "))
+	} else {
+		r.Recorder.Eventf(r.kataConfig, corev1.EventTypeNormal, "UninstallVerified",
+			"no kata leftover found on the %d uninstalled nodes", len(report.VerifiedNodesList))
+	}
+
+	if ds != nil {
+		if err := r.Client.Delete(r.ctx, ds); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.clearNodeProgress(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Log.Info("Uninstallation verified. Proceeding with the KataConfig deletion")
+	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+	if err := r.Client.Update(r.ctx, r.kataConfig); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -28,10 +28,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,18 +49,35 @@ type KataConfigKubernetesReconciler struct {
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 
+	// APIReader reads from the API server the KataConfigs whose status update conflicted
+	APIReader client.Reader
+
+	// baseLog is Log as set up by the manager, Log gets the values of the current reconcile
+	baseLog logr.Logger
+
 	clientset  kubernetes.Interface
 	kataConfig *kataconfigurationv1.KataConfig
+
+	// ctx is cancelled when the manager stops so that in-flight reconciliations abort
+	ctx context.Context
+
+	// statusMutations are the status changes written at the end of the reconcile
+	statusMutations []statusMutation
 }
 
 func (r *KataConfigKubernetesReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("kataconfig", req.NamespacedName)
+	if r.ctx == nil {
+		r.ctx = context.Background()
+	}
+	if r.baseLog == nil {
+		r.baseLog = r.Log
+	}
+	r.Log = reconcileLogger(r.baseLog, req)
 	r.Log.Info("Reconciling KataConfig in Kubernetes Cluster")
 
 	// Fetch the KataConfig instance
 	r.kataConfig = &kataconfigurationv1.KataConfig{}
-	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
+	err := r.Client.Get(r.ctx, req.NamespacedName, r.kataConfig)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
@@ -72,13 +89,25 @@ func (r *KataConfigKubernetesReconciler) Reconcile(req ctrl.Request) (ctrl.Resul
 		return ctrl.Result{}, err
 	}
 
-	// Check if the KataConfig instance is marked to be deleted, which is
-	// indicated by the deletion timestamp being set.
-	if r.kataConfig.GetDeletionTimestamp() != nil {
-		return r.processKataConfigDeleteRequest()
-	}
+	r.statusMutations = nil
+	result, err := func() (ctrl.Result, error) {
+		// Check if the KataConfig instance is marked to be deleted, which is
+		// indicated by the deletion timestamp being set.
+		if r.kataConfig.GetDeletionTimestamp() != nil {
+			return r.processKataConfigDeleteRequest()
+		}
+
+		return r.processKataConfigInstallRequest()
+	}()
 
-	return r.processKataConfigInstallRequest()
+	if statusErr := r.flushStatus(); statusErr != nil {
+		if err != nil {
+			r.Log.Error(statusErr, "failed to update the KataConfig status")
+			return result, err
+		}
+		return ctrl.Result{}, statusErr
+	}
+	return result, err
 }
 
 func (r *KataConfigKubernetesReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
@@ -86,6 +115,10 @@ func (r *KataConfigKubernetesReconciler) processKataConfigDeleteRequest() (ctrl.
 }
 
 func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
+	if len(r.kataConfig.Spec.KataConfigPoolSelectors) > 0 {
+		return ctrl.Result{}, fmt.Errorf("KataConfigPoolSelectors are only supported on OpenShift, use the KataConfigPoolSelector")
+	}
+
 	if r.kataConfig.Status.TotalNodesCount == 0 {
 
 		nodesList := &corev1.NodeList{}
@@ -100,13 +133,12 @@ func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl
 			client.MatchingLabels(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels),
 		}
 
-		err := r.Client.List(context.TODO(), nodesList, listOpts...)
+		err := r.Client.List(r.ctx, nodesList, listOpts...)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-		r.kataConfig.Status.TotalNodesCount = len(nodesList.Items)
 
-		if r.kataConfig.Status.TotalNodesCount == 0 {
+		if len(nodesList.Items) == 0 {
 			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second},
 				fmt.Errorf("No suitable worker nodes found for kata installation. Please make sure to label the nodes with labels specified in KataConfigPoolSelector")
 		}
@@ -116,15 +148,15 @@ func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl
 				fmt.Errorf("SourceImage must be specified to download the kata binaries")
 		}
 
-		if r.kataConfig.Status.KataImage == "" {
-			// TODO - placeholder. This will change in future.
-			r.kataConfig.Status.KataImage = r.kataConfig.Spec.Config.SourceImage
-		}
-
-		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+		totalNodesCount := len(nodesList.Items)
+		sourceImage := r.kataConfig.Spec.Config.SourceImage
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.TotalNodesCount = totalNodesCount
+			if status.KataImage == "" {
+				// TODO - placeholder. This will change in future.
+				status.KataImage = sourceImage
+			}
+		})
 	}
 
 	// Don't create the daemonset if kata is already installed on the cluster nodes
@@ -136,10 +168,10 @@ func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl
 			return ctrl.Result{}, err
 		}
 		foundDs := &appsv1.DaemonSet{}
-		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
 		if err != nil && errors.IsNotFound(err) {
 			r.Log.Info("Creating a new installation Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
-			err = r.Client.Create(context.TODO(), ds)
+			err = r.Client.Create(r.ctx, ds)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
@@ -168,15 +200,12 @@ func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.R
 			return rs, err
 		}
 
-		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList = r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList
-		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount = len(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList)
-		r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList = []string{}
-		r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesCount = 0
-
-		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.InstallationStatus.Completed.CompletedNodesList = status.InstallationStatus.InProgress.BinariesInstalledNodesList
+			status.InstallationStatus.Completed.CompletedNodesCount = len(status.InstallationStatus.Completed.CompletedNodesList)
+			status.InstallationStatus.InProgress.BinariesInstalledNodesList = []string{}
+			status.InstallationStatus.InProgress.InProgressNodesCount = 0
+		})
 
 		return ctrl.Result{}, nil
 	}
@@ -193,7 +222,7 @@ func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.R
 		client.MatchingLabels(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels),
 	}
 
-	err := r.Client.List(context.TODO(), nodesList, listOpts...)
+	err := r.Client.List(r.ctx, nodesList, listOpts...)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -202,13 +231,14 @@ func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.R
 		if !contains(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList, node.Name) {
 			for k, v := range node.GetLabels() {
 				if k == "katacontainers.io/kata-runtime" && v == "true" {
-					r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList = append(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList, node.Name)
-					r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesCount++
-
-					err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-					if err != nil {
-						return ctrl.Result{}, err
-					}
+					nodeName := node.Name
+					r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+						if contains(status.InstallationStatus.InProgress.BinariesInstalledNodesList, nodeName) {
+							return
+						}
+						status.InstallationStatus.InProgress.BinariesInstalledNodesList = append(status.InstallationStatus.InProgress.BinariesInstalledNodesList, nodeName)
+						status.InstallationStatus.InProgress.InProgressNodesCount++
+					})
 				}
 			}
 		}
@@ -223,6 +253,7 @@ func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.R
 func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error) {
 	runtimeClassNames := []string{"kata-qemu-virtiofs", "kata-qemu", "kata-clh", "kata-fc", "kata"}
 
+	var notAdopted []string
 	for _, runtimeClassName := range runtimeClassNames {
 		rc := func() *nodeapi.RuntimeClass {
 			rc := &nodeapi.RuntimeClass{
@@ -236,9 +267,13 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 				Handler: runtimeClassName,
 			}
 
+			var poolLabels map[string]string
 			if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+				poolLabels = r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels
+			}
+			if nodeSelector := runtimeClassNodeSelector(r.kataConfig.Spec.SchedulingNodeSelector, poolLabels); nodeSelector != nil {
 				rc.Scheduling = &nodeapi.Scheduling{
-					NodeSelector: r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels,
+					NodeSelector: nodeSelector,
 				}
 			}
 			return rc
@@ -250,22 +285,53 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 		}
 
 		foundRc := &nodeapi.RuntimeClass{}
-		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: rc.Name}, foundRc)
 		if err != nil && errors.IsNotFound(err) {
 			r.Log.Info("Creating a new RuntimeClass", "rc.Name", rc.Name)
-			err = r.Client.Create(context.TODO(), rc)
+			err = r.Client.Create(r.ctx, rc)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
 		}
 
+		foreign, err := foreignRuntimeClass(r.ctx, r.Client, r.kataConfig, rc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if foreign == nil {
+			continue
+		}
+		if !r.kataConfig.Spec.AdoptRuntimeClass {
+			notAdopted = append(notAdopted, rc.Name)
+			continue
+		}
+		message, err := releaseForAdoption(r.ctx, r.Client, foreign, rc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info(message)
+		if foreign.Handler != rc.Handler {
+			err = r.Client.Create(r.ctx, rc)
+		} else {
+			rc.ResourceVersion = foreign.ResourceVersion
+			rc.OwnerReferences = append(foreign.OwnerReferences, rc.OwnerReferences...)
+			err = r.Client.Update(r.ctx, rc)
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	r.kataConfig.Status.RuntimeClass = strings.Join(runtimeClassNames, ",")
-	err := r.Client.Status().Update(context.TODO(), r.kataConfig)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
+	condition := runtimeClassConflictCondition(notAdopted)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.RuntimeClass = strings.Join(runtimeClassNames, ",")
+		if len(notAdopted) > 0 || meta.FindStatusCondition(status.Conditions, condition.Type) != nil {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		}
+	})
 
 	return ctrl.Result{}, nil
 }
@@ -280,6 +346,11 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 		"name": dsName,
 	}
 
+	var strategy *kataconfigurationv1.KataDaemonUpdateStrategy
+	if operation == InstallOperation {
+		strategy = r.kataConfig.Spec.DaemonUpdateStrategy
+	}
+
 	var nodeSelector map[string]string
 	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
 		nodeSelector = r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels
@@ -289,7 +360,7 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 		}
 	}
 
-	return &appsv1.DaemonSet{
+	ds := &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
 			Kind:       "DaemonSet",
@@ -302,15 +373,7 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
-			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
-				Type: "RollingUpdate",
-				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
-					MaxUnavailable: &intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: 1,
-					},
-				},
-			},
+			UpdateStrategy: daemonUpdateStrategy(strategy),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels,
@@ -429,9 +492,23 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 			},
 		},
 	}
+
+	if operation == InstallOperation {
+		addDevmapperConfig(ds, r.kataConfig.Spec.Devmapper)
+	}
+	return ds
 }
 
 func (r *KataConfigKubernetesReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx, err := shutdownContext(mgr)
+	if err != nil {
+		return err
+	}
+	r.ctx = ctx
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kataconfigurationv1.KataConfig{}).
 		Owns(&appsv1.DaemonSet{}).
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kubeVirtSchedulableLabel is set by OpenShift Virtualization on the nodes its VMs can run on
+const kubeVirtSchedulableLabel = "kubevirt.io/schedulable"
+
+// kubeVirtGVK is the KubeVirt object OpenShift Virtualization is deployed with. Its types are not
+// vendored, it is handled as an unstructured object
+var kubeVirtGVK = schema.GroupVersionKind{
+	Group:   "kubevirt.io",
+	Version: "v1",
+	Kind:    "KubeVirt",
+}
+
+// activeKubeVirt returns the deployed KubeVirt object, nil when OpenShift Virtualization isn't
+// installed or its API isn't served
+func (r *KataConfigOpenShiftReconciler) activeKubeVirt() (*unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(kubeVirtGVK.GroupVersion().WithKind("KubeVirtList"))
+	if err := r.Client.List(r.ctx, list); meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		kubeVirt := &list.Items[i]
+		if kubeVirt.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if phase, _, _ := unstructured.NestedString(kubeVirt.Object, "status", "phase"); phase == "Deployed" {
+			return kubeVirt, nil
+		}
+	}
+	return nil, nil
+}
+
+// reconcileKubeVirtCoexistence reports the kata nodes OpenShift Virtualization can run VMs on in
+// the KubeVirtCoexistence condition: the kata pods and the VMs share /dev/kvm, and the memory
+// the VMs overcommit isn't accounted for the kata pods. The nodes dedicated to the VMs are left
+// out of the kata pool with spec.kubeVirt.vmNodeLabels
+func (r *KataConfigOpenShiftReconciler) reconcileKubeVirtCoexistence(machinePool string) error {
+	kubeVirt, err := r.activeKubeVirt()
+	if err != nil {
+		return err
+	}
+
+	var shared []string
+	var overcommit int64
+	if kubeVirt != nil {
+		nodes, err := r.listKataNodes(machinePool)
+		if err != nil {
+			return err
+		}
+		for i := range nodes {
+			if nodes[i].GetLabels()[kubeVirtSchedulableLabel] == "true" {
+				shared = append(shared, nodes[i].Name)
+			}
+		}
+		overcommit, _, _ = unstructured.NestedInt64(kubeVirt.Object,
+			"spec", "configuration", "developerConfiguration", "memoryOvercommit")
+	}
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigKubeVirtCoexistence,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: "OpenShift Virtualization doesn't run VMs on the kata nodes",
+	}
+	if len(shared) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SharedNodes"
+		condition.Message = fmt.Sprintf("the kata pods share /dev/kvm with the OpenShift Virtualization VMs on %s",
+			strings.Join(shared, ", "))
+		if overcommit > 100 {
+			condition.Message += fmt.Sprintf(", the VMs overcommit the memory to %d%%, "+
+				"which the kata pods aren't accounted for", overcommit)
+		}
+		condition.Message += "; set spec.kubeVirt.vmNodeLabels to leave the VM nodes out of the kata pool"
+	}
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Log.Info("OpenShift Virtualization runs VMs on kata nodes", "nodes", shared)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// reconcileLogger returns the logger of one reconcile pass. All its lines carry the KataConfig
+// and a reconcile ID, so that a pass can be followed in the aggregated logs of a rollout
+func reconcileLogger(base logr.Logger, req ctrl.Request) logr.Logger {
+	return base.WithValues("kataconfig", req.Name, "reconcileID", string(uuid.NewUUID()))
+}
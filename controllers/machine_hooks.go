@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// machineHookName is the pre-drain lifecycle hook holding the deletion of the machines,
+	// e.g. by the cluster autoscaler, while kata is being installed or uninstalled on their node
+	machineHookName  = "KataInstallation"
+	machineHookOwner = "kata-operator"
+)
+
+// machineGVK is the Machine of the OpenShift machine API, handled as unstructured objects
+var machineGVK = machineSetGVK.GroupVersion().WithKind("Machine")
+
+// machineDeletionBlocked tells whether the machine of a node in the given state must not be
+// drained: the daemon is writing the kata binaries, or the node still waits for the
+// uninstallation. The nodes reported as failed don't hold their machine
+func machineDeletionBlocked(state nodeprogress.State, failed, deleting bool) bool {
+	if failed {
+		return false
+	}
+	if !deleting {
+		return state == nodeprogress.Installing
+	}
+	switch state {
+	case nodeprogress.Installing, nodeprogress.BinariesInstalled, nodeprogress.Installed, nodeprogress.Uninstalling:
+		return true
+	}
+	return false
+}
+
+// reconcileMachineHooks adds the kata pre-drain hook to the machines of the nodes that must
+// not be removed yet, and releases it once they are done. The machine API keeps a deleted
+// machine, and its node, until its pre-drain hooks are gone
+func (r *KataConfigOpenShiftReconciler) reconcileMachineHooks() error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(machineGVK.GroupVersion().WithKind("MachineList"))
+	machines, err := r.listPages(list, client.InNamespace(machineAPINamespace))
+	if meta.IsNoMatchError(err) {
+		// no machine API on this cluster
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList); err != nil {
+		return err
+	}
+	nodes := map[string]*corev1.Node{}
+	for i := range nodesList.Items {
+		nodes[nodesList.Items[i].Name] = &nodesList.Items[i]
+	}
+
+	deleting := r.kataConfig.GetDeletionTimestamp() != nil
+	var failed []string
+	for _, node := range r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList {
+		failed = append(failed, node.Name)
+	}
+	for _, node := range r.kataConfig.Status.UnInstallationStatus.Failed.FailedNodesList {
+		failed = append(failed, node.Name)
+	}
+
+	for i := range machines {
+		machine := &machines[i]
+		nodeName, _, _ := unstructured.NestedString(machine.Object, "status", "nodeRef", "name")
+
+		block := false
+		if node, ok := nodes[nodeName]; ok {
+			state := nodeprogress.Get(node, r.kataConfig.Name).State
+			block = machineDeletionBlocked(state, contains(failed, nodeName), deleting)
+		}
+
+		hooks, _, err := unstructured.NestedSlice(machine.Object, "spec", "lifecycleHooks", "preDrain")
+		if err != nil {
+			return err
+		}
+		var kept []interface{}
+		for _, hook := range hooks {
+			if h, ok := hook.(map[string]interface{}); ok && h["name"] == machineHookName && h["owner"] == machineHookOwner {
+				continue
+			}
+			kept = append(kept, hook)
+		}
+		hooked := len(kept) != len(hooks)
+		if hooked == block {
+			continue
+		}
+
+		if block {
+			kept = append(kept, map[string]interface{}{"name": machineHookName, "owner": machineHookOwner})
+			r.Log.Info("Holding the deletion of the machine until kata is done on its node", "machine", machine.GetName(), "node", nodeName)
+		} else {
+			r.Log.Info("Releasing the deletion of the machine", "machine", machine.GetName(), "node", nodeName)
+		}
+		original := machine.DeepCopy()
+		if err := unstructured.SetNestedSlice(machine.Object, kept, "spec", "lifecycleHooks", "preDrain"); err != nil {
+			return err
+		}
+		if err := r.Client.Patch(r.ctx, machine, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
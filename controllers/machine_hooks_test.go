@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+)
+
+func TestMachineDeletionBlocked(t *testing.T) {
+	states := []nodeprogress.State{
+		"",
+		nodeprogress.Installing,
+		nodeprogress.BinariesInstalled,
+		nodeprogress.Installed,
+		nodeprogress.InstallFailed,
+		nodeprogress.Uninstalling,
+		nodeprogress.BinariesUninstalled,
+		nodeprogress.UninstallFailed,
+		nodeprogress.UninstallVerified,
+	}
+	// the states holding the machine, while installing and while deleting the KataConfig
+	blocking := map[bool][]nodeprogress.State{
+		false: {nodeprogress.Installing},
+		true: {
+			nodeprogress.Installing,
+			nodeprogress.BinariesInstalled,
+			nodeprogress.Installed,
+			nodeprogress.Uninstalling,
+		},
+	}
+
+	for _, deleting := range []bool{false, true} {
+		for _, failed := range []bool{false, true} {
+			for _, state := range states {
+				expected := !failed && containsState(blocking[deleting], state)
+				if blocked := machineDeletionBlocked(state, failed, deleting); blocked != expected {
+					t.Errorf("state %q, failed %v, deleting %v: expected %v, got %v",
+						state, failed, deleting, expected, blocked)
+				}
+			}
+		}
+	}
+}
+
+func containsState(states []nodeprogress.State, state nodeprogress.State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reinstallReplacedMachines records the machine the installed nodes run, and reports the nodes
+// whose machine was replaced since as installing again: a machine replaced under the same node
+// name boots without the kata binaries, it would be reported installed forever otherwise. The
+// install daemon pod of the node is restarted, it may have found the node installed already
+func (r *KataConfigOpenShiftReconciler) reinstallReplacedMachines(nodes []corev1.Node) error {
+	if r.extensionDelivery() {
+		// the binaries are part of the OS image of the new machine
+		return nil
+	}
+
+	reinstall, err := nodeprogress.Patch(nodeprogress.Progress{
+		KataConfig: r.kataConfig.Name,
+		State:      nodeprogress.Installing,
+	})
+	if err != nil {
+		return err
+	}
+
+	var replaced []string
+	for i := range nodes {
+		node := &nodes[i]
+		p := nodeprogress.Get(node, r.kataConfig.Name)
+		if p.State != nodeprogress.Installed {
+			continue
+		}
+		info := node.Status.NodeInfo
+		if p.Replaced(node) {
+			message := fmt.Sprintf("machine %s replaced by %s (boot %s), reinstalling kata",
+				p.MachineID, info.MachineID, info.BootID)
+			r.Log.Info("The machine of the node was replaced, reinstalling kata", "node", node.Name,
+				"previous machine", p.MachineID, "machine", info.MachineID)
+			r.Recorder.Event(node, corev1.EventTypeWarning, "MachineReplaced", message)
+			if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, reinstall)); err != nil {
+				return err
+			}
+			if err := r.restartInstallDaemon(node.Name); err != nil {
+				return err
+			}
+			replaced = append(replaced, node.Name)
+			continue
+		}
+
+		// the boot ID is refreshed on reboots, for the next replacement to be told apart
+		if (info.MachineID == p.MachineID && info.BootID == p.BootID) || info.MachineID == "" {
+			continue
+		}
+		patch, err := nodeprogress.MachinePatch(info)
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+	}
+
+	if len(replaced) > 0 {
+		message := fmt.Sprintf("machine of %s replaced, reinstalling kata", strings.Join(replaced, ", "))
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "MachineReplaced", message)
+		r.recordHistory(kataconfigurationv1.HistoryNodeReinstalled, message)
+	}
+	return nil
+}
+
+// restartInstallDaemon deletes the install daemon pods of a node, the daemonset runs them again
+func (r *KataConfigOpenShiftReconciler) restartInstallDaemon(nodeName string) error {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(r.ctx, pods, client.InNamespace(daemonNamespace)); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != nodeName || !strings.HasPrefix(pod.Labels["name"], installDaemonPrefix) {
+			continue
+		}
+		if err := r.Client.Delete(r.ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
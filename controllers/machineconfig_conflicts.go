@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	ignTypes "github.com/coreos/ignition/config/v2_2/types"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/vincent-petithory/dataurl"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// generatedByAnnotation is set by the machine config operator on the MachineConfigs it renders
+	generatedByAnnotation = "machineconfiguration.openshift.io/generated-by-controller-version"
+
+	// crioConfPath is the main CRI-O configuration file, the drop-ins override it
+	crioConfPath = "/etc/crio/crio.conf"
+)
+
+// foreignMachineConfig tells whether the MachineConfig comes from another source than the
+// operator: an administrator, another operator or a ContainerRuntimeConfig. The base
+// MachineConfigs of the machine config operator are not foreign
+func foreignMachineConfig(mc *mcfgv1.MachineConfig) bool {
+	if _, ok := mc.Labels[kataConfigOwnerLabel]; ok || mc.Name == kataMachineConfigName {
+		return false
+	}
+	if _, ok := mc.Annotations[generatedByAnnotation]; !ok {
+		return true
+	}
+	for _, owner := range mc.OwnerReferences {
+		if owner.Kind == "ContainerRuntimeConfig" {
+			return true
+		}
+	}
+	return false
+}
+
+// machineConfigTouches returns what the MachineConfig sets that the kata installation depends
+// on too: the CRI-O runtime settings and the kernel arguments
+func machineConfigTouches(mc *mcfgv1.MachineConfig) ([]string, error) {
+	var touches []string
+	for _, owner := range mc.OwnerReferences {
+		if owner.Kind == "ContainerRuntimeConfig" {
+			touches = append(touches, fmt.Sprintf("is rendered from the ContainerRuntimeConfig %s", owner.Name))
+		}
+	}
+	if len(mc.Spec.KernelArguments) > 0 {
+		touches = append(touches, fmt.Sprintf("sets the kernel arguments %s", strings.Join(mc.Spec.KernelArguments, " ")))
+	}
+	if len(mc.Spec.Config.Raw) == 0 {
+		return touches, nil
+	}
+
+	var ic ignTypes.Config
+	if err := json.Unmarshal(mc.Spec.Config.Raw, &ic); err != nil {
+		return nil, err
+	}
+	for _, file := range ic.Storage.Files {
+		if file.Path != crioConfPath && !strings.HasPrefix(file.Path, crioDropinDir) {
+			continue
+		}
+		content, err := dataurl.DecodeString(file.Contents.Source)
+		if err != nil {
+			// not inline, the settings it writes can't be told
+			touches = append(touches, fmt.Sprintf("writes %s", file.Path))
+			continue
+		}
+		var conf struct {
+			Crio struct {
+				Runtime map[string]interface{} `toml:"runtime"`
+			} `toml:"crio"`
+		}
+		if _, err := toml.Decode(string(content.Data), &conf); err != nil {
+			touches = append(touches, fmt.Sprintf("writes %s", file.Path))
+			continue
+		}
+		var keys []string
+		for key := range conf.Crio.Runtime {
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			touches = append(touches, fmt.Sprintf("sets %s in %s", strings.Join(keys, ", "), file.Path))
+		}
+	}
+	return touches, nil
+}
+
+// checkPotentialConflicts reports the MachineConfigs of other sources rendered into the kata
+// pool that touch the CRI-O runtime settings or the kernel arguments in the PotentialConflict
+// condition. The machine config operator merges them with the kata MachineConfig, the last
+// one in the lexical order of the names winning, without telling. Unlike the ConfigConflict
+// ones, they don't hold the kata MachineConfig back
+func (r *KataConfigOpenShiftReconciler) checkPotentialConflicts(machinePool string) error {
+	mcs := &mcfgv1.MachineConfigList{}
+	if err := r.Client.List(r.ctx, mcs); err != nil {
+		return err
+	}
+
+	roles := []string{machinePool, r.kataPoolName(machinePool)}
+	var conflicts []string
+	for i := range mcs.Items {
+		mc := &mcs.Items[i]
+		if !foreignMachineConfig(mc) || !contains(roles, mc.Labels["machineconfiguration.openshift.io/role"]) {
+			continue
+		}
+		touches, err := machineConfigTouches(mc)
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("machine config %s: invalid ignition config: %v", mc.Name, err))
+			continue
+		}
+		if len(touches) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("machine config %s %s", mc.Name, strings.Join(touches, ", ")))
+		}
+	}
+	sort.Strings(conflicts)
+
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigPotentialConflict,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: "no other machine config of the kata pool touches the CRI-O runtime settings or the kernel arguments",
+	}
+	if len(conflicts) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ForeignMachineConfig"
+		condition.Message = fmt.Sprintf("merged with the %s machine config, the last in name order wins: %s",
+			kataMachineConfigName, strings.Join(conflicts, "; "))
+	}
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Log.Info("Machine configs of other sources touch the kata settings", "conflicts", conflicts)
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return nil
+}
+
+// foreignMachineConfigChanged filters the MachineConfig events down to the MachineConfigs of
+// other sources
+var foreignMachineConfigChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		mc, ok := e.Object.(*mcfgv1.MachineConfig)
+		return ok && foreignMachineConfig(mc)
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		mc, ok := e.ObjectNew.(*mcfgv1.MachineConfig)
+		return ok && foreignMachineConfig(mc) && e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		mc, ok := e.Object.(*mcfgv1.MachineConfig)
+		return ok && foreignMachineConfig(mc)
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/maintenance"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// waitForMaintenanceWindow tells how long a change of the kata MachineConfig must wait for the
+// maintenance window of spec.rollout.schedule, zero when it may be rolled out now. The
+// PendingWindow condition is set while the change is held
+func (r *KataConfigOpenShiftReconciler) waitForMaintenanceWindow() (time.Duration, error) {
+	rollout := r.kataConfig.Spec.Rollout
+	if rollout == nil || rollout.Schedule == nil {
+		r.clearPendingWindow()
+		return 0, nil
+	}
+
+	schedule := rollout.Schedule
+	window, err := maintenance.NewWindow(schedule.Start, schedule.Duration.Duration, schedule.TimeZone)
+	if err != nil {
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:    kataconfigurationv1.KataConfigPendingWindow,
+				Status:  metav1.ConditionTrue,
+				Reason:  "InvalidSchedule",
+				Message: err.Error(),
+			})
+		})
+		return 0, err
+	}
+
+	now := time.Now()
+	open, at := window.Open(now)
+	if open {
+		r.clearPendingWindow()
+		return 0, nil
+	}
+
+	message := fmt.Sprintf("the machine config change waits for the maintenance window opening at %s", at.Format(time.RFC3339))
+	r.Log.Info("Holding the Machine Config change until the maintenance window", "opening", at)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigPendingWindow,
+			Status:  metav1.ConditionTrue,
+			Reason:  "OutsideMaintenanceWindow",
+			Message: message,
+		})
+	})
+	return at.Sub(now), nil
+}
+
+// clearPendingWindow resets the PendingWindow condition once no change is held anymore
+func (r *KataConfigOpenShiftReconciler) clearPendingWindow() {
+	if !meta.IsStatusConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigPendingWindow) {
+		return
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigPendingWindow,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AsExpected",
+			Message: "no machine config change is held",
+		})
+	})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mcpWait is the machine config pool a KataConfig waits for, and since when
+type mcpWait struct {
+	pool  string
+	since time.Time
+}
+
+// mcpWaits tracks the machine config pool every KataConfig waits for
+type mcpWaits struct {
+	mu    sync.Mutex
+	waits map[string]mcpWait
+}
+
+// observe records that the KataConfig still waits for the pool, and returns since when. The
+// wait starts over when the KataConfig waits for another pool, which is returned as previous
+func (w *mcpWaits) observe(name, pool string, now time.Time) (since time.Time, previous string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.waits == nil {
+		w.waits = map[string]mcpWait{}
+	}
+	wait, ok := w.waits[name]
+	if !ok || wait.pool != pool {
+		previous = wait.pool
+		wait = mcpWait{pool: pool, since: now}
+		w.waits[name] = wait
+	}
+	return wait.since, previous
+}
+
+// reset ends the wait of the KataConfig, and returns the pool it waited for
+func (w *mcpWaits) reset(name string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wait := w.waits[name]
+	delete(w.waits, name)
+	return wait.pool
+}
+
+// mcpDegradedCondition returns the condition the machine config operator reports the pool
+// degraded with, nil if it doesn't. The node and render conditions tell more than the summary
+func mcpDegradedCondition(mcp *mcfgv1.MachineConfigPool) *mcfgv1.MachineConfigPoolCondition {
+	var degraded *mcfgv1.MachineConfigPoolCondition
+	for i := range mcp.Status.Conditions {
+		condition := &mcp.Status.Conditions[i]
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case mcfgv1.MachineConfigPoolNodeDegraded, mcfgv1.MachineConfigPoolRenderDegraded:
+			return condition
+		case mcfgv1.MachineConfigPoolDegraded:
+			degraded = condition
+		}
+	}
+	return degraded
+}
+
+// recordMCPWait records how long the KataConfig has waited for the machine config pool to be
+// ready, and reports the rollout as stalled once it waited longer than the threshold
+func (r *KataConfigOpenShiftReconciler) recordMCPWait(mcp *mcfgv1.MachineConfigPool) {
+	if mcp.Status.MachineCount > 0 && mcp.Status.ReadyMachineCount == mcp.Status.MachineCount {
+		return
+	}
+	since, previous := r.mcpWaits.observe(r.kataConfig.Name, mcp.Name, time.Now())
+	if previous != "" {
+		mcpSyncWait.DeleteLabelValues(r.kataConfig.Name, previous)
+	}
+	wait := time.Since(since)
+	mcpSyncWait.WithLabelValues(r.kataConfig.Name, mcp.Name).Set(wait.Seconds())
+	if wait < r.Intervals.MCPStallThreshold {
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:   kataconfigurationv1.KataConfigRolloutStalled,
+		Status: metav1.ConditionTrue,
+		Reason: "MachineConfigPoolNotReady",
+		Message: fmt.Sprintf("machine config pool %s has not been ready for more than %s, %d of %d machines ready",
+			mcp.Name, r.Intervals.MCPStallThreshold, mcp.Status.ReadyMachineCount, mcp.Status.MachineCount),
+	}
+	if degraded := mcpDegradedCondition(mcp); degraded != nil {
+		condition.Reason = string(degraded.Type)
+		condition.Message = fmt.Sprintf("%s, %s: %s", condition.Message, degraded.Reason, degraded.Message)
+	}
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type)
+	if current == nil || current.Status != condition.Status || current.Message != condition.Message {
+		r.Log.Info("The rollout of the machine config pool is stalled", "mcp.Name", mcp.Name, "waiting", wait.Round(time.Second), "reason", condition.Reason)
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+}
+
+// endMCPWait ends the wait of the KataConfig for its machine config pool, and resets the
+// RolloutStalled condition
+func (r *KataConfigOpenShiftReconciler) endMCPWait() {
+	if pool := r.mcpWaits.reset(r.kataConfig.Name); pool != "" {
+		mcpSyncWait.DeleteLabelValues(r.kataConfig.Name, pool)
+	}
+	if !meta.IsStatusConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigRolloutStalled) {
+		return
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigRolloutStalled,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AsExpected",
+			Message: "the machine config pool is ready",
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// installTimedOutNodes is the number of nodes of a KataConfig that exceeded the installation timeout
+	installTimedOutNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kata_operator_install_timed_out_nodes",
+			Help: "Number of nodes that did not install kata within the installTimeout of the KataConfig",
+		},
+		[]string{"kataconfig"},
+	)
+
+	// nodeInstallDuration is the wall time of the installation of the nodes, from the start of
+	// the installation daemon to the node being installed
+	nodeInstallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kata_operator_node_install_duration_seconds",
+			Help:    "Time a node took to install kata, from the start of the installation daemon to the node reboot",
+			Buckets: []float64{60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 5400},
+		},
+		[]string{"kataconfig"},
+	)
+
+	// orphanedVMs is the number of peer pods VMs without a peer pod found by the last collection
+	orphanedVMs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kata_operator_peer_pods_orphaned_vms",
+			Help: "Number of VMs of the cloud provider without a peer pod found by the last collection",
+		},
+		[]string{"kataconfig", "provider"},
+	)
+
+	// deletedOrphanedVMs counts the orphaned peer pods VMs deleted from the cloud provider
+	deletedOrphanedVMs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kata_operator_peer_pods_orphaned_vms_deleted_total",
+			Help: "Number of VMs without a peer pod deleted from the cloud provider",
+		},
+		[]string{"kataconfig", "provider"},
+	)
+
+	// vmCollectionFailures counts the collections of the orphaned peer pods VMs that failed
+	vmCollectionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kata_operator_peer_pods_vm_collection_failures_total",
+			Help: "Number of collections of the VMs without a peer pod that failed",
+		},
+		[]string{"kataconfig", "provider"},
+	)
+
+	// mcpSyncWait is how long a KataConfig has been waiting for a machine config pool to be ready
+	mcpSyncWait = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kata_operator_mcp_sync_wait_seconds",
+			Help: "Time the KataConfig has been waiting for all the machines of the machine config pool to be ready",
+		},
+		[]string{"kataconfig", "pool"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(installTimedOutNodes, nodeInstallDuration, orphanedVMs, deletedOrphanedVMs, vmCollectionFailures,
+		mcpSyncWait)
+}
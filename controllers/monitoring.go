@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// operatorNamespace is the namespace of the operator, its daemons and their metrics
+	operatorNamespace = daemonNamespace
+
+	// operatorMetricsName names the Service and the ServiceMonitor of the operator metrics,
+	// served by the kube-rbac-proxy sidecar of the manager
+	operatorMetricsName = "kata-operator-metrics"
+
+	// servingCertAnnotation has the OpenShift service CA operator issue the serving
+	// certificate of a Service into the named secret
+	servingCertAnnotation = "service.beta.openshift.io/serving-cert-secret-name"
+
+	// kubeRBACProxyImage authorizes the scrapes of the metrics endpoints
+	kubeRBACProxyImage = "gcr.io/kubebuilder/kube-rbac-proxy@sha256:e10d1d982dd653db74ca87a1d1ad017bc5ef1aeb651bdea089debf16485b080b"
+
+	// prometheusRoleName lets the OpenShift cluster monitoring discover the metrics endpoints
+	// of the operator namespace
+	prometheusRoleName = "kata-operator-prometheus"
+)
+
+// serviceMonitorGVK is the ServiceMonitor of the prometheus operator. Its types are not
+// vendored, the ServiceMonitors are handled as unstructured objects
+var serviceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// newMetricsService returns the Service of an https metrics endpoint, with a serving
+// certificate issued by the service CA into the <name>-tls secret
+func newMetricsService(name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   operatorNamespace,
+			Labels:      map[string]string{"name": name},
+			Annotations: map[string]string{servingCertAnnotation: name + "-tls"},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: selector,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "https",
+					Port:       8443,
+					TargetPort: intstr.FromString("https"),
+				},
+			},
+		},
+	}
+}
+
+// newServiceMonitor returns the ServiceMonitor scraping the Service of newMetricsService. The
+// certificate of the endpoint is checked against the service CA bundle mounted in prometheus
+func newServiceMonitor(name string) *unstructured.Unstructured {
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(serviceMonitorGVK)
+	sm.SetName(name)
+	sm.SetNamespace(operatorNamespace)
+	sm.Object["spec"] = map[string]interface{}{
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port":            "https",
+				"path":            "/metrics",
+				"scheme":          "https",
+				"bearerTokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
+				"tlsConfig": map[string]interface{}{
+					"caFile":     "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt",
+					"serverName": fmt.Sprintf("%s.%s.svc", name, operatorNamespace),
+				},
+			},
+		},
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"name": name},
+		},
+	}
+	return sm
+}
+
+// kubeRBACProxyContainer returns the sidecar serving the metrics of upstream over https, with
+// the serving certificate mounted from the tls volume
+func kubeRBACProxyContainer(upstream string) corev1.Container {
+	return corev1.Container{
+		Name:  "kube-rbac-proxy",
+		Image: kubeRBACProxyImage,
+		Args: []string{
+			"--secure-listen-address=0.0.0.0:8443",
+			"--upstream=" + upstream,
+			"--tls-cert-file=/etc/tls/private/tls.crt",
+			"--tls-private-key-file=/etc/tls/private/tls.key",
+			"--logtostderr=true",
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: 8443,
+				Name:          "https",
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "tls",
+				MountPath: "/etc/tls/private",
+				ReadOnly:  true,
+			},
+		},
+	}
+}
+
+// MetricsMonitoring exposes the operator metrics to the OpenShift cluster monitoring: it
+// creates the metrics Service, its ServiceMonitor and lets prometheus discover the endpoints
+// of the operator namespace. It runs on the leader only
+type MetricsMonitoring struct {
+	Client client.Client
+	Log    logr.Logger
+}
+
+var _ manager.Runnable = &MetricsMonitoring{}
+
+// Start applies the monitoring objects once. Errors are logged, the metrics are still served
+// and can be scraped once the objects are created by hand
+func (m *MetricsMonitoring) Start(<-chan struct{}) error {
+	ctx := context.Background()
+
+	objects := []runtime.Object{
+		newMetricsService(operatorMetricsName, map[string]string{"control-plane": "controller-manager"}),
+		newPrometheusRole(),
+		newPrometheusRoleBinding(),
+		newServiceMonitor(operatorMetricsName),
+	}
+	for _, obj := range objects {
+		err := m.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+		if meta.IsNoMatchError(err) {
+			m.Log.Info("The prometheus operator is not installed, the operator metrics are not scraped")
+			continue
+		} else if err != nil {
+			m.Log.Error(err, "unable to create the monitoring objects of the operator metrics")
+		}
+	}
+	return nil
+}
+
+// newPrometheusRole lets prometheus discover the metrics endpoints of the operator namespace
+func newPrometheusRole() *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prometheusRoleName,
+			Namespace: operatorNamespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"services", "endpoints", "pods"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		},
+	}
+}
+
+// newPrometheusRoleBinding grants newPrometheusRole to the cluster monitoring prometheus
+func newPrometheusRoleBinding() *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prometheusRoleName,
+			Namespace: operatorNamespace,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     prometheusRoleName,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      "prometheus-k8s",
+				Namespace: "openshift-monitoring",
+			},
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// kataEligibleLabel is set on the worker nodes found able to run kata
+	kataEligibleLabel = "kata.openshift.io/eligible"
+
+	nfdLabelPrefix = "feature.node.kubernetes.io/"
+)
+
+// kataArchitectures are the node architectures kata payloads are built for
+var kataArchitectures = []string{"amd64", "arm64", "ppc64le", "s390x"}
+
+// checkNodeEligibility tells if kata can be installed on the node, and why not otherwise
+func checkNodeEligibility(node *corev1.Node) (bool, string) {
+	labels := node.GetLabels()
+
+	if _, ok := labels["node-role.kubernetes.io/worker"]; !ok {
+		return false, "not a worker node"
+	}
+
+	if node.Spec.Unschedulable {
+		return false, "node is unschedulable"
+	}
+
+	ready := false
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue {
+			ready = true
+		}
+	}
+	if !ready {
+		return false, "node is not ready"
+	}
+
+	arch, ok := labels[nodeArchLabel]
+	if !ok {
+		arch = node.Status.NodeInfo.Architecture
+	}
+	if !contains(kataArchitectures, arch) {
+		return false, "unsupported architecture " + arch
+	}
+
+	// When Node Feature Discovery runs in the cluster, rely on it to tell whether the
+	// x86 CPU exposes the hardware virtualization extensions
+	if arch == "amd64" && hasLabelPrefix(labels, nfdLabelPrefix) {
+		_, vmx := labels[nfdLabelPrefix+"cpu-cpuid.VMX"]
+		_, svm := labels[nfdLabelPrefix+"cpu-cpuid.SVM"]
+		if !vmx && !svm {
+			return false, "no hardware virtualization support"
+		}
+	}
+
+	return true, ""
+}
+
+func hasLabelPrefix(labels map[string]string, prefix string) bool {
+	for k := range labels {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeEligibilityChanged filters the node events down to the ones that can change the
+// eligibility of a node, or its membership of the kata pool, ignoring the periodic status updates
+// and the nodes flapping NotReady and unschedulable while the machine config operator updates them
+var nodeEligibilityChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		if !equality.Semantic.DeepEqual(oldNode.GetLabels(), newNode.GetLabels()) {
+			return true
+		}
+		if mcoUpdating(newNode) {
+			return false
+		}
+		if mcoUpdating(oldNode) {
+			// the node is checked again once updated
+			return true
+		}
+		oldEligible, _ := checkNodeEligibility(oldNode)
+		newEligible, _ := checkNodeEligibility(newNode)
+		return oldEligible != newEligible
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return true
+	},
+}
+
+// labelEligibleNodes labels the worker nodes passing the eligibility checks and, if asked
+// to, adds the labels of the pool selectors to them so that they join the kata pool. The nodes
+// excluded from the kata pool are left out of it
+func (r *KataConfigOpenShiftReconciler) labelEligibleNodes() error {
+	eligibility := r.kataConfig.Spec.NodeEligibility
+	if eligibility == nil || !eligibility.AutoLabel {
+		return nil
+	}
+
+	selectors, err := kataPoolSelectors(r.kataConfig)
+	if err != nil {
+		return err
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList, client.HasLabels{"node-role.kubernetes.io/worker"}); err != nil {
+		return err
+	}
+
+	for i := range nodesList.Items {
+		node := &nodesList.Items[i]
+		original := node.DeepCopy()
+		labels := node.GetLabels()
+
+		eligible, reason := checkNodeEligibility(node)
+		if eligible {
+			labels[kataEligibleLabel] = "true"
+			if eligibility.ExtendPool && !excludedNode(r.kataConfig, labels) {
+				extended, err := extendPoolLabels(r.kataConfig, labels)
+				if err != nil {
+					return err
+				}
+				for k, v := range extended {
+					labels[k] = v
+				}
+				// labelKataPoolNodes would only label it on the next reconcile
+				if matchesAny(selectors, labels) {
+					labels[kataPoolLabel] = "true"
+				}
+			}
+		} else if _, ok := labels[kataEligibleLabel]; ok && !mcoUpdating(node) {
+			// the nodes the machine config operator updates are only drained and rebooted
+			r.Log.Info("Node is no longer eligible for kata", "node", node.Name, "reason", reason)
+			delete(labels, kataEligibleLabel)
+		}
+
+		if equality.Semantic.DeepEqual(original.GetLabels(), labels) {
+			continue
+		}
+
+		r.Log.Info("Updating the kata labels of the node", "node", node.Name, "eligible", eligible)
+		node.SetLabels(labels)
+		if err := r.Client.Patch(r.ctx, node, client.MergeFrom(original)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extendPoolLabels returns the labels adding the node to the kata pool, built from the first
+// of the KataConfigPoolSelectors, or from the KataConfigPoolSelector, the node matches once
+// labeled. A selector adds its match labels and the values of its In and Exists expressions.
+// Nil when the node is already in the pool or no selector can match it
+func extendPoolLabels(kataConfig *kataconfigurationv1.KataConfig, nodeLabels map[string]string) (map[string]string, error) {
+	poolSelectors := kataConfig.Spec.KataConfigPoolSelectors
+	selectors, err := kataPoolSelectors(kataConfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(poolSelectors) == 0 && kataConfig.Spec.KataConfigPoolSelector != nil {
+		poolSelectors = []metav1.LabelSelector{*kataConfig.Spec.KataConfigPoolSelector}
+		selector, err := metav1.LabelSelectorAsSelector(kataConfig.Spec.KataConfigPoolSelector)
+		if err != nil {
+			return nil, err
+		}
+		selectors = []labels.Selector{selector}
+	}
+	if matchesAny(selectors, nodeLabels) {
+		return nil, nil
+	}
+
+	for i, selector := range selectors {
+		extended := labels.Set{}
+		for k, v := range nodeLabels {
+			extended[k] = v
+		}
+		added := selectorLabels(&poolSelectors[i])
+		for k, v := range added {
+			extended[k] = v
+		}
+		if selector.Matches(extended) && !excludedNode(kataConfig, extended) {
+			return added, nil
+		}
+	}
+	return nil, nil
+}
+
+// selectorLabels returns the labels a node needs to match the selector, as far as they can be
+// told: the match labels and the first value of the In expressions, empty for Exists
+func selectorLabels(selector *metav1.LabelSelector) map[string]string {
+	added := map[string]string{}
+	for k, v := range selector.MatchLabels {
+		added[k] = v
+	}
+	for _, requirement := range selector.MatchExpressions {
+		switch requirement.Operator {
+		case metav1.LabelSelectorOpIn:
+			added[requirement.Key] = requirement.Values[0]
+		case metav1.LabelSelectorOpExists:
+			added[requirement.Key] = ""
+		}
+	}
+	return added
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExtendPoolLabels(t *testing.T) {
+	worker := map[string]string{"node-role.kubernetes.io/worker": "", kataEligibleLabel: "true"}
+	with := func(extra map[string]string) map[string]string {
+		nodeLabels := map[string]string{}
+		for k, v := range worker {
+			nodeLabels[k] = v
+		}
+		for k, v := range extra {
+			nodeLabels[k] = v
+		}
+		return nodeLabels
+	}
+
+	tests := []struct {
+		name       string
+		selector   *metav1.LabelSelector
+		selectors  []metav1.LabelSelector
+		nodeLabels map[string]string
+		expected   map[string]string
+	}{
+		{name: "no pool selector", nodeLabels: worker},
+		{
+			name:       "pool selector",
+			selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			nodeLabels: worker,
+			expected:   map[string]string{"custom-kata1": "test"},
+		},
+		{
+			name:       "already in the pool",
+			selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			nodeLabels: with(map[string]string{"custom-kata1": "test"}),
+		},
+		{
+			name:       "excluded node",
+			selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"custom-kata1": "test"}},
+			nodeLabels: with(map[string]string{kataExcludeLabel: "true"}),
+		},
+		{
+			name: "expression-only selector",
+			selectors: []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "custom-kata1", Operator: metav1.LabelSelectorOpIn, Values: []string{"test", "prod"}},
+				{Key: "custom-kata2", Operator: metav1.LabelSelectorOpExists},
+				{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}}},
+			nodeLabels: worker,
+			expected:   map[string]string{"custom-kata1": "test", "custom-kata2": ""},
+		},
+		{
+			name: "later selector",
+			selectors: []metav1.LabelSelector{
+				{
+					MatchLabels:      map[string]string{"custom-kata1": "test"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist}},
+				},
+				{MatchLabels: map[string]string{"custom-kata2": "test"}},
+			},
+			nodeLabels: with(map[string]string{"gpu": "nvidia"}),
+			expected:   map[string]string{"custom-kata2": "test"},
+		},
+		{
+			name: "no selector can match",
+			selectors: []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"nvidia"}},
+			}}},
+			nodeLabels: with(map[string]string{"gpu": "nvidia"}),
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{Spec: kataconfigurationv1.KataConfigSpec{
+			KataConfigPoolSelector:  test.selector,
+			KataConfigPoolSelectors: test.selectors,
+		}}
+		extended, err := extendPoolLabels(kataConfig, test.nodeLabels)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(extended, test.expected) {
+			t.Errorf("%s: expected to extend the pool with %v, got %v", test.name, test.expected, extended)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeEvents follows the progress reported by the nodes to record the kata lifecycle as events
+// of the nodes themselves, next to the ones of the KataConfig
+type nodeEvents struct {
+	mu      sync.Mutex
+	started time.Time
+	states  map[string]nodeprogress.State
+}
+
+// transition records the state of a node and returns whether it entered it since it was last
+// seen. A node seen for the first time only entered its state if it did after the operator
+// started, the transitions of the previous operator run were already recorded
+func (e *nodeEvents) transition(name string, p nodeprogress.Progress) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.states == nil {
+		e.states = map[string]nodeprogress.State{}
+		e.started = time.Now()
+	}
+	previous, known := e.states[name]
+	e.states[name] = p.State
+	if known {
+		return previous != p.State
+	}
+	return !p.Since.Before(e.started.Truncate(time.Second))
+}
+
+// recordNodeEvents records an event on the nodes that started or completed the installation,
+// failed it, or completed the uninstallation since the last reconcile
+func (r *KataConfigOpenShiftReconciler) recordNodeEvents(nodes []corev1.Node) {
+	for i := range nodes {
+		node := &nodes[i]
+		p := nodeprogress.Get(node, r.kataConfig.Name)
+		if p.State == "" || !r.nodeEvents.transition(node.Name, p) {
+			continue
+		}
+
+		switch p.State {
+		case nodeprogress.Installing:
+			r.Recorder.Event(node, corev1.EventTypeNormal, "KataInstallStarted",
+				fmt.Sprintf("installing kata for the KataConfig %s", r.kataConfig.Name))
+		case nodeprogress.Installed:
+			r.Recorder.Event(node, corev1.EventTypeNormal, "KataInstalled",
+				fmt.Sprintf("kata installed for the KataConfig %s", r.kataConfig.Name))
+		case nodeprogress.InstallFailed:
+			r.Recorder.Event(node, corev1.EventTypeWarning, "KataInstallFailed",
+				fmt.Sprintf("kata installation for the KataConfig %s failed: %s", r.kataConfig.Name, p.Error))
+		case nodeprogress.BinariesUninstalled:
+			r.Recorder.Event(node, corev1.EventTypeNormal, "KataUninstalled",
+				fmt.Sprintf("kata uninstalled for the KataConfig %s", r.kataConfig.Name))
+		case nodeprogress.UninstallFailed:
+			r.Recorder.Event(node, corev1.EventTypeWarning, "KataUninstallFailed",
+				fmt.Sprintf("kata uninstallation for the KataConfig %s failed: %s", r.kataConfig.Name, p.Error))
+		}
+	}
+}
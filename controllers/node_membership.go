@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pausedForNodesAnnotation marks the kata pool paused by the operator while the nodes that
+// joined it install the kata binaries
+const pausedForNodesAnnotation = "kataconfiguration.openshift.io/paused-for-new-nodes"
+
+// reconcilePoolMembership follows the nodes joining and leaving the kata pool once kata is
+// installed. The nodes that left are dropped from the status. The installation is extended to
+// the nodes that joined, the install daemonset, or the extension rollout, is run again through
+// processKataConfigInstallRequest until all the nodes are installed. It returns true while
+// nodes are being added
+func (r *KataConfigOpenShiftReconciler) reconcilePoolMembership() (bool, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return false, err
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.reconcileNodeRemovals(nodes, false); err != nil {
+		return false, err
+	}
+
+	if err := r.reconcileDepartedNodes(nodes); err != nil {
+		return false, err
+	}
+
+	added := addedNodes(&r.kataConfig.Status, nodes)
+	if len(nodes) > r.kataConfig.Status.TotalNodesCount {
+		total := len(nodes)
+		r.Log.Info("Nodes joined the kata pool, extending the installation", "nodes", added)
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.TotalNodesCount = total
+		})
+		r.recordHistory(kataconfigurationv1.HistoryNodesAdded,
+			fmt.Sprintf("installation extended to %s", strings.Join(added, ", ")))
+	}
+
+	if len(added) == 0 {
+		// all the nodes are installed, clean up after the additions if any
+		if err := r.deleteKataDaemonset(InstallOperation); err != nil {
+			return false, err
+		}
+		return false, r.pauseKataPool(machinePool, false)
+	}
+
+	// The new nodes join the kata pool, and get the kata CRI-O handler, as soon as they are
+	// labeled. The pool is held until they have the kata binaries so that CRI-O never
+	// starts with a handler it can't run
+	if !r.extensionDelivery() {
+		installing := false
+		for _, name := range added {
+			if !contains(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList, name) {
+				installing = true
+			}
+		}
+		if err := r.pauseKataPool(machinePool, installing); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// addedNodes returns the nodes of the kata pool kata isn't installed on yet. The nodes that
+// failed are reported in the status and don't hold the others
+func addedNodes(status *kataconfigurationv1.KataConfigStatus, nodes []corev1.Node) []string {
+	installed := status.InstallationStatus.Completed.CompletedNodesList
+	var failed []string
+	for _, node := range status.InstallationStatus.Failed.FailedNodesList {
+		failed = append(failed, node.Name)
+	}
+	var added []string
+	for _, node := range nodes {
+		if !contains(installed, node.Name) && !contains(failed, node.Name) {
+			added = append(added, node.Name)
+		}
+	}
+	return added
+}
+
+// pauseKataPool pauses or resumes the kata machine config pool. Only the pauses made by the
+// operator are undone, and the worker and master pools are never paused
+func (r *KataConfigOpenShiftReconciler) pauseKataPool(machinePool string, pause bool) error {
+	poolName := r.kataPoolName(machinePool)
+	if poolName == machinePool {
+		return nil
+	}
+
+	mcp := &mcfgv1.MachineConfigPool{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: poolName}, mcp)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, pausedByUs := mcp.Annotations[pausedForNodesAnnotation]
+	if pause == pausedByUs || (pause && mcp.Spec.Paused) {
+		return nil
+	}
+
+	original := mcp.DeepCopy()
+	if pause {
+		r.Log.Info("Pausing the kata pool until the new nodes have the kata binaries", "mcp.Name", mcp.Name)
+		if mcp.Annotations == nil {
+			mcp.Annotations = map[string]string{}
+		}
+		mcp.Annotations[pausedForNodesAnnotation] = "true"
+		mcp.Spec.Paused = true
+	} else {
+		r.Log.Info("Resuming the kata pool", "mcp.Name", mcp.Name)
+		delete(mcp.Annotations, pausedForNodesAnnotation)
+		mcp.Spec.Paused = false
+	}
+	return r.Client.Patch(r.ctx, mcp, client.MergeFrom(original))
+}
+
+// statusNodes returns all the nodes the status knows about
+func statusNodes(status *kataconfigurationv1.KataConfigStatus) []string {
+	var names []string
+	add := func(name string) {
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range status.InstallationStatus.Completed.CompletedNodesList {
+		add(name)
+	}
+	for _, name := range status.InstallationStatus.InProgress.BinariesInstalledNodesList {
+		add(name)
+	}
+	for _, node := range status.InstallationStatus.Failed.FailedNodesList {
+		add(node.Name)
+	}
+	for _, name := range status.UnInstallationStatus.Completed.CompletedNodesList {
+		add(name)
+	}
+	for _, name := range status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList {
+		add(name)
+	}
+	for _, node := range status.UnInstallationStatus.Failed.FailedNodesList {
+		add(node.Name)
+	}
+	return names
+}
+
+// dropNodes removes the nodes from the per-node parts of the status
+func dropNodes(status *kataconfigurationv1.KataConfigStatus, departed []string) {
+	keep := func(names []string) []string {
+		var kept []string
+		for _, name := range names {
+			if !contains(departed, name) {
+				kept = append(kept, name)
+			}
+		}
+		return kept
+	}
+	keepFailed := func(failed *kataconfigurationv1.KataFailedNodeStatus) {
+		var kept []kataconfigurationv1.FailedNodeStatus
+		for _, node := range failed.FailedNodesList {
+			if !contains(departed, node.Name) {
+				kept = append(kept, node)
+			}
+		}
+		failed.FailedNodesList = kept
+		failed.FailedNodesCount = len(kept)
+	}
+
+	installation := &status.InstallationStatus
+	installation.Completed.CompletedNodesList = keep(installation.Completed.CompletedNodesList)
+	installation.Completed.CompletedNodesCount = len(installation.Completed.CompletedNodesList)
+	installation.InProgress.BinariesInstalledNodesList = keep(installation.InProgress.BinariesInstalledNodesList)
+	keepFailed(&installation.Failed)
+	keepFailed(&installation.Degraded)
+	installation.SmokeTest.VerifiedNodesList = keep(installation.SmokeTest.VerifiedNodesList)
+	var failedSmokeTests []kataconfigurationv1.FailedNodeStatus
+	for _, node := range installation.SmokeTest.FailedNodesList {
+		if !contains(departed, node.Name) {
+			failedSmokeTests = append(failedSmokeTests, node)
+		}
+	}
+	installation.SmokeTest.FailedNodesList = failedSmokeTests
+	var artifacts []kataconfigurationv1.KataNodeArtifacts
+	for _, node := range installation.Artifacts {
+		if !contains(departed, node.Name) {
+			artifacts = append(artifacts, node)
+		}
+	}
+	installation.Artifacts = artifacts
+	var durations []kataconfigurationv1.KataNodeInstallDuration
+	for _, node := range installation.InstallDurations {
+		if !contains(departed, node.Name) {
+			durations = append(durations, node)
+		}
+	}
+	installation.InstallDurations = durations
+
+	uninstallation := &status.UnInstallationStatus
+	uninstallation.Completed.CompletedNodesList = keep(uninstallation.Completed.CompletedNodesList)
+	uninstallation.Completed.CompletedNodesCount = len(uninstallation.Completed.CompletedNodesList)
+	uninstallation.InProgress.BinariesUnInstalledNodesList = keep(uninstallation.InProgress.BinariesUnInstalledNodesList)
+	keepFailed(&uninstallation.Failed)
+
+	status.Upgradestatus.UpgradingNodesList = keep(status.Upgradestatus.UpgradingNodesList)
+}
+
+// reconcileNodeRemovals drops from the status the nodes that were deleted or no longer match
+// the kataConfigPoolSelector, so that they don't hold the installation forever. The nodes that
+// left the pool go back to their parent pool, which removes the kata CRI-O handler, and the
+// kata binaries are removed by the cleanup daemonset unless they were delivered as an
+// extension. While deleting, the operator removes the pool labels itself and the deleted nodes
+// are counted as uninstalled, see completeVanishedNodes
+func (r *KataConfigOpenShiftReconciler) reconcileNodeRemovals(members []corev1.Node, deleting bool) error {
+	var departed, leftPool, vanished []string
+	for _, name := range statusNodes(&r.kataConfig.Status) {
+		found := false
+		for _, node := range members {
+			if node.Name == name {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		node := &corev1.Node{}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, node)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if deleting {
+			if err != nil {
+				vanished = append(vanished, name)
+			}
+			continue
+		}
+		departed = append(departed, name)
+		if err == nil {
+			leftPool = append(leftPool, name)
+			// the progress the node reported would add it back to the status
+			if nodeprogress.Get(node, r.kataConfig.Name).State != "" {
+				patch, err := nodeprogress.ClearPatch()
+				if err != nil {
+					return err
+				}
+				if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if deleting {
+		return r.completeVanishedNodes(members, vanished)
+	}
+
+	total := r.kataConfig.Status.TotalNodesCount
+	if len(members) < total {
+		total = len(members)
+	}
+	if len(departed) == 0 && total == r.kataConfig.Status.TotalNodesCount {
+		return nil
+	}
+
+	r.Log.Info("Nodes left the kata pool, dropping them from the status", "nodes", departed,
+		"left the pool without being deleted", leftPool)
+	cleanup := !r.extensionDelivery()
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		dropNodes(status, departed)
+		status.TotalNodesCount = total
+		if cleanup {
+			for _, name := range leftPool {
+				if !contains(status.UnInstallationStatus.DepartedNodesList, name) {
+					status.UnInstallationStatus.DepartedNodesList = append(status.UnInstallationStatus.DepartedNodesList, name)
+				}
+			}
+		}
+	})
+	if len(departed) > 0 {
+		r.recordHistory(kataconfigurationv1.HistoryNodesRemoved,
+			fmt.Sprintf("%s left the kata pool", strings.Join(departed, ", ")))
+	}
+	return nil
+}
+
+// completeVanishedNodes counts the nodes deleted, or replaced, during the uninstallation as
+// uninstalled. They would never report it, and the uninstallation counters would never match.
+// The total follows the live nodes, so that the nodes deleted before reporting any progress
+// don't hold the uninstallation either
+func (r *KataConfigOpenShiftReconciler) completeVanishedNodes(members []corev1.Node, vanished []string) error {
+	completed, total := vanishedNodesAccounting(&r.kataConfig.Status, members, vanished)
+	if len(completed) == 0 && total == r.kataConfig.Status.TotalNodesCount {
+		return nil
+	}
+
+	if len(completed) > 0 {
+		r.Log.Info("Nodes deleted during the uninstallation, counting them as uninstalled", "nodes", completed)
+		r.Recorder.Eventf(r.kataConfig, corev1.EventTypeNormal, "NodesVanished",
+			"%s deleted during the uninstallation, counted as uninstalled", strings.Join(completed, ", "))
+		r.recordHistory(kataconfigurationv1.HistoryNodesRemoved,
+			fmt.Sprintf("%s deleted during the uninstallation", strings.Join(completed, ", ")))
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		dropNodes(status, completed)
+		uninstallation := &status.UnInstallationStatus
+		uninstallation.Completed.CompletedNodesList = append(uninstallation.Completed.CompletedNodesList, completed...)
+		uninstallation.Completed.CompletedNodesCount = len(uninstallation.Completed.CompletedNodesList)
+		status.TotalNodesCount = total
+	})
+	return nil
+}
+
+// vanishedNodesAccounting returns the vanished nodes not counted as uninstalled yet, and the
+// total of nodes once they are: the nodes the status knows about and the live ones, at most
+func vanishedNodesAccounting(status *kataconfigurationv1.KataConfigStatus, members []corev1.Node, vanished []string) ([]string, int) {
+	uninstalled := status.UnInstallationStatus.Completed.CompletedNodesList
+	var completed []string
+	for _, name := range vanished {
+		if !contains(uninstalled, name) {
+			completed = append(completed, name)
+		}
+	}
+
+	expected := statusNodes(status)
+	for _, node := range members {
+		if !contains(expected, node.Name) {
+			expected = append(expected, node.Name)
+		}
+	}
+	total := status.TotalNodesCount
+	if len(expected) < total {
+		total = len(expected)
+	}
+	return completed, total
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func nodes(names ...string) []corev1.Node {
+	var nodes []corev1.Node
+	for _, name := range names {
+		nodes = append(nodes, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return nodes
+}
+
+func failedNodes(names ...string) kataconfigurationv1.KataFailedNodeStatus {
+	failed := kataconfigurationv1.KataFailedNodeStatus{FailedNodesCount: len(names)}
+	for _, name := range names {
+		failed.FailedNodesList = append(failed.FailedNodesList, kataconfigurationv1.FailedNodeStatus{Name: name, Error: "boom"})
+	}
+	return failed
+}
+
+func TestAddedNodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed []string
+		failed    []string
+		nodes     []corev1.Node
+		expected  []string
+	}{
+		{name: "no nodes"},
+		{name: "first installation", nodes: nodes("worker-0", "worker-1"), expected: []string{"worker-0", "worker-1"}},
+		{name: "installed pool", completed: []string{"worker-0", "worker-1"}, nodes: nodes("worker-0", "worker-1")},
+		{
+			name:      "joining node",
+			completed: []string{"worker-0"},
+			nodes:     nodes("worker-0", "worker-1"),
+			expected:  []string{"worker-1"},
+		},
+		{
+			name:      "failed node",
+			completed: []string{"worker-0"},
+			failed:    []string{"worker-1"},
+			nodes:     nodes("worker-0", "worker-1", "worker-2"),
+			expected:  []string{"worker-2"},
+		},
+	}
+
+	for _, test := range tests {
+		status := &kataconfigurationv1.KataConfigStatus{}
+		status.InstallationStatus.Completed.CompletedNodesList = test.completed
+		status.InstallationStatus.Failed = failedNodes(test.failed...)
+		if added := addedNodes(status, test.nodes); !reflect.DeepEqual(added, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, added)
+		}
+	}
+}
+
+func TestStatusNodes(t *testing.T) {
+	status := &kataconfigurationv1.KataConfigStatus{}
+	status.InstallationStatus.Completed.CompletedNodesList = []string{"worker-0", "worker-1"}
+	status.InstallationStatus.InProgress.BinariesInstalledNodesList = []string{"worker-2"}
+	status.InstallationStatus.Failed = failedNodes("worker-3")
+	status.UnInstallationStatus.Completed.CompletedNodesList = []string{"worker-1", "worker-4"}
+	status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList = []string{"worker-5"}
+	status.UnInstallationStatus.Failed = failedNodes("worker-0", "worker-6")
+
+	expected := []string{"worker-0", "worker-1", "worker-2", "worker-3", "worker-4", "worker-5", "worker-6"}
+	if names := statusNodes(status); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestDropNodes(t *testing.T) {
+	status := &kataconfigurationv1.KataConfigStatus{}
+	status.InstallationStatus.Completed.CompletedNodesList = []string{"worker-0", "worker-1"}
+	status.InstallationStatus.Completed.CompletedNodesCount = 2
+	status.InstallationStatus.InProgress.BinariesInstalledNodesList = []string{"worker-2"}
+	status.InstallationStatus.Failed = failedNodes("worker-1", "worker-3")
+	status.InstallationStatus.SmokeTest.VerifiedNodesList = []string{"worker-0", "worker-1"}
+	status.UnInstallationStatus.Completed.CompletedNodesList = []string{"worker-1"}
+
+	dropNodes(status, []string{"worker-1", "worker-2"})
+
+	expected := &kataconfigurationv1.KataConfigStatus{}
+	expected.InstallationStatus.Completed.CompletedNodesList = []string{"worker-0"}
+	expected.InstallationStatus.Completed.CompletedNodesCount = 1
+	expected.InstallationStatus.Failed = failedNodes("worker-3")
+	expected.InstallationStatus.SmokeTest.VerifiedNodesList = []string{"worker-0"}
+	if !reflect.DeepEqual(status.InstallationStatus, expected.InstallationStatus) {
+		t.Errorf("expected the installation status %+v, got %+v", expected.InstallationStatus, status.InstallationStatus)
+	}
+	if names := statusNodes(status); !reflect.DeepEqual(names, []string{"worker-0", "worker-3"}) {
+		t.Errorf("expected the departed nodes to be dropped, the status still lists %v", names)
+	}
+}
+
+func TestVanishedNodesAccounting(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       int
+		installed   []string
+		uninstalled []string
+		members     []corev1.Node
+		vanished    []string
+		completed   []string
+		expected    int
+	}{
+		{
+			name:      "nothing vanished",
+			total:     2,
+			installed: []string{"worker-0", "worker-1"},
+			members:   nodes("worker-0", "worker-1"),
+			expected:  2,
+		},
+		{
+			name:      "deleted node",
+			total:     3,
+			installed: []string{"worker-0", "worker-1", "worker-2"},
+			members:   nodes("worker-0", "worker-1"),
+			vanished:  []string{"worker-2"},
+			completed: []string{"worker-2"},
+			expected:  3,
+		},
+		{
+			name:        "deleted node already uninstalled",
+			total:       2,
+			installed:   []string{"worker-0", "worker-1"},
+			uninstalled: []string{"worker-1"},
+			members:     nodes("worker-0"),
+			vanished:    []string{"worker-1"},
+			expected:    2,
+		},
+		{
+			name:      "total larger than the known nodes",
+			total:     5,
+			installed: []string{"worker-0", "worker-1"},
+			members:   nodes("worker-0", "worker-3"),
+			vanished:  []string{"worker-1"},
+			completed: []string{"worker-1"},
+			expected:  3,
+		},
+	}
+
+	for _, test := range tests {
+		status := &kataconfigurationv1.KataConfigStatus{TotalNodesCount: test.total}
+		status.InstallationStatus.Completed.CompletedNodesList = test.installed
+		status.UnInstallationStatus.Completed.CompletedNodesList = test.uninstalled
+		completed, total := vanishedNodesAccounting(status, test.members, test.vanished)
+		if !reflect.DeepEqual(completed, test.completed) || total != test.expected {
+			t.Errorf("%s: expected %v out of %d nodes, got %v out of %d",
+				test.name, test.completed, test.expected, completed, total)
+		}
+	}
+}
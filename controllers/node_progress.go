@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// aggregateNodeProgress folds the progress the daemons reported on their nodes into the
+// KataConfig status, together with the nodes exceeding the installation timeout. The
+// daemons never write the KataConfig themselves
+func (r *KataConfigOpenShiftReconciler) aggregateNodeProgress() error {
+	if err := r.reportCrashedDaemons(); err != nil {
+		return err
+	}
+	nodes, err := r.listReportingNodes()
+	if err != nil {
+		return err
+	}
+
+	if err := r.reportNodeRepairs(nodes); err != nil {
+		return err
+	}
+	deleting := r.kataConfig.GetDeletionTimestamp() != nil
+	if !deleting {
+		if err := r.reinstallReplacedMachines(nodes); err != nil {
+			return err
+		}
+	}
+	r.recordNodeEvents(nodes)
+	r.reportTEECapabilities(nodes)
+
+	status := r.kataConfig.Status.DeepCopy()
+	reported := nodeprogress.Aggregate(status, nodes, r.kataConfig.Name, deleting)
+
+	timedOut, err := r.installTimedOutNodes()
+	if err != nil {
+		return err
+	}
+	// the conditions set from the progress are cleared once no node reports it anymore
+	if !reported && len(timedOut) == 0 &&
+		!anyConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigDegraded,
+			kataconfigurationv1.KataConfigFIPSIncompatible, kataconfigurationv1.KataConfigVirtualizationDisabled,
+			kataconfigurationv1.KataConfigAttestationUnavailable) {
+		return nil
+	}
+	if !deleting {
+		markInstallTimedOut(status, timedOut)
+		setDegradedCondition(status, timedOut)
+	}
+
+	setNodeFailureConditions(status, nodes, r.kataConfig.Name)
+
+	wasDegraded := meta.IsStatusConditionTrue(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigDegraded)
+	if degraded := meta.FindStatusCondition(status.Conditions, kataconfigurationv1.KataConfigDegraded); degraded != nil &&
+		degraded.Status == metav1.ConditionTrue && !wasDegraded {
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, degraded.Reason, degraded.Message)
+	}
+	installTimedOutNodes.WithLabelValues(r.kataConfig.Name).Set(float64(len(timedOut)))
+
+	if equality.Semantic.DeepEqual(status.InstallationStatus, r.kataConfig.Status.InstallationStatus) &&
+		equality.Semantic.DeepEqual(status.UnInstallationStatus, r.kataConfig.Status.UnInstallationStatus) &&
+		equality.Semantic.DeepEqual(status.Conditions, r.kataConfig.Status.Conditions) {
+		return nil
+	}
+
+	installation := status.InstallationStatus
+	uninstallation := status.UnInstallationStatus
+	conditions := status.Conditions
+	if !deleting {
+		r.observeInstallDurations(installation.InstallDurations)
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		if deleting {
+			status.UnInstallationStatus.InProgress = uninstallation.InProgress
+			status.UnInstallationStatus.Failed = uninstallation.Failed
+		} else {
+			status.InstallationStatus = installation
+		}
+		for _, condition := range conditions {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		}
+	})
+	return nil
+}
+
+// setNodeFailureConditions sets the conditions of the installation failures the nodes reported
+// for a known reason, and sets them back to False once no node reports the reason anymore
+func setNodeFailureConditions(status *kataconfigurationv1.KataConfigStatus, nodes []corev1.Node, kataConfigName string) {
+	var fipsIncompatible, virtualizationDisabled, attestationUnavailable []string
+	for i := range nodes {
+		p := nodeprogress.Get(&nodes[i], kataConfigName)
+		if p.State == nodeprogress.InstallFailed && p.Reason == nodeprogress.ReasonFIPSIncompatible {
+			fipsIncompatible = append(fipsIncompatible, fmt.Sprintf("%s: %s", nodes[i].Name, p.Error))
+		}
+		if p.State == nodeprogress.InstallFailed && p.Reason == nodeprogress.ReasonVirtualizationDisabled {
+			virtualizationDisabled = append(virtualizationDisabled, nodes[i].Name)
+		}
+		if p.State == nodeprogress.InstallFailed && p.Reason == nodeprogress.ReasonAttestationUnavailable {
+			attestationUnavailable = append(attestationUnavailable, fmt.Sprintf("%s: %s", nodes[i].Name, p.Error))
+		}
+	}
+	if len(virtualizationDisabled) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:   kataconfigurationv1.KataConfigVirtualizationDisabled,
+			Status: metav1.ConditionTrue,
+			Reason: "NoHardwareVirtualization",
+			Message: fmt.Sprintf("the VMs of %s don't expose the hardware assisted virtualization, enable VHV on them",
+				strings.Join(virtualizationDisabled, ", ")),
+		})
+	} else if meta.IsStatusConditionTrue(status.Conditions, kataconfigurationv1.KataConfigVirtualizationDisabled) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigVirtualizationDisabled,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AsExpected",
+			Message: "the VMs of the kata nodes expose the hardware assisted virtualization",
+		})
+	}
+	if len(attestationUnavailable) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigAttestationUnavailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  "AttestationServiceUnreachable",
+			Message: strings.Join(attestationUnavailable, "; "),
+		})
+	} else if meta.IsStatusConditionTrue(status.Conditions, kataconfigurationv1.KataConfigAttestationUnavailable) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigAttestationUnavailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AsExpected",
+			Message: "the kata nodes reach the attestation services",
+		})
+	}
+	if len(fipsIncompatible) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigFIPSIncompatible,
+			Status:  metav1.ConditionTrue,
+			Reason:  "NonCompliantPayload",
+			Message: strings.Join(fipsIncompatible, "; "),
+		})
+	} else if meta.IsStatusConditionTrue(status.Conditions, kataconfigurationv1.KataConfigFIPSIncompatible) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    kataconfigurationv1.KataConfigFIPSIncompatible,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AsExpected",
+			Message: "the kata payloads of the FIPS nodes are FIPS compliant",
+		})
+	}
+}
+
+// anyConditionTrue tells whether any of the given conditions is True
+func anyConditionTrue(conditions []metav1.Condition, conditionTypes ...string) bool {
+	for _, conditionType := range conditionTypes {
+		if meta.IsStatusConditionTrue(conditions, conditionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// observeInstallDurations records in the install duration histogram the nodes that completed
+// their installation since the status was last written
+func (r *KataConfigOpenShiftReconciler) observeInstallDurations(durations []kataconfigurationv1.KataNodeInstallDuration) {
+	for _, node := range durations {
+		observed := false
+		for _, previous := range r.kataConfig.Status.InstallationStatus.InstallDurations {
+			observed = observed || previous == node
+		}
+		if !observed {
+			nodeInstallDuration.WithLabelValues(r.kataConfig.Name).Observe(node.Duration.Seconds())
+		}
+	}
+}
+
+// reportNodeRepairs records an event, and the history, for every drift the daemons repaired on
+// their node, and removes the repair from the node once reported
+func (r *KataConfigOpenShiftReconciler) reportNodeRepairs(nodes []corev1.Node) error {
+	patch, err := nodeprogress.RepairPatch("")
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		repair := nodeprogress.Get(node, r.kataConfig.Name).Repair
+		if repair == "" {
+			continue
+		}
+
+		r.Log.Info("The kata daemon repaired the node", "node", node.Name, "repair", repair)
+		message := fmt.Sprintf("node %s: %s", node.Name, repair)
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "DriftRepaired", message)
+		r.Recorder.Event(node, corev1.EventTypeNormal, "DriftRepaired", repair)
+		r.recordHistory(kataconfigurationv1.HistoryNodeRepaired, message)
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportTEECapabilities publishes the trusted execution environment capabilities the nodes
+// reported in the status, by node name
+func (r *KataConfigOpenShiftReconciler) reportTEECapabilities(nodes []corev1.Node) {
+	var capabilities []kataconfigurationv1.KataNodeTEECapabilities
+	for i := range nodes {
+		tee := nodeprogress.Get(&nodes[i], r.kataConfig.Name).TEE
+		if tee == nil {
+			continue
+		}
+		capabilities = append(capabilities, kataconfigurationv1.KataNodeTEECapabilities{
+			NodeName:        nodes[i].Name,
+			SNPEnabled:      tee.SNP,
+			FirmwareVersion: tee.Firmware,
+			MaxGuests:       tee.MaxGuests,
+		})
+	}
+	sort.Slice(capabilities, func(i, j int) bool { return capabilities[i].NodeName < capabilities[j].NodeName })
+
+	if equality.Semantic.DeepEqual(capabilities, r.kataConfig.Status.TEECapabilities) {
+		return
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		status.TEECapabilities = capabilities
+	})
+}
+
+// clearNodeProgress removes the progress annotations of the KataConfig from the nodes
+func (r *KataConfigOpenShiftReconciler) clearNodeProgress() error {
+	nodes, err := r.listReportingNodes()
+	if err != nil {
+		return err
+	}
+
+	patch, err := nodeprogress.ClearPatch()
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeProgressChanged filters the node events down to the progress reports of the daemons,
+// and the reboots that may tell a replaced machine
+var nodeProgressChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, okOld := e.ObjectOld.(*corev1.Node)
+		newNode, okNew := e.ObjectNew.(*corev1.Node)
+		if okOld && okNew && oldNode.Status.NodeInfo.BootID != newNode.Status.NodeInfo.BootID {
+			return true
+		}
+		for _, a := range nodeprogress.Annotations {
+			if e.MetaOld.GetAnnotations()[a] != e.MetaNew.GetAnnotations()[a] {
+				return true
+			}
+		}
+		return false
+	},
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetNodeFailureConditions(t *testing.T) {
+	failedNode := func(name, reason string) corev1.Node {
+		node := progressNode(name, nodeprogress.InstallFailed)
+		node.Annotations[nodeprogress.ReasonAnnotation] = reason
+		node.Annotations[nodeprogress.ErrorAnnotation] = "boom"
+		return node
+	}
+	conditionTypes := []string{
+		kataconfigurationv1.KataConfigFIPSIncompatible,
+		kataconfigurationv1.KataConfigVirtualizationDisabled,
+		kataconfigurationv1.KataConfigAttestationUnavailable,
+	}
+
+	tests := []struct {
+		name     string
+		previous metav1.ConditionStatus
+		nodes    []corev1.Node
+		expected map[string]metav1.ConditionStatus
+	}{
+		{name: "no failure", nodes: []corev1.Node{progressNode("worker-0", nodeprogress.Installed)}},
+		{
+			name: "FIPS incompatible payload",
+			nodes: []corev1.Node{
+				failedNode("worker-0", nodeprogress.ReasonFIPSIncompatible),
+				progressNode("worker-1", nodeprogress.Installed),
+			},
+			expected: map[string]metav1.ConditionStatus{kataconfigurationv1.KataConfigFIPSIncompatible: metav1.ConditionTrue},
+		},
+		{
+			name:     "payload fixed",
+			previous: metav1.ConditionTrue,
+			nodes:    []corev1.Node{progressNode("worker-0", nodeprogress.Installed)},
+			expected: map[string]metav1.ConditionStatus{
+				kataconfigurationv1.KataConfigFIPSIncompatible:       metav1.ConditionFalse,
+				kataconfigurationv1.KataConfigVirtualizationDisabled: metav1.ConditionFalse,
+				kataconfigurationv1.KataConfigAttestationUnavailable: metav1.ConditionFalse,
+			},
+		},
+		{
+			name:     "failed node left",
+			previous: metav1.ConditionTrue,
+			expected: map[string]metav1.ConditionStatus{
+				kataconfigurationv1.KataConfigFIPSIncompatible:       metav1.ConditionFalse,
+				kataconfigurationv1.KataConfigVirtualizationDisabled: metav1.ConditionFalse,
+				kataconfigurationv1.KataConfigAttestationUnavailable: metav1.ConditionFalse,
+			},
+		},
+		{
+			name:     "still failing",
+			previous: metav1.ConditionTrue,
+			nodes: []corev1.Node{
+				failedNode("worker-0", nodeprogress.ReasonVirtualizationDisabled),
+				failedNode("worker-1", nodeprogress.ReasonAttestationUnavailable),
+			},
+			expected: map[string]metav1.ConditionStatus{
+				kataconfigurationv1.KataConfigFIPSIncompatible:       metav1.ConditionFalse,
+				kataconfigurationv1.KataConfigVirtualizationDisabled: metav1.ConditionTrue,
+				kataconfigurationv1.KataConfigAttestationUnavailable: metav1.ConditionTrue,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		status := &kataconfigurationv1.KataConfigStatus{}
+		if test.previous != "" {
+			for _, conditionType := range conditionTypes {
+				meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+					Type:   conditionType,
+					Status: test.previous,
+					Reason: "Failed",
+				})
+			}
+		}
+
+		setNodeFailureConditions(status, test.nodes, "example")
+
+		for _, conditionType := range conditionTypes {
+			condition := meta.FindStatusCondition(status.Conditions, conditionType)
+			expected, ok := test.expected[conditionType]
+			switch {
+			case !ok && condition != nil:
+				t.Errorf("%s: expected no %s condition, got %+v", test.name, conditionType, condition)
+			case ok && (condition == nil || condition.Status != expected):
+				t.Errorf("%s: expected the %s condition %s, got %+v", test.name, conditionType, expected, condition)
+			case ok && expected == metav1.ConditionFalse && condition.Reason != "AsExpected":
+				t.Errorf("%s: expected the %s condition to be cleared as expected, got %s", test.name, conditionType, condition.Reason)
+			}
+		}
+	}
+}
@@ -22,26 +22,34 @@ import (
 	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"text/template"
-	"time"
 
 	ignTypes "github.com/coreos/ignition/config/v2_2/types"
 	"github.com/go-logr/logr"
 	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // blank assignment to verify that KataConfigOpenShiftReconciler implements reconcile.Reconciler
@@ -53,8 +61,64 @@ type KataConfigOpenShiftReconciler struct {
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 
+	// Recorder emits the events of the KataConfig, e.g. when it becomes degraded, and the
+	// kata lifecycle events of the nodes
+	Recorder record.EventRecorder
+
+	// Intervals are the wait intervals between the checks of the installation progress
+	Intervals Intervals
+
+	// Health is told the outcome of every reconcile, for the liveness probe of the manager
+	Health *ReconcileHealth
+
+	// Settings are the settings reloaded from the operator config file, if any. They take
+	// effect from the next reconcile
+	Settings *LiveSettings
+	settings Settings
+
+	// APIReader reads from the API server the objects the operator may only get, e.g. the
+	// ClusterVersion, which the cache can't list and watch
+	APIReader client.Reader
+
+	// OperatorCache caches the pods, daemonsets, configmaps and secrets of the operator
+	// namespace when the manager cache is scoped, see NewScopedClientFunc. Nil otherwise
+	OperatorCache cache.Cache
+
+	// secretsCache caches the Secrets of the operator namespace, where the cloud credentials of
+	// the peer pods are. It is OperatorCache when the manager cache is scoped
+	secretsCache cache.Cache
+
+	// baseLog is Log as set up by the manager, Log gets the values of the current reconcile
+	baseLog logr.Logger
+
 	clientset  kubernetes.Interface
 	kataConfig *kataconfigurationv1.KataConfig
+
+	// ctx is cancelled when the manager stops so that in-flight reconciliations abort
+	ctx context.Context
+
+	// statusMutations are the status changes written at the end of the reconcile
+	statusMutations []statusMutation
+
+	// mcpPoll backs off the polling of the machine config pools, mcpPolled tells whether
+	// the current reconcile polled one
+	mcpPoll   mcpPollBackoff
+	mcpPolled bool
+
+	// clusterVersion is the OpenShift version of the cluster, read once per reconcile
+	clusterVersion *version.Version
+
+	// mcpWaits tracks since when the KataConfigs wait for their machine config pool
+	mcpWaits mcpWaits
+
+	// mcDebounce holds back the MachineConfig updates while the spec keeps changing
+	mcDebounce mcDebounce
+
+	// nodeEvents tracks the node states the lifecycle events of the nodes are recorded from
+	nodeEvents nodeEvents
+
+	// nodeEventWindow is Intervals.NodeEventCoalescing, read by the event handlers
+	nodeEventWindow int64
 }
 
 // +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataconfigs;kataconfigs/finalizers,verbs=get;list;watch;create;update;patch;delete
@@ -63,28 +127,57 @@ type KataConfigOpenShiftReconciler struct {
 // +kubebuilder:rbac:groups=apps,resources=daemonsets/finalizers,resourceNames=manager-role,verbs=update
 // +kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get
+// +kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=katapayloads,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubevirt.io,resources=kubevirts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=katanodeconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataannotationpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=operators.coreos.com,resources=operatorconditions,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+// +kubebuilder:rbac:groups=machine.openshift.io,resources=machinesets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups="";machineconfiguration.openshift.io,resources=nodes;machineconfigs;machineconfigpools;pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("kataconfig", req.NamespacedName)
+	if r.ctx == nil {
+		r.ctx = context.Background()
+	}
+	if r.baseLog == nil {
+		r.baseLog = r.Log
+	}
+	r.Log = reconcileLogger(r.baseLog, req)
 	r.Log.Info("Reconciling KataConfig in OpenShift Cluster")
+	r.applySettings()
+
+	result, err := r.reconcileKataConfig(req)
+	r.Health.Observe(err)
+	return result, err
+}
+
+// reconcileKataConfig is one pass over the KataConfig
+func (r *KataConfigOpenShiftReconciler) reconcileKataConfig(req ctrl.Request) (ctrl.Result, error) {
 
 	// Fetch the KataConfig instance
 	r.kataConfig = &kataconfigurationv1.KataConfig{}
-	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
+	err := r.Client.Get(r.ctx, req.NamespacedName, r.kataConfig)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after ctrl request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
-			return ctrl.Result{}, nil
+			return ctrl.Result{}, r.upgradeableWithoutKataConfig()
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
 
-	return func() (ctrl.Result, error) {
+	r.statusMutations = nil
+	r.mcpPolled = false
+	r.clusterVersion = nil
+	result, err := func() (ctrl.Result, error) {
 		oldest, err := r.isOldestCR()
 		if !oldest && err != nil {
 			return reconcile.Result{Requeue: true}, err
@@ -92,23 +185,100 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result
 			return reconcile.Result{}, nil
 		}
 
+		if err := r.aggregateNodeProgress(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.reconcileMachineHooks(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.labelKataRuntimeNodes(); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		// Check if the KataConfig instance is marked to be deleted, which is
 		// indicated by the deletion timestamp being set.
 		if r.kataConfig.GetDeletionTimestamp() != nil {
 			return r.processKataConfigDeleteRequest()
 		}
 
+		if r.kataConfig.Spec.DryRun {
+			return r.reconcileDryRun()
+		}
+		if r.kataConfig.Status.DryRun != nil {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				status.DryRun = nil
+			})
+		}
+
+		if err := r.labelKataPoolNodes(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.labelEligibleNodes(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.reconcileProvisionedWorkers(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if wait, err := r.reconcileChannel(); err != nil || wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, err
+		}
+
+		if compatible, err := r.checkClusterVersion(); err != nil || !compatible {
+			return r.requeue(), err
+		}
+
+		machinePool, err := r.workerOrMaster()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if mode, err := r.reconcileInstallMode(machinePool); err != nil {
+			return ctrl.Result{}, err
+		} else if mode == kataconfigurationv1.InstallModePeerPods {
+			// the peer pods run in VMs of the cloud provider, nothing is installed on the nodes
+			return r.reconcilePeerPods(machinePool)
+		}
+
+		if err := r.reconcileKubeVirtCoexistence(machinePool); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.checkPotentialConflicts(machinePool); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.checkCgroupCompatibility(machinePool); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.checkSGXCapacity(machinePool); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.publishRenderedConfig(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// The completion is computed from the progress the nodes of the pool report, the counts
+		// of the status drift when nodes join or leave the pool
+		nodes, err := r.listKataNodes(machinePool)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		binariesInstalled, completed := r.installCompletion(nodes)
+
 		// if we are using openshift then make sure that MCO related things are
 		// handled only after kata binaries are installed on the nodes
-		if r.kataConfig.Status.TotalNodesCount > 0 &&
-			len(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList) == r.kataConfig.Status.TotalNodesCount {
+		if binariesInstalled {
 			return r.monitorKataConfigInstallation()
 		}
 
 		// Once all the nodes have installed kata binaries and configured the CRI runtime create the runtime class
-		if r.kataConfig.Status.TotalNodesCount > 0 &&
-			r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == r.kataConfig.Status.TotalNodesCount &&
-			r.kataConfig.Status.RuntimeClass == "" {
+		if completed && r.kataConfig.Status.RuntimeClass == "" {
 
 			err := r.deleteKataDaemonset(InstallOperation)
 			if err != nil {
@@ -117,40 +287,168 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result
 
 			return r.setRuntimeClass()
 		}
+
+		// Once installed, keep the objects rendered from the spec in sync with it, unless the
+		// installation is being extended to new nodes
+		if r.kataConfig.Status.RuntimeClass != "" {
+			if wait, err := r.reconcilePoolSelector(); err != nil || wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, err
+			}
+			adding, err := r.reconcilePoolMembership()
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !adding {
+				if result, err := r.reconcileSpecChanges(); err != nil || result.RequeueAfter > 0 {
+					return result, err
+				}
+			}
+		}
+
 		// Intiate the installation of kata runtime on the nodes if it doesn't exist already
-		return r.processKataConfigInstallRequest()
+		return r.processKataConfigInstallRequest(machinePool, completed)
 	}()
+
+	if !r.mcpPolled {
+		r.mcpPoll.reset(r.kataConfig.Name)
+		r.endMCPWait()
+	}
+
+	// OLM must not replace the operator in the middle of a rollout
+	if conditionErr := r.reconcileOperatorCondition(); conditionErr != nil {
+		r.Log.Error(conditionErr, "failed to update the OperatorCondition")
+	}
+
+	if statusErr := r.flushStatus(); statusErr != nil {
+		if err != nil {
+			r.Log.Error(statusErr, "failed to update the KataConfig status")
+			return result, err
+		}
+		return ctrl.Result{}, statusErr
+	}
+	return result, err
+}
+
+// daemonsetArchitectures returns the architectures that get their own kata daemonset. Unless
+// per-architecture payloads are requested, or resolved from the channel, a single daemonset covers the whole pool
+func (r *KataConfigOpenShiftReconciler) daemonsetArchitectures() ([]string, error) {
+	if len(r.payloadImages()) == 0 {
+		return []string{""}, nil
+	}
+
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return nil, err
+	}
+
+	archs := nodeArchitectures(nodes)
+	if len(archs) == 0 {
+		return []string{""}, nil
+	}
+	return archs, nil
 }
 
-func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOperation) *appsv1.DaemonSet {
-	var (
-		runPrivileged           = true
-		configmapOptional       = true
-		runAsUser         int64 = 0
-	)
+func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOperation, arch string) *appsv1.DaemonSet {
+	configmapOptional := true
 
 	dsName := "kata-operator-daemon-" + string(operation)
+	if arch != "" {
+		dsName += "-" + arch
+	}
 	labels := map[string]string{
 		"name": dsName,
 	}
 
+	// the nodes excluded from the kata pool don't run the daemon
+	var requirements []corev1.NodeSelectorRequirement
+	for _, exclusion := range poolExclusions(r.kataConfig) {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      exclusion.Key,
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   exclusion.Values,
+		})
+	}
+	env := []corev1.EnvVar{
+		{
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "spec.nodeName",
+				},
+			},
+		},
+		{
+			Name: "KATA_PAYLOAD_IMAGE",
+			ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "payload-config",
+					},
+					Key:      "daemon.payload",
+					Optional: &configmapOptional,
+				},
+			},
+		},
+	}
+
+	// the daemons check the shim is installed
+	if shim := kataShim(r.kataConfig); shim.Path != defaultShimPath {
+		env = append(env, corev1.EnvVar{
+			Name:  "KATA_SHIM_PATH",
+			Value: shim.Path,
+		})
+	}
+
+	if arch != "" {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      nodeArchLabel,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{arch},
+		})
+
+		if image, ok := r.payloadImages()[arch]; ok {
+			env = append(env, corev1.EnvVar{
+				Name:  "KATA_ARCH_PAYLOAD_IMAGE",
+				Value: image,
+			})
+		}
+	}
+
 	var nodeSelector map[string]string
-	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-		nodeSelector = r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels
+	if selector := kataPoolSelector(r.kataConfig); selector != nil {
+		nodeSelector = selector.MatchLabels
 	} else {
 		nodeSelector = map[string]string{
 			"node-role.kubernetes.io/worker": "",
 		}
 	}
 
-	return &appsv1.DaemonSet{
+	affinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: requirements,
+					},
+				},
+			},
+		},
+	}
+
+	ds := &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
 			Kind:       "DaemonSet",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      dsName,
-			Namespace: "kata-operator-system",
+			Namespace: daemonNamespace,
+			Labels:    map[string]string{daemonOperationLabel: string(operation)},
 		},
 		Spec: appsv1.DaemonSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -161,49 +459,28 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "default",
+					ServiceAccountName: daemonServiceAccountName,
 					NodeSelector:       nodeSelector,
+					Affinity:           affinity,
 					Containers: []corev1.Container{
 						{
 							Name:            "kata-install-pod",
-							Image:           "quay.io/isolatedcontainers/kata-operator-daemon@sha256:528c7f6b9495f4ac13c156f79f59023b46b1817250f51ac88c73fd4163d45f8f",
+							Image:           r.settings.daemonImage(),
 							ImagePullPolicy: "Always",
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &runPrivileged,
-								RunAsUser:  &runAsUser,
-							},
-							Lifecycle: &corev1.Lifecycle{
-								PreStop: &corev1.Handler{
-									Exec: &corev1.ExecAction{
-										Command: []string{"/bin/sh", "-c", "rm -rf /host/opt/kata-install /host/usr/local/kata/"},
-									},
-								},
-							},
-							Command: []string{"/bin/sh", "-c", fmt.Sprintf("/daemon --resource %s --operation %s", r.kataConfig.Name, operation)},
+							SecurityContext: daemonSecurityContext(),
+							Command:         []string{"/bin/sh", "-c", fmt.Sprintf("/daemon --resource %s --operation %s", r.kataConfig.Name, operation)},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "hostroot",
 									MountPath: "/host",
 								},
 							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "KATA_PAYLOAD_IMAGE",
-									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "payload-config",
-											},
-											Key:      "daemon.payload",
-											Optional: &configmapOptional,
-										},
-									},
-								},
-							},
+							Env: env,
 						},
 					},
 					Volumes: []corev1.Volume{
 						{
+							// The daemon chroots into the host to drive rpm-ostree
 							Name: "hostroot", // Has to match VolumeMounts in containers
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
@@ -213,12 +490,41 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 							},
 						},
 					},
-					HostNetwork: true,
-					HostPID:     true,
 				},
 			},
 		},
 	}
+
+	if operation == InstallOperation {
+		// Pull the payload into the node cache on every node before installing anything,
+		// so the installation itself, and any later re-install, works from the local copy
+		prePull := *ds.Spec.Template.Spec.Containers[0].DeepCopy()
+		prePull.Name = "kata-prepull-pod"
+		prePull.Command = []string{"/bin/sh", "-c", fmt.Sprintf("/daemon --resource %s --operation %s", r.kataConfig.Name, PrePullOperation)}
+		ds.Spec.Template.Spec.InitContainers = []corev1.Container{prePull}
+		ds.Spec.UpdateStrategy = daemonUpdateStrategy(r.kataConfig.Spec.DaemonUpdateStrategy)
+	}
+
+	return ds
+}
+
+// daemonUpdateStrategy returns the update strategy of the install daemonset, rolling one node
+// at a time unless the KataConfig sets another one
+func daemonUpdateStrategy(strategy *kataconfigurationv1.KataDaemonUpdateStrategy) appsv1.DaemonSetUpdateStrategy {
+	if strategy != nil && strategy.Type == kataconfigurationv1.DaemonOnDelete {
+		return appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}
+	}
+
+	maxUnavailable := intstr.FromInt(1)
+	if strategy != nil && strategy.MaxUnavailable != nil {
+		maxUnavailable = *strategy.MaxUnavailable
+	}
+	return appsv1.DaemonSetUpdateStrategy{
+		Type: appsv1.RollingUpdateDaemonSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
 }
 
 func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool {
@@ -228,11 +534,7 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 		Values:   []string{"kata-oc", "worker"},
 	}
 
-	var nodeSelector *metav1.LabelSelector
-
-	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-		nodeSelector = r.kataConfig.Spec.KataConfigPoolSelector
-	}
+	nodeSelector := kataPoolSelector(r.kataConfig)
 
 	mcp := &mcfgv1.MachineConfigPool{
 		TypeMeta: metav1.TypeMeta{
@@ -240,7 +542,9 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 			Kind:       "MachineConfigPool",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "kata-oc",
+			Name:            "kata-oc",
+			Labels:          map[string]string{kataConfigOwnerLabel: r.kataConfig.Name},
+			OwnerReferences: []metav1.OwnerReference{r.kataConfigOwnerReference()},
 		},
 		Spec: mcfgv1.MachineConfigPoolSpec{
 			MachineConfigSelector: &metav1.LabelSelector{
@@ -268,23 +572,28 @@ ExecRestart=/usr/libexec/kata-containers/osbuilder/kata-osbuilder.sh
 WantedBy=multi-user.target
 `
 
-	kataOC, err := r.kataOcExists()
+	nodes, err := r.listKataNodes(machinePool)
 	if err != nil {
 		return nil, err
 	}
 
-	if kataOC {
-		machinePool = "kata-oc"
-	} else if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; ok {
-		r.Log.Info("in newMCforCR machinePool" + machinePool)
-	} else {
-		r.Log.Error(err, "no valid role for mc found")
-	}
+	// the MachineConfig follows the pool selector: the kata pool when it selects some of the
+	// nodes, the whole worker or master pool otherwise
+	machinePool = r.kataPoolName(machinePool)
 
 	file := ignTypes.File{}
 	c := ignTypes.FileContents{}
 
-	dropinConf, err := generateDropinConfig(r.kataConfig.Status.RuntimeClass)
+	handler, err := r.kataHandlerEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	var handlers []crioHandler
+	if handler {
+		handlers = kataHandlers(r.kataConfig)
+	}
+	dropinConf, err := generateDropinConfig(handlers)
 	if err != nil {
 		return nil, err
 	}
@@ -295,18 +604,62 @@ WantedBy=multi-user.target
 	m := 420
 	file.Mode = &m
 	file.Path = "/etc/crio/crio.conf.d/50-kata.conf"
+	files := []ignTypes.File{file}
+
+	kataConf, err := generateKataConfigDropin(r.kataConfig, nodeArchitectures(nodes), nodeCgroupVersions(r.kataConfig.Name, nodes), false)
+	if err != nil {
+		return nil, err
+	}
+	if kataConf != "" {
+		kataFile := ignTypes.File{}
+		kataFile.Contents = ignTypes.FileContents{
+			Source: "data:text/plain;charset=utf-8;base64," + kataConf,
+		}
+		kataFile.Filesystem = "root"
+		kataFile.Mode = &m
+		kataFile.Path = kataConfigDropinPath
+		files = append(files, kataFile)
+	}
+
+	units := []ignTypes.Unit{
+		{Name: name, Enabled: &isenabled, Contents: content},
+	}
+	if confidentialEnabled(r.kataConfig) {
+		ccConf, err := generateKataConfigDropin(r.kataConfig, nodeArchitectures(nodes), nodeCgroupVersions(r.kataConfig.Name, nodes), true)
+		if err != nil {
+			return nil, err
+		}
+		ccFile := ignTypes.File{}
+		ccFile.Contents = ignTypes.FileContents{
+			Source: "data:text/plain;charset=utf-8;base64," + ccConf,
+		}
+		ccFile.Filesystem = "root"
+		ccFile.Mode = &m
+		ccFile.Path = kataCCConfigDropinPath
+		files = append(files, ccFile)
+		units = append(units, ignTypes.Unit{Name: kataCCConfigUnitName, Enabled: &isenabled, Contents: kataCCConfigUnit})
+	}
+
+	if payloads := generateInstalledPayloads(r.kataConfig.Status.Upgradestatus.InstalledPayloads); payloads != "" {
+		payloadsFile := ignTypes.File{}
+		payloadsFile.Contents = ignTypes.FileContents{
+			Source: "data:text/plain;charset=utf-8;base64," + payloads,
+		}
+		payloadsFile.Filesystem = "root"
+		payloadsFile.Mode = &m
+		payloadsFile.Path = kataPayloadsPath
+		files = append(files, payloadsFile)
+	}
 
 	ic := ignTypes.Config{
 		Ignition: ignTypes.Ignition{
 			Version: "2.2.0",
 		},
 		Systemd: ignTypes.Systemd{
-			Units: []ignTypes.Unit{
-				{Name: name, Enabled: &isenabled, Contents: content},
-			},
+			Units: units,
 		},
 	}
-	ic.Storage.Files = []ignTypes.File{file}
+	ic.Storage.Files = files
 
 	icb, err := json.Marshal(ic)
 	if err != nil {
@@ -319,44 +672,59 @@ WantedBy=multi-user.target
 			Kind:       "MachineConfig",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "50-kata-crio-dropin",
+			Name: kataMachineConfigName,
 			Labels: map[string]string{
 				"machineconfiguration.openshift.io/role": machinePool,
 				"app":                                    r.kataConfig.Name,
+				kataConfigOwnerLabel:                     r.kataConfig.Name,
 			},
-			Namespace: "kata-operator",
+			OwnerReferences: []metav1.OwnerReference{r.kataConfigOwnerReference()},
+			Namespace:       "kata-operator",
 		},
 		Spec: mcfgv1.MachineConfigSpec{
 			Config: runtime.RawExtension{
 				Raw: icb,
 			},
+			KernelArguments: kernelArguments(r.kataConfig, nodeArchitectures(nodes)),
 		},
 	}
 
+	if r.extensionDelivery() {
+		mc.Spec.Extensions = []string{kataExtensionName}
+	}
+
 	return &mc, nil
 }
 
-func generateDropinConfig(handlerName string) (string, error) {
+// generateDropinConfig renders the CRI-O drop-in with the given kata handlers, none while kata
+// is disabled
+func generateDropinConfig(handlers []crioHandler) (string, error) {
 	var err error
 	buf := new(bytes.Buffer)
 	type RuntimeConfig struct {
-		RuntimeName string
+		Handlers []crioHandler
 	}
 	const b = `
 [crio.runtime]
   manage_ns_lifecycle = true
-
-[crio.runtime.runtimes.{{.RuntimeName}}]
-  runtime_path = "/usr/bin/containerd-shim-kata-v2"
-  runtime_type = "vm"
-  runtime_root = "/run/vc"
-  
+{{range .Handlers}}
+[crio.runtime.runtimes.{{.Name}}]
+  runtime_path = "{{.Shim.Path}}"
+  runtime_type = "{{.Shim.Type}}"
+  runtime_root = "{{.Shim.Root}}"
+{{- if .ConfigPath}}
+  runtime_config_path = "{{.ConfigPath}}"
+{{- end}}
+{{- if .PullImage}}
+  runtime_pull_image = true
+{{- end}}
+  {{end}}
 [crio.runtime.runtimes.runc]
   runtime_path = ""
   runtime_type = "oci"
   runtime_root = "/run/runc"
 `
-	c := RuntimeConfig{RuntimeName: "kata"}
+	c := RuntimeConfig{Handlers: handlers}
 	t := template.Must(template.New("test").Parse(b))
 	err = t.Execute(buf, c)
 	if err != nil {
@@ -371,7 +739,7 @@ func (r *KataConfigOpenShiftReconciler) addFinalizer() error {
 	controllerutil.AddFinalizer(r.kataConfig, kataConfigFinalizer)
 
 	// Update CR
-	err := r.Client.Update(context.TODO(), r.kataConfig)
+	err := r.Client.Update(r.ctx, r.kataConfig)
 	if err != nil {
 		r.Log.Error(err, "Failed to update KataConfig with finalizer")
 		return err
@@ -379,27 +747,39 @@ func (r *KataConfigOpenShiftReconciler) addFinalizer() error {
 	return nil
 }
 
-func (r *KataConfigOpenShiftReconciler) listKataPods() error {
-	podList := &corev1.PodList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(corev1.NamespaceAll),
-	}
-	if err := r.Client.List(context.TODO(), podList, listOpts...); err != nil {
-		return fmt.Errorf("Failed to list kata pods: %v", err)
-	}
-	for _, pod := range podList.Items {
-		if pod.Spec.RuntimeClassName != nil {
-			if *pod.Spec.RuntimeClassName == r.kataConfig.Status.RuntimeClass {
-				return fmt.Errorf("Existing pods using Kata Runtime found. Please delete the pods manually for KataConfig deletion to proceed")
+// listKataPods returns the pods using one of the kata runtime classes
+func (r *KataConfigOpenShiftReconciler) listKataPods() ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	for _, runtimeClassName := range strings.Split(r.kataConfig.Status.RuntimeClass, ",") {
+		if runtimeClassName == "" {
+			continue
+		}
+
+		if r.OperatorCache != nil {
+			classPods, err := r.listPodsByRuntimeClass(runtimeClassName)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to list kata pods: %v", err)
 			}
+			pods = append(pods, classPods...)
+			continue
+		}
+
+		podList := &corev1.PodList{}
+		listOpts := []client.ListOption{
+			client.InNamespace(corev1.NamespaceAll),
+			client.MatchingFields{podRuntimeClassNameField: runtimeClassName},
+		}
+		if err := r.Client.List(r.ctx, podList, listOpts...); err != nil {
+			return nil, fmt.Errorf("Failed to list kata pods: %v", err)
 		}
+		pods = append(pods, podList.Items...)
 	}
-	return nil
+	return pods, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) kataOcExists() (bool, error) {
 	kataOcMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "kata-oc"}, kataOcMcp)
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: "kata-oc"}, kataOcMcp)
 	if err != nil && errors.IsNotFound(err) {
 		r.Log.Info("No kata-oc machine config pool found!")
 		return false, nil
@@ -411,10 +791,25 @@ func (r *KataConfigOpenShiftReconciler) kataOcExists() (bool, error) {
 	return true, nil
 }
 
+// listKataNodes returns the nodes selected by the kata pool selector, defaulting to all the
+// nodes of the given machine pool role, without the excluded nodes
+func (r *KataConfigOpenShiftReconciler) listKataNodes(machinePool string) ([]corev1.Node, error) {
+	selector, err := kataNodesSelector(r.kataConfig, machinePool)
+	if err != nil {
+		return nil, err
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(r.ctx, nodesList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	return nodesList.Items, nil
+}
+
 func (r *KataConfigOpenShiftReconciler) workerOrMaster() (string, error) {
 	var role string
 	workerMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "worker"}, workerMcp)
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: "worker"}, workerMcp)
 	if err != nil && errors.IsNotFound(err) {
 		r.Log.Error(err, "No worker machine config pool found!")
 		return "", err
@@ -431,68 +826,50 @@ func (r *KataConfigOpenShiftReconciler) workerOrMaster() (string, error) {
 	return role, nil
 }
 
-func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
+func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest(machinePool string, completed bool) (ctrl.Result, error) {
 	if r.kataConfig.Status.TotalNodesCount == 0 {
 
 		nodesList := &corev1.NodeList{}
 
-		/* This could be the case in a compact cluster where master and workers are on the same node */
-		machinePool, err := r.workerOrMaster()
-		if err != nil {
-			return reconcile.Result{}, err
-		}
-
-		if r.kataConfig.Spec.KataConfigPoolSelector == nil {
+		if kataPoolSelector(r.kataConfig) == nil {
 			r.kataConfig.Spec.KataConfigPoolSelector = &metav1.LabelSelector{
 				MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
 			}
 		}
 
-		listOpts := []client.ListOption{
-			client.MatchingLabels(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels),
+		selector, err := kataNodesSelector(r.kataConfig, machinePool)
+		if err != nil {
+			return ctrl.Result{}, err
 		}
 
-		err = r.Client.List(context.TODO(), nodesList, listOpts...)
+		err = r.Client.List(r.ctx, nodesList, client.MatchingLabelsSelector{Selector: selector})
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-		r.kataConfig.Status.TotalNodesCount = len(nodesList.Items)
 
-		if r.kataConfig.Status.TotalNodesCount == 0 {
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second},
+		if len(nodesList.Items) == 0 {
+			return r.requeue(),
 				fmt.Errorf("No suitable worker nodes found for kata installation. Please make sure to label the nodes with labels specified in KataConfigPoolSelector")
 		}
 
-		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
+		if err := validateConfidentialConfig(r.kataConfig, nodeArchitectures(nodesList.Items)); err != nil {
+			return r.requeue(), err
+		}
+		if err := validateQEMUConfig(r.kataConfig, nodeArchitectures(nodesList.Items)); err != nil {
+			return r.requeue(), err
 		}
-	}
 
-	if r.kataConfig.Status.KataImage == "" {
-		// TODO - placeholder. This will change in future.
-		r.kataConfig.Status.KataImage = "quay.io/kata-operator/kata-artifacts:1.0"
+		totalNodesCount := len(nodesList.Items)
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.TotalNodesCount = totalNodesCount
+		})
 	}
 
-	// Don't create the daemonset if kata is already installed on the cluster nodes
-	if r.kataConfig.Status.TotalNodesCount > 0 &&
-		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount != r.kataConfig.Status.TotalNodesCount {
-		ds := r.processDaemonsetForCR(InstallOperation)
-		// Set KataConfig instance as the owner and controller
-		if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
-			return ctrl.Result{}, err
-		}
-		foundDs := &appsv1.DaemonSet{}
-		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
-		if err != nil && errors.IsNotFound(err) {
-			r.Log.Info("Creating a new installation Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
-			err = r.Client.Create(context.TODO(), ds)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-		} else if err != nil {
-			return ctrl.Result{}, err
-		}
+	if r.kataConfig.Status.KataImage == "" {
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			// TODO - placeholder. This will change in future.
+			status.KataImage = "quay.io/kata-operator/kata-artifacts:1.0"
+		})
 	}
 
 	// Add finalizer for this CR
@@ -502,61 +879,102 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 		}
 	}
 
-	return ctrl.Result{}, nil
-}
+	// Don't create the daemonset if kata is already installed on the cluster nodes
+	if r.kataConfig.Status.TotalNodesCount > 0 && !completed {
+		if r.extensionDelivery() {
+			return r.reconcileExtensionInstallation()
+		}
 
-func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
-	runtimeClassName := "kata"
+		archs, err := r.daemonsetArchitectures()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 
-	rc := func() *nodeapi.RuntimeClass {
-		rc := &nodeapi.RuntimeClass{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "node.k8s.io/v1beta1",
-				Kind:       "RuntimeClass",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: runtimeClassName,
-			},
-			Handler: runtimeClassName,
-			// Use same values for Pod Overhead as upstream kata-deploy using, see
-			// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
-			Overhead: &nodeapi.Overhead{
-				PodFixed: corev1.ResourceList{
-					corev1.ResourceCPU:    resource.MustParse("250m"),
-					corev1.ResourceMemory: resource.MustParse("160Mi"),
-				},
-			},
+		if err := r.applyDaemonSCC(); err != nil {
+			return ctrl.Result{}, err
 		}
 
-		if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-			rc.Scheduling = &nodeapi.Scheduling{
-				NodeSelector: r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels,
+		for _, arch := range archs {
+			ds := r.processDaemonsetForCR(InstallOperation, arch)
+			// Set KataConfig instance as the owner and controller
+			if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+				return ctrl.Result{}, err
+			}
+			foundDs := &appsv1.DaemonSet{}
+			err := r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+			if err != nil && !errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			created := errors.IsNotFound(err)
+			if created {
+				r.Log.Info("Creating a new installation Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
+			}
+			// Applied on every reconcile to revert changes made to the daemonset while installing
+			err = r.applyObject(ds)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if created {
+				r.recordHistory(kataconfigurationv1.HistoryPayloadApplied,
+					fmt.Sprintf("installation daemonset %s created with payload %s", ds.Name, r.kataConfig.Status.KataImage))
 			}
 		}
-		return rc
-	}()
+	}
 
-	// Set Kataconfig r.kataConfig as the owner and controller
-	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
-		return ctrl.Result{}, err
+	return ctrl.Result{}, nil
+}
+
+func (r *KataConfigOpenShiftReconciler) newRuntimeClassForCR() *nodeapi.RuntimeClass {
+	rc := &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: kataRuntimeClassName,
+		},
+		Handler: runtimeHandler(r.kataConfig),
+		Overhead: &nodeapi.Overhead{
+			PodFixed: podOverhead(r.kataConfig, r.kataConfig.Spec.Overhead),
+		},
 	}
 
-	foundRc := &nodeapi.RuntimeClass{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating a new RuntimeClass", "rc.Name", rc.Name)
-		err = r.Client.Create(context.TODO(), rc)
-		if err != nil {
+	rc.Scheduling = &nodeapi.Scheduling{
+		NodeSelector: runtimeClassNodeSelector(r.kataConfig.Spec.SchedulingNodeSelector, map[string]string{kataRuntimeLabel: "true"}),
+	}
+	return rc
+}
+
+func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
+	rcs := r.newRuntimeClassesForCR()
+	skipped, err := r.adoptRuntimeClasses(rcs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var names []string
+	for _, rc := range rcs {
+		// Set Kataconfig r.kataConfig as the owner and controller
+		if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
 			return ctrl.Result{}, err
 		}
+
+		// A KataConfig created disabled gets its RuntimeClass once enabled
+		if r.kataEnabled() && !skipped[rc.Name] {
+			r.Log.Info("Applying the RuntimeClass", "rc.Name", rc.Name)
+			err := r.applyObject(rc)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		names = append(names, rc.Name)
 	}
 
 	if r.kataConfig.Status.RuntimeClass == "" {
-		r.kataConfig.Status.RuntimeClass = runtimeClassName
-		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+		generation := r.kataConfig.Generation
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.RuntimeClass = strings.Join(names, ",")
+			status.ObservedGeneration = generation
+		})
 	}
 
 	return ctrl.Result{}, nil
@@ -566,28 +984,98 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 	r.Log.Info("KataConfig deletion in progress: ")
 	machinePool, err := r.workerOrMaster()
 	if err != nil {
-		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+		return r.requeue(), err
 	}
 
 	if contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		// the smoke test pods run the kata runtime, they must not hold the deletion
+		if err := r.removeSmokeTests(); err != nil {
+			return r.requeue(), err
+		}
+
 		// Get the list of pods that might be running using kata runtime
-		err := r.listKataPods()
+		pods, err := r.listKataPods()
 		if err != nil {
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+			return r.requeue(), err
+		}
+		if len(pods) > 0 || meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataconfigurationv1.KataConfigDeletionBlocked) != nil {
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				setDeletionBlockedCondition(status, pods)
+			})
+		}
+		if len(pods) > 0 && r.kataConfig.Spec.ForceUninstall != nil && r.kataConfig.Spec.ForceUninstall.Enabled {
+			err = r.evictKataPods(pods)
+			if err != nil {
+				return r.requeue(), err
+			}
+			r.Log.Info("Waiting for the evicted kata pods to terminate", "pods", len(pods))
+			return r.requeue(), nil
+		}
+		if len(pods) > 0 {
+			return r.requeue(),
+				fmt.Errorf("%d pods using Kata Runtime found, see the %s condition. Please delete the pods manually for KataConfig deletion to proceed",
+					len(pods), kataconfigurationv1.KataConfigDeletionBlocked)
 		}
 
-		ds := r.processDaemonsetForCR(UninstallOperation)
+		if err := r.removeKataMonitor(); err != nil {
+			return r.requeue(), err
+		}
 
-		foundDs := &appsv1.DaemonSet{}
-		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
-		if err != nil && errors.IsNotFound(err) {
-			r.Log.Info("Creating a new uninstallation Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
-			err = r.Client.Create(context.TODO(), ds)
-			if err != nil {
+		if err := r.removeQuoteGeneration(); err != nil {
+			return r.requeue(), err
+		}
+
+		if err := r.removeNodeConfigs(); err != nil {
+			return r.requeue(), err
+		}
+
+		// CRI-O must not be left with settings for the kata handler once kata is removed
+		if removed, err := r.removeCrioReload(); err != nil || !removed {
+			return r.requeue(), err
+		}
+
+		if r.kataConfig.Status.UnInstallationStatus.Report != nil {
+			return r.verifyUninstallation()
+		}
+
+		// the nodes deleted during the uninstallation would never report it
+		nodes, err := r.listKataNodes(machinePool)
+		if err != nil {
+			return r.requeue(), err
+		}
+		if err := r.reconcileNodeRemovals(nodes, true); err != nil {
+			return r.requeue(), err
+		}
+
+		if r.extensionDelivery() {
+			return r.processExtensionDeleteRequest(machinePool)
+		}
+
+		archs, err := r.daemonsetArchitectures()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.applyDaemonSCC(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		for _, arch := range archs {
+			ds := r.processDaemonsetForCR(UninstallOperation, arch)
+
+			foundDs := &appsv1.DaemonSet{}
+			err = r.Client.Get(r.ctx, types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+			if err != nil && errors.IsNotFound(err) {
+				r.Log.Info("Creating a new uninstallation Daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
+				err = r.applyObject(ds)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				r.recordHistory(kataconfigurationv1.HistoryUninstallStarted,
+					fmt.Sprintf("uninstallation daemonset %s created", ds.Name))
+			} else if err != nil {
 				return ctrl.Result{}, err
 			}
-		} else if err != nil {
-			return ctrl.Result{}, err
 		}
 
 		if r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount != r.kataConfig.Status.TotalNodesCount {
@@ -595,8 +1083,7 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount,
 				"Total number of kata installed nodes ", r.kataConfig.Status.TotalNodesCount)
 			// TODO - we don't need this nil check if we know that pool is always initialized
-			if r.kataConfig.Spec.KataConfigPoolSelector != nil &&
-				r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels != nil && len(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels) > 0 {
+			if selector := kataPoolSelector(r.kataConfig); selector != nil && len(selector.MatchLabels) > 0 {
 				if r.clientset == nil {
 					r.clientset, err = getClientSet()
 					if err != nil {
@@ -609,21 +1096,24 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 						continue
 					}
 
-					if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+					if r.kataPoolName(machinePool) != machinePool {
 						r.Log.Info("Removing the kata pool selector label from the node", "node name ", nodeName)
-						node, err := r.clientset.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
-						if err != nil {
+						node, err := r.clientset.CoreV1().Nodes().Get(r.ctx, nodeName, metav1.GetOptions{})
+						if errors.IsNotFound(err) {
+							// counted as uninstalled by reconcileNodeRemovals
+							continue
+						} else if err != nil {
 							return ctrl.Result{}, err
 						}
 
 						nodeLabels := node.GetLabels()
 
-						for k := range r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels {
+						for k := range selector.MatchLabels {
 							delete(nodeLabels, k)
 						}
 
 						node.SetLabels(nodeLabels)
-						_, err = r.clientset.CoreV1().Nodes().Update(context.Background(), node, metav1.UpdateOptions{})
+						_, err = r.clientset.CoreV1().Nodes().Update(r.ctx, node, metav1.UpdateOptions{})
 
 						if err != nil {
 							return ctrl.Result{}, err
@@ -634,11 +1124,11 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		}
 
 		r.Log.Info("Making sure parent MCP is synced properly, KataNodeRole=" + machinePool)
-		if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; ok {
+		if r.kataPoolName(machinePool) == machinePool {
 			mc, err := r.newMCForCR(machinePool)
 			var isMcDeleted bool
 
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, mc)
+			err = r.Client.Get(r.ctx, types.NamespacedName{Name: mc.Name}, mc)
 			if err != nil && errors.IsNotFound(err) {
 				isMcDeleted = true
 			} else if err != nil {
@@ -646,7 +1136,7 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 			}
 
 			if !isMcDeleted {
-				err = r.Client.Delete(context.TODO(), mc)
+				err = r.deleteObject(mc)
 				if err != nil {
 					// error during removing mc, don't block the uninstall. Just log the error and move on.
 					r.Log.Info("Error found deleting machine config. If the machine config exists after installation it can be safely deleted manually.",
@@ -654,30 +1144,37 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				}
 				// Sleep for MCP to reflect the changes
 				r.Log.Info("Pausing for a minute to make sure worker mcp has started syncing up")
-				time.Sleep(60 * time.Second)
+				if err := sleepWithContext(r.ctx, r.Intervals.MCPSyncDelay); err != nil {
+					return ctrl.Result{}, err
+				}
 			}
 
 			workreMcp := &mcfgv1.MachineConfigPool{}
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, workreMcp)
+			err = r.Client.Get(r.ctx, types.NamespacedName{Name: machinePool}, workreMcp)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
 			r.Log.Info("Monitoring worker mcp", "worker mcp name", workreMcp.Name, "ready machines", workreMcp.Status.ReadyMachineCount,
 				"total machines", workreMcp.Status.MachineCount)
 			if workreMcp.Status.ReadyMachineCount != workreMcp.Status.MachineCount {
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+				return r.pollMCP(workreMcp), nil
 			}
 		} else {
-			// Sleep for MCP to reflect the changes
-			if len(r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList) > 0 {
+			// Sleep for MCP to reflect the changes. When every node vanished none reports the
+			// uninstallation, the pool is removed right away
+			uninstallation := r.kataConfig.Status.UnInstallationStatus
+			if len(uninstallation.InProgress.BinariesUnInstalledNodesList) > 0 ||
+				(uninstallation.Completed.CompletedNodesCount > 0 && uninstallation.Completed.CompletedNodesCount >= r.kataConfig.Status.TotalNodesCount) {
 				r.Log.Info("Pausing for a minute to make sure parent mcp has started syncing up")
-				time.Sleep(60 * time.Second)
+				if err := sleepWithContext(r.ctx, r.Intervals.MCPSyncDelay); err != nil {
+					return ctrl.Result{}, err
+				}
 
 				parentMcp := &mcfgv1.MachineConfigPool{}
 
-				err := r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, parentMcp)
+				err := r.Client.Get(r.ctx, types.NamespacedName{Name: machinePool}, parentMcp)
 				if err != nil && errors.IsNotFound(err) {
-					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, fmt.Errorf("Not able to find parent pool %s", parentMcp.GetName())
+					return r.requeue(), fmt.Errorf("Not able to find parent pool %s", parentMcp.GetName())
 				} else if err != nil {
 					return ctrl.Result{}, err
 				}
@@ -685,11 +1182,11 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				r.Log.Info("Monitoring parent mcp", "parent mcp name", parentMcp.Name, "ready machines", parentMcp.Status.ReadyMachineCount,
 					"total machines", parentMcp.Status.MachineCount)
 				if parentMcp.Status.ReadyMachineCount != parentMcp.Status.MachineCount {
-					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+					return r.pollMCP(parentMcp), nil
 				}
 
 				mcp := r.newMCPforCR()
-				err = r.Client.Delete(context.TODO(), mcp)
+				err = r.deleteObject(mcp)
 				if err != nil {
 					// error during removing mcp, don't block the uninstall. Just log the error and move on.
 					r.Log.Info("Error found deleting mcp. If the mcp exists after installation it can be safely deleted manually.",
@@ -697,33 +1194,30 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				}
 
 				mc, err := r.newMCForCR(machinePool)
-				err = r.Client.Delete(context.TODO(), mc)
+				err = r.deleteObject(mc)
 				if err != nil {
 					// error during removing mc, don't block the uninstall. Just log the error and move on.
 					r.Log.Info("Error found deleting machine config. If the machine config exists after installation it can be safely deleted manually.",
 						"mc", mc.Name, "error", err)
 				}
 			} else {
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+				return r.requeue(), nil
 			}
 		}
 
-		for _, nodeName := range r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList {
-			if contains(r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList, nodeName) {
-				continue
-			}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			for _, nodeName := range status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList {
+				if contains(status.UnInstallationStatus.Completed.CompletedNodesList, nodeName) {
+					continue
+				}
 
-			r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount++
-			r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList = append(r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList, nodeName)
-			if r.kataConfig.Status.UnInstallationStatus.InProgress.InProgressNodesCount > 0 {
-				r.kataConfig.Status.UnInstallationStatus.InProgress.InProgressNodesCount--
+				status.UnInstallationStatus.Completed.CompletedNodesCount++
+				status.UnInstallationStatus.Completed.CompletedNodesList = append(status.UnInstallationStatus.Completed.CompletedNodesList, nodeName)
+				if status.UnInstallationStatus.InProgress.InProgressNodesCount > 0 {
+					status.UnInstallationStatus.InProgress.InProgressNodesCount--
+				}
 			}
-		}
-
-		err = r.Client.Status().Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+		})
 
 		r.Log.Info("Deleting uninstall daemonset")
 		err = r.deleteKataDaemonset(UninstallOperation)
@@ -731,36 +1225,43 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 			return ctrl.Result{}, err
 		}
 
-		r.Log.Info("Uninstallation completed on all nodes. Proceeding with the KataConfig deletion")
-		controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
-		err = r.Client.Update(context.TODO(), r.kataConfig)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+		return r.startUninstallVerification()
 	}
 	return ctrl.Result{}, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) deleteKataDaemonset(operation DaemonOperation) error {
-
-	ds := r.processDaemonsetForCR(operation)
-	foundDs := &appsv1.DaemonSet{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
-	if err != nil && errors.IsNotFound(err) {
-		// DaemonSet not found, nothing to delete, ignore the request.
-		return nil
-	} else if err != nil {
+	// the architectures may have left the pool, or the payloads the spec, since the daemonsets
+	// were created: all the daemonsets of the operation are deleted
+	dsList := &appsv1.DaemonSetList{}
+	if err := r.Client.List(r.ctx, dsList, client.InNamespace(daemonNamespace)); err != nil {
 		return err
 	}
 
-	err = r.Client.Delete(context.TODO(), foundDs)
-	if err != nil {
-		return err
+	for i := range dsList.Items {
+		ds := &dsList.Items[i]
+		if !isOperationDaemonset(ds, operation) {
+			continue
+		}
+		err := r.Client.Delete(r.ctx, ds)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// isOperationDaemonset tells whether the daemonset is one of the daemonsets of the operation.
+// The daemonsets created before they were labeled with their operation are told by name
+func isOperationDaemonset(ds *appsv1.DaemonSet, operation DaemonOperation) bool {
+	if op, ok := ds.Labels[daemonOperationLabel]; ok {
+		return op == string(operation)
+	}
+	name := "kata-operator-daemon-" + string(operation)
+	return ds.Name == name || strings.HasPrefix(ds.Name, name+"-")
+}
+
 func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Result, error) {
 	r.Log.Info("installation is complete on targetted nodes, now dropping in crio config using MCO")
 	machinePool, err := r.workerOrMaster()
@@ -768,20 +1269,22 @@ func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Re
 		return reconcile.Result{}, err
 	}
 
-	if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+	if r.kataPoolName(machinePool) != machinePool {
 		r.Log.Info("creating new Mcp")
 		mcp := r.newMCPforCR()
 
 		founcMcp := &mcfgv1.MachineConfigPool{}
-		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: mcp.Name}, founcMcp)
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: mcp.Name}, founcMcp)
 		if err != nil && errors.IsNotFound(err) {
 			r.Log.Info("Creating a new Machine Config Pool ", "mcp.Name", mcp.Name)
-			err = r.Client.Create(context.TODO(), mcp)
+			err = r.applyObject(mcp)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
+			r.recordHistory(kataconfigurationv1.HistoryMachineConfigPoolCreated,
+				fmt.Sprintf("machine config pool %s created", mcp.Name))
 			// mcp created successfully - requeue to check the status later
-			return ctrl.Result{Requeue: true, RequeueAfter: 20 * time.Second}, nil
+			return r.pollMCP(nil), nil
 		} else if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -789,14 +1292,18 @@ func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Re
 		// Wait till MCP is ready
 		if founcMcp.Status.MachineCount == 0 {
 			r.Log.Info("Waiting till Machine Config Pool is initialized ", "mcp.Name", mcp.Name)
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+			return r.pollMCP(founcMcp), nil
 		}
 		if founcMcp.Status.MachineCount != founcMcp.Status.ReadyMachineCount {
 			r.Log.Info("Waiting till Machine Config Pool is ready ", "mcp.Name", mcp.Name)
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+			return r.pollMCP(founcMcp), nil
 		}
 	}
 
+	if conflict, err := r.checkCrioConflicts(machinePool); err != nil || conflict {
+		return r.requeue(), err
+	}
+
 	r.Log.Info("KataNodeRole is: " + machinePool)
 	mc, err := r.newMCForCR(machinePool)
 	if err != nil {
@@ -804,13 +1311,18 @@ func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Re
 	}
 
 	foundMc := &mcfgv1.MachineConfig{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, foundMc)
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: mc.Name}, foundMc)
 	if err != nil && errors.IsNotFound(err) {
+		if wait, err := r.waitForMaintenanceWindow(); err != nil || wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, err
+		}
 		r.Log.Info("Creating a new Machine Config ", "mc.Name", mc.Name)
-		err = r.Client.Create(context.TODO(), mc)
+		err = r.applyObject(mc)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+		r.recordHistory(kataconfigurationv1.HistoryMachineConfigCreated,
+			fmt.Sprintf("machine config %s created for the %s pool", mc.Name, machinePool))
 		// mc created successfully - don't requeue
 		return ctrl.Result{}, nil
 	} else if err != nil {
@@ -821,8 +1333,113 @@ func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Re
 }
 
 func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	ctx, err := shutdownContext(mgr)
+	if err != nil {
+		return err
+	}
+	r.ctx = ctx
+
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("kataconfig-controller")
+	}
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+	r.Intervals.setDefaults()
+	r.setNodeEventWindow()
+
+	// the index would cache the pods of the whole cluster
+	if r.OperatorCache == nil {
+		if err := indexPodRuntimeClassName(mgr); err != nil {
+			return err
+		}
+	}
+	if err := indexNodes(mgr); err != nil {
+		return err
+	}
+	// the manager cache would hold the Secrets of the whole cluster
+	r.secretsCache = r.OperatorCache
+	if r.secretsCache == nil {
+		if r.secretsCache, err = NewOperatorCache(mgr.GetConfig(), mgr.GetScheme()); err != nil {
+			return err
+		}
+		if err := mgr.Add(r.secretsCache); err != nil {
+			return err
+		}
+	}
+
+	enqueueKataConfigs := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			kataConfigList := &kataconfigurationv1.KataConfigList{}
+			if err := mgr.GetClient().List(context.TODO(), kataConfigList); err != nil {
+				return []reconcile.Request{}
+			}
+
+			var requests []reconcile.Request
+			for _, kataConfig := range kataConfigList.Items {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: kataConfig.Name},
+				})
+			}
+			return requests
+		}),
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&kataconfigurationv1.KataConfig{}).
+		Owns(&nodeapi.RuntimeClass{})
+	if r.OperatorCache == nil {
+		b = b.Owns(&appsv1.DaemonSet{}).
+			Owns(&corev1.Pod{}).
+			// The daemons crashing before they report their progress fail their node
+			Watches(&source.Kind{Type: &corev1.Pod{}}, enqueueKataConfigs, builder.WithPredicates(daemonPodChanged))
+	} else {
+		// the daemonsets and the smoke test pods are all in the operator namespace
+		owned := &handler.EnqueueRequestForOwner{OwnerType: &kataconfigurationv1.KataConfig{}, IsController: true}
+		b = b.Watches(source.NewKindWithCache(&appsv1.DaemonSet{}, r.OperatorCache), owned).
+			Watches(source.NewKindWithCache(&corev1.Pod{}, r.OperatorCache), owned).
+			Watches(source.NewKindWithCache(&corev1.Pod{}, r.OperatorCache), enqueueKataConfigs, builder.WithPredicates(daemonPodChanged))
+	}
+	return b.
+		// The cloud-api-adaptor is rolled out again when its credentials are rotated
+		Watches(source.NewKindWithCache(&corev1.Secret{}, r.secretsCache), enqueueKataConfigs, builder.WithPredicates(credentialsSecretChanged)).
+		// New capacity is labeled, and kata installed on it, as soon as it joins the cluster
+		Watches(&source.Kind{Type: &corev1.Node{}}, &coalescingHandler{
+			mapper: enqueueKataConfigs.ToRequests,
+			window: &r.nodeEventWindow,
+		}, builder.WithPredicates(nodeEligibilityChanged)).
+		// The channels are resolved again whenever the catalog changes
+		Watches(&source.Kind{Type: &kataconfigurationv1.KataPayload{}}, enqueueKataConfigs).
+		// The KataNodeConfigs are rolled out as soon as they change
+		Watches(&source.Kind{Type: &kataconfigurationv1.KataNodeConfig{}}, enqueueKataConfigs).
+		// CRI-O passes the kata annotations of the policies down to the kata runtime
+		Watches(&source.Kind{Type: &kataconfigurationv1.KataAnnotationPolicy{}}, enqueueKataConfigs).
+		// The daemons report their progress on their node
+		Watches(&source.Kind{Type: &corev1.Node{}}, &coalescingHandler{
+			mapper: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+				name, ok := obj.Meta.GetAnnotations()[nodeprogress.KataConfigAnnotation]
+				if !ok {
+					return []reconcile.Request{}
+				}
+				return []reconcile.Request{{
+					NamespacedName: types.NamespacedName{Name: name},
+				}}
+			}),
+			window: &r.nodeEventWindow,
+		}, builder.WithPredicates(nodeProgressChanged)).
+		// The kata MachineConfig has no owner, map its changes back to the KataConfig
+		Watches(&source.Kind{Type: &mcfgv1.MachineConfig{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+				if obj.Meta.GetName() != kataMachineConfigName {
+					return []reconcile.Request{}
+				}
+				return []reconcile.Request{{
+					NamespacedName: types.NamespacedName{Name: obj.Meta.GetLabels()["app"]},
+				}}
+			}),
+		}).
+		// The MachineConfigs of other sources may conflict with the kata one
+		Watches(&source.Kind{Type: &mcfgv1.MachineConfig{}}, enqueueKataConfigs, builder.WithPredicates(foreignMachineConfigChanged)).
 		Complete(r)
 }
 
@@ -831,7 +1448,7 @@ func (r *KataConfigOpenShiftReconciler) isOldestCR() (bool, error) {
 	listOpts := []client.ListOption{
 		client.InNamespace(corev1.NamespaceAll),
 	}
-	if err := r.Client.List(context.TODO(), kataConfigList, listOpts...); err != nil {
+	if err := r.Client.List(r.ctx, kataConfigList, listOpts...); err != nil {
 		return false, fmt.Errorf("Failed to list KataConfig custom resources: %v", err)
 	}
 
@@ -866,18 +1483,16 @@ func (r *KataConfigOpenShiftReconciler) isOldestCR() (bool, error) {
 	oldestCRCreationDate := oldestCR.GetCreationTimestamp()
 	if !tkccd.Before(&oldestCRCreationDate) {
 		if r.kataConfig.Status.InstallationStatus.Failed.FailedNodesCount != -1 {
-			r.kataConfig.Status.InstallationStatus.Failed.FailedNodesCount = -1
-			r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{
-				{
-					Name:  "",
-					Error: fmt.Sprintf("Multiple KataConfig CRs are not supported, %s already exists", oldestCR.Name),
-				},
-			}
-
-			err := r.Client.Status().Update(context.TODO(), r.kataConfig)
-			if err != nil {
-				return false, err
-			}
+			oldestName := oldestCR.Name
+			r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+				status.InstallationStatus.Failed.FailedNodesCount = -1
+				status.InstallationStatus.Failed.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{
+					{
+						Name:  "",
+						Error: fmt.Sprintf("Multiple KataConfig CRs are not supported, %s already exists", oldestName),
+					},
+				}
+			})
 
 			return false, nil
 		}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// mcDebounce holds back the MachineConfig update of every KataConfig until its spec has stayed
+// unchanged for the debounce window. The MachineConfig is rendered from the whole spec, so the
+// edits made in the meantime are rolled out together and the nodes reboot once
+type mcDebounce struct {
+	mu      sync.Mutex
+	pending map[string]pendingMCUpdate
+}
+
+// pendingMCUpdate is the generation waiting to be rendered and when it was first seen
+type pendingMCUpdate struct {
+	generation int64
+	since      time.Time
+}
+
+// wait returns how long the MachineConfig update for the generation must still be held back.
+// A new generation starts the window over
+func (d *mcDebounce) wait(name string, generation int64, window time.Duration) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending == nil {
+		d.pending = map[string]pendingMCUpdate{}
+	}
+	pending, ok := d.pending[name]
+	if !ok || pending.generation != generation {
+		d.pending[name] = pendingMCUpdate{generation: generation, since: time.Now()}
+		return window
+	}
+	if remaining := window - time.Since(pending.since); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// done forgets the pending update of the KataConfig once the MachineConfig is written
+func (d *mcDebounce) done(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, name)
+}
+
+// reconcileSpecChanges keeps the objects rendered from the spec in sync once kata is installed,
+// rolling out the spec edits and reverting the changes made by others, and records the rolled
+// out generation. The MachineConfig updates caused by spec edits are debounced
+func (r *KataConfigOpenShiftReconciler) reconcileSpecChanges() (ctrl.Result, error) {
+	if r.kataConfig.Status.ObservedGeneration != r.kataConfig.Generation {
+		r.Log.Info("KataConfig spec changed after installation, updating the rendered objects",
+			"generation", r.kataConfig.Generation, "observedGeneration", r.kataConfig.Status.ObservedGeneration)
+	}
+
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The rendered CRI-O settings are left as they are until the conflicts are resolved
+	if conflict, err := r.checkCrioConflicts(machinePool); err != nil || conflict {
+		return r.requeue(), err
+	}
+
+	if wait, err := r.updateMachineConfig(machinePool); err != nil || wait > 0 {
+		return ctrl.Result{Requeue: true, RequeueAfter: wait}, err
+	}
+
+	if err := r.updateMachineConfigPool(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updateRuntimeClass(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileCrioReload(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileNodeConfigs(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileKataMonitor(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileQuoteGeneration(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileSmokeTests(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the kata pods aren't watched, check again later for the kata handler to be removed
+	waitingForPods, err := r.reconcileDisabled()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.kataConfig.Status.ObservedGeneration != r.kataConfig.Generation {
+		generation := r.kataConfig.Generation
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.ObservedGeneration = generation
+		})
+	}
+
+	if waitingForPods {
+		return r.requeue(), nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// updateMachineConfig re-renders the MachineConfig. It returns how long to wait before trying
+// again when the update is held back by the debounce window or the maintenance window
+func (r *KataConfigOpenShiftReconciler) updateMachineConfig(machinePool string) (time.Duration, error) {
+	mc, err := r.newMCForCR(machinePool)
+	if err != nil {
+		return 0, err
+	}
+
+	foundMc := &mcfgv1.MachineConfig{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: mc.Name}, foundMc)
+	if err != nil && errors.IsNotFound(err) {
+		if wait, err := r.waitForMaintenanceWindow(); err != nil || wait > 0 {
+			return wait, err
+		}
+		r.Log.Info("Creating a new Machine Config ", "mc.Name", mc.Name)
+		r.recordHistory(kataconfigurationv1.HistoryMachineConfigCreated,
+			fmt.Sprintf("machine config %s created for the %s pool", mc.Name, machinePool))
+		return 0, r.applyObject(mc)
+	} else if err != nil {
+		return 0, err
+	}
+
+	if equalRawJSON(foundMc.Spec.Config.Raw, mc.Spec.Config.Raw) &&
+		equality.Semantic.DeepEqual(foundMc.Spec.Extensions, mc.Spec.Extensions) &&
+		equality.Semantic.DeepEqual(foundMc.Spec.KernelArguments, mc.Spec.KernelArguments) &&
+		hasLabels(foundMc.Labels, mc.Labels) {
+		r.mcDebounce.done(r.kataConfig.Name)
+		r.clearPendingWindow()
+		return 0, nil
+	}
+
+	// Changes made by others to the MachineConfig are reverted right away, only the
+	// spec edits wait for the spec to settle
+	if r.kataConfig.Status.ObservedGeneration != r.kataConfig.Generation {
+		if wait := r.mcDebounce.wait(r.kataConfig.Name, r.kataConfig.Generation, r.Intervals.MCDebounce); wait > 0 {
+			r.Log.Info("Waiting for the KataConfig spec to settle before updating the Machine Config",
+				"mc.Name", mc.Name, "generation", r.kataConfig.Generation, "wait", wait)
+			return wait, nil
+		}
+	}
+
+	if wait, err := r.waitForMaintenanceWindow(); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	r.Log.Info("Updating the Machine Config, the nodes of the pool will be rebooted", "mc.Name", mc.Name)
+	r.recordHistory(kataconfigurationv1.HistoryMachineConfigUpdated,
+		fmt.Sprintf("machine config %s re-rendered", mc.Name))
+	if err := r.applyObject(mc); err != nil {
+		return 0, err
+	}
+	r.mcDebounce.done(r.kataConfig.Name)
+	return 0, nil
+}
+
+func (r *KataConfigOpenShiftReconciler) updateMachineConfigPool() error {
+	mcp := r.newMCPforCR()
+
+	foundMcp := &mcfgv1.MachineConfigPool{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: mcp.Name}, foundMcp)
+	if err != nil && errors.IsNotFound(err) {
+		// kata runs in the worker or master pool, there is no kata pool to update
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(foundMcp.Spec.NodeSelector, mcp.Spec.NodeSelector) &&
+		equality.Semantic.DeepEqual(foundMcp.Spec.MachineConfigSelector, mcp.Spec.MachineConfigSelector) &&
+		hasLabels(foundMcp.Labels, mcp.Labels) {
+		return nil
+	}
+
+	r.Log.Info("Updating the Machine Config Pool selectors", "mcp.Name", mcp.Name)
+	r.recordHistory(kataconfigurationv1.HistoryMachineConfigPoolUpdated,
+		fmt.Sprintf("selectors of machine config pool %s changed", mcp.Name))
+	return r.applyObject(mcp)
+}
+
+func (r *KataConfigOpenShiftReconciler) updateRuntimeClass() error {
+	rcs := r.newRuntimeClassesForCR()
+	skipped, err := r.adoptRuntimeClasses(rcs)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, rc := range rcs {
+		names = append(names, rc.Name)
+		// the RuntimeClasses of others are left alone
+		if skipped[rc.Name] {
+			continue
+		}
+		if err := r.updateRuntimeClassObject(rc); err != nil {
+			return err
+		}
+	}
+
+	// the kata-cc and kata-sgx RuntimeClasses go away with the confidential and SGX sandboxes
+	if !confidentialEnabled(r.kataConfig) {
+		if err := r.deleteRuntimeClass(kataCCRuntimeClassName, "confidential sandboxes are disabled"); err != nil {
+			return err
+		}
+	}
+	if !sgxEnabled(r.kataConfig) {
+		if err := r.deleteRuntimeClass(kataSGXRuntimeClassName, "SGX sandboxes are disabled"); err != nil {
+			return err
+		}
+	}
+
+	if runtimeClass := strings.Join(names, ","); r.kataConfig.Status.RuntimeClass != runtimeClass {
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.RuntimeClass = runtimeClass
+		})
+	}
+	return nil
+}
+
+// updateRuntimeClassObject applies a kata RuntimeClass if it differs from the existing one,
+// or deletes it while kata is disabled
+func (r *KataConfigOpenShiftReconciler) updateRuntimeClassObject(rc *nodeapi.RuntimeClass) error {
+	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
+		return err
+	}
+
+	// No new kata pod can be created once the RuntimeClass is gone
+	if !r.kataEnabled() {
+		return r.deleteRuntimeClass(rc.Name, "kata is disabled")
+	}
+
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: rc.Name}, foundRc)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if err == nil &&
+		foundRc.Handler == rc.Handler &&
+		equality.Semantic.DeepEqual(foundRc.Overhead, rc.Overhead) &&
+		equality.Semantic.DeepEqual(foundRc.Scheduling, rc.Scheduling) {
+		return nil
+	}
+
+	r.Log.Info("Applying the RuntimeClass", "rc.Name", rc.Name)
+	r.recordHistory(kataconfigurationv1.HistoryRuntimeClassUpdated,
+		fmt.Sprintf("runtime class %s re-rendered", rc.Name))
+	return r.applyObject(rc)
+}
+
+// deleteRuntimeClass deletes a kata RuntimeClass if it exists
+func (r *KataConfigOpenShiftReconciler) deleteRuntimeClass(name, reason string) error {
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, foundRc)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	r.Log.Info("Deleting the RuntimeClass", "rc.Name", name, "reason", reason)
+	r.recordHistory(kataconfigurationv1.HistoryRuntimeClassUpdated,
+		fmt.Sprintf("runtime class %s deleted, %s", name, reason))
+	if err := r.deleteObject(foundRc); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMCDebounce(t *testing.T) {
+	window := time.Minute
+	debounce := &mcDebounce{}
+
+	if wait := debounce.wait("example", 2, window); wait != window {
+		t.Errorf("expected a new generation to wait for the whole window, got %s", wait)
+	}
+	if wait := debounce.wait("example", 2, window); wait <= 0 || wait > window {
+		t.Errorf("expected the generation to wait for the rest of the window, got %s", wait)
+	}
+
+	// the window is over
+	debounce.pending["example"] = pendingMCUpdate{generation: 2, since: time.Now().Add(-window)}
+	if wait := debounce.wait("example", 2, window); wait != 0 {
+		t.Errorf("expected the update to go ahead once the spec settled, got %s", wait)
+	}
+
+	// an edit in the meantime starts the window over
+	if wait := debounce.wait("example", 3, window); wait != window {
+		t.Errorf("expected a newer generation to start the window over, got %s", wait)
+	}
+	if wait := debounce.wait("other", 1, window); wait != window {
+		t.Errorf("expected the KataConfigs to be debounced separately, got %s", wait)
+	}
+
+	debounce.done("example")
+	if _, ok := debounce.pending["example"]; ok {
+		t.Error("expected the pending update to be forgotten once the MachineConfig is written")
+	}
+}
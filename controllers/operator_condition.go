@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// operatorConditionNameEnv is set by OLM on the operator deployment to the name of the
+	// OperatorCondition of the operator
+	operatorConditionNameEnv = "OPERATOR_CONDITION_NAME"
+
+	// operatorConditionUpgradeable is the condition OLM checks before replacing the operator
+	operatorConditionUpgradeable = "Upgradeable"
+)
+
+// operatorConditionGVK is the OperatorCondition of OLM. Its types are not vendored, the
+// OperatorCondition is handled as an unstructured object
+var operatorConditionGVK = schema.GroupVersionKind{
+	Group:   "operators.coreos.com",
+	Version: "v1",
+	Kind:    "OperatorCondition",
+}
+
+// upgradeableCondition returns whether OLM may replace the operator: not while any KataConfig
+// installs, upgrades or removes the kata binaries, nor while its kata MachineConfig is rolled out
+// to the nodes. The operator is upgradeable without KataConfigs
+func (r *KataConfigOpenShiftReconciler) upgradeableCondition() (metav1.Condition, error) {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := r.Client.List(r.ctx, kataConfigList); err != nil {
+		return metav1.Condition{}, err
+	}
+
+	for i := range kataConfigList.Items {
+		kataConfig := &kataConfigList.Items[i]
+		// the KataConfig of the reconcile has the status not written yet
+		if r.kataConfig != nil && r.kataConfig.Name == kataConfig.Name {
+			kataConfig = r.kataConfig
+		}
+		reason, message, err := r.kataRolloutInFlight(kataConfig)
+		if err != nil {
+			return metav1.Condition{}, err
+		}
+		if reason != "" {
+			return metav1.Condition{
+				Type:    operatorConditionUpgradeable,
+				Status:  metav1.ConditionFalse,
+				Reason:  reason,
+				Message: message,
+			}, nil
+		}
+	}
+
+	return metav1.Condition{
+		Type:    operatorConditionUpgradeable,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AsExpected",
+		Message: "no kata rollout is in flight",
+	}, nil
+}
+
+// kataRolloutInFlight returns the reason, and the message, why the KataConfig holds back the
+// upgrade of the operator. The reason is empty if it doesn't
+func (r *KataConfigOpenShiftReconciler) kataRolloutInFlight(kataConfig *kataconfigurationv1.KataConfig) (string, string, error) {
+	status := kataConfig.Status
+	switch {
+	case kataConfig.GetDeletionTimestamp() != nil:
+		return "Uninstalling", fmt.Sprintf("kata is being uninstalled by the KataConfig %s", kataConfig.Name), nil
+	case len(status.Upgradestatus.UpgradingNodesList) > 0:
+		return "PayloadUpgrading", fmt.Sprintf("the kata payload is being upgraded on %d nodes by the KataConfig %s",
+			len(status.Upgradestatus.UpgradingNodesList), kataConfig.Name), nil
+	case status.InstallationStatus.InProgress.InProgressNodesCount > 0 ||
+		len(status.InstallationStatus.InProgress.BinariesInstalledNodesList) > 0:
+		return "Installing", fmt.Sprintf("kata is being installed by the KataConfig %s", kataConfig.Name), nil
+	}
+
+	if status.RuntimeClass == "" || kataConfig.Spec.PayloadDelivery == kataconfigurationv1.PayloadDeliveryExtension {
+		return "", "", nil
+	}
+	rolledOut, err := r.kataMachineConfigRolledOut(kataConfig)
+	if err != nil {
+		return "", "", err
+	}
+	if !rolledOut {
+		return "MachineConfigRollout", fmt.Sprintf("the %s machine config of the KataConfig %s is being rolled out",
+			kataMachineConfigName, kataConfig.Name), nil
+	}
+	return "", "", nil
+}
+
+// kataMachineConfigRolledOut tells whether all the machines of the kata pool of the KataConfig
+// run the kata MachineConfig
+func (r *KataConfigOpenShiftReconciler) kataMachineConfigRolledOut(kataConfig *kataconfigurationv1.KataConfig) (bool, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return false, err
+	}
+
+	mcp := &mcfgv1.MachineConfigPool{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: kataConfigPoolName(kataConfig, machinePool)}, mcp)
+	if err != nil && errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	// a pool left without machines has nothing to roll out
+	if mcp.Status.MachineCount == 0 {
+		return true, nil
+	}
+	return machineConfigRolledOut(mcp, kataMachineConfigName, true), nil
+}
+
+// reconcileOperatorCondition reports in the Upgradeable condition of the OperatorCondition of
+// the operator whether OLM may replace it. Nothing is done unless the operator is deployed by
+// OLM
+func (r *KataConfigOpenShiftReconciler) reconcileOperatorCondition() error {
+	name := os.Getenv(operatorConditionNameEnv)
+	if name == "" {
+		return nil
+	}
+
+	operatorCondition := &unstructured.Unstructured{}
+	operatorCondition.SetGroupVersionKind(operatorConditionGVK)
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: name, Namespace: operatorNamespace}, operatorCondition)
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	condition, err := r.upgradeableCondition()
+	if err != nil {
+		return err
+	}
+
+	var conditions []metav1.Condition
+	if raw, ok, _ := unstructured.NestedSlice(operatorCondition.Object, "spec", "conditions"); ok {
+		for _, item := range raw {
+			c := metav1.Condition{}
+			if obj, ok := item.(map[string]interface{}); ok {
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &c); err != nil {
+					return err
+				}
+			}
+			conditions = append(conditions, c)
+		}
+	}
+
+	updated := append([]metav1.Condition{}, conditions...)
+	meta.SetStatusCondition(&updated, condition)
+	if equality.Semantic.DeepEqual(updated, conditions) {
+		return nil
+	}
+
+	var raw []interface{}
+	for i := range updated {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&updated[i])
+		if err != nil {
+			return err
+		}
+		raw = append(raw, obj)
+	}
+	if err := unstructured.SetNestedSlice(operatorCondition.Object, raw, "spec", "conditions"); err != nil {
+		return err
+	}
+
+	r.Log.Info("Reporting the operator upgradeability to OLM", "upgradeable", condition.Status, "reason", condition.Reason)
+	return r.Client.Update(r.ctx, operatorCondition)
+}
+
+// upgradeableWithoutKataConfig reports the upgradeability of the operator once the KataConfig
+// of the reconcile is gone, from the KataConfigs left
+func (r *KataConfigOpenShiftReconciler) upgradeableWithoutKataConfig() error {
+	r.kataConfig = nil
+	return r.reconcileOperatorCondition()
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/kata-operator/pkg/operatorconfig"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// defaultDaemonImage is the image of the kata daemonsets
+	defaultDaemonImage = "quay.io/isolatedcontainers/kata-operator-daemon@sha256:528c7f6b9495f4ac13c156f79f59023b46b1817250f51ac88c73fd4163d45f8f"
+
+	// DefaultConfigReloadInterval is how often the operator config file is checked for changes.
+	// The files mounted from a ConfigMap are updated by the kubelet about every minute
+	DefaultConfigReloadInterval = 30 * time.Second
+)
+
+// Settings are the settings of the operator config file applied without restart
+type Settings struct {
+	Intervals            Intervals
+	DaemonImage          string
+	SmokeTestImage       string
+	PodVMBuilderImage    string
+	CloudAPIAdaptorImage string
+}
+
+// daemonImage returns the image of the kata daemonsets
+func (s Settings) daemonImage() string {
+	if s.DaemonImage != "" {
+		return s.DaemonImage
+	}
+	return defaultDaemonImage
+}
+
+// cloudAPIAdaptorImage returns the image of the cloud-api-adaptor
+func (s Settings) cloudAPIAdaptorImage() string {
+	if s.CloudAPIAdaptorImage != "" {
+		return s.CloudAPIAdaptorImage
+	}
+	return defaultCloudAPIAdaptorImage
+}
+
+// smokeTestImage returns the image of the smoke test pods that don't set one
+func (s Settings) smokeTestImage() string {
+	if s.SmokeTestImage != "" {
+		return s.SmokeTestImage
+	}
+	return defaultSmokeTestImage
+}
+
+// SettingsFromConfig returns the settings of base, e.g. the values of the flags, overridden by
+// the fields set in the config file
+func SettingsFromConfig(base Settings, config *operatorconfig.Config) Settings {
+	settings := base
+	override := func(value *time.Duration, configured time.Duration) {
+		if configured != 0 {
+			*value = configured
+		}
+	}
+	override(&settings.Intervals.Requeue, config.Intervals.Requeue.Duration)
+	override(&settings.Intervals.MCPPoll, config.Intervals.MCPPoll.Duration)
+	override(&settings.Intervals.MCPPollMax, config.Intervals.MCPPollMax.Duration)
+	override(&settings.Intervals.MCPSyncDelay, config.Intervals.MCPSyncDelay.Duration)
+	override(&settings.Intervals.MCDebounce, config.Intervals.MCDebounce.Duration)
+	override(&settings.Intervals.NodeEventCoalescing, config.Intervals.NodeEventCoalescing.Duration)
+	override(&settings.Intervals.MCPStallThreshold, config.Intervals.MCPStallThreshold.Duration)
+	if config.Images.Daemon != "" {
+		settings.DaemonImage = config.Images.Daemon
+	}
+	if config.Images.SmokeTest != "" {
+		settings.SmokeTestImage = config.Images.SmokeTest
+	}
+	if config.Images.PodVMBuilder != "" {
+		settings.PodVMBuilderImage = config.Images.PodVMBuilder
+	}
+	if config.Images.CloudAPIAdaptor != "" {
+		settings.CloudAPIAdaptorImage = config.Images.CloudAPIAdaptor
+	}
+	return settings
+}
+
+// LiveSettings hands the settings reloaded from the config file over to the reconciler
+type LiveSettings struct {
+	value atomic.Value
+}
+
+// Store replaces the settings
+func (s *LiveSettings) Store(settings Settings) {
+	s.value.Store(settings)
+}
+
+// Load returns the latest settings, if any was stored
+func (s *LiveSettings) Load() (Settings, bool) {
+	if s == nil {
+		return Settings{}, false
+	}
+	settings, ok := s.value.Load().(Settings)
+	return settings, ok
+}
+
+// applySettings takes the settings reloaded since the previous reconcile
+func (r *KataConfigOpenShiftReconciler) applySettings() {
+	settings, ok := r.Settings.Load()
+	if !ok {
+		return
+	}
+	if settings != r.settings {
+		r.Log.Info("Applying the operator configuration", "intervals", settings.Intervals,
+			"daemonImage", settings.daemonImage(), "smokeTestImage", settings.smokeTestImage(),
+			"podVMBuilderImage", settings.PodVMBuilderImage, "cloudAPIAdaptorImage", settings.cloudAPIAdaptorImage())
+	}
+	r.settings = settings
+	r.Intervals = settings.Intervals
+	r.Intervals.setDefaults()
+	r.setNodeEventWindow()
+}
+
+// ConfigWatcher reloads the operator config file when it changes. The intervals and the images
+// are applied from the next reconcile, the other settings are only read at startup
+type ConfigWatcher struct {
+	Path     string
+	Base     Settings
+	Settings *LiveSettings
+	Interval time.Duration
+	Log      logr.Logger
+
+	raw     []byte
+	initial *operatorconfig.Config
+}
+
+var _ manager.Runnable = &ConfigWatcher{}
+var _ manager.LeaderElectionRunnable = &ConfigWatcher{}
+
+// NewConfigWatcher loads the config file and stores its settings. It returns the config for
+// the settings read at startup
+func NewConfigWatcher(path string, base Settings, settings *LiveSettings, log logr.Logger) (*ConfigWatcher, *operatorconfig.Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := operatorconfig.Parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings.Store(SettingsFromConfig(base, config))
+	return &ConfigWatcher{
+		Path:     path,
+		Base:     base,
+		Settings: settings,
+		Interval: DefaultConfigReloadInterval,
+		Log:      log,
+		raw:      raw,
+		initial:  config,
+	}, config, nil
+}
+
+// NeedLeaderElection tells the manager to reload the config on every replica
+func (w *ConfigWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start checks the file for changes until stop is closed. An unreadable or invalid file is
+// logged and the previous settings are kept
+func (w *ConfigWatcher) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// reload applies the file if it changed
+func (w *ConfigWatcher) reload() {
+	raw, err := ioutil.ReadFile(w.Path)
+	if err != nil {
+		w.Log.Error(err, "unable to read the operator configuration, keeping the current settings", "path", w.Path)
+		return
+	}
+	if bytes.Equal(raw, w.raw) {
+		return
+	}
+	config, err := operatorconfig.Parse(raw)
+	if err != nil {
+		w.Log.Error(err, "invalid operator configuration, keeping the current settings", "path", w.Path)
+		return
+	}
+	w.raw = raw
+
+	w.Log.Info("Reloading the operator configuration", "path", w.Path)
+	w.Settings.Store(SettingsFromConfig(w.Base, config))
+	if config.LeaderElectionNamespace != w.initial.LeaderElectionNamespace ||
+		!reflect.DeepEqual(config.FeatureGates, w.initial.FeatureGates) {
+		w.Log.Info("The leader election namespace and the feature gates of the operator configuration only apply after a restart")
+	}
+}
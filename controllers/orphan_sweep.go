@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// kataConfigOwnerLabel is set on the cluster-scoped objects rendered for a KataConfig. Along with
+// their owner reference it lets the objects left behind by a missing KataConfig be found
+const kataConfigOwnerLabel = "kataconfiguration.openshift.io/owner"
+
+// kataConfigOwnerReference makes the rendered objects owned by the KataConfig, so that they
+// are garbage collected if the KataConfig goes away without the uninstallation completing,
+// e.g. when its finalizer is removed by hand
+func (r *KataConfigOpenShiftReconciler) kataConfigOwnerReference() metav1.OwnerReference {
+	return *metav1.NewControllerRef(r.kataConfig, kataconfigurationv1.GroupVersion.WithKind("KataConfig"))
+}
+
+// OrphanSweeper removes at startup the MachineConfigs and MachineConfigPools rendered for
+// KataConfigs that no longer exist, e.g. when the operator was removed in the middle of an
+// uninstallation. It runs on the leader only
+type OrphanSweeper struct {
+	// Reader reads from the API server, the cache may not be started yet
+	Reader client.Reader
+	Client client.Client
+	Log    logr.Logger
+}
+
+var _ manager.Runnable = &OrphanSweeper{}
+
+// Start sweeps the orphaned objects once. Errors are logged and the objects are left for
+// the next start, they must not stop the manager
+func (s *OrphanSweeper) Start(<-chan struct{}) error {
+	ctx := context.Background()
+
+	kataConfigs := &kataconfigurationv1.KataConfigList{}
+	if err := s.Reader.List(ctx, kataConfigs); err != nil {
+		s.Log.Error(err, "unable to list the KataConfigs, skipping the sweep of the orphaned objects")
+		return nil
+	}
+	existing := map[string]bool{}
+	for _, kataConfig := range kataConfigs.Items {
+		existing[kataConfig.Name] = true
+	}
+
+	mcs := &mcfgv1.MachineConfigList{}
+	if err := s.Reader.List(ctx, mcs); err != nil {
+		s.Log.Error(err, "unable to list the MachineConfigs, skipping their sweep")
+	}
+	for i := range mcs.Items {
+		mc := &mcs.Items[i]
+		owner, ok := mc.Labels[kataConfigOwnerLabel]
+		if !ok && mc.Name == kataMachineConfigName {
+			// rendered before the owner label existed
+			owner, ok = mc.Labels["app"]
+		}
+		if ok && !existing[owner] {
+			s.delete(ctx, mc, "MachineConfig", mc.Name, owner)
+		}
+	}
+
+	mcps := &mcfgv1.MachineConfigPoolList{}
+	if err := s.Reader.List(ctx, mcps); err != nil {
+		s.Log.Error(err, "unable to list the MachineConfigPools, skipping their sweep")
+	}
+	for i := range mcps.Items {
+		mcp := &mcps.Items[i]
+		owner, ok := mcp.Labels[kataConfigOwnerLabel]
+		if ok && !existing[owner] {
+			s.delete(ctx, mcp, "MachineConfigPool", mcp.Name, owner)
+		} else if !ok && mcp.Name == "kata-oc" && len(existing) == 0 {
+			// rendered before the owner label existed, only known orphaned once no KataConfig is left
+			s.delete(ctx, mcp, "MachineConfigPool", mcp.Name, "")
+		}
+	}
+
+	return nil
+}
+
+func (s *OrphanSweeper) delete(ctx context.Context, obj runtime.Object, kind, name, owner string) {
+	s.Log.Info("Deleting an object rendered for a KataConfig that no longer exists",
+		"kind", kind, "name", name, "kataconfig", owner)
+	if err := s.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+		s.Log.Error(err, "unable to delete the orphaned object", "kind", kind, "name", name)
+	}
+}
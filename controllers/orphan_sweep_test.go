@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOrphanSweeper(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kataconfigurationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mcfgv1.Install(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	owned := func(name, owner string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Labels: map[string]string{kataConfigOwnerLabel: owner}}
+	}
+	objects := []runtime.Object{
+		&kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example"}},
+		&mcfgv1.MachineConfig{ObjectMeta: owned("50-kata-example", "example")},
+		&mcfgv1.MachineConfig{ObjectMeta: owned("50-kata-removed", "removed")},
+		&mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: kataMachineConfigName, Labels: map[string]string{"app": "legacy"}}},
+		&mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: "99-worker-ssh"}},
+		&mcfgv1.MachineConfigPool{ObjectMeta: owned("kata-example", "example")},
+		&mcfgv1.MachineConfigPool{ObjectMeta: owned("kata-removed", "removed")},
+		// rendered before the owner label, kept while a KataConfig exists
+		&mcfgv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: "kata-oc"}},
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objects...)
+
+	sweeper := &OrphanSweeper{Reader: c, Client: c, Log: ctrl.Log.WithName("test")}
+	if err := sweeper.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		obj     runtime.Object
+		name    string
+		deleted bool
+	}{
+		{&mcfgv1.MachineConfig{}, "50-kata-example", false},
+		{&mcfgv1.MachineConfig{}, "50-kata-removed", true},
+		{&mcfgv1.MachineConfig{}, kataMachineConfigName, true},
+		{&mcfgv1.MachineConfig{}, "99-worker-ssh", false},
+		{&mcfgv1.MachineConfigPool{}, "kata-example", false},
+		{&mcfgv1.MachineConfigPool{}, "kata-removed", true},
+		{&mcfgv1.MachineConfigPool{}, "kata-oc", false},
+	} {
+		err := c.Get(context.Background(), types.NamespacedName{Name: test.name}, test.obj)
+		if err != nil && !errors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		if deleted := errors.IsNotFound(err); deleted != test.deleted {
+			t.Errorf("%s: expected deleted %v, got %v", test.name, test.deleted, err)
+		}
+	}
+}
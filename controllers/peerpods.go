@@ -0,0 +1,421 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	securityv1 "github.com/openshift/api/security/v1"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=get;patch
+
+const (
+	defaultCloudAPIAdaptorImage = "quay.io/confidential-containers/cloud-api-adaptor:latest"
+
+	// peerPodsConfigMapName is the configuration of the cloud-api-adaptor
+	peerPodsConfigMapName = "peer-pods-cm"
+
+	// cloudAPIAdaptorName names the daemonset of the cloud-api-adaptor, creating the VMs of the
+	// peer pods of its node
+	cloudAPIAdaptorName = "peer-pods-cloud-api-adaptor"
+
+	// peerPodsServiceAccountName and peerPodsSCCName admit the cloud-api-adaptor pods, which
+	// set the network namespaces of the peer pods up in the host network
+	peerPodsServiceAccountName = "kata-operator-peer-pods"
+	peerPodsSCCName            = "kata-operator-peer-pods"
+
+	// peerPodsVMResource is the resource the pod webhook makes the peer pods request, one per VM
+	peerPodsVMResource corev1.ResourceName = "kata.peerpods.io/vm"
+
+	defaultPeerPodsLimit = 10
+)
+
+// peerPodsProviders are the cloud-api-adaptor providers of the platforms running peer pods
+var peerPodsProviders = map[configv1.PlatformType]string{
+	configv1.AWSPlatformType:      "aws",
+	configv1.AzurePlatformType:    "azure",
+	configv1.GCPPlatformType:      "gcp",
+	configv1.IBMCloudPlatformType: "ibmcloud",
+}
+
+// libvirtProvider creates the peer pods VMs on a libvirt host, whatever the platform
+const libvirtProvider = "libvirt"
+
+// libvirtConfig returns the libvirt host of the peer pods VMs, nil if they are created in the
+// cloud provider of the cluster
+func libvirtConfig(kataConfig *kataconfigurationv1.KataConfig) *kataconfigurationv1.KataLibvirtConfig {
+	if kataConfig.Spec.PeerPods == nil {
+		return nil
+	}
+	return kataConfig.Spec.PeerPods.Libvirt
+}
+
+// peerPodsProvider returns the cloud-api-adaptor provider of the KataConfig: libvirt when it
+// sets a libvirt host, or the one of the platform. Empty if the platform has none
+func peerPodsProvider(kataConfig *kataconfigurationv1.KataConfig) string {
+	if libvirtConfig(kataConfig) != nil {
+		return libvirtProvider
+	}
+	for p, provider := range peerPodsProviders {
+		if strings.EqualFold(string(p), kataConfig.Status.Platform) {
+			return provider
+		}
+	}
+	return ""
+}
+
+// peerPodsProviderKeys are the cloud-api-adaptor settings of a provider, empty when the
+// provider has no such setting
+type peerPodsProviderKeys struct {
+	instanceType   string
+	instanceTypes  string
+	vpc            string
+	subnet         string
+	securityGroups string
+	image          string
+}
+
+var peerPodsConfigKeys = map[string]peerPodsProviderKeys{
+	"aws": {
+		instanceType:   "PODVM_INSTANCE_TYPE",
+		instanceTypes:  "PODVM_INSTANCE_TYPES",
+		vpc:            "AWS_VPC_ID",
+		subnet:         "AWS_SUBNET_ID",
+		securityGroups: "AWS_SG_IDS",
+		image:          "PODVM_AMI_ID",
+	},
+	"azure": {
+		instanceType:   "AZURE_INSTANCE_SIZE",
+		instanceTypes:  "AZURE_INSTANCE_SIZES",
+		subnet:         "AZURE_SUBNET_ID",
+		securityGroups: "AZURE_NSG_ID",
+		image:          "AZURE_IMAGE_ID",
+	},
+	"gcp": {
+		instanceType: "GCP_MACHINE_TYPE",
+		vpc:          "GCP_NETWORK",
+		image:        "PODVM_IMAGE_NAME",
+	},
+	"ibmcloud": {
+		instanceType:   "IBMCLOUD_PODVM_INSTANCE_PROFILE_NAME",
+		instanceTypes:  "IBMCLOUD_PODVM_INSTANCE_PROFILE_LIST",
+		vpc:            "IBMCLOUD_VPC_ID",
+		subnet:         "IBMCLOUD_VPC_SUBNET_ID",
+		securityGroups: "IBMCLOUD_VPC_SG_ID",
+		image:          "IBMCLOUD_PODVM_IMAGE_ID",
+	},
+	// the image of the libvirt VMs is a volume of the storage pool
+	libvirtProvider: {
+		image: "LIBVIRT_VOL_NAME",
+	},
+}
+
+// peerPodsLimit returns how many peer pods VMs each node runs at once
+func peerPodsLimit(kataConfig *kataconfigurationv1.KataConfig) int64 {
+	if conf := kataConfig.Spec.PeerPods; conf != nil && conf.Limit != nil {
+		return int64(*conf.Limit)
+	}
+	return defaultPeerPodsLimit
+}
+
+// peerPodsConfigData renders the cloud-api-adaptor settings of the provider out of the
+// KataConfig and the image of the VMs
+func peerPodsConfigData(kataConfig *kataconfigurationv1.KataConfig, provider string) map[string]string {
+	data := map[string]string{"CLOUD_PROVIDER": provider}
+	conf := kataConfig.Spec.PeerPods
+	if conf == nil {
+		conf = &kataconfigurationv1.KataPeerPodsConfig{}
+	}
+	keys := peerPodsConfigKeys[provider]
+	set := func(key, value string) {
+		if key != "" && value != "" {
+			data[key] = value
+		}
+	}
+
+	if len(conf.InstanceTypes) > 0 {
+		set(keys.instanceType, conf.InstanceTypes[0])
+		set(keys.instanceTypes, strings.Join(conf.InstanceTypes, ","))
+	}
+	set(keys.vpc, conf.VPC)
+	set(keys.subnet, conf.Subnet)
+	if keys.securityGroups != "" && len(conf.SecurityGroups) > 0 {
+		// the network security group of an Azure VM is a single one
+		if provider == "azure" {
+			set(keys.securityGroups, conf.SecurityGroups[0])
+		} else {
+			set(keys.securityGroups, strings.Join(conf.SecurityGroups, ","))
+		}
+	}
+	if status := kataConfig.Status.PeerPods; status != nil && status.PodVMImage != nil {
+		set(keys.image, status.PodVMImage.ID)
+	}
+	if provider == ibmcloudProvider {
+		ibmcloudConfigData(kataConfig, data)
+	}
+	if libvirt := conf.Libvirt; libvirt != nil && provider == libvirtProvider {
+		data["LIBVIRT_URI"] = libvirt.URI
+		data["LIBVIRT_POOL"] = "default"
+		data["LIBVIRT_NET"] = "default"
+		set("LIBVIRT_POOL", libvirt.StoragePool)
+		set("LIBVIRT_NET", libvirt.Network)
+	}
+
+	// the VMs of the KataConfig are told apart by their tag when collecting the orphaned ones
+	tags := []string{peerPodsVMTag + "=" + kataConfig.Name}
+	for key, value := range conf.Tags {
+		if key != peerPodsVMTag {
+			tags = append(tags, key+"="+value)
+		}
+	}
+	sort.Strings(tags)
+	data["TAGS"] = strings.Join(tags, ",")
+	return data
+}
+
+// newPeerPodsConfigMap returns the configuration of the cloud-api-adaptor
+func (r *KataConfigOpenShiftReconciler) newPeerPodsConfigMap(provider string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      peerPodsConfigMapName,
+			Namespace: daemonNamespace,
+		},
+		Data: peerPodsConfigData(r.kataConfig, provider),
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// newPeerPodsSCC returns the SecurityContextConstraints admitting the cloud-api-adaptor pods,
+// privileged in the host network to set the network namespaces of the peer pods up
+func (r *KataConfigOpenShiftReconciler) newPeerPodsSCC() *securityv1.SecurityContextConstraints {
+	return &securityv1.SecurityContextConstraints{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "security.openshift.io/v1",
+			Kind:       "SecurityContextConstraints",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: peerPodsSCCName,
+		},
+		AllowPrivilegedContainer: true,
+		AllowHostNetwork:         true,
+		AllowHostDirVolumePlugin: true,
+		Volumes: []securityv1.FSType{
+			securityv1.FSTypeHostPath,
+			securityv1.FSTypeConfigMap,
+			securityv1.FSTypeDownwardAPI,
+			securityv1.FSProjected,
+			securityv1.FSTypeSecret,
+		},
+		SELinuxContext: securityv1.SELinuxContextStrategyOptions{
+			Type: securityv1.SELinuxStrategyRunAsAny,
+		},
+		RunAsUser: securityv1.RunAsUserStrategyOptions{
+			Type: securityv1.RunAsUserStrategyRunAsAny,
+		},
+		SupplementalGroups: securityv1.SupplementalGroupsStrategyOptions{
+			Type: securityv1.SupplementalGroupsStrategyRunAsAny,
+		},
+		FSGroup: securityv1.FSGroupStrategyOptions{
+			Type: securityv1.FSGroupStrategyRunAsAny,
+		},
+		Users: []string{"system:serviceaccount:" + daemonNamespace + ":" + peerPodsServiceAccountName},
+	}
+}
+
+// newCloudAPIAdaptorDaemonset returns the daemonset running the cloud-api-adaptor on the kata
+// nodes, configured by the peer pods ConfigMap and given the cloud credentials. The hash of the
+// credentials rolls it out again when they are rotated
+func (r *KataConfigOpenShiftReconciler) newCloudAPIAdaptorDaemonset(provider, credentialsHash string) (*appsv1.DaemonSet, error) {
+	labels := map[string]string{"name": cloudAPIAdaptorName}
+	nodeSelector := map[string]string{"node-role.kubernetes.io/worker": ""}
+	if selector := kataPoolSelector(r.kataConfig); selector != nil {
+		nodeSelector = selector.MatchLabels
+	}
+	env, envFrom := peerPodsCredentialsEnv(r.kataConfig, provider)
+	privileged := true
+	bidirectional := corev1.MountPropagationBidirectional
+	directoryOrCreate := corev1.HostPathDirectoryOrCreate
+
+	ds := &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloudAPIAdaptorName,
+			Namespace: daemonNamespace,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{credentialsHashAnnotation: credentialsHash},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: peerPodsServiceAccountName,
+					NodeSelector:       nodeSelector,
+					HostNetwork:        true,
+					Containers: []corev1.Container{
+						{
+							Name:  "cloud-api-adaptor",
+							Image: r.settings.cloudAPIAdaptorImage(),
+							SecurityContext: &corev1.SecurityContext{
+								Privileged: &privileged,
+							},
+							// the credentials errors are found in the termination message
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+							Env:                      env,
+							EnvFrom: append([]corev1.EnvFromSource{
+								{
+									ConfigMapRef: &corev1.ConfigMapEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: peerPodsConfigMapName},
+									},
+								},
+							}, envFrom...),
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "pods-dir",
+									MountPath: "/run/peerpod",
+								},
+								{
+									Name:             "netns",
+									MountPath:        "/run/netns",
+									MountPropagation: &bidirectional,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							// the kata shim of the node reaches the adaptor through its socket
+							Name: "pods-dir",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: "/run/peerpod",
+									Type: &directoryOrCreate,
+								},
+							},
+						},
+						{
+							Name: "netns",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: "/run/netns",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if volume, mount := sshKeyVolume(r.kataConfig, provider); volume != nil {
+		podSpec := &ds.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, *volume)
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, *mount)
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// advertisePeerPodsCapacity sets the kata.peerpods.io/vm capacity of the kata nodes, how many
+// peer pods the scheduler places on each of them. The kubelet keeps the extended resources
+// set in the status of its node
+func (r *KataConfigOpenShiftReconciler) advertisePeerPodsCapacity(nodes []corev1.Node) error {
+	limit := *resource.NewQuantity(peerPodsLimit(r.kataConfig), resource.DecimalSI)
+	for i := range nodes {
+		node := &nodes[i]
+		if capacity, ok := node.Status.Capacity[peerPodsVMResource]; ok && capacity.Cmp(limit) == 0 {
+			continue
+		}
+		patch := client.MergeFrom(node.DeepCopy())
+		if node.Status.Capacity == nil {
+			node.Status.Capacity = corev1.ResourceList{}
+		}
+		node.Status.Capacity[peerPodsVMResource] = limit
+		r.Log.Info("Advertising the peer pods capacity of the node", "node", node.Name, "limit", limit.String())
+		if err := r.Client.Status().Patch(r.ctx, node, patch); err != nil {
+			return fmt.Errorf("failed to advertise the peer pods capacity of node %s: %v", node.Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcilePeerPods provides the peer pods. Nothing is installed on the nodes, the kata pods
+// run in VMs of the cloud provider created by the cloud-api-adaptor of their node
+func (r *KataConfigOpenShiftReconciler) reconcilePeerPods(machinePool string) (ctrl.Result, error) {
+	result, err := r.reconcilePodVMImage()
+	if err != nil {
+		return result, err
+	}
+
+	provider := peerPodsProvider(r.kataConfig)
+	if provider == "" {
+		r.Log.Info("No peer pods provider for the platform, the cloud-api-adaptor is not deployed", "platform", r.kataConfig.Status.Platform)
+		return result, nil
+	}
+
+	if err := validatePeerPodsConfig(r.kataConfig, provider); err != nil {
+		return r.requeue(), err
+	}
+
+	cm, err := r.newPeerPodsConfigMap(provider)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyObject(cm); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	credentialsHash, err := r.reconcilePeerPodsCredentials(provider)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	scc := r.newPeerPodsSCC()
+	if err := controllerutil.SetControllerReference(r.kataConfig, scc, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyObject(scc); err != nil {
+		return ctrl.Result{}, err
+	}
+	ds, err := r.newCloudAPIAdaptorDaemonset(provider, credentialsHash)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyObject(ds); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.advertisePeerPodsCapacity(nodes); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.collectOrphanedVMs(provider)
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// +kubebuilder:rbac:groups=cloudcredential.openshift.io,resources=credentialsrequests,verbs=get;list;watch;create;update;patch;delete
+
+const (
+	// credentialsRequestName requests the cloud credentials of the peer pods from the cloud
+	// credential operator, which mints them into peerPodsCredentialsSecret
+	credentialsRequestName      = "kata-operator-peer-pods"
+	credentialsRequestNamespace = "openshift-cloud-credential-operator"
+	peerPodsCredentialsSecret   = "peer-pods-cloud-credentials"
+
+	// credentialsHashAnnotation is the hash of the cloud credentials on the pod template of the
+	// cloud-api-adaptor, which is rolled out again when they are rotated
+	credentialsHashAnnotation = "kataconfiguration.openshift.io/credentials-hash"
+)
+
+// credentialsRequestKeys are the keys of the Secrets minted by the cloud credential operator
+// and the environment variables of the cloud-api-adaptor they are given as
+var credentialsRequestKeys = map[string]map[string]string{
+	"aws": {
+		"aws_access_key_id":     "AWS_ACCESS_KEY_ID",
+		"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY",
+	},
+	"azure": {
+		"azure_client_id":       "AZURE_CLIENT_ID",
+		"azure_client_secret":   "AZURE_CLIENT_SECRET",
+		"azure_tenant_id":       "AZURE_TENANT_ID",
+		"azure_subscription_id": "AZURE_SUBSCRIPTION_ID",
+	},
+	"gcp": {
+		"service_account.json": "GCP_CREDENTIALS",
+	},
+	"ibmcloud": {
+		"ibmcloud_api_key": "IBMCLOUD_API_KEY",
+	},
+}
+
+// credentialsProviderSpecs are the permissions the peer pods request from each cloud: creating
+// and deleting the VMs, and uploading their images
+var credentialsProviderSpecs = map[string]map[string]interface{}{
+	"aws": {
+		"kind": "AWSProviderSpec",
+		"statementEntries": []interface{}{
+			map[string]interface{}{
+				"effect": "Allow",
+				"action": []interface{}{
+					"ec2:RunInstances", "ec2:TerminateInstances", "ec2:DescribeInstances", "ec2:DescribeInstanceTypes",
+					"ec2:CreateTags", "ec2:DescribeImages", "ec2:RegisterImage", "ec2:DeregisterImage",
+					"ec2:ImportSnapshot", "ec2:DescribeImportSnapshotTasks", "ec2:DescribeSnapshots", "ec2:DeleteSnapshot",
+					"s3:CreateBucket", "s3:PutObject", "s3:GetObject", "s3:DeleteObject",
+				},
+				"resource": "*",
+			},
+		},
+	},
+	"azure": {
+		"kind": "AzureProviderSpec",
+		"roleBindings": []interface{}{
+			map[string]interface{}{"role": "Contributor"},
+		},
+	},
+	"gcp": {
+		"kind":             "GCPProviderSpec",
+		"predefinedRoles":  []interface{}{"roles/compute.instanceAdmin.v1", "roles/iam.serviceAccountUser"},
+		"skipServiceCheck": true,
+	},
+	"ibmcloud": {
+		"kind": "IBMCloudProviderSpec",
+		"policies": []interface{}{
+			map[string]interface{}{
+				"attributes": []interface{}{
+					map[string]interface{}{"name": "serviceName", "value": "is"},
+				},
+				"roles": []interface{}{"crn:v1:bluemix:public:iam::::role:Editor"},
+			},
+		},
+	},
+}
+
+// credentialErrors are found in the termination messages of the cloud-api-adaptor when the
+// cloud provider, or the libvirt host, rejects its credentials
+var credentialErrors = []string{
+	"AuthFailure", "InvalidClientTokenId", "SignatureDoesNotMatch", "UnrecognizedClientException",
+	"invalid_client", "AADSTS", "invalid_grant", "Unauthorized", "Permission denied (publickey",
+}
+
+// credentialsSecretChanged filters the Secrets of the operator namespace, where the cloud
+// credentials of the peer pods are rotated
+var credentialsSecretChanged = predicate.NewPredicateFuncs(func(meta metav1.Object, _ runtime.Object) bool {
+	return meta.GetNamespace() == daemonNamespace
+})
+
+// peerPodsCredentialsEnv returns the environment of the peer pods components with the cloud
+// credentials: the variables of the Secret of the KataConfig, or the keys minted by the cloud
+// credential operator. The libvirt host is reached with the SSH key of sshKeyVolume instead
+func peerPodsCredentialsEnv(kataConfig *kataconfigurationv1.KataConfig, provider string) ([]corev1.EnvVar, []corev1.EnvFromSource) {
+	if provider == libvirtProvider {
+		return nil, nil
+	}
+	if conf := kataConfig.Spec.PeerPods; conf != nil && conf.CredentialsSecret != "" {
+		return nil, []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: conf.CredentialsSecret},
+				},
+			},
+		}
+	}
+
+	keys := make([]string, 0, len(credentialsRequestKeys[provider]))
+	for key := range credentialsRequestKeys[provider] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var env []corev1.EnvVar
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{
+			Name: credentialsRequestKeys[provider][key],
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: peerPodsCredentialsSecret},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	return env, nil
+}
+
+// sshKeyVolume returns the volume of the SSH key of the libvirt host, the id_rsa key of the
+// Secret of the KataConfig, and its mount as the key of root. Nil without a Secret
+func sshKeyVolume(kataConfig *kataconfigurationv1.KataConfig, provider string) (*corev1.Volume, *corev1.VolumeMount) {
+	conf := kataConfig.Spec.PeerPods
+	if provider != libvirtProvider || conf == nil || conf.CredentialsSecret == "" {
+		return nil, nil
+	}
+	var mode int32 = 0400
+	return &corev1.Volume{
+		Name: "ssh",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  conf.CredentialsSecret,
+				DefaultMode: &mode,
+			},
+		},
+	}, &corev1.VolumeMount{
+		Name:      "ssh",
+		MountPath: "/root/.ssh",
+		ReadOnly:  true,
+	}
+}
+
+// peerPodsCredentialsSecretName returns the Secret holding the cloud credentials of the peer pods
+func peerPodsCredentialsSecretName(kataConfig *kataconfigurationv1.KataConfig) string {
+	if conf := kataConfig.Spec.PeerPods; conf != nil && conf.CredentialsSecret != "" {
+		return conf.CredentialsSecret
+	}
+	return peerPodsCredentialsSecret
+}
+
+// newCredentialsRequest returns the CredentialsRequest of the cloud credentials of the peer pods.
+// The cloud credential operator API is not vendored, the object is unstructured
+func (r *KataConfigOpenShiftReconciler) newCredentialsRequest(provider string) (*unstructured.Unstructured, error) {
+	providerSpec := map[string]interface{}{"apiVersion": "cloudcredential.openshift.io/v1"}
+	for key, value := range credentialsProviderSpecs[provider] {
+		providerSpec[key] = value
+	}
+
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloudcredential.openshift.io/v1",
+		"kind":       "CredentialsRequest",
+		"metadata": map[string]interface{}{
+			"name":      credentialsRequestName,
+			"namespace": credentialsRequestNamespace,
+		},
+		"spec": map[string]interface{}{
+			"secretRef": map[string]interface{}{
+				"name":      peerPodsCredentialsSecret,
+				"namespace": daemonNamespace,
+			},
+			"providerSpec": providerSpec,
+		},
+	}}
+	if err := controllerutil.SetControllerReference(r.kataConfig, cr, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// setCredentialsCondition reports whether the cloud credentials of the peer pods are usable
+func (r *KataConfigOpenShiftReconciler) setCredentialsCondition(invalid bool, reason, message string) {
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigCredentialsInvalid,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: message,
+	}
+	if invalid {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reason
+	}
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type)
+	if current == nil || current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+}
+
+// secretsReader reads the Secrets of the operator namespace from their cache, set up with the
+// controller
+func (r *KataConfigOpenShiftReconciler) secretsReader() client.Reader {
+	if r.secretsCache == nil {
+		return r.Client
+	}
+	return r.secretsCache
+}
+
+// credentialsHash returns the hash of the data of a credentials Secret
+func credentialsHash(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%x\n", key, secret.Data[key])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// reconcilePeerPodsCredentials requests the cloud credentials of the peer pods unless the
+// KataConfig gives a Secret, and returns the hash of the credentials, empty until they exist.
+// The missing or refused credentials are reported by the CredentialsInvalid condition
+func (r *KataConfigOpenShiftReconciler) reconcilePeerPodsCredentials(provider string) (string, error) {
+	secretName := peerPodsCredentialsSecretName(r.kataConfig)
+	if provider == libvirtProvider && secretName == peerPodsCredentialsSecret {
+		// the cloud credential operator has nothing to do with the libvirt hosts
+		uri := libvirtConfig(r.kataConfig).URI
+		if strings.HasPrefix(uri, "qemu+ssh://") {
+			r.setCredentialsCondition(true, "SSHKeyNotSet", fmt.Sprintf("no SSH key for the libvirt host %s, set peerPods.credentialsSecret", uri))
+		} else {
+			r.setCredentialsCondition(false, "", fmt.Sprintf("the peer pods reach the libvirt host %s without credentials", uri))
+		}
+		return "", nil
+	} else if secretName == peerPodsCredentialsSecret {
+		cr, err := r.newCredentialsRequest(provider)
+		if err != nil {
+			return "", err
+		}
+		if err := r.applyObject(cr); meta.IsNoMatchError(err) {
+			r.setCredentialsCondition(true, "NoCloudCredentialOperator",
+				"the cloud credential operator is not installed, set peerPods.credentialsSecret")
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(cr.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "CredentialsProvisionFailure" && condition["status"] == "True" {
+				r.setCredentialsCondition(true, "ProvisionFailed", fmt.Sprintf("the cloud credential operator failed to provide the credentials of CredentialsRequest %s: %v",
+					credentialsRequestName, condition["message"]))
+				return "", nil
+			}
+		}
+	}
+
+	secret := &corev1.Secret{}
+	err := r.secretsReader().Get(r.ctx, types.NamespacedName{Name: secretName, Namespace: daemonNamespace}, secret)
+	if errors.IsNotFound(err) {
+		r.setCredentialsCondition(true, "SecretNotFound", fmt.Sprintf("the cloud credentials Secret %s/%s doesn't exist", daemonNamespace, secretName))
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if _, ok := secret.Data["id_rsa"]; provider == libvirtProvider && !ok {
+		r.setCredentialsCondition(true, "SSHKeyNotFound", fmt.Sprintf("the Secret %s/%s has no id_rsa SSH key for the libvirt host", daemonNamespace, secretName))
+		return "", nil
+	}
+	hash := credentialsHash(secret)
+
+	rejected, err := r.rejectedCredentials(hash)
+	if err != nil {
+		return "", err
+	}
+	if rejected != "" {
+		r.setCredentialsCondition(true, "Rejected", fmt.Sprintf("the cloud provider rejects the credentials of the Secret %s/%s: %s",
+			daemonNamespace, secretName, rejected))
+	} else {
+		r.setCredentialsCondition(false, "", fmt.Sprintf("the peer pods use the cloud credentials of the Secret %s/%s", daemonNamespace, secretName))
+	}
+	return hash, nil
+}
+
+// rejectedCredentials returns the termination message of a cloud-api-adaptor pod of the
+// current credentials that failed on a credentials error, empty if there is none
+func (r *KataConfigOpenShiftReconciler) rejectedCredentials(hash string) (string, error) {
+	pods := &corev1.PodList{}
+	err := r.Client.List(r.ctx, pods, client.InNamespace(daemonNamespace), client.MatchingLabels{"name": cloudAPIAdaptorName})
+	if err != nil {
+		return "", err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Annotations[credentialsHashAnnotation] != hash {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.ExitCode == 0 {
+					continue
+				}
+				for _, pattern := range credentialErrors {
+					if strings.Contains(terminated.Message, pattern) {
+						return fmt.Sprintf("pod %s: %s", pod.Name, strings.TrimSpace(terminated.Message)), nil
+					}
+				}
+			}
+		}
+	}
+	return "", nil
+}
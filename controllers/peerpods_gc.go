@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/webhooks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// peerPodsVMTag is set on the VMs the cloud-api-adaptor creates for the peer pods of a
+	// KataConfig, to the name of the KataConfig
+	peerPodsVMTag = "kata-operator-kataconfig"
+
+	// vmCollectionName names the pod collecting the orphaned peer pods VMs and the ConfigMap of
+	// the peer pods it keeps the VMs of
+	vmCollectionName = "peer-pods-vm-gc"
+
+	// vmCollectionPodsKey lists the live peer pods, one namespace/name per line
+	vmCollectionPodsKey = "pods"
+
+	// vmCollectionInterval is the wait between two collections of the orphaned VMs
+	vmCollectionInterval = 30 * time.Minute
+
+	// vmCollectionGracePeriod keeps the VMs created shortly before the peer pods were listed,
+	// whose pods may not be listed yet
+	vmCollectionGracePeriod = 5 * time.Minute
+)
+
+// vmCollectionReport is the termination message of the collection pod
+type vmCollectionReport struct {
+	// Instances is how many VMs of the cloud provider carry the tag of the KataConfig
+	Instances int32 `json:"instances"`
+	// Orphans is how many of them had no peer pod left
+	Orphans int32 `json:"orphans"`
+	// Deleted is how many orphans were deleted, the others failed to be
+	Deleted int32 `json:"deleted"`
+}
+
+// newVMCollectionConfigMap returns the list of the running peer pods, whose VMs are kept. The
+// VMs of the completed pods are orphans
+func (r *KataConfigOpenShiftReconciler) newVMCollectionConfigMap(pods []corev1.Pod) (*corev1.ConfigMap, error) {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			names = append(names, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	sort.Strings(names)
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vmCollectionName,
+			Namespace: daemonNamespace,
+		},
+		Data: map[string]string{
+			vmCollectionPodsKey: strings.Join(names, "\n"),
+		},
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// newVMCollectionPod returns the pod of the builder image deleting the VMs of the KataConfig
+// created before notAfter whose peer pod is not listed. It reports a vmCollectionReport in its
+// termination message
+func (r *KataConfigOpenShiftReconciler) newVMCollectionPod(provider string, notAfter time.Time) (*corev1.Pod, error) {
+	pod, err := r.newBuilderPod(vmCollectionName, "gc", provider, []corev1.EnvVar{
+		{Name: "PEER_PODS_TAG", Value: peerPodsVMTag + "=" + r.kataConfig.Name},
+		{Name: "PEER_PODS_LIST", Value: "/etc/peer-pods-gc/" + vmCollectionPodsKey},
+		{Name: "CREATED_BEFORE", Value: notAfter.UTC().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	pod.Labels = map[string]string{"name": vmCollectionName}
+	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      "pods",
+		MountPath: "/etc/peer-pods-gc",
+		ReadOnly:  true,
+	})
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: "pods",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: vmCollectionName},
+			},
+		},
+	})
+	return pod, nil
+}
+
+// collectOrphanedVMs deletes the VMs the cloud-api-adaptor left behind in the cloud provider,
+// e.g. when it crashed or its node was lost before it deleted the VM of a peer pod. A pod of the
+// builder image deletes the VMs tagged for the KataConfig whose peer pod no longer exists, every
+// vmCollectionInterval. The pod is owned by the KataConfig, its completion triggers a reconcile
+func (r *KataConfigOpenShiftReconciler) collectOrphanedVMs(provider string) (ctrl.Result, error) {
+	if r.settings.PodVMBuilderImage == "" {
+		return ctrl.Result{}, nil
+	}
+
+	found := &corev1.Pod{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: vmCollectionName, Namespace: daemonNamespace}, found)
+	if errors.IsNotFound(err) {
+		if status := r.kataConfig.Status.PeerPods; status != nil && status.LastVMCollection != nil {
+			if wait := time.Until(status.LastVMCollection.Add(vmCollectionInterval)); wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, nil
+			}
+		}
+		return ctrl.Result{}, r.startVMCollection(provider)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch found.Status.Phase {
+	case corev1.PodSucceeded:
+		report := &vmCollectionReport{}
+		if err := json.Unmarshal([]byte(terminationMessage(found)), report); err != nil {
+			r.Log.Info("Unreadable report of the collection of the orphaned peer pods VMs", "pod", found.Name, "error", err.Error())
+		} else {
+			r.recordVMCollection(provider, report)
+		}
+	case corev1.PodFailed:
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "OrphanedVMCollectionFailed",
+			fmt.Sprintf("failed to collect the orphaned peer pods VMs: %s", terminationMessage(found)))
+		vmCollectionFailures.WithLabelValues(r.kataConfig.Name, provider).Inc()
+	default:
+		return ctrl.Result{}, nil
+	}
+
+	// the next collection waits for the interval, failed or not
+	now := metav1.Now()
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		if status.PeerPods == nil {
+			status.PeerPods = &kataconfigurationv1.KataPeerPodsStatus{}
+		}
+		status.PeerPods.LastVMCollection = &now
+	})
+	if err := r.Client.Delete(r.ctx, found); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: vmCollectionInterval}, nil
+}
+
+// startVMCollection lists the live peer pods and starts the pod collecting the VMs of the others
+func (r *KataConfigOpenShiftReconciler) startVMCollection(provider string) error {
+	// the VMs created since notAfter are kept, their pods may not be listed yet
+	notAfter := time.Now().Add(-vmCollectionGracePeriod)
+	pods, err := r.listPodsByRuntimeClass(webhooks.PeerPodsRuntimeClass)
+	if err != nil {
+		return err
+	}
+	cm, err := r.newVMCollectionConfigMap(pods)
+	if err != nil {
+		return err
+	}
+	if err := r.applyObject(cm); err != nil {
+		return err
+	}
+
+	pod, err := r.newVMCollectionPod(provider, notAfter)
+	if err != nil {
+		return err
+	}
+	r.Log.Info("Collecting the orphaned peer pods VMs", "provider", provider, "peerPods", len(pods))
+	if err := r.Client.Create(r.ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// recordVMCollection reports the orphaned VMs found by a collection
+func (r *KataConfigOpenShiftReconciler) recordVMCollection(provider string, report *vmCollectionReport) {
+	r.Log.Info("Collected the orphaned peer pods VMs", "instances", report.Instances, "orphans", report.Orphans, "deleted", report.Deleted)
+	orphanedVMs.WithLabelValues(r.kataConfig.Name, provider).Set(float64(report.Orphans))
+	deletedOrphanedVMs.WithLabelValues(r.kataConfig.Name, provider).Add(float64(report.Deleted))
+	if report.Orphans > 0 {
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "OrphanedVMsCollected",
+			fmt.Sprintf("deleted %d of the %d %s VMs left behind by the peer pods", report.Deleted, report.Orphans, provider))
+	}
+	if failed := report.Orphans - report.Deleted; failed > 0 {
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "OrphanedVMCollectionFailed",
+			fmt.Sprintf("failed to delete %d %s VMs left behind by the peer pods, they are retried at the next collection", failed, provider))
+	}
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		if status.PeerPods == nil {
+			status.PeerPods = &kataconfigurationv1.KataPeerPodsStatus{}
+		}
+		status.PeerPods.OrphanedVMs = report.Orphans
+	})
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+)
+
+const (
+	ibmcloudProvider = "ibmcloud"
+
+	// defaultSecureExecutionProfile is the instance profile of the confidential peer pods on
+	// IBM Cloud when the KataConfig sets none
+	defaultSecureExecutionProfile = "bz2e-2x8"
+)
+
+// ibmcloudProfileFamily returns the family of an IBM Cloud VPC instance profile, e.g. bz2e for
+// bz2e-2x8. Its second letter is the processor: x for x86_64, z for IBM Z
+func ibmcloudProfileFamily(profile string) string {
+	return strings.SplitN(profile, "-", 2)[0]
+}
+
+// ibmcloudProfileArchitecture returns the architecture of the VMs of an instance profile
+func ibmcloudProfileArchitecture(profile string) string {
+	if family := ibmcloudProfileFamily(profile); len(family) > 1 && family[1] == 'z' {
+		return archS390X
+	}
+	return archAMD64
+}
+
+// secureExecutionProfile tells whether the VMs of an instance profile run as Secure Execution
+// guests, the IBM Z families ending in e
+func secureExecutionProfile(profile string) bool {
+	return ibmcloudProfileArchitecture(profile) == archS390X && strings.HasSuffix(ibmcloudProfileFamily(profile), "e")
+}
+
+// secureExecutionPeerPods tells whether the peer pods are confidential IBM Cloud VMs
+func secureExecutionPeerPods(kataConfig *kataconfigurationv1.KataConfig) bool {
+	return confidentialEnabled(kataConfig) && kataConfig.Spec.Confidential.TEE == kataconfigurationv1.TEESE
+}
+
+// ibmcloudProfiles returns the instance profiles of the peer pods VMs on IBM Cloud
+func ibmcloudProfiles(kataConfig *kataconfigurationv1.KataConfig) []string {
+	if conf := kataConfig.Spec.PeerPods; conf != nil && len(conf.InstanceTypes) > 0 {
+		return conf.InstanceTypes
+	}
+	if secureExecutionPeerPods(kataConfig) {
+		return []string{defaultSecureExecutionProfile}
+	}
+	return nil
+}
+
+// validatePeerPodsConfig checks the peer pods settings of the KataConfig against its provider.
+// The VMs of IBM Cloud boot a single image, their profiles share its architecture, and the
+// confidential peer pods are Secure Execution guests of Secure Execution profiles
+func validatePeerPodsConfig(kataConfig *kataconfigurationv1.KataConfig, provider string) error {
+	if conf := kataConfig.Spec.PeerPods; conf != nil && conf.Zone != "" && provider != ibmcloudProvider {
+		return fmt.Errorf("The zone of the peer pods VMs is only set on IBM Cloud, not with the %s provider", provider)
+	}
+
+	if confidentialEnabled(kataConfig) && (provider != ibmcloudProvider || !secureExecutionPeerPods(kataConfig)) {
+		return fmt.Errorf("The confidential peer pods are Secure Execution guests on IBM Cloud, they need tee se and the ibmcloud provider, not tee %s and the %s provider",
+			kataConfig.Spec.Confidential.TEE, provider)
+	}
+	if provider != ibmcloudProvider {
+		return nil
+	}
+
+	profiles := ibmcloudProfiles(kataConfig)
+	for _, profile := range profiles {
+		if arch := ibmcloudProfileArchitecture(profile); arch != ibmcloudProfileArchitecture(profiles[0]) {
+			return fmt.Errorf("The instance profiles of the peer pods VMs boot the same image, %s is %s but %s is %s",
+				profile, arch, profiles[0], ibmcloudProfileArchitecture(profiles[0]))
+		}
+		if secureExecutionPeerPods(kataConfig) && !secureExecutionProfile(profile) {
+			return fmt.Errorf("The confidential peer pods need Secure Execution instance profiles, e.g. %s, %s is not one", defaultSecureExecutionProfile, profile)
+		}
+	}
+	return nil
+}
+
+// ibmcloudConfigData adds the IBM Cloud settings of the cloud-api-adaptor and the pod VM
+// builder: the zone, the architecture of the image and whether it boots as a Secure Execution
+// guest
+func ibmcloudConfigData(kataConfig *kataconfigurationv1.KataConfig, data map[string]string) {
+	if conf := kataConfig.Spec.PeerPods; conf != nil && conf.Zone != "" {
+		data["IBMCLOUD_ZONE"] = conf.Zone
+	}
+	profiles := ibmcloudProfiles(kataConfig)
+	if len(profiles) == 0 {
+		return
+	}
+	keys := peerPodsConfigKeys[ibmcloudProvider]
+	data[keys.instanceType] = profiles[0]
+	data[keys.instanceTypes] = strings.Join(profiles, ",")
+	data["PODVM_ARCH"] = ibmcloudProfileArchitecture(profiles[0])
+	if secureExecutionPeerPods(kataConfig) {
+		data["SE_BOOT"] = "true"
+	}
+}
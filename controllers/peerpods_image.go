@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// podVMImageLabel marks the pods building and deleting the images of the peer pods VMs
+	podVMImageLabel = "kataconfiguration.openshift.io/podvm-image"
+
+	// podVMImageAnnotation is the payload a build pod uploads, or the image a deletion pod
+	// deletes
+	podVMImageAnnotation = "kataconfiguration.openshift.io/podvm-image-source"
+
+	podVMImageBuildPod  = "peer-pods-podvm-image-build"
+	podVMImageDeletePod = "peer-pods-podvm-image-delete"
+)
+
+// podVMImageConfig returns the image settings of the peer pods VMs, nil if unset
+func podVMImageConfig(kataConfig *kataconfigurationv1.KataConfig) *kataconfigurationv1.KataPodVMImageConfig {
+	if kataConfig.Spec.PeerPods == nil {
+		return nil
+	}
+	return kataConfig.Spec.PeerPods.PodVMImage
+}
+
+// newBuilderPod returns a pod of the builder image acting on the cloud provider with its
+// credentials and the settings of the cloud-api-adaptor. It reports its outcome in its
+// termination message
+func (r *KataConfigOpenShiftReconciler) newBuilderPod(name, action, provider string, env []corev1.EnvVar) (*corev1.Pod, error) {
+	credentialsEnv, credentialsEnvFrom := peerPodsCredentialsEnv(r.kataConfig, provider)
+	optional := true
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: daemonNamespace,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:  "podvm-builder",
+					Image: r.settings.PodVMBuilderImage,
+					Env: append(append([]corev1.EnvVar{
+						{Name: "PROVIDER", Value: provider},
+						{Name: "ACTION", Value: action},
+					}, env...), credentialsEnv...),
+					EnvFrom: append([]corev1.EnvFromSource{
+						{
+							ConfigMapRef: &corev1.ConfigMapEnvSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: peerPodsConfigMapName},
+								Optional:             &optional,
+							},
+						},
+					}, credentialsEnvFrom...),
+				},
+			},
+		},
+	}
+	if volume, mount := sshKeyVolume(r.kataConfig, provider); volume != nil {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, *volume)
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, *mount)
+	}
+	if err := controllerutil.SetControllerReference(r.kataConfig, pod, r.Scheme); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// newPodVMImagePod returns a builder pod building or deleting an image of the cloud provider.
+// It reports the ID of the image it built in its termination message
+func (r *KataConfigOpenShiftReconciler) newPodVMImagePod(name, action, provider, source string, env []corev1.EnvVar) (*corev1.Pod, error) {
+	pod, err := r.newBuilderPod(name, action, provider, env)
+	if err != nil {
+		return nil, err
+	}
+	pod.Labels = map[string]string{podVMImageLabel: action}
+	pod.Annotations = map[string]string{podVMImageAnnotation: source}
+	return pod, nil
+}
+
+// terminationMessage returns the termination message of the first terminated container of pod
+func terminationMessage(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			return strings.TrimSpace(terminated.Message)
+		}
+	}
+	return ""
+}
+
+// setPodVMImageCondition reports whether the image of the peer pods VMs could be built
+func (r *KataConfigOpenShiftReconciler) setPodVMImageCondition(failed bool, reason, message string) {
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigPodVMImageFailed,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: message,
+	}
+	if failed {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reason
+	}
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type)
+	if current == nil || current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+}
+
+// recordPodVMImage records the image the peer pods VMs boot from now on. The image the operator
+// built before is queued for deletion
+func (r *KataConfigOpenShiftReconciler) recordPodVMImage(image *kataconfigurationv1.KataPodVMImageStatus) {
+	r.Log.Info("Peer pods VM image recorded", "image", image.ID, "provider", image.Provider, "payload", image.Payload)
+	r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+		if status.PeerPods == nil {
+			status.PeerPods = &kataconfigurationv1.KataPeerPodsStatus{}
+		}
+		if previous := status.PeerPods.PodVMImage; previous != nil && previous.Payload != "" && previous.ID != image.ID {
+			status.PeerPods.StaleImages = append(status.PeerPods.StaleImages, previous.ID)
+		}
+		status.PeerPods.PodVMImage = image
+	})
+}
+
+// reconcilePodVMImage provides the image of the peer pods VMs: the image of the cloud provider
+// the KataConfig sets, or one built from its payload by a pod of the builder image. The images
+// built from the former payloads are then deleted. The pods are owned by the KataConfig, their
+// changes trigger a reconcile
+func (r *KataConfigOpenShiftReconciler) reconcilePodVMImage() (ctrl.Result, error) {
+	provider := peerPodsProvider(r.kataConfig)
+	var current *kataconfigurationv1.KataPodVMImageStatus
+	if r.kataConfig.Status.PeerPods != nil {
+		current = r.kataConfig.Status.PeerPods.PodVMImage
+	}
+
+	conf := podVMImageConfig(r.kataConfig)
+	switch {
+	case conf == nil || (conf.ImageID == "" && conf.Payload == ""):
+		// the cloud-api-adaptor falls back to its own image
+	case conf.ImageID != "":
+		if current == nil || current.ID != conf.ImageID || current.Payload != "" {
+			r.recordPodVMImage(&kataconfigurationv1.KataPodVMImageStatus{ID: conf.ImageID, Provider: provider})
+		}
+		r.setPodVMImageCondition(false, "", "the peer pods VMs boot the image "+conf.ImageID)
+	case current == nil || current.Payload != conf.Payload:
+		if err := r.buildPodVMImage(conf.Payload, provider); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, r.deleteStalePodVMImages(provider)
+}
+
+// buildPodVMImage runs the pod building the image of the payload and records the image once
+// built. A failed build is kept, and not retried, until the payload changes or the pod is
+// deleted
+func (r *KataConfigOpenShiftReconciler) buildPodVMImage(payload, provider string) error {
+	if provider == "" {
+		r.setPodVMImageCondition(true, "UnsupportedPlatform", fmt.Sprintf("no pod VM image can be built for the %s platform, set podVMImage.imageID",
+			r.kataConfig.Status.Platform))
+		return nil
+	}
+	if r.settings.PodVMBuilderImage == "" {
+		r.setPodVMImageCondition(true, "NoBuilderImage", "no pod VM builder image, set images.podVMBuilder in the operator configuration")
+		return nil
+	}
+
+	found := &corev1.Pod{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: podVMImageBuildPod, Namespace: daemonNamespace}, found)
+	if errors.IsNotFound(err) {
+		pod, err := r.newPodVMImagePod(podVMImageBuildPod, "create", provider, payload,
+			[]corev1.EnvVar{{Name: "PODVM_PAYLOAD", Value: payload}})
+		if err != nil {
+			return err
+		}
+		r.Log.Info("Building the peer pods VM image", "payload", payload, "provider", provider)
+		if err := r.Client.Create(r.ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// the build of a former payload is abandoned
+	if found.Annotations[podVMImageAnnotation] != payload {
+		if err := r.Client.Delete(r.ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	switch found.Status.Phase {
+	case corev1.PodSucceeded:
+		id := terminationMessage(found)
+		if id == "" {
+			r.setPodVMImageCondition(true, "BuildFailed", fmt.Sprintf("pod %s built no image out of %s", found.Name, payload))
+			return nil
+		}
+		now := metav1.Now()
+		r.recordPodVMImage(&kataconfigurationv1.KataPodVMImageStatus{ID: id, Provider: provider, Payload: payload, Created: &now})
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "PodVMImageBuilt", fmt.Sprintf("built the %s image %s out of %s", provider, id, payload))
+		r.setPodVMImageCondition(false, "", fmt.Sprintf("the peer pods VMs boot the image %s built out of %s", id, payload))
+		if err := r.Client.Delete(r.ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	case corev1.PodFailed:
+		r.setPodVMImageCondition(true, "BuildFailed", fmt.Sprintf("pod %s failed to build the image out of %s, delete it to try again: %s",
+			found.Name, payload, terminationMessage(found)))
+	}
+	return nil
+}
+
+// deleteStalePodVMImages deletes the images built from the former payloads from the cloud
+// provider, one at a time. The images failing to be deleted are left to the administrator
+func (r *KataConfigOpenShiftReconciler) deleteStalePodVMImages(provider string) error {
+	if r.kataConfig.Status.PeerPods == nil || len(r.kataConfig.Status.PeerPods.StaleImages) == 0 ||
+		provider == "" || r.settings.PodVMBuilderImage == "" {
+		return nil
+	}
+	id := r.kataConfig.Status.PeerPods.StaleImages[0]
+
+	found := &corev1.Pod{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: podVMImageDeletePod, Namespace: daemonNamespace}, found)
+	if errors.IsNotFound(err) {
+		pod, err := r.newPodVMImagePod(podVMImageDeletePod, "delete", provider, id,
+			[]corev1.EnvVar{{Name: "IMAGE_ID", Value: id}})
+		if err != nil {
+			return err
+		}
+		r.Log.Info("Deleting the stale peer pods VM image", "image", id, "provider", provider)
+		if err := r.Client.Create(r.ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// the deletion of an image already removed from the list is abandoned
+	if found.Annotations[podVMImageAnnotation] == id {
+		switch found.Status.Phase {
+		case corev1.PodSucceeded:
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "PodVMImageDeleted", fmt.Sprintf("deleted the stale %s image %s", provider, id))
+		case corev1.PodFailed:
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "PodVMImageDeletionFailed",
+				fmt.Sprintf("failed to delete the stale %s image %s, delete it by hand: %s", provider, id, terminationMessage(found)))
+		default:
+			return nil
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			var stale []string
+			for _, image := range status.PeerPods.StaleImages {
+				if image != id {
+					stale = append(stale, image)
+				}
+			}
+			status.PeerPods.StaleImages = stale
+		})
+	}
+	if err := r.Client.Delete(r.ctx, found); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
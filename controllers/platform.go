@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const instanceTypeLabel = "node.kubernetes.io/instance-type"
+
+var (
+	// peerPodsPlatforms are the clouds the peer pods VMs can be created in. Their instances
+	// don't offer nested virtualization, except for the bare metal ones
+	peerPodsPlatforms = []configv1.PlatformType{configv1.AWSPlatformType, configv1.AzurePlatformType,
+		configv1.GCPPlatformType, configv1.IBMCloudPlatformType}
+
+	// virtualizedPlatforms run the nodes in VMs, kata relies on nested virtualization there
+	virtualizedPlatforms = []configv1.PlatformType{configv1.VSpherePlatformType, configv1.OpenStackPlatformType,
+		configv1.OvirtPlatformType, configv1.LibvirtPlatformType, "KubeVirt"}
+)
+
+// clusterPlatform returns the platform of the cluster out of the Infrastructure object, empty
+// when it can't be told
+func (r *KataConfigOpenShiftReconciler) clusterPlatform() (configv1.PlatformType, error) {
+	infrastructure := &configv1.Infrastructure{}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: "cluster"}, infrastructure)
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if infrastructure.Status.PlatformStatus != nil && infrastructure.Status.PlatformStatus.Type != "" {
+		return infrastructure.Status.PlatformStatus.Type, nil
+	}
+	return infrastructure.Status.Platform, nil
+}
+
+// bareMetalNode tells whether the node is known to expose the hardware virtualization: a bare
+// metal instance type, or the VMX or SVM CPU flag found by Node Feature Discovery
+func bareMetalNode(node *corev1.Node) bool {
+	labels := node.GetLabels()
+	if strings.Contains(labels[instanceTypeLabel], ".metal") {
+		return true
+	}
+	_, vmx := labels[nfdLabelPrefix+"cpu-cpuid.VMX"]
+	_, svm := labels[nfdLabelPrefix+"cpu-cpuid.SVM"]
+	return vmx || svm
+}
+
+// selectInstallMode returns the install mode of the platform: the nodes of the clouds run the
+// kata pods as peer pods unless they are all bare metal instances, the other platforms install
+// kata on the nodes
+func selectInstallMode(platform configv1.PlatformType, nodes []corev1.Node) kataconfigurationv1.KataInstallMode {
+	if !platformIn(platform, peerPodsPlatforms) {
+		return kataconfigurationv1.InstallModeMachineConfig
+	}
+	for i := range nodes {
+		if !bareMetalNode(&nodes[i]) {
+			return kataconfigurationv1.InstallModePeerPods
+		}
+	}
+	if len(nodes) == 0 {
+		return kataconfigurationv1.InstallModePeerPods
+	}
+	return kataconfigurationv1.InstallModeMachineConfig
+}
+
+func platformIn(platform configv1.PlatformType, platforms []configv1.PlatformType) bool {
+	for _, p := range platforms {
+		if strings.EqualFold(string(p), string(platform)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileInstallMode detects the platform of the cluster and selects the install mode when
+// spec.installMode is Auto. The selection is kept once kata is installed, the nodes joining
+// the pool later don't change it. With kata installed through the machine config operator, the
+// cloud nodes whose instance type can't run kata are reported as failed, and the nodes that are
+// VMs are reported in the NestedVirtualization condition
+func (r *KataConfigOpenShiftReconciler) reconcileInstallMode(machinePool string) (kataconfigurationv1.KataInstallMode, error) {
+	platform, err := r.clusterPlatform()
+	if err != nil {
+		return "", err
+	}
+	nodes, err := r.listKataNodes(machinePool)
+	if err != nil {
+		return "", err
+	}
+
+	mode := r.kataConfig.Spec.InstallMode
+	if mode == "" || mode == kataconfigurationv1.InstallModeAuto {
+		mode = r.kataConfig.Status.InstallMode
+		if mode == "" || r.kataConfig.Status.RuntimeClass == "" {
+			mode = selectInstallMode(platform, nodes)
+			// the VMs of the peer pods are created on the libvirt host whatever the platform
+			if libvirtConfig(r.kataConfig) != nil {
+				mode = kataconfigurationv1.InstallModePeerPods
+			}
+		}
+	}
+
+	status := r.kataConfig.Status
+	if status.Platform != string(platform) || status.InstallMode != mode {
+		message := fmt.Sprintf("kata is provided in the %s install mode on the %s platform", mode, platform)
+		r.Log.Info("Install mode selected", "platform", platform, "mode", mode)
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "InstallModeSelected", message)
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			status.Platform = string(platform)
+			status.InstallMode = mode
+		})
+	}
+
+	var nested []string
+	if mode == kataconfigurationv1.InstallModeMachineConfig {
+		if err := r.failUnsupportedInstanceTypes(platform, nodes); err != nil {
+			return "", err
+		}
+		for i := range nodes {
+			if platformIn(platform, virtualizedPlatforms) ||
+				(platformIn(platform, peerPodsPlatforms) && !bareMetalNode(&nodes[i])) {
+				nested = append(nested, nodes[i].Name)
+			}
+		}
+	}
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigNestedVirtualization,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: "the kata nodes run kata on hardware virtualization, or the kata pods as peer pods",
+	}
+	if len(nested) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "VirtualizedNodes"
+		condition.Message = fmt.Sprintf("%s nodes of the %s platform rely on nested virtualization, "+
+			"which may be unsupported or slow: %s", mode, platform, strings.Join(nested, ", "))
+	}
+	if current := meta.FindStatusCondition(status.Conditions, condition.Type); current == nil ||
+		current.Status != condition.Status || current.Message != condition.Message {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return mode, nil
+}
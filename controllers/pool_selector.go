@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// cleanupDaemonsetName is the daemonset removing the kata binaries from the nodes that left the
+// kata pool
+const cleanupDaemonsetName = "kata-operator-daemon-cleanup"
+
+// reconcilePoolSelector follows the edits of the kataConfigPoolSelector once kata is installed.
+// The kata machine config pool is created when the selector goes from a whole worker or master
+// pool to some of its nodes, and deleted the other way around once the kata MachineConfig is
+// rendered into the whole pool. The node selector of the kata pool is updated right away, the
+// nodes newly matching join it and the ones no longer matching go back to their parent pool
+func (r *KataConfigOpenShiftReconciler) reconcilePoolSelector() (time.Duration, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return 0, err
+	}
+	poolName := r.kataPoolName(machinePool)
+
+	kataPool := &mcfgv1.MachineConfigPool{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: "kata-oc"}, kataPool)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	kataPoolExists := err == nil
+
+	if poolName != machinePool && !kataPoolExists {
+		mcp := r.newMCPforCR()
+		r.Log.Info("The kata pool selector selects some of the nodes, creating the kata pool", "mcp.Name", mcp.Name)
+		if err := r.applyObject(mcp); err != nil {
+			return 0, err
+		}
+		r.recordHistory(kataconfigurationv1.HistoryMachineConfigPoolCreated,
+			fmt.Sprintf("machine config pool %s created, the kata pool selector selects some of the %s nodes", mcp.Name, machinePool))
+	}
+
+	// the kata MachineConfig moves to the pool of the selected nodes
+	mc := &mcfgv1.MachineConfig{}
+	err = r.Client.Get(r.ctx, types.NamespacedName{Name: kataMachineConfigName}, mc)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	if err == nil && mc.Labels["machineconfiguration.openshift.io/role"] != poolName {
+		r.Log.Info("The kata pool selector changed, moving the Machine Config to the pool", "mc.Name", mc.Name, "pool", poolName)
+		if wait, err := r.updateMachineConfig(machinePool); err != nil || wait > 0 {
+			return wait, err
+		}
+	}
+
+	// the nodes of the kata pool go back to their parent pool, which now renders the kata MachineConfig
+	if poolName == machinePool && kataPoolExists {
+		r.Log.Info("The kata pool selector selects the whole pool, deleting the kata pool", "mcp.Name", kataPool.Name, "pool", machinePool)
+		if err := r.deleteObject(kataPool); err != nil && !errors.IsNotFound(err) {
+			return 0, err
+		}
+		r.recordHistory(kataconfigurationv1.HistoryMachineConfigPoolDeleted,
+			fmt.Sprintf("machine config pool %s deleted, the kata pool selector selects the whole %s pool", kataPool.Name, machinePool))
+		return 0, nil
+	}
+
+	return 0, r.updateMachineConfigPool()
+}
+
+// newCleanupDaemonset returns the daemonset uninstalling the kata binaries from the given nodes
+func (r *KataConfigOpenShiftReconciler) newCleanupDaemonset(nodeNames []string) (*appsv1.DaemonSet, error) {
+	ds := r.processDaemonsetForCR(UninstallOperation, "")
+	ds.Name = cleanupDaemonsetName
+	// it outlives the uninstallation daemonsets
+	delete(ds.Labels, daemonOperationLabel)
+	ds.Spec.Selector.MatchLabels = map[string]string{"name": cleanupDaemonsetName}
+	ds.Spec.Template.Labels = map[string]string{"name": cleanupDaemonsetName}
+	ds.Spec.Template.Spec.Containers[0].Name = "kata-cleanup-pod"
+	ds.Spec.Template.Spec.NodeSelector = nil
+	ds.Spec.Template.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchFields: []corev1.NodeSelectorRequirement{
+							{
+								Key:      "metadata.name",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   nodeNames,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// reconcileDepartedNodes removes the kata binaries from the nodes that no longer match the
+// kataConfigPoolSelector. They are dropped from the list once they reported the outcome, or
+// when they were deleted or joined the kata pool again
+func (r *KataConfigOpenShiftReconciler) reconcileDepartedNodes(members []corev1.Node) error {
+	var pending, done []string
+	for _, name := range r.kataConfig.Status.UnInstallationStatus.DepartedNodesList {
+		member := false
+		for _, node := range members {
+			if node.Name == name {
+				member = true
+			}
+		}
+		if member {
+			done = append(done, name)
+			continue
+		}
+
+		node := &corev1.Node{}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, node)
+		if err != nil && errors.IsNotFound(err) {
+			done = append(done, name)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		p := nodeprogress.Get(node, r.kataConfig.Name)
+		if p.State != nodeprogress.BinariesUninstalled && p.State != nodeprogress.UninstallFailed {
+			pending = append(pending, name)
+			continue
+		}
+		if p.State == nodeprogress.UninstallFailed {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, "NodeCleanupFailed",
+				fmt.Sprintf("kata binaries left on %s, which left the kata pool: %s", name, p.Error))
+		}
+		patch, err := nodeprogress.ClearPatch()
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+		done = append(done, name)
+	}
+
+	if len(done) > 0 {
+		r.Log.Info("Nodes that left the kata pool are cleaned up", "nodes", done)
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			var kept []string
+			for _, name := range status.UnInstallationStatus.DepartedNodesList {
+				if !contains(done, name) {
+					kept = append(kept, name)
+				}
+			}
+			status.UnInstallationStatus.DepartedNodesList = kept
+		})
+	}
+
+	if len(pending) == 0 {
+		ds := &appsv1.DaemonSet{}
+		err := r.Client.Get(r.ctx, types.NamespacedName{Name: cleanupDaemonsetName, Namespace: daemonNamespace}, ds)
+		if err != nil && errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return r.Client.Delete(r.ctx, ds)
+	}
+
+	ds, err := r.newCleanupDaemonset(pending)
+	if err != nil {
+		return err
+	}
+	r.Log.Info("Removing the kata binaries from the nodes that left the kata pool", "nodes", strings.Join(pending, ", "))
+	return r.applyObject(ds)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestKataConfigPoolName(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     kataconfigurationv1.KataConfigSpec
+		pool     string
+		expected string
+	}{
+		{name: "whole worker pool", pool: "worker", expected: "worker"},
+		{name: "whole master pool", pool: "master", expected: "master"},
+		{
+			name: "worker role selector",
+			spec: kataconfigurationv1.KataConfigSpec{KataConfigPoolSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+			}},
+			pool:     "worker",
+			expected: "worker",
+		},
+		{
+			name: "some of the workers",
+			spec: kataconfigurationv1.KataConfigSpec{KataConfigPoolSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"custom-kata1": "test"},
+			}},
+			pool:     "worker",
+			expected: "kata-oc",
+		},
+		{
+			name: "pool selectors",
+			spec: kataconfigurationv1.KataConfigSpec{KataConfigPoolSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""}},
+			}},
+			pool:     "worker",
+			expected: "kata-oc",
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{Spec: test.spec}
+		if pool := kataConfigPoolName(kataConfig, test.pool); pool != test.expected {
+			t.Errorf("%s: expected the %s pool, got %s", test.name, test.expected, pool)
+		}
+	}
+}
+
+func TestReconcileDepartedNodes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kataconfigurationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	departed := func(name string, state nodeprogress.State) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				nodeprogress.KataConfigAnnotation: "example",
+				nodeprogress.StateAnnotation:      string(state),
+			},
+		}}
+	}
+	objects := []runtime.Object{
+		departed("cleaned", nodeprogress.BinariesUninstalled),
+		departed("failed", nodeprogress.UninstallFailed),
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: cleanupDaemonsetName, Namespace: daemonNamespace}},
+	}
+	kataConfig := &kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+	kataConfig.Status.UnInstallationStatus.DepartedNodesList = []string{"cleaned", "failed", "deleted", "back"}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &KataConfigOpenShiftReconciler{
+		Client:     fake.NewFakeClientWithScheme(scheme, objects...),
+		Log:        ctrl.Log.WithName("test"),
+		Scheme:     scheme,
+		Recorder:   recorder,
+		kataConfig: kataConfig,
+		ctx:        context.Background(),
+	}
+	members := []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "back"}}}
+	if err := r.reconcileDepartedNodes(members); err != nil {
+		t.Fatal(err)
+	}
+
+	if departed := kataConfig.Status.UnInstallationStatus.DepartedNodesList; len(departed) != 0 {
+		t.Errorf("expected the departed nodes to be cleaned up, %v are left", departed)
+	}
+	if len(r.statusMutations) == 0 {
+		t.Error("expected the status update to be queued")
+	}
+	for _, name := range []string{"cleaned", "failed"} {
+		node := &corev1.Node{}
+		if err := r.Client.Get(r.ctx, types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatal(err)
+		}
+		if state := nodeprogress.Get(node, "example").State; state != "" {
+			t.Errorf("expected the progress of %s to be cleared, got %s", name, state)
+		}
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected an event for the failed cleanup, got %d", len(recorder.Events))
+	}
+	err := r.Client.Get(r.ctx, types.NamespacedName{Name: cleanupDaemonsetName, Namespace: daemonNamespace}, &appsv1.DaemonSet{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the cleanup daemonset to be deleted, got %v", err)
+	}
+}
+
+func TestNewCleanupDaemonset(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kataconfigurationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	r := &KataConfigOpenShiftReconciler{
+		Scheme:     scheme,
+		kataConfig: &kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example", UID: "uid"}},
+	}
+
+	ds, err := r.newCleanupDaemonset([]string{"worker-0", "worker-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ds.Labels[daemonOperationLabel]; ok {
+		t.Error("expected the cleanup daemonset not to be deleted with the uninstallation daemonsets")
+	}
+	if ds.Spec.Template.Spec.NodeSelector != nil {
+		t.Errorf("expected no node selector, got %v", ds.Spec.Template.Spec.NodeSelector)
+	}
+	terms := ds.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchFields) != 1 || len(terms[0].MatchFields[0].Values) != 2 {
+		t.Errorf("expected the daemonset to only run on the departed nodes, got %v", terms)
+	}
+}
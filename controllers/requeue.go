@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultRequeueInterval is how long the controller waits before checking again on
+	// something it can't watch, e.g. the pods of the kata runtime to be deleted
+	DefaultRequeueInterval = 15 * time.Second
+
+	// DefaultMCPPollInterval is the first wait for a machine config pool to become ready
+	DefaultMCPPollInterval = 15 * time.Second
+
+	// DefaultMCPPollMaxInterval caps the exponential backoff of the machine config pool polling
+	DefaultMCPPollMaxInterval = 5 * time.Minute
+
+	// DefaultMCPSyncDelay is how long the controller waits for the MCO to start syncing
+	// a pool after a machine config is deleted
+	DefaultMCPSyncDelay = 60 * time.Second
+
+	// DefaultMCDebounceWindow is how long the spec of a KataConfig must stay unchanged before
+	// its MachineConfig is updated
+	DefaultMCDebounceWindow = 10 * time.Second
+
+	// DefaultNodeEventCoalescingWindow is how long the node events wait before they trigger a
+	// reconcile, the events of the window are coalesced into one
+	DefaultNodeEventCoalescingWindow = 5 * time.Second
+
+	// DefaultMCPStallThreshold is how long a machine config pool may stay unready before the
+	// rollout is reported as stalled
+	DefaultMCPStallThreshold = time.Hour
+
+	// mcpPollJitter spreads the polling of the KataConfigs waiting on their pools
+	mcpPollJitter = 0.2
+)
+
+// Intervals are the wait intervals of the controller. Zero values take the defaults
+type Intervals struct {
+	Requeue      time.Duration
+	MCPPoll      time.Duration
+	MCPPollMax   time.Duration
+	MCPSyncDelay time.Duration
+	MCDebounce   time.Duration
+	// NodeEventCoalescing is the window the node events are coalesced over
+	NodeEventCoalescing time.Duration
+	// MCPStallThreshold is how long a machine config pool may stay unready
+	MCPStallThreshold time.Duration
+}
+
+func (i *Intervals) setDefaults() {
+	if i.Requeue == 0 {
+		i.Requeue = DefaultRequeueInterval
+	}
+	if i.MCPPoll == 0 {
+		i.MCPPoll = DefaultMCPPollInterval
+	}
+	if i.MCPPollMax == 0 {
+		i.MCPPollMax = DefaultMCPPollMaxInterval
+	}
+	if i.MCPSyncDelay == 0 {
+		i.MCPSyncDelay = DefaultMCPSyncDelay
+	}
+	if i.MCDebounce == 0 {
+		i.MCDebounce = DefaultMCDebounceWindow
+	}
+	if i.NodeEventCoalescing == 0 {
+		i.NodeEventCoalescing = DefaultNodeEventCoalescingWindow
+	}
+	if i.MCPStallThreshold == 0 {
+		i.MCPStallThreshold = DefaultMCPStallThreshold
+	}
+}
+
+// mcpPollBackoff counts the consecutive machine config pool polls of every KataConfig
+type mcpPollBackoff struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// next returns the wait before the next poll for the KataConfig: the interval doubled for
+// every previous poll, up to max, with some jitter
+func (b *mcpPollBackoff) next(name string, interval, max time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.attempts == nil {
+		b.attempts = map[string]int{}
+	}
+	attempts := b.attempts[name]
+	b.attempts[name] = attempts + 1
+
+	d := time.Duration(float64(interval) * math.Pow(2, float64(attempts)))
+	if d > max || d <= 0 {
+		d = max
+	}
+	return wait.Jitter(d, mcpPollJitter)
+}
+
+// reset starts the backoff of the KataConfig over
+func (b *mcpPollBackoff) reset(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.attempts, name)
+}
+
+// requeue returns the result checking the KataConfig again after the requeue interval
+func (r *KataConfigOpenShiftReconciler) requeue() ctrl.Result {
+	return ctrl.Result{Requeue: true, RequeueAfter: r.Intervals.Requeue}
+}
+
+// pollMCP returns the result checking a machine config pool again, backing off exponentially
+// while it stays unready. The backoff is reset by the first reconcile that doesn't poll. The
+// wait for the pool, nil while it is not created yet, is recorded
+func (r *KataConfigOpenShiftReconciler) pollMCP(mcp *mcfgv1.MachineConfigPool) ctrl.Result {
+	r.mcpPolled = true
+	if mcp != nil {
+		r.recordMCPWait(mcp)
+	}
+	return ctrl.Result{Requeue: true, RequeueAfter: r.mcpPoll.next(r.kataConfig.Name, r.Intervals.MCPPoll, r.Intervals.MCPPollMax)}
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalsDefaults(t *testing.T) {
+	intervals := Intervals{Requeue: time.Minute}
+	intervals.setDefaults()
+
+	if intervals.Requeue != time.Minute {
+		t.Errorf("expected the requeue interval to be kept, got %s", intervals.Requeue)
+	}
+	if intervals.MCPPoll != DefaultMCPPollInterval || intervals.MCPPollMax != DefaultMCPPollMaxInterval ||
+		intervals.MCPSyncDelay != DefaultMCPSyncDelay || intervals.MCDebounce != DefaultMCDebounceWindow ||
+		intervals.NodeEventCoalescing != DefaultNodeEventCoalescingWindow ||
+		intervals.MCPStallThreshold != DefaultMCPStallThreshold {
+		t.Errorf("expected the unset intervals to take the defaults, got %+v", intervals)
+	}
+}
+
+func TestMCPPollBackoff(t *testing.T) {
+	interval, max := 10*time.Second, time.Minute
+	backoff := &mcpPollBackoff{}
+
+	// the jitter only lengthens the wait
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		wait := backoff.next("example", interval, max)
+		if wait < expected || wait > time.Duration(float64(expected)*(1+mcpPollJitter)) {
+			t.Errorf("expected a wait of %s plus jitter, got %s", expected, wait)
+		}
+	}
+
+	if wait := backoff.next("other", interval, max); wait > time.Duration(float64(interval)*(1+mcpPollJitter)) {
+		t.Errorf("expected the KataConfigs to back off separately, got %s", wait)
+	}
+
+	backoff.reset("example")
+	if wait := backoff.next("example", interval, max); wait > time.Duration(float64(interval)*(1+mcpPollJitter)) {
+		t.Errorf("expected the backoff to start over after a reset, got %s", wait)
+	}
+}
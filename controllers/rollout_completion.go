@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	installDaemonPrefix   = "kata-operator-daemon-" + string(InstallOperation)
+	uninstallDaemonPrefix = "kata-operator-daemon-" + string(UninstallOperation)
+)
+
+// installCompletion tells, out of the progress the nodes of the pool reported, whether they all
+// installed the kata binaries and wait for the CRI-O drop-in, and whether they all completed
+// the installation. The nodes the status lists as completed without reporting it, e.g. with the
+// binaries delivered as an OS extension, are completed. A pool without nodes is neither
+func (r *KataConfigOpenShiftReconciler) installCompletion(nodes []corev1.Node) (binariesInstalled, completed bool) {
+	if len(nodes) == 0 {
+		return false, false
+	}
+	binariesInstalled = len(nodeprogress.Pending(nodes, r.kataConfig.Name, nodeprogress.BinariesInstalled)) == 0
+
+	completed = true
+	for _, name := range nodeprogress.Pending(nodes, r.kataConfig.Name, nodeprogress.Installed) {
+		if !contains(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList, name) {
+			completed = false
+			break
+		}
+	}
+	return binariesInstalled, completed
+}
+
+// crashedDaemon returns why the daemon of the pod keeps crashing, empty while it doesn't
+func crashedDaemon(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		waiting := status.State.Waiting
+		if waiting == nil || waiting.Reason != "CrashLoopBackOff" {
+			continue
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			return fmt.Sprintf("the daemon pod %s keeps crashing, %s with exit code %d after %d restarts",
+				pod.Name, terminated.Reason, terminated.ExitCode, status.RestartCount)
+		}
+		return fmt.Sprintf("the daemon pod %s keeps crashing after %d restarts", pod.Name, status.RestartCount)
+	}
+	return ""
+}
+
+// reportCrashedDaemons reports as failed the nodes whose daemon keeps crashing before reporting
+// the outcome of its operation, which would otherwise never complete. The daemon reports its
+// progress again when it gets past the crash
+func (r *KataConfigOpenShiftReconciler) reportCrashedDaemons() error {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(r.ctx, pods, client.InNamespace(daemonNamespace)); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		name := pod.Labels["name"]
+		failed := nodeprogress.InstallFailed
+		ongoing := []nodeprogress.State{"", nodeprogress.Installing}
+		if strings.HasPrefix(name, uninstallDaemonPrefix) {
+			failed = nodeprogress.UninstallFailed
+			ongoing = []nodeprogress.State{nodeprogress.Uninstalling}
+		} else if !strings.HasPrefix(name, installDaemonPrefix) {
+			continue
+		}
+		reason := crashedDaemon(pod)
+		if reason == "" || pod.Spec.NodeName == "" {
+			continue
+		}
+
+		node := &corev1.Node{}
+		if err := r.Client.Get(r.ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		progress := nodeprogress.Get(node, r.kataConfig.Name)
+		if node.Annotations[nodeprogress.KataConfigAnnotation] != "" && progress.KataConfig == "" {
+			// the node reports for another KataConfig
+			continue
+		}
+		stuck := false
+		for _, state := range ongoing {
+			stuck = stuck || progress.State == state
+		}
+		if !stuck {
+			continue
+		}
+
+		r.Log.Info("The kata daemon of the node keeps crashing", "node", node.Name, "pod", pod.Name, "reason", reason)
+		patch, err := nodeprogress.Patch(nodeprogress.Progress{
+			KataConfig: r.kataConfig.Name,
+			State:      failed,
+			Error:      reason,
+			Reason:     nodeprogress.ReasonDaemonCrashed,
+			Started:    progress.Started,
+		})
+		if err != nil {
+			return err
+		}
+		if err := r.Client.Patch(r.ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// daemonPodChanged filters the pod events down to the containers of the install and uninstall
+// daemons changing state, and their deletion
+var daemonPodChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, okOld := e.ObjectOld.(*corev1.Pod)
+		newPod, okNew := e.ObjectNew.(*corev1.Pod)
+		if !okOld || !okNew || !daemonPod(e.MetaNew.GetNamespace(), e.MetaNew.GetLabels()) {
+			return false
+		}
+		return !equality.Semantic.DeepEqual(containerStates(oldPod), containerStates(newPod))
+	},
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return daemonPod(e.Meta.GetNamespace(), e.Meta.GetLabels())
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// daemonPod tells whether the pod is one of the install or uninstall daemons
+func daemonPod(namespace string, labels map[string]string) bool {
+	name := labels["name"]
+	return namespace == daemonNamespace &&
+		(strings.HasPrefix(name, installDaemonPrefix) || strings.HasPrefix(name, uninstallDaemonPrefix))
+}
+
+// containerStates returns the states of the containers of the pod
+func containerStates(pod *corev1.Pod) []corev1.ContainerState {
+	states := make([]corev1.ContainerState, 0, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		states = append(states, status.State)
+	}
+	return states
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func progressNode(name string, state nodeprogress.State) corev1.Node {
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name: name,
+		Annotations: map[string]string{
+			nodeprogress.KataConfigAnnotation: "example",
+			nodeprogress.StateAnnotation:      string(state),
+		},
+	}}
+}
+
+func TestInstallCompletion(t *testing.T) {
+	tests := []struct {
+		name              string
+		nodes             []corev1.Node
+		completed         []string
+		binariesInstalled bool
+		installed         bool
+	}{
+		{name: "no nodes"},
+		{
+			name:  "installing",
+			nodes: []corev1.Node{progressNode("worker-0", nodeprogress.BinariesInstalled), progressNode("worker-1", nodeprogress.Installing)},
+		},
+		{
+			name:              "waiting for the drop-in",
+			nodes:             []corev1.Node{progressNode("worker-0", nodeprogress.BinariesInstalled), progressNode("worker-1", nodeprogress.BinariesInstalled)},
+			binariesInstalled: true,
+		},
+		{
+			name:      "installed",
+			nodes:     []corev1.Node{progressNode("worker-0", nodeprogress.Installed), progressNode("worker-1", nodeprogress.Installed)},
+			installed: true,
+		},
+		{
+			name:      "completed without reporting",
+			nodes:     []corev1.Node{progressNode("worker-0", nodeprogress.Installed), {ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}},
+			completed: []string{"worker-1"},
+			installed: true,
+		},
+	}
+
+	for _, test := range tests {
+		kataConfig := &kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+		kataConfig.Status.InstallationStatus.Completed.CompletedNodesList = test.completed
+		r := &KataConfigOpenShiftReconciler{kataConfig: kataConfig}
+		binariesInstalled, installed := r.installCompletion(test.nodes)
+		if binariesInstalled != test.binariesInstalled || installed != test.installed {
+			t.Errorf("%s: expected binaries installed %v and installed %v, got %v and %v",
+				test.name, test.binariesInstalled, test.installed, binariesInstalled, installed)
+		}
+	}
+}
+
+func TestCrashedDaemon(t *testing.T) {
+	crashLoop := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	tests := []struct {
+		name     string
+		status   corev1.ContainerStatus
+		expected string
+	}{
+		{name: "running", status: corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		{
+			name:   "pulling",
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+		},
+		{
+			name:     "crashing",
+			status:   corev1.ContainerStatus{State: crashLoop, RestartCount: 3},
+			expected: "the daemon pod daemon-0 keeps crashing after 3 restarts",
+		},
+		{
+			name: "crashing with an exit code",
+			status: corev1.ContainerStatus{
+				State:                crashLoop,
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 2}},
+				RestartCount:         5,
+			},
+			expected: "the daemon pod daemon-0 keeps crashing, Error with exit code 2 after 5 restarts",
+		},
+	}
+
+	for _, test := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "daemon-0"},
+			Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{test.status}},
+		}
+		if reason := crashedDaemon(pod); reason != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, reason)
+		}
+	}
+}
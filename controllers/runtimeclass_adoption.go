@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// foreignRuntimeClass returns the existing RuntimeClass with the name of rc when the KataConfig
+// doesn't control it, e.g. one left over by kata-deploy, nil otherwise
+func foreignRuntimeClass(ctx context.Context, c client.Client, kataConfig *kataconfigurationv1.KataConfig,
+	rc *nodeapi.RuntimeClass) (*nodeapi.RuntimeClass, error) {
+	found := &nodeapi.RuntimeClass{}
+	err := c.Get(ctx, types.NamespacedName{Name: rc.Name}, found)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if owner := metav1.GetControllerOf(found); owner != nil && owner.UID == kataConfig.UID {
+		return nil, nil
+	}
+	return found, nil
+}
+
+// releaseForAdoption makes the foreign RuntimeClass found ready to be taken over as rc: its
+// handler can't be changed, a RuntimeClass with another handler is deleted to be created again.
+// It returns a description of the adoption
+func releaseForAdoption(ctx context.Context, c client.Client, found, rc *nodeapi.RuntimeClass) (string, error) {
+	if found.Handler == rc.Handler {
+		return fmt.Sprintf("runtime class %s adopted", rc.Name), nil
+	}
+	if err := c.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	return fmt.Sprintf("runtime class %s adopted, re-created with the %s handler instead of %s",
+		rc.Name, rc.Handler, found.Handler), nil
+}
+
+// runtimeClassConflictCondition returns the RuntimeClassConflict condition of the foreign
+// RuntimeClasses left alone
+func runtimeClassConflictCondition(names []string) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    kataconfigurationv1.KataConfigRuntimeClassConflict,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: "the kata runtime classes are managed by the KataConfig",
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NotAdopted"
+		condition.Message = fmt.Sprintf("runtime class %s already exists and is left as is, "+
+			"set spec.adoptRuntimeClass to take it over", strings.Join(names, ", "))
+	}
+	return condition
+}
+
+// adoptRuntimeClasses looks for existing RuntimeClasses with the names of the kata ones that
+// the KataConfig doesn't control. With spec.adoptRuntimeClass they are taken over: the
+// RuntimeClasses applied next get the KataConfig as owner and the overhead and scheduling of
+// the spec. Otherwise they are left as is, reported in the RuntimeClassConflict condition, and
+// the returned names must not be applied
+func (r *KataConfigOpenShiftReconciler) adoptRuntimeClasses(rcs []*nodeapi.RuntimeClass) (map[string]bool, error) {
+	skipped := map[string]bool{}
+	var names []string
+	for _, rc := range rcs {
+		found, err := foreignRuntimeClass(r.ctx, r.Client, r.kataConfig, rc)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil || r.rendering() {
+			continue
+		}
+		if !r.kataConfig.Spec.AdoptRuntimeClass {
+			skipped[rc.Name] = true
+			names = append(names, rc.Name)
+			continue
+		}
+
+		message, err := releaseForAdoption(r.ctx, r.Client, found, rc)
+		if err != nil {
+			return nil, err
+		}
+		r.Log.Info("Adopting the RuntimeClass", "rc.Name", rc.Name, "handler", found.Handler)
+		r.Recorder.Event(r.kataConfig, corev1.EventTypeNormal, "RuntimeClassAdopted", message)
+		r.recordHistory(kataconfigurationv1.HistoryRuntimeClassUpdated, message)
+	}
+
+	condition := runtimeClassConflictCondition(names)
+	if current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, condition.Type); (current == nil && len(names) > 0) ||
+		(current != nil && (current.Status != condition.Status || current.Message != condition.Message)) {
+		if condition.Status == metav1.ConditionTrue {
+			r.Recorder.Event(r.kataConfig, corev1.EventTypeWarning, condition.Type, condition.Message)
+		}
+		r.setStatus(func(status *kataconfigurationv1.KataConfigStatus) {
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+	}
+	return skipped, nil
+}
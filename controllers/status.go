@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// statusMutation changes the KataConfig status. It can be called more than once, on a
+// fresh copy of the status every time
+type statusMutation func(status *kataconfigurationv1.KataConfigStatus)
+
+// updateStatus applies the mutations on top of the latest KataConfig status and writes it
+// in a single update. On conflicts, e.g. with a concurrent edit of the KataConfig, the
+// KataConfig is read again from the API server through apiReader, the cache may still hold the
+// conflicting version, and the mutations are replayed. kataConfig is refreshed with the written
+// status, nothing is done when it was deleted in the meantime
+func updateStatus(ctx context.Context, apiReader client.Reader, c client.Client, kataConfig *kataconfigurationv1.KataConfig, mutations []statusMutation) error {
+	if len(mutations) == 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &kataconfigurationv1.KataConfig{}
+		err := apiReader.Get(ctx, types.NamespacedName{Name: kataConfig.Name}, latest)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		for _, mutate := range mutations {
+			mutate(&latest.Status)
+		}
+
+		err = c.Status().Update(ctx, latest)
+		if err != nil {
+			return err
+		}
+
+		kataConfig.Status = latest.Status
+		kataConfig.ResourceVersion = latest.ResourceVersion
+		return nil
+	})
+}
+
+// setStatus applies mutate to the status of the KataConfig being reconciled and queues it
+// to be written once the reconcile is done
+func (r *KataConfigOpenShiftReconciler) setStatus(mutate statusMutation) {
+	mutate(&r.kataConfig.Status)
+	r.statusMutations = append(r.statusMutations, mutate)
+}
+
+// flushStatus writes all the status changes made during the reconcile
+func (r *KataConfigOpenShiftReconciler) flushStatus() error {
+	mutations := r.statusMutations
+	r.statusMutations = nil
+	return updateStatus(r.ctx, r.APIReader, r.Client, r.kataConfig, mutations)
+}
+
+// setStatus applies mutate to the status of the KataConfig being reconciled and queues it
+// to be written once the reconcile is done
+func (r *KataConfigKubernetesReconciler) setStatus(mutate statusMutation) {
+	mutate(&r.kataConfig.Status)
+	r.statusMutations = append(r.statusMutations, mutate)
+}
+
+// flushStatus writes all the status changes made during the reconcile
+func (r *KataConfigKubernetesReconciler) flushStatus() error {
+	mutations := r.statusMutations
+	r.statusMutations = nil
+	return updateStatus(r.ctx, r.APIReader, r.Client, r.kataConfig, mutations)
+}
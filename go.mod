@@ -3,6 +3,7 @@ module github.com/openshift/kata-operator
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/ajeddeloh/go-json v0.0.0-20200220154158-5ae607161559 // indirect
 	github.com/coreos/ignition v0.35.0
 	github.com/go-logr/logr v0.2.1
@@ -10,14 +11,18 @@ require (
 	github.com/monopole/mdrip v1.0.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/openshift/api v0.0.0-20200829102639-8a3a835f1acf
 	github.com/openshift/machine-config-operator v0.0.1-0.20200918082730-c08c048584ef
-	github.com/vincent-petithory/dataurl v0.0.0-20191104211930-d1553a71de50 // indirect
+	github.com/prometheus/client_golang v1.7.1
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/vincent-petithory/dataurl v0.0.0-20191104211930-d1553a71de50
 	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
 	k8s.io/api v0.19.0
 	k8s.io/apimachinery v0.19.0
 	k8s.io/client-go v0.19.0
 	k8s.io/kubernetes v0.19.0
 	sigs.k8s.io/controller-runtime v0.6.3
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
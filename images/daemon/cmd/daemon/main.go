@@ -18,7 +18,7 @@ import (
 func main() {
 
 	var kataOperation string
-	flag.StringVar(&kataOperation, "operation", "", "Specify kata operations. Valid options are 'install', 'upgrade', 'uninstall'")
+	flag.StringVar(&kataOperation, "operation", "", "Specify kata operations. Valid options are 'prepull', 'install', 'upgrade', 'uninstall', 'reload', 'nodeconfig', 'verify', 'monitor'")
 
 	var kataConfigResourceName string
 	flag.StringVar(&kataConfigResourceName, "resource", "", "Kata Config Custom Resource Name")
@@ -46,6 +46,13 @@ func main() {
 	}
 
 	switch kataOperation {
+	case "prepull":
+		// runs as an init container of the install daemonset, exit once the payload is cached
+		if err := kataActions.PrePull(kataConfigResourceName); err != nil {
+			fmt.Printf("Error while pre-pulling the payload: %+v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	case "install":
 		err := kataActions.Install(kataConfigResourceName)
 		if err != nil {
@@ -58,6 +65,27 @@ func main() {
 		if err != nil {
 			fmt.Printf("Error while uninstallation: %+v", err)
 		}
+	case "reload":
+		if err := kataActions.ReloadCrio(); err != nil {
+			fmt.Printf("Error while reloading CRI-O: %+v", err)
+			os.Exit(1)
+		}
+	case "nodeconfig":
+		if err := kataActions.ApplyNodeConfig(); err != nil {
+			fmt.Printf("Error while applying the kata node settings: %+v", err)
+			os.Exit(1)
+		}
+	case "verify":
+		if err := kataActions.VerifyUninstall(kataConfigResourceName); err != nil {
+			fmt.Printf("Error while verifying the uninstallation: %+v", err)
+			os.Exit(1)
+		}
+	case "monitor":
+		// restarted by the daemonset whenever kata-monitor exits
+		if err := kataActions.Monitor(kataConfigResourceName); err != nil {
+			fmt.Printf("Error while running kata-monitor: %+v", err)
+		}
+		os.Exit(1)
 	default:
 		fmt.Println("invalid operation. Check -h for more information.")
 	}
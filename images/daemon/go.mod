@@ -10,6 +10,8 @@ require (
 	github.com/openshift/client-go v0.0.0-20200827190008-3062137373b5
 	github.com/openshift/kata-operator v0.0.0-20201106123035-a3bf549cd866
 	github.com/openshift/machine-config-operator v0.0.1-0.20200918082730-c08c048584ef
+	github.com/vincent-petithory/dataurl v0.0.0-20191104211930-d1553a71de50
+	k8s.io/api v0.19.0
 	k8s.io/apimachinery v0.19.0
 	k8s.io/client-go v12.0.0+incompatible
 	k8s.io/kubernetes v0.19.0
@@ -23,6 +25,7 @@ replace (
 	github.com/docker/docker => github.com/moby/moby v0.7.3-0.20190826074503-38ab9da00309 // Required by Helm
 	github.com/go-log/log => github.com/go-log/log v0.1.1-0.20181211034820-a514cf01a3eb
 	github.com/openshift/api => github.com/openshift/api v0.0.0-20200916161728-83f0cb093902
+	github.com/openshift/kata-operator => ../../
 
 	// So that we can import MCO
 	k8s.io/api => k8s.io/api v0.19.0
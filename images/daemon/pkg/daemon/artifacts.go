@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kataPackages are the packages the daemon layers onto the nodes
+var kataPackages = []string{"kata-runtime", "kata-osbuilder"}
+
+// guestPullPackages are layered onto the nodes along with the kata packages when the
+// confidential sandboxes pull their images in the guest: the confidential guest image with
+// the guest components pulling, verifying and unpacking the images
+var guestPullPackages = []string{"kata-guest-components"}
+
+// kataBinaries returns the executables installed on the host by the kata packages
+func kataBinaries() ([]string, error) {
+	args := append([]string{hostRoot, "rpm", "-ql"}, kataPackages...)
+	out, err := exec.Command("chroot", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files of the kata packages: %v", err)
+	}
+
+	var binaries []string
+	for _, path := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		info, err := os.Lstat(filepath.Join(hostRoot, path))
+		if err != nil {
+			// a file of the package not installed, e.g. excluded documentation
+			continue
+		}
+		if info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			binaries = append(binaries, path)
+		}
+	}
+	sort.Strings(binaries)
+	return binaries, nil
+}
+
+// checksum returns the SHA256 checksum of a host file
+func checksum(path string) (string, error) {
+	f, err := os.Open(filepath.Join(hostRoot, path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordArtifacts records on the node the checksums of the kata binaries installed on it
+func recordArtifacts(kataClient client.Client) error {
+	binaries, err := kataBinaries()
+	if err != nil {
+		return err
+	}
+
+	artifacts := map[string]string{}
+	for _, path := range binaries {
+		sum, err := checksum(path)
+		if err != nil {
+			return err
+		}
+		artifacts[path] = sum
+	}
+	log.Printf("Recording the checksums of %d kata binaries", len(artifacts))
+
+	patch, err := nodeprogress.ArtifactsPatch(artifacts)
+	if err != nil {
+		return err
+	}
+	return patchNode(kataClient, patch)
+}
+
+// clearArtifacts removes the checksums recorded on the node, before the binaries are installed
+// again
+func clearArtifacts(kataClient client.Client) error {
+	patch, err := nodeprogress.ArtifactsPatch(nil)
+	if err != nil {
+		return err
+	}
+	return patchNode(kataClient, patch)
+}
+
+// modifiedArtifacts returns the recorded kata binaries that were changed since they were
+// installed. The binaries gone are not reported, there is nothing left to protect
+func modifiedArtifacts(artifacts map[string]string) ([]string, error) {
+	var modified []string
+	for path, recorded := range artifacts {
+		sum, err := checksum(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if sum != recorded {
+			modified = append(modified, path)
+		}
+	}
+	sort.Strings(modified)
+	return modified, nil
+}
+
+// verifyArtifacts checks that the kata binaries recorded on the node are still the ones the
+// daemon installed before they are replaced or removed
+func verifyArtifacts(kataClient client.Client, kataConfigResourceName string) error {
+	progress, err := getProgress(kataClient, kataConfigResourceName)
+	if err != nil {
+		return err
+	}
+
+	modified, err := modifiedArtifacts(progress.Artifacts)
+	if err != nil {
+		return err
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("refusing to replace or remove the kata binaries modified since their installation: %s",
+			strings.Join(modified, ", "))
+	}
+	return nil
+}
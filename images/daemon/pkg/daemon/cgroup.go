@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"log"
+	"os"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cgroupControllersPath is only present when the unified cgroup hierarchy is mounted. The
+// container runtime mounts the cgroup hierarchy of the host into the daemon pod
+const cgroupControllersPath = "/sys/fs/cgroup/cgroup.controllers"
+
+// cgroupVersion returns the cgroup version of the node
+func cgroupVersion() string {
+	if _, err := os.Stat(cgroupControllersPath); err == nil {
+		return nodeprogress.CgroupV2
+	}
+	return nodeprogress.CgroupV1
+}
+
+// recordCgroupVersion records the cgroup version of the node unless already recorded, the
+// operator renders the kata configuration matching it
+func recordCgroupVersion(kataClient client.Client, recorded string) error {
+	version := cgroupVersion()
+	if version == recorded {
+		return nil
+	}
+	log.Printf("The node runs cgroup %s", version)
+
+	patch, err := nodeprogress.CgroupPatch(version)
+	if err != nil {
+		return err
+	}
+	return patchNode(kataClient, patch)
+}
@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+)
+
+const (
+	// crioReloadableDropinPath is the CRI-O drop-in holding the settings applied without reboot.
+	// It sorts after the 50-kata.conf drop-in of the MachineConfig and takes precedence over it
+	crioReloadableDropinPath = "/host/etc/crio/crio.conf.d/51-kata-reloadable.conf"
+
+	// crioReloadedMarker makes the reload pod ready, see the readiness probe of the daemonset
+	crioReloadedMarker = "/tmp/crio-reloaded"
+)
+
+// ReloadCrio writes the CRI-O drop-in rendered by the operator, or removes it when empty,
+// and reloads CRI-O to apply it
+func (k *KataOpenShift) ReloadCrio() error {
+	dropin := os.Getenv("CRIO_RELOADABLE_DROPIN")
+
+	current, err := ioutil.ReadFile(crioReloadableDropinPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if string(current) == dropin {
+		log.Println("CRI-O settings are up to date on the node")
+	} else {
+		if dropin == "" {
+			log.Println("Removing " + crioReloadableDropinPath)
+			if err := os.Remove(crioReloadableDropinPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		} else {
+			log.Println("Writing " + crioReloadableDropinPath)
+			if err := ioutil.WriteFile(crioReloadableDropinPath, []byte(dropin), 0644); err != nil {
+				return err
+			}
+		}
+
+		if err := doCmd(exec.Command("chroot", "/host", "systemctl", "reload", "crio")); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(crioReloadedMarker, nil, 0644)
+}
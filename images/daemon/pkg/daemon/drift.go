@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/vincent-petithory/dataurl"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataMachineConfigName is the MachineConfig of the operator delivering the CRI-O drop-in
+	kataMachineConfigName = "50-kata-crio-dropin"
+
+	crioDropinPath = "/etc/crio/crio.conf.d/50-kata.conf"
+)
+
+// kataBinaryPaths are the kata binaries installed by the daemon that CRI-O runs
+var kataBinaryPaths = []string{
+	kataShimPath,
+	"/usr/bin/kata-runtime",
+}
+
+// missingPaths returns the paths not found on the host
+func missingPaths(paths []string) ([]string, error) {
+	var missing []string
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(hostRoot, path)); os.IsNotExist(err) {
+			missing = append(missing, path)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// machineConfigFile returns the content of a file of the MachineConfig
+func machineConfigFile(kataClient client.Client, mcName, path string) ([]byte, error) {
+	mc := &mcfgv1.MachineConfig{}
+	if err := kataClient.Get(context.Background(), client.ObjectKey{Name: mcName}, mc); err != nil {
+		return nil, err
+	}
+
+	var ignition struct {
+		Storage struct {
+			Files []struct {
+				Path     string `json:"path"`
+				Contents struct {
+					Source string `json:"source"`
+				} `json:"contents"`
+			} `json:"files"`
+		} `json:"storage"`
+	}
+	if err := json.Unmarshal(mc.Spec.Config.Raw, &ignition); err != nil {
+		return nil, err
+	}
+	for _, file := range ignition.Storage.Files {
+		if file.Path != path {
+			continue
+		}
+		content, err := dataurl.DecodeString(file.Contents.Source)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %s of the %s machine config: %v", path, mcName, err)
+		}
+		return content.Data, nil
+	}
+	return nil, fmt.Errorf("%s not found in the %s machine config", path, mcName)
+}
+
+// restoreCrioDropin writes back the CRI-O drop-in of the kata MachineConfig, as the machine
+// config daemon did, and reloads CRI-O to apply it
+func (k *KataOpenShift) restoreCrioDropin() error {
+	content, err := machineConfigFile(k.KataClient, kataMachineConfigName, crioDropinPath)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(hostRoot, crioDropinPath), content, 0644); err != nil {
+		return err
+	}
+	return doCmd(exec.Command("chroot", "/host", "systemctl", "reload", "crio"))
+}
+
+// repairDrift puts back what was removed from an installed node, by an admin or another
+// MachineConfig. The missing CRI-O drop-in is restored in place, the missing binaries are
+// installed again by reporting the node as installing, which has the operator run the install
+// daemonset on it. The repair is reported on the node for the operator to record an event.
+// It returns true when the node was repaired
+func (k *KataOpenShift) repairDrift(kataConfigResourceName string) (bool, error) {
+	kataConfig, err := getKataConfig(k.KataClient, kataConfigResourceName)
+	if err != nil {
+		return false, err
+	}
+
+	// the extension delivered binaries are part of the OS image, they are not repaired here
+	if kataConfig.Spec.PayloadDelivery != kataTypes.PayloadDeliveryExtension {
+		missing, err := missingPaths(kataBinaryPaths)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			repair := fmt.Sprintf("kata binaries missing (%s), reinstalling kata", strings.Join(missing, ", "))
+			log.Println(repair)
+			patch, err := nodeprogress.Patch(nodeprogress.Progress{
+				KataConfig: kataConfigResourceName,
+				State:      nodeprogress.Installing,
+				Repair:     repair,
+			})
+			if err != nil {
+				return false, err
+			}
+			return true, patchNode(k.KataClient, patch)
+		}
+	}
+
+	missing, err := missingPaths([]string{crioDropinPath})
+	if err != nil || len(missing) == 0 {
+		return false, err
+	}
+	if err := k.restoreCrioDropin(); err != nil {
+		return false, fmt.Errorf("unable to restore the CRI-O drop-in %s: %v", crioDropinPath, err)
+	}
+	repair := fmt.Sprintf("CRI-O drop-in %s restored from the %s machine config", crioDropinPath, kataMachineConfigName)
+	log.Println(repair)
+
+	patch, err := nodeprogress.RepairPatch(repair)
+	if err != nil {
+		return false, err
+	}
+	return true, patchNode(k.KataClient, patch)
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	fipsEnabledPath = "/proc/sys/crypto/fips_enabled"
+
+	// fipsCompliantLabel marks payload images whose binaries (including QEMU) are
+	// built against FIPS validated crypto libraries
+	fipsCompliantLabel = "io.openshift.kata-operator.fips-compliant"
+
+	// fipsPayloadTagSuffix selects the FIPS flavour of the default payload image
+	fipsPayloadTagSuffix = "-fips"
+)
+
+// fipsIncompatibleError is returned when a node running in FIPS mode is asked
+// to install a payload that is not FIPS compliant
+type fipsIncompatibleError struct {
+	image string
+}
+
+func (e *fipsIncompatibleError) Error() string {
+	return fmt.Sprintf("the node runs in FIPS mode but payload %s is not labeled %s=true", e.image, fipsCompliantLabel)
+}
+
+func isFIPSEnabled() (bool, error) {
+	content, err := ioutil.ReadFile(fipsEnabledPath)
+	if os.IsNotExist(err) {
+		// kernel built without FIPS support
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(content)) == "1", nil
+}
+
+// checkFIPSCompliance refuses payload images that are not labeled as FIPS compliant.
+// Only the image configuration is fetched, so nothing is downloaded for a rejected payload
+func checkFIPSCompliance(ctx context.Context, ref types.ImageReference, sys *types.SystemContext) error {
+	img, err := ref.NewImage(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	info, err := img.Inspect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if info.Labels[fipsCompliantLabel] != "true" {
+		return &fipsIncompatibleError{image: transports.ImageName(ref)}
+	}
+	return nil
+}
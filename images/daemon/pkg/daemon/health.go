@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+)
+
+const (
+	// healthProbeInterval is how often the kata-monitor daemon probes the health of its node
+	healthProbeInterval = 5 * time.Minute
+
+	kvmDevicePath = "/dev/kvm"
+)
+
+// kataShimPath is the kata shim CRI-O runs. The operator sets KATA_SHIM_PATH for the payloads
+// installing it elsewhere
+var kataShimPath = func() string {
+	if path := os.Getenv("KATA_SHIM_PATH"); path != "" {
+		return path
+	}
+	return "/usr/bin/containerd-shim-kata-v2"
+}()
+
+// kataConfigurationPaths are where kata-runtime looks for its configuration, in order
+var kataConfigurationPaths = []string{
+	"/etc/kata-containers/configuration.toml",
+	"/usr/share/kata-containers/defaults/configuration.toml",
+}
+
+// checkKataHealth verifies that the node is still able to start kata sandboxes: the shim is
+// an executable, the kata configuration is readable and the host exposes /dev/kvm
+func checkKataHealth() error {
+	shim, err := os.Stat(filepath.Join(hostRoot, kataShimPath))
+	if err != nil {
+		return fmt.Errorf("kata shim %s: %v", kataShimPath, err)
+	}
+	if !shim.Mode().IsRegular() || shim.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("kata shim %s is not an executable file", kataShimPath)
+	}
+
+	var configuration string
+	for _, path := range kataConfigurationPaths {
+		if _, err := os.Stat(filepath.Join(hostRoot, path)); err == nil {
+			configuration = path
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if configuration == "" {
+		return fmt.Errorf("no kata configuration found in %v", kataConfigurationPaths)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(hostRoot, configuration))
+	if err != nil {
+		return fmt.Errorf("kata configuration %s: %v", configuration, err)
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("kata configuration %s is empty", configuration)
+	}
+
+	kvm, err := os.Stat(filepath.Join(hostRoot, kvmDevicePath))
+	if err != nil {
+		return fmt.Errorf("%s: %v", kvmDevicePath, err)
+	}
+	if kvm.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%s is not a character device", kvmDevicePath)
+	}
+	return nil
+}
+
+// probeHealth repairs the drift of the node and probes its health every healthProbeInterval
+// once kata is installed, and reports it on the node whenever it changes. The operator flips
+// the degraded nodes in the KataConfig status. The checksums of the kata binaries are
+// recorded on the first probe of an installed node
+func (k *KataOpenShift) probeHealth(kataConfigResourceName string) {
+	for {
+		if err := k.reportHealth(kataConfigResourceName); err != nil {
+			log.Printf("Unable to report the health of the node: %+v", err)
+		}
+		time.Sleep(healthProbeInterval)
+	}
+}
+
+func (k *KataOpenShift) reportHealth(kataConfigResourceName string) error {
+	progress, err := getProgress(k.KataClient, kataConfigResourceName)
+	if err != nil {
+		return err
+	}
+	if progress.State != nodeprogress.Installed {
+		return nil
+	}
+
+	// the health is probed again once the node is repaired
+	if repaired, err := k.repairDrift(kataConfigResourceName); err != nil || repaired {
+		return err
+	}
+
+	if len(progress.Artifacts) == 0 {
+		if err := recordArtifacts(k.KataClient); err != nil {
+			log.Printf("Unable to record the checksums of the kata binaries: %+v", err)
+		}
+	}
+
+	// the cgroup version changes when the node is rebooted with other kernel arguments
+	if err := recordCgroupVersion(k.KataClient, progress.Cgroup); err != nil {
+		log.Printf("Unable to record the cgroup version of the node: %+v", err)
+	}
+
+	kataConfig, err := getKataConfig(k.KataClient, kataConfigResourceName)
+	if err != nil {
+		return err
+	}
+
+	var failure string
+	if err := checkKataHealth(); err != nil {
+		failure = err.Error()
+	} else if kataConfig.Spec.DevicePassthrough != nil {
+		// the IOMMU kernel arguments set by the operator are in effect once the node is installed
+		if err := checkIOMMU(); err != nil {
+			failure = err.Error()
+		}
+	}
+	if failure == progress.Health {
+		return nil
+	}
+	if failure != "" {
+		log.Println("The node failed its health probe: " + failure)
+	} else {
+		log.Println("The node is healthy again")
+	}
+
+	patch, err := nodeprogress.HealthPatch(failure)
+	if err != nil {
+		return err
+	}
+	return patchNode(k.KataClient, patch)
+}
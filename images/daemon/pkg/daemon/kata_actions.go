@@ -7,35 +7,62 @@ import (
 	"time"
 
 	kataTypes "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // KataActions declares the possible actions the daemon can take.
 type KataActions interface {
+	PrePull(kataConfigResourceName string) error
 	Install(kataConfigResourceName string) error
 	Upgrade() error
 	Uninstall(kataConfigResourceName string) error
+	ReloadCrio() error
+	ApplyNodeConfig() error
+	VerifyUninstall(kataConfigResourceName string) error
+	Monitor(kataConfigResourceName string) error
 }
 
-type updateStatus = func(a *kataTypes.KataConfigStatus)
+// daemonStarted is when the daemon started, the installation time of the node is measured from it
+var daemonStarted = time.Now()
+
+// reportProgress annotates the node the daemon runs on with its progress, the operator
+// aggregates the progress of all the nodes into the KataConfig status. The start of the
+// daemon is recorded with the start of the installation
+func reportProgress(kataClient client.Client, kataConfigResourceName string, state nodeprogress.State, reportErr error, reason string) (err error) {
+	p := nodeprogress.Progress{
+		KataConfig: kataConfigResourceName,
+		State:      state,
+		Reason:     reason,
+	}
+	if state == nodeprogress.Installing {
+		p.Started = daemonStarted
+	}
+	if reportErr != nil {
+		p.Error = fmt.Sprintf("%+v", reportErr)
+	}
+	patch, err := nodeprogress.Patch(p)
+	if err != nil {
+		return err
+	}
+	return patchNode(kataClient, patch)
+}
 
-func updateKataConfigStatus(kataClient client.Client, kataConfigResourceName string, us updateStatus) (err error) {
-	var kataConfig kataTypes.KataConfig
+// patchNode applies the merge patch to the node the daemon runs on
+func patchNode(kataClient client.Client, patch []byte) (err error) {
+	nodeName, err := getNodeName()
+	if err != nil {
+		return err
+	}
+
+	node := &corev1.Node{}
+	node.Name = nodeName
 	attempts := 5
 	for i := 0; i < attempts; i++ {
-		err = kataClient.Get(context.Background(), client.ObjectKey{
-			Name: kataConfigResourceName,
-		}, &kataConfig)
-
-		if err != nil {
-			continue // Error, let's attempt to get the object again. TODO - maybe check error type and decide?
-		}
-
-		us(&kataConfig.Status)
-
-		err = kataClient.Status().Update(context.Background(), &kataConfig)
-
+		err = kataClient.Patch(context.Background(), node, client.RawPatch(types.MergePatchType, patch))
 		if err == nil {
 			break
 		}
@@ -46,16 +73,30 @@ func updateKataConfigStatus(kataClient client.Client, kataConfigResourceName str
 	return err
 }
 
-func getFailedNode(err error) (fn kataTypes.FailedNodeStatus, retErr error) {
-	nodeName, hErr := getNodeName()
-	if hErr != nil {
-		return kataTypes.FailedNodeStatus{}, hErr
+// getProgress returns the progress previously reported on the node for the KataConfig
+func getProgress(kataClient client.Client, kataConfigResourceName string) (nodeprogress.Progress, error) {
+	nodeName, err := getNodeName()
+	if err != nil {
+		return nodeprogress.Progress{}, err
 	}
 
-	return kataTypes.FailedNodeStatus{
-		Name:  nodeName,
-		Error: fmt.Sprintf("%+v", err),
-	}, nil
+	node := &corev1.Node{}
+	err = kataClient.Get(context.Background(), client.ObjectKey{Name: nodeName}, node)
+	if err != nil {
+		return nodeprogress.Progress{}, err
+	}
+	return nodeprogress.Get(node, kataConfigResourceName), nil
+}
+
+func getKataConfig(kataClient client.Client, kataConfigResourceName string) (*kataTypes.KataConfig, error) {
+	kataConfig := &kataTypes.KataConfig{}
+	err := kataClient.Get(context.Background(), client.ObjectKey{
+		Name: kataConfigResourceName,
+	}, kataConfig)
+	if err != nil {
+		return nil, err
+	}
+	return kataConfig, nil
 }
 
 func getHostName() (string, error) {
@@ -66,6 +107,11 @@ func getHostName() (string, error) {
 	return hostname, nil
 }
 
+// getNodeName returns the node the daemon runs on, as given by the downward API. The daemon
+// no longer shares the host network namespace, so the hostname is the one of the pod
 func getNodeName() (string, error) {
+	if nodeName := os.Getenv("NODE_NAME"); nodeName != "" {
+		return nodeName, nil
+	}
 	return getHostName()
 }
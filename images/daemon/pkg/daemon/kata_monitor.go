@@ -0,0 +1,26 @@
+package daemon
+
+import (
+	"log"
+	"os/exec"
+)
+
+const (
+	// kataMonitorListenAddress is where kata-monitor serves the metrics of the kata sandboxes of
+	// the node, only reachable by the kube-rbac-proxy sidecar of the pod
+	kataMonitorListenAddress = "127.0.0.1:8090"
+
+	crioSocket = "/run/crio/crio.sock"
+)
+
+// Monitor runs the kata-monitor installed on the node, which exposes the metrics of the
+// kata sandboxes and of their guests, and probes the health of the node meanwhile. It only
+// returns when kata-monitor exits
+func (k *KataOpenShift) Monitor(kataConfigResourceName string) error {
+	go k.probeHealth(kataConfigResourceName)
+
+	log.Println("Starting kata-monitor on " + kataMonitorListenAddress)
+	return doCmd(exec.Command("chroot", "/host", "/usr/bin/kata-monitor",
+		"--listen-address", kataMonitorListenAddress,
+		"--runtime-endpoint", crioSocket))
+}
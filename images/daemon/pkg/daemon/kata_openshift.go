@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,13 +10,10 @@ import (
 	"strings"
 	"syscall"
 
-	"github.com/containers/image/v5/copy"
-	"github.com/containers/image/v5/signature"
-	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/coreos/go-semver/semver"
-	"github.com/opencontainers/image-tools/image"
 	confv1client "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
 	kataTypes "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,6 +35,19 @@ type KataOpenShift struct {
 	KataConfigPoolLabels  map[string]string
 	CRIODropinPath        string
 	PayloadTag            string
+	SELinuxShimMode       kataTypes.SELinuxMode
+	// Upgrading is set when the operator upgrades the payload of the installed node
+	Upgrading bool
+	// GuestPull is set when the confidential sandboxes pull their images in the guest
+	GuestPull bool
+}
+
+// packages returns the packages the daemon layers onto the node
+func (k *KataOpenShift) packages() []string {
+	if !k.GuestPull {
+		return kataPackages
+	}
+	return append(append([]string{}, kataPackages...), guestPullPackages...)
 }
 
 var _ KataActions = (*KataOpenShift)(nil)
@@ -46,40 +57,13 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 
 	if k.KataInstallChecker == nil {
 		k.KataInstallChecker = func() (bool, bool, error) {
-			var (
-				isKataInstalled bool
-				isCrioDropInInstalled bool
-				err error
-				kataConfig kataTypes.KataConfig
-			)
-
-			err = k.KataClient.Get(context.Background(), client.ObjectKey{
-				Name: kataConfigResourceName,
-			}, &kataConfig)
-			if err != nil {
-				return isKataInstalled, isCrioDropInInstalled, err
-			}
-
-			nodeName, err := getNodeName()
+			progress, err := getProgress(k.KataClient, kataConfigResourceName)
 			if err != nil {
-				return isKataInstalled, isCrioDropInInstalled, err
+				return false, false, err
 			}
 
-			for _, n := range kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList {
-				if n == nodeName {
-					isKataInstalled = true
-					break
-				}
-			}
-
-			for _, n := range kataConfig.Status.InstallationStatus.Completed.CompletedNodesList {
-				if n == nodeName {
-					isCrioDropInInstalled = true
-					break
-				}
-			}
-
-			return isKataInstalled, isCrioDropInInstalled, err
+			k.Upgrading = progress.State == nodeprogress.Installing && progress.Reason == nodeprogress.ReasonUpgrade
+			return progress.State == nodeprogress.BinariesInstalled, progress.State == nodeprogress.Installed, nil
 		}
 	}
 
@@ -103,35 +87,19 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 		k.KataBinaryInstaller = installRPMs
 	}
 
-	nodeName, err := getNodeName()
-	if err != nil {
-		return err
-	}
-
 	if isKataInstalled {
 		// kata exist - mark completion if crio drop in file exists
 		if k.CRIODropinPath == "" {
 			k.CRIODropinPath = "/host/etc/crio/crio.conf.d/50-kata.conf"
 		}
 		if _, err := os.Stat(k.CRIODropinPath); err == nil {
-			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-				ks.InstallationStatus.Completed.CompletedNodesList = append(ks.InstallationStatus.Completed.CompletedNodesList, nodeName)
-				ks.InstallationStatus.Completed.CompletedNodesCount = len(ks.InstallationStatus.Completed.CompletedNodesList)
-				if ks.InstallationStatus.InProgress.InProgressNodesCount > 0 {
-					ks.InstallationStatus.InProgress.InProgressNodesCount--
-				}
-				for i, node := range ks.InstallationStatus.InProgress.BinariesInstalledNodesList {
-					if node == nodeName {
-						ks.InstallationStatus.InProgress.BinariesInstalledNodesList =
-							append(ks.InstallationStatus.InProgress.BinariesInstalledNodesList[:i],
-								ks.InstallationStatus.InProgress.BinariesInstalledNodesList[i+1:]...)
-						break
-					}
-				}
-			})
-
+			// an upgraded node already has the drop-in, it runs the new payload once rebooted
+			if staged, err := stagedDeployment(); err != nil || staged {
+				return err
+			}
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.Installed, nil, "")
 			if err != nil {
-				return fmt.Errorf("kata exists on the node, error updating kataconfig status %+v", err)
+				return fmt.Errorf("kata exists on the node, error reporting the progress %+v", err)
 			}
 		} else if os.IsNotExist(err) {
 			// Kata is installed but no crio drop in yet, we will wait.
@@ -141,43 +109,108 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 		}
 
 	} else {
-		// kata doesn't exist, install it.
-		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-			ks.InstallationStatus.InProgress.InProgressNodesCount++
-		})
+		// the operator already found that the node can't run kata
+		progress, err := getProgress(k.KataClient, kataConfigResourceName)
+		if err != nil {
+			return err
+		}
+		if progress.State == nodeprogress.InstallFailed && progress.Reason == nodeprogress.ReasonUnsupportedInstanceType {
+			return errors.New(progress.Error)
+		}
 
+		// kata doesn't exist, make sure the node can run it before installing anything
+		kataConfig, err := getKataConfig(k.KataClient, kataConfigResourceName)
 		if err != nil {
-			return fmt.Errorf("kata is not installed on the node, error updating kataconfig status %+v", err)
+			return err
 		}
 
-		err = k.KataBinaryInstaller(k)
+		if err := recordTEECapabilities(k.KataClient, kataConfig); err != nil {
+			log.Printf("Unable to record the TEE capabilities of the node: %+v", err)
+		}
+
+		if checkErr := checkNodeCapabilities(kataConfig); checkErr != nil {
+			var reason string
+			var virtErr *virtualizationDisabledError
+			var iommuErr *iommuDisabledError
+			var attestationErr *attestationUnavailableError
+			switch {
+			case errors.As(checkErr, &virtErr):
+				reason = nodeprogress.ReasonVirtualizationDisabled
+			case errors.As(checkErr, &iommuErr):
+				reason = nodeprogress.ReasonIOMMUDisabled
+			case errors.As(checkErr, &attestationErr):
+				reason = nodeprogress.ReasonAttestationUnavailable
+			}
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.InstallFailed, checkErr, reason)
+			if err != nil {
+				return fmt.Errorf("pre-flight checks failed, error reporting the progress %+v", err)
+			}
+			return checkErr
+		}
 
+		if err := recordCgroupVersion(k.KataClient, progress.Cgroup); err != nil {
+			return err
+		}
+
+		if kataConfig.Spec.SELinux != nil {
+			k.SELinuxShimMode = kataConfig.Spec.SELinux.ShimMode
+		}
+		if conf := kataConfig.Spec.Confidential; conf != nil && conf.Enabled {
+			k.GuestPull = conf.GuestPull
+		}
+
+		// the binaries changed since they were installed are not overwritten, the checksums
+		// are recorded again once the node is installed
+		if verifyErr := verifyArtifacts(k.KataClient, kataConfigResourceName); verifyErr != nil {
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.InstallFailed, verifyErr, "")
+			if err != nil {
+				return fmt.Errorf("kata binaries modified, error reporting the progress %+v", err)
+			}
+			return verifyErr
+		}
+		if err := clearArtifacts(k.KataClient); err != nil {
+			return err
+		}
+
+		// a node repaired after its binaries were removed already has the CRI-O drop-in, it is
+		// installed again as soon as the binaries are back. The installer chroots, check it before.
+		// An upgraded node has the drop-in too but waits for the reboot into the new payload
+		repairing := false
+		if _, err := os.Stat(hostRoot + crioDropinPath); err == nil {
+			repairing = !k.Upgrading
+		}
+
+		// kata doesn't exist, install it.
+		err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.Installing, nil, "")
 		if err != nil {
-			// kata installation failed. report it.
-			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-				ks.InstallationStatus.InProgress.InProgressNodesCount--
+			return fmt.Errorf("kata is not installed on the node, error reporting the progress %+v", err)
+		}
 
-				fn, err := getFailedNode(err)
-				if err != nil {
-					return
-				}
+		err = k.KataBinaryInstaller(k)
 
-				ks.InstallationStatus.Failed.FailedNodesList = append(ks.InstallationStatus.Failed.FailedNodesList, fn)
-				ks.InstallationStatus.Failed.FailedNodesCount = len(ks.InstallationStatus.Failed.FailedNodesList)
-			})
+		if err != nil {
+			// kata installation failed. report it.
+			var reason string
+			var fipsErr *fipsIncompatibleError
+			if errors.As(err, &fipsErr) {
+				reason = nodeprogress.ReasonFIPSIncompatible
+			}
 
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.InstallFailed, err, reason)
 			if err != nil {
-				return fmt.Errorf("kata installation failed, error updating kataconfig status %+v", err)
+				return fmt.Errorf("kata installation failed, error reporting the progress %+v", err)
 			}
 
+		} else if repairing {
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.Installed, nil, "")
+			if err != nil {
+				return fmt.Errorf("kata reinstalled, but error reporting the progress %+v", err)
+			}
 		} else {
 			// mark binaries installed
-			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-				ks.InstallationStatus.InProgress.BinariesInstalledNodesList = append(ks.InstallationStatus.InProgress.BinariesInstalledNodesList, nodeName)
-			})
-
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.BinariesInstalled, nil, "")
 			if err != nil {
-				return fmt.Errorf("kata installation succeeded, but error updating kataconfig status %+v", err)
+				return fmt.Errorf("kata installation succeeded, but error reporting the progress %+v", err)
 			}
 		}
 	}
@@ -185,6 +218,40 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 	return nil
 }
 
+// PrePull pulls the kata payload into the node cache, ahead of the installation
+func (k *KataOpenShift) PrePull(kataConfigResourceName string) error {
+	progress, err := getProgress(k.KataClient, kataConfigResourceName)
+	if err != nil {
+		return err
+	}
+
+	if progress.State == nodeprogress.BinariesInstalled || progress.State == nodeprogress.Installed {
+		log.Println("kata is already installed on the node, nothing to pre-pull")
+		return nil
+	}
+
+	k.PayloadTag, err = getClusterVersion()
+	if err != nil {
+		return err
+	}
+
+	fips, err := isFIPSEnabled()
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Chroot("/host"); err != nil {
+		log.Fatalf("Unable to chroot to %s: %s", "/host", err)
+	}
+
+	if err := syscall.Chdir("/"); err != nil {
+		log.Fatalf("Unable to chdir to %s: %s", "/", err)
+	}
+
+	_, err = pullPayload(context.Background(), payloadImageName(k, fips), fips)
+	return err
+}
+
 // Upgrade the kata binaries and configure the runtime on Openshift
 func (k *KataOpenShift) Upgrade() error {
 	return fmt.Errorf("Not Implemented Yet")
@@ -194,19 +261,9 @@ func (k *KataOpenShift) Upgrade() error {
 func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 	if k.KataUninstallChecker == nil {
 		k.KataUninstallChecker = func() (bool, bool, error) {
-
-			var (
-				isKataUnInstalled bool
-				isCrioDropInUnInstalled bool
-				err error
-				kataConfig kataTypes.KataConfig
-			)
-
-			err = k.KataClient.Get(context.Background(), client.ObjectKey{
-				Name: kataConfigResourceName,
-			}, &kataConfig)
+			kataConfig, err := getKataConfig(k.KataClient, kataConfigResourceName)
 			if err != nil {
-				return isKataUnInstalled, isCrioDropInUnInstalled, err
+				return false, false, err
 			}
 
 			// Storing it locally so that we can avoid one more call to API server further down
@@ -216,16 +273,18 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 
 			nodeName, err := getNodeName()
 			if err != nil {
-				return isKataUnInstalled, isCrioDropInUnInstalled, err
+				return false, false, err
 			}
 
-			for _, n := range kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList {
-				if n == nodeName {
-					isKataUnInstalled = true
-					break
-				}
+			progress, err := getProgress(k.KataClient, kataConfigResourceName)
+			if err != nil {
+				return false, false, err
 			}
+			isKataUnInstalled := progress.State == nodeprogress.BinariesUninstalled ||
+				progress.State == nodeprogress.UninstallFailed
 
+			// the operator completes the uninstallation once the machine config is removed
+			isCrioDropInUnInstalled := false
 			for _, n := range kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList {
 				if n == nodeName {
 					isCrioDropInUnInstalled = true
@@ -233,7 +292,7 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 				}
 			}
 
-			return isKataUnInstalled, isCrioDropInUnInstalled, err
+			return isKataUnInstalled, isCrioDropInUnInstalled, nil
 		}
 	}
 
@@ -246,19 +305,21 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 		return nil
 	}
 
-	nodeName, err := getNodeName()
-	if err != nil {
-		return err
-	}
-
 	if !isKataUnInstalled {
-		// Kata binaries need to be uninstalled
-		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-			ks.UnInstallationStatus.InProgress.InProgressNodesCount++
-		})
+		// the binaries changed since they were installed are not removed, it doesn't block
+		// the deletion of the KataConfig
+		if verifyErr := verifyArtifacts(k.KataClient, kataConfigResourceName); verifyErr != nil {
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.UninstallFailed, verifyErr, "")
+			if err != nil {
+				return fmt.Errorf("kata binaries modified, error reporting the progress %+v", err)
+			}
+			return nil
+		}
 
+		// Kata binaries need to be uninstalled
+		err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.Uninstalling, nil, "")
 		if err != nil {
-			return fmt.Errorf("kata is not installed on the node, error updating kataconfig status %+v", err)
+			return fmt.Errorf("kata is not installed on the node, error reporting the progress %+v", err)
 		}
 
 		if k.KataBinaryUnInstaller == nil {
@@ -268,31 +329,18 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 		err = k.KataBinaryUnInstaller(k)
 
 		if err != nil {
-			// kata uninstallation failed. report it.
-			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-				ks.UnInstallationStatus.InProgress.InProgressNodesCount--
-
-				fn, err := getFailedNode(err)
-				if err != nil {
-					return
-				}
-
-				ks.UnInstallationStatus.Failed.FailedNodesList = append(ks.UnInstallationStatus.Failed.FailedNodesList, fn)
-				ks.UnInstallationStatus.Failed.FailedNodesCount = len(ks.UnInstallationStatus.Failed.FailedNodesList)
-			})
-
+			// kata uninstallation failed. report it, it doesn't block the deletion of the KataConfig
+			err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.UninstallFailed, err, "")
 			if err != nil {
-				return fmt.Errorf("kata installation failed, error updating kataconfig status %+v", err)
+				return fmt.Errorf("kata uninstallation failed, error reporting the progress %+v", err)
 			}
-
+			return nil
 		}
-		// mark binaries uninstalled
-		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
-			ks.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList = append(ks.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList, nodeName)
-		})
 
+		// mark binaries uninstalled
+		err = reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.BinariesUninstalled, nil, "")
 		if err != nil {
-			return fmt.Errorf("kata uninstallation succeeded, but error updating kataconfig status %+v", err)
+			return fmt.Errorf("kata uninstallation succeeded, but error reporting the progress %+v", err)
 		}
 	}
 
@@ -344,7 +392,25 @@ func uninstallRPMs(k *KataOpenShift) error {
 		log.Println("cleanupHost failed")
 	}
 
-	cmd := exec.Command("rpm-ostree", "uninstall", "--idempotent", "--all") //FIXME not -a but kata-runtime, kata-osbuilder,...
+	uninstallSELinuxPolicy()
+
+	if err := os.RemoveAll(kataCCConfigDir); err != nil {
+		log.Println("removing the configuration of the confidential handler failed")
+	}
+	if err := os.Remove(kataNodeDropinPath); err != nil && !os.IsNotExist(err) {
+		log.Println("removing the kata node settings failed")
+	}
+
+	if err := os.RemoveAll(payloadCacheDir); err != nil {
+		log.Println("removing the payload cache failed")
+	}
+	if err := removePayloadTree(); err != nil {
+		log.Println("removing the payload tree failed")
+	}
+
+	// only the kata packages, the other packages layered onto the node were not installed by the daemon
+	packages := append(append([]string{}, kataPackages...), guestPullPackages...)
+	cmd := exec.Command("rpm-ostree", append([]string{"uninstall", "--idempotent"}, packages...)...)
 	err = doCmd(cmd)
 	if err != nil {
 		return err
@@ -357,54 +423,44 @@ func installRPMs(k *KataOpenShift) error {
 	fmt.Fprintf(os.Stderr, "%s\n", os.Getenv("PATH"))
 	log.SetOutput(os.Stdout)
 
-	cmd := exec.Command("mkdir", "-p", "/host/opt/kata-install")
-	err := doCmd(cmd)
+	fips, err := isFIPSEnabled()
 	if err != nil {
 		return err
 	}
 
-	if err := syscall.Chroot("/host"); err != nil {
-		log.Fatalf("Unable to chroot to %s: %s", "/host", err)
+	selinuxMode, err := getSELinuxMode()
+	if err != nil {
+		return err
 	}
 
-	if err := syscall.Chdir("/"); err != nil {
-		log.Fatalf("Unable to chdir to %s: %s", "/", err)
+	// Leftovers of an interrupted installation
+	cmd := exec.Command("/usr/bin/rm", "-rf", "/host/opt/kata-install", "/host/usr/local/kata")
+	if err := doCmd(cmd); err != nil {
+		return err
 	}
 
-	policy, err := signature.DefaultPolicy(nil)
-	if err != nil {
-		fmt.Println(err)
-	}
-	policyContext, err := signature.NewPolicyContext(policy)
+	cmd = exec.Command("mkdir", "-p", "/host/opt/kata-install")
+	err = doCmd(cmd)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
-	payloadImage := os.Getenv("KATA_PAYLOAD_IMAGE")
-	if payloadImage == "" {
-		payloadImage = "docker://quay.io/isolatedcontainers/kata-operator-payload:" + k.PayloadTag
-	} else {
-		log.Println("WARNING: kataconfig installation is tainted")
-		log.Println("Using env variable KATA_PAYLOAD_IMAGE " + payloadImage)
-		payloadImage = "docker://" + payloadImage
+	if err := syscall.Chroot("/host"); err != nil {
+		log.Fatalf("Unable to chroot to %s: %s", "/host", err)
 	}
 
-	srcRef, err := alltransports.ParseImageName(payloadImage)
-	if err != nil {
-		fmt.Println("Invalid source name of payload container image: " + payloadImage)
-		return err
+	if err := syscall.Chdir("/"); err != nil {
+		log.Fatalf("Unable to chdir to %s: %s", "/", err)
 	}
-	destRef, err := alltransports.ParseImageName("oci:/opt/kata-install/kata-image:latest")
+
+	refName, err := pullPayload(context.Background(), payloadImageName(k, fips), fips)
 	if err != nil {
-		fmt.Println("Invalid destination name")
 		return err
 	}
 
-	_, err = copy.Image(context.Background(), policyContext, destRef, srcRef, &copy.Options{})
-	err = image.CreateRuntimeBundleLayout("/opt/kata-install/kata-image/",
-		"/usr/local/kata", "latest", "linux", []string{"name=latest"})
-	if err != nil {
-		fmt.Println("error creating Runtime bundle layout in /usr/local/kata")
+	// only the files that changed since the previous payload are written
+	if err := extractPayload(context.Background(), refName); err != nil {
+		fmt.Println("error extracting the payload in " + payloadTreeDir)
 		return err
 	}
 
@@ -414,24 +470,37 @@ func installRPMs(k *KataOpenShift) error {
 		return err
 	}
 
-	cmd = exec.Command("/usr/bin/cp", "-f", "/usr/local/kata/latest/packages.repo",
+	cmd = exec.Command("/usr/bin/cp", "-f", payloadTreeDir+"/packages.repo",
 		"/etc/yum.repos.d/")
 	if err := doCmd(cmd); err != nil {
 		return err
 	}
 
-	cmd = exec.Command("/usr/bin/cp", "-a",
-		"/usr/local/kata/latest/packages", "/opt/kata-install/packages")
-	if err = doCmd(cmd); err != nil {
+	// the repository of packages.repo is served from the payload tree, not copied
+	if err := os.Symlink(payloadTreeDir+"/packages", "/opt/kata-install/packages"); err != nil {
 		return err
 	}
 
-	cmd = exec.Command("/bin/bash", "-c", "/usr/bin/rpm-ostree install --idempotent kata-runtime kata-osbuilder")
+	packages := k.packages()
+	cmd = exec.Command("rpm-ostree", append([]string{"install", "--idempotent"}, packages...)...)
+	if k.Upgrading {
+		// the packages are layered already, they are replaced by the ones of the new payload
+		// in a new deployment, booted once the operator updates the MachineConfig
+		args := append([]string{"uninstall", "--idempotent"}, packages...)
+		for _, pkg := range packages {
+			args = append(args, "--install", pkg)
+		}
+		cmd = exec.Command("rpm-ostree", args...)
+	}
 	err = doCmd(cmd)
 	if err != nil {
 		return err
 	}
 
+	if err = installSELinuxPolicy(k.SELinuxShimMode, selinuxMode); err != nil {
+		return err
+	}
+
 	err = cleanupHost()
 	if err != nil {
 		log.Println("cleanupHost failed")
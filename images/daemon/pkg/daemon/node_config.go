@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// kataNodeDropin is the kata drop-in holding the settings of the KataNodeConfigs selecting
+	// the node. It sorts after the 50-kata-operator.toml drop-in of the MachineConfig and takes
+	// precedence over it
+	kataNodeDropin = "60-kata-node.toml"
+
+	// kataNodeDropinPath is the drop-in of the default kata handler
+	kataNodeDropinPath = "/etc/kata-containers/config.d/" + kataNodeDropin
+
+	// nodeConfiguredMarker makes the node config pod ready, see the readiness probe of the daemonset
+	nodeConfiguredMarker = "/tmp/kata-node-configured"
+)
+
+// nodeConfigTemplate renders the merged KataNodeConfigs, kata reads it when it starts a sandbox
+const nodeConfigTemplate = `{{- if .}}[hypervisor.qemu]
+{{- if .DefaultMemory}}
+default_memory = {{.DefaultMemory}}
+{{- end}}
+{{- if .DefaultVCPUs}}
+default_vcpus = {{.DefaultVCPUs}}
+{{- end}}
+{{end}}`
+
+// mergeNodeConfigs returns the hypervisor settings of the KataNodeConfigs selecting the node, the
+// later ones overriding the earlier ones field by field. It returns nil when none sets any
+func mergeNodeConfigs(configs []kataTypes.KataNodeConfigSpec, nodeLabels map[string]string) (*kataTypes.KataHypervisorConfig, error) {
+	var merged *kataTypes.KataHypervisorConfig
+	for i := range configs {
+		selector, err := metav1.LabelSelectorAsSelector(&configs[i].NodeSelector)
+		if err != nil {
+			return nil, err
+		}
+		hypervisor := configs[i].Hypervisor
+		if hypervisor == nil || !selector.Matches(labels.Set(nodeLabels)) {
+			continue
+		}
+
+		if merged == nil {
+			merged = &kataTypes.KataHypervisorConfig{}
+		}
+		if hypervisor.DefaultMemory != 0 {
+			merged.DefaultMemory = hypervisor.DefaultMemory
+		}
+		if hypervisor.DefaultVCPUs != 0 {
+			merged.DefaultVCPUs = hypervisor.DefaultVCPUs
+		}
+	}
+	return merged, nil
+}
+
+// writeNodeDropin writes the drop-in, or removes it when empty, and tells whether it changed
+func writeNodeDropin(path string, dropin string) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if string(current) == dropin {
+		return false, nil
+	}
+
+	if dropin == "" {
+		log.Println("Removing " + path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		return true, nil
+	}
+	log.Println("Writing " + path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(path, []byte(dropin), 0644)
+}
+
+// ApplyNodeConfig merges the KataNodeConfigs passed by the operator that select the node into
+// a kata drop-in, next to the one of the KataConfig. The drop-in is also written for the
+// confidential handler when the node has its configuration. The kata pods started from then on
+// get the new settings
+func (k *KataOpenShift) ApplyNodeConfig() error {
+	var configs []kataTypes.KataNodeConfigSpec
+	if raw := os.Getenv("KATA_NODE_CONFIGS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			return err
+		}
+	}
+
+	nodeName, err := getNodeName()
+	if err != nil {
+		return err
+	}
+	node := &corev1.Node{}
+	if err := k.KataClient.Get(context.Background(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		return err
+	}
+
+	merged, err := mergeNodeConfigs(configs, node.GetLabels())
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	t := template.Must(template.New("nodeconfig").Parse(nodeConfigTemplate))
+	if err := t.Execute(buf, merged); err != nil {
+		return err
+	}
+
+	paths := []string{kataNodeDropinPath}
+	if _, err := os.Stat(filepath.Join(hostRoot, kataCCConfigDir)); err == nil {
+		paths = append(paths, filepath.Join(kataCCConfigDir, "config.d", kataNodeDropin))
+	}
+	changed := false
+	for _, path := range paths {
+		written, err := writeNodeDropin(filepath.Join(hostRoot, path), buf.String())
+		if err != nil {
+			return err
+		}
+		changed = changed || written
+	}
+	if !changed {
+		log.Println("kata node settings are up to date on the node")
+	}
+
+	return ioutil.WriteFile(nodeConfiguredMarker, nil, 0644)
+}
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// payloadCacheDir is the OCI layout, on the host, holding the payloads pulled on the node.
+	// It survives the installation so re-installs and upgrades only fetch the missing blobs
+	payloadCacheDir = "/var/cache/kata-operator/payloads"
+
+	defaultPayloadRepository = "quay.io/isolatedcontainers/kata-operator-payload"
+)
+
+// payloadImageName returns the payload image to install on the node, in the
+// transport:reference form expected by alltransports
+func payloadImageName(k *KataOpenShift, fips bool) string {
+	payloadImage := os.Getenv("KATA_PAYLOAD_IMAGE")
+	if payloadImage != "" {
+		log.Println("WARNING: kataconfig installation is tainted")
+		log.Println("Using env variable KATA_PAYLOAD_IMAGE " + payloadImage)
+		return "docker://" + payloadImage
+	}
+
+	if archPayloadImage := os.Getenv("KATA_ARCH_PAYLOAD_IMAGE"); archPayloadImage != "" {
+		log.Println("Using the " + runtime.GOARCH + " payload image from the KataConfig " + archPayloadImage)
+		return "docker://" + archPayloadImage
+	}
+
+	payloadImage = "docker://" + defaultPayloadRepository + ":" + k.PayloadTag
+	if fips {
+		payloadImage += fipsPayloadTagSuffix
+	}
+	return payloadImage
+}
+
+// payloadCacheRefName names a payload in the cache after the digest of its manifest and the
+// node architecture, so a moving tag pointing to a new payload is never served from the cache
+func payloadCacheRefName(ctx context.Context, srcRef types.ImageReference, sys *types.SystemContext) (string, error) {
+	src, err := srcRef.NewImageSource(ctx, sys)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	rawManifest, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	digest, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return "", err
+	}
+
+	return digest.Hex() + "-" + runtime.GOARCH, nil
+}
+
+// pullPayload makes sure the payload is present in the node-local cache and returns its
+// reference name in payloadCacheDir. It must be called once chrooted into the host.
+// Payloads already cached are not downloaded again, and the blobs shared with other
+// cached payloads are reused
+func pullPayload(ctx context.Context, payloadImage string, fips bool) (string, error) {
+	srcRef, err := alltransports.ParseImageName(payloadImage)
+	if err != nil {
+		log.Println("Invalid source name of payload container image: " + payloadImage)
+		return "", err
+	}
+
+	// Pick the payload matching the node architecture out of the multi-arch manifest,
+	// e.g. the ppc64le build on Power nodes
+	sourceCtx := &types.SystemContext{
+		ArchitectureChoice: runtime.GOARCH,
+		OSChoice:           "linux",
+	}
+
+	if fips {
+		log.Println("Node runs in FIPS mode, validating the payload image " + payloadImage)
+		if err := checkFIPSCompliance(ctx, srcRef, sourceCtx); err != nil {
+			return "", err
+		}
+	}
+
+	refName, err := payloadCacheRefName(ctx, srcRef, sourceCtx)
+	if err != nil {
+		return "", err
+	}
+
+	destRef, err := alltransports.ParseImageName("oci:" + payloadCacheDir + ":" + refName)
+	if err != nil {
+		log.Println("Invalid destination name")
+		return "", err
+	}
+
+	if cached, err := destRef.NewImageSource(ctx, nil); err == nil {
+		cached.Close()
+		log.Println("Payload " + payloadImage + " found in the node cache as " + refName)
+		return refName, nil
+	}
+
+	if err := os.MkdirAll(payloadCacheDir, 0700); err != nil {
+		return "", err
+	}
+
+	policy, err := signature.DefaultPolicy(nil)
+	if err != nil {
+		return "", err
+	}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return "", err
+	}
+	defer policyContext.Destroy()
+
+	log.Println("Pulling payload " + payloadImage + " into " + transports.ImageName(destRef))
+	_, err = copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{
+		SourceCtx: sourceCtx,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refName, nil
+}
@@ -0,0 +1,324 @@
+package daemon
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// payloadTreeDir is the payload extracted on the host. It survives the installation, the
+	// next payload only rewrites the files that changed
+	payloadTreeDir = "/var/cache/kata-operator/tree"
+
+	// payloadTreeIndex records the content of payloadTreeDir, by path
+	payloadTreeIndex = "/var/cache/kata-operator/tree.json"
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// treeEntry is a file of the payload tree. Digest addresses its content: the SHA256 of a
+// regular file, the target of a link
+type treeEntry struct {
+	Type   byte        `json:"type"`
+	Mode   os.FileMode `json:"mode"`
+	Digest string      `json:"digest"`
+
+	// layer is the last layer defining the entry, the one it is extracted from
+	layer int
+}
+
+// payloadLayers returns the layers of a payload of the cache, the lowest first
+func payloadLayers(ctx context.Context, refName string) (types.ImageSource, []types.BlobInfo, error) {
+	ref, err := alltransports.ParseImageName("oci:" + payloadCacheDir + ":" + refName)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := ref.NewImageSource(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	m, err := manifest.FromBlob(rawManifest, mimeType)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+
+	var layers []types.BlobInfo
+	for _, layer := range m.LayerInfos() {
+		layers = append(layers, layer.BlobInfo)
+	}
+	return src, layers, nil
+}
+
+// walkLayer calls fn on every entry of a layer, with its cleaned absolute path
+func walkLayer(ctx context.Context, src types.ImageSource, layer types.BlobInfo, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	blob, _, err := src.GetBlob(ctx, layer, none.NoCache)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	stream, _, err := compression.AutoDecompress(blob)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(path.Clean("/"+hdr.Name), hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// payloadTree computes the content of the payload tree out of the layers, whiteouts applied,
+// without writing anything
+func payloadTree(ctx context.Context, src types.ImageSource, layers []types.BlobInfo) (map[string]treeEntry, error) {
+	tree := map[string]treeEntry{}
+	for i, layer := range layers {
+		err := walkLayer(ctx, src, layer, func(name string, hdr *tar.Header, r io.Reader) error {
+			dir, base := path.Split(name)
+			if base == whiteoutOpaque {
+				removeTree(tree, path.Clean(dir), false)
+				return nil
+			}
+			if strings.HasPrefix(base, whiteoutPrefix) {
+				removeTree(tree, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), true)
+				return nil
+			}
+
+			entry := treeEntry{Type: hdr.Typeflag, Mode: os.FileMode(hdr.Mode).Perm(), layer: i}
+			switch hdr.Typeflag {
+			case tar.TypeReg, tar.TypeRegA:
+				entry.Type = tar.TypeReg
+				h := sha256.New()
+				if _, err := io.Copy(h, r); err != nil {
+					return err
+				}
+				entry.Digest = hex.EncodeToString(h.Sum(nil))
+			case tar.TypeSymlink:
+				entry.Digest = hdr.Linkname
+			case tar.TypeLink:
+				target := path.Clean("/" + hdr.Linkname)
+				entry.Digest = target + "@" + tree[target].Digest
+			case tar.TypeDir:
+			default:
+				// devices and fifos have no place in a payload
+				return nil
+			}
+			tree[name] = entry
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// removeTree removes the content of a path of the tree and, withRoot, the path itself
+func removeTree(tree map[string]treeEntry, root string, withRoot bool) {
+	for name := range tree {
+		if (withRoot && name == root) || strings.HasPrefix(name, root+"/") {
+			delete(tree, name)
+		}
+	}
+}
+
+// readTreeIndex returns the content of the payload tree recorded by the previous extraction
+func readTreeIndex() (map[string]treeEntry, error) {
+	index := map[string]treeEntry{}
+	content, err := ioutil.ReadFile(payloadTreeIndex)
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		// a corrupted index only costs a full extraction
+		log.Printf("Ignoring the corrupted payload tree index: %v", err)
+		return map[string]treeEntry{}, nil
+	}
+	return index, nil
+}
+
+// extractPayload updates payloadTreeDir to the content of a payload of the cache. The files
+// are compared with the index of the previous extraction by content digest, only the changed
+// ones are written and the ones gone are removed. It must be called once chrooted into the host
+func extractPayload(ctx context.Context, refName string) error {
+	src, layers, err := payloadLayers(ctx, refName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tree, err := payloadTree(ctx, src, layers)
+	if err != nil {
+		return err
+	}
+	index, err := readTreeIndex()
+	if err != nil {
+		return err
+	}
+
+	changed := map[string]bool{}
+	changedLayers := map[int]bool{}
+	for name, entry := range tree {
+		previous, ok := index[name]
+		if ok && previous.Type == entry.Type && previous.Mode == entry.Mode && previous.Digest == entry.Digest {
+			if _, err := os.Lstat(filepath.Join(payloadTreeDir, name)); err == nil {
+				continue
+			}
+		}
+		changed[name] = true
+		changedLayers[entry.layer] = true
+	}
+
+	// the index goes first, an interrupted extraction is redone in full
+	if err := os.Remove(payloadTreeIndex); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var removed int
+	for name := range index {
+		if _, ok := tree[name]; ok {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(payloadTreeDir, name)); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	for i, layer := range layers {
+		if !changedLayers[i] {
+			continue
+		}
+		err := walkLayer(ctx, src, layer, func(name string, hdr *tar.Header, r io.Reader) error {
+			entry, ok := tree[name]
+			if !ok || !changed[name] || entry.layer != i {
+				return nil
+			}
+			return writeTreeEntry(name, entry, hdr, r)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	content, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(payloadTreeIndex, content, 0600); err != nil {
+		return err
+	}
+	log.Printf("Payload %s extracted: %d files written, %d removed, %d unchanged", refName,
+		len(changed), removed, len(tree)-len(changed))
+	return nil
+}
+
+// checkTreePath makes sure no parent of a path of the tree is a symlink, the payload must not
+// write outside of payloadTreeDir
+func checkTreePath(name string) error {
+	dir := payloadTreeDir
+	for _, component := range strings.Split(path.Dir(name), "/") {
+		if component == "" {
+			continue
+		}
+		dir = filepath.Join(dir, component)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("payload path %s goes through the symlink %s", name, dir)
+		}
+	}
+	return nil
+}
+
+// writeTreeEntry writes an entry of a layer into payloadTreeDir, replacing what was there
+func writeTreeEntry(name string, entry treeEntry, hdr *tar.Header, r io.Reader) error {
+	if err := checkTreePath(name); err != nil {
+		return err
+	}
+	dest := filepath.Join(payloadTreeDir, name)
+	if entry.Type == tar.TypeDir {
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+		return os.Chmod(dest, entry.Mode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+
+	switch entry.Type {
+	case tar.TypeSymlink:
+		return os.Symlink(hdr.Linkname, dest)
+	case tar.TypeLink:
+		return os.Link(filepath.Join(payloadTreeDir, path.Clean("/"+hdr.Linkname)), dest)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, entry.Mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dest, entry.Mode)
+}
+
+// removePayloadTree removes the extracted payload and its index
+func removePayloadTree() error {
+	if err := os.RemoveAll(payloadTreeDir); err != nil {
+		return err
+	}
+	if err := os.Remove(payloadTreeIndex); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove the payload tree index: %v", err)
+	}
+	return nil
+}
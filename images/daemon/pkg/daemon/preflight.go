@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+)
+
+// ultravisorDeviceTreePath is only present when the Power firmware runs the
+// ultravisor needed by the Protected Execution Facility
+const ultravisorDeviceTreePath = "/host/proc/device-tree/ibm,ultravisor"
+
+// protVirtHostPath is set once the s390x ultravisor runs the Secure Execution guests, with the
+// prot_virt=1 kernel argument
+const protVirtHostPath = "/sys/firmware/uv/prot_virt_host"
+
+const (
+	// dmiVendorPath is the vendor of the system, VMware for the vSphere VMs
+	dmiVendorPath = "/sys/class/dmi/id/sys_vendor"
+
+	cpuinfoPath = "/proc/cpuinfo"
+
+	// iommuGroupsPath lists the IOMMU groups of the node, none while its IOMMU is disabled
+	iommuGroupsPath = "/sys/kernel/iommu_groups"
+
+	attestationDialTimeout = 10 * time.Second
+)
+
+// virtualizationDisabledError is returned when the VM of the node doesn't expose the hardware
+// assisted virtualization
+type virtualizationDisabledError struct {
+	platform string
+}
+
+func (e *virtualizationDisabledError) Error() string {
+	return fmt.Sprintf("the %s VM of the node doesn't expose the hardware assisted virtualization, no vmx or svm CPU flag: "+
+		"enable \"Expose hardware assisted virtualization to the guest OS\" (VHV) in the CPU settings of the VM", e.platform)
+}
+
+// iommuDisabledError is returned when the device passthrough is requested on a node without
+// IOMMU
+type iommuDisabledError struct{}
+
+func (e *iommuDisabledError) Error() string {
+	return "the IOMMU of the node is disabled, no IOMMU group in " + iommuGroupsPath + ": enable it in the firmware " +
+		"and with the intel_iommu=on kernel argument on Intel nodes, or set devicePassthrough.kernelArguments"
+}
+
+// attestationUnavailableError is returned when the node can't reach a service the confidential
+// guests are attested with: the key broker service, or the quote generation service of TDX
+type attestationUnavailableError struct {
+	service string
+	url     string
+	err     error
+}
+
+func (e *attestationUnavailableError) Error() string {
+	return fmt.Sprintf("the %s %s is unreachable from the node, the confidential guests can't be attested: %v", e.service, e.url, e.err)
+}
+
+// checkNodeCapabilities verifies the node is able to run the kata sandboxes
+// requested by the KataConfig before anything gets installed on it
+func checkNodeCapabilities(kataConfig *kataTypes.KataConfig) error {
+	if err := checkSELinuxSupport(); err != nil {
+		return err
+	}
+
+	if err := checkVSphereVirtualization(); err != nil {
+		return err
+	}
+
+	// the kernel arguments set by the operator only apply once the node reboots
+	if passthrough := kataConfig.Spec.DevicePassthrough; passthrough != nil &&
+		!(passthrough.KernelArguments && runtime.GOARCH == "amd64") {
+		if err := checkIOMMU(); err != nil {
+			return err
+		}
+	}
+
+	conf := kataConfig.Spec.Confidential
+	if conf == nil || !conf.Enabled {
+		return nil
+	}
+
+	switch conf.TEE {
+	case kataTypes.TEEPEF:
+		if err := checkPEFSupport(); err != nil {
+			return err
+		}
+	case kataTypes.TEESNP:
+		if err := checkSNPSupport(); err != nil {
+			return err
+		}
+	case kataTypes.TEETDX:
+		if err := checkTDXSupport(); err != nil {
+			return err
+		}
+		if q := conf.QuoteGeneration; q != nil {
+			if err := checkQuoteGeneration(q); err != nil {
+				return err
+			}
+		}
+	case kataTypes.TEESE:
+		if err := checkSESupport(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported trusted execution environment %q", conf.TEE)
+	}
+
+	if conf.KBSURL != "" {
+		return checkConnectivity("key broker service", conf.KBSURL)
+	}
+	return nil
+}
+
+func checkPEFSupport() error {
+	if runtime.GOARCH != "ppc64le" {
+		return fmt.Errorf("Protected Execution Facility requires a ppc64le node, this node is %s", runtime.GOARCH)
+	}
+
+	if _, err := os.Stat(ultravisorDeviceTreePath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Protected Execution Facility is not enabled in the node firmware, %s not found", ultravisorDeviceTreePath)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkSESupport verifies that the ultravisor of the node runs the Secure Execution guests
+func checkSESupport() error {
+	if runtime.GOARCH != "s390x" {
+		return fmt.Errorf("Secure Execution requires an s390x node, this node is %s", runtime.GOARCH)
+	}
+
+	enabled, err := ioutil.ReadFile(protVirtHostPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("the node doesn't support Secure Execution, %s not found", protVirtHostPath)
+	} else if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(enabled)) != "1" {
+		return fmt.Errorf("Secure Execution is disabled on the node, enable it with the prot_virt=1 kernel argument")
+	}
+	return nil
+}
+
+// checkVSphereVirtualization verifies that a node running in a vSphere VM is exposed the hardware
+// assisted virtualization (VHV), without which no kata sandbox starts. The other nodes are not
+// checked
+func checkVSphereVirtualization() error {
+	vendor, err := ioutil.ReadFile(dmiVendorPath)
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(string(vendor)), "VMware") {
+		return nil
+	}
+
+	cpuinfo, err := ioutil.ReadFile(cpuinfoPath)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(cpuinfo), "\n") {
+		if !strings.HasPrefix(line, "flags") {
+			continue
+		}
+		for _, flag := range strings.Fields(line) {
+			if flag == "vmx" || flag == "svm" {
+				return nil
+			}
+		}
+	}
+	return &virtualizationDisabledError{platform: "vSphere"}
+}
+
+// checkIOMMU verifies that the IOMMU of the node is enabled, the VFIO devices can't be assigned
+// to the guests otherwise
+func checkIOMMU() error {
+	groups, err := ioutil.ReadDir(iommuGroupsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(groups) == 0 {
+		return &iommuDisabledError{}
+	}
+	return nil
+}
+
+// checkConnectivity verifies that the node reaches a service the guests are attested with, the
+// guests share the network of their node to get attested
+func checkConnectivity(service, serviceURL string) error {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return &attestationUnavailableError{service: service, url: serviceURL, err: err}
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), attestationDialTimeout)
+	if err != nil {
+		return &attestationUnavailableError{service: service, url: serviceURL, err: err}
+	}
+	return conn.Close()
+}
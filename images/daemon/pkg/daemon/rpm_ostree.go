@@ -0,0 +1,29 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// rpmOstreeStatus is the part of `rpm-ostree status --json` the daemon looks at
+type rpmOstreeStatus struct {
+	Deployments []struct {
+		Booted bool `json:"booted"`
+	} `json:"deployments"`
+}
+
+// stagedDeployment tells whether the host has a deployment waiting for a reboot, e.g. the one
+// the packages of an upgraded payload were layered in. The default deployment comes first
+func stagedDeployment() (bool, error) {
+	out, err := exec.Command("chroot", hostRoot, "rpm-ostree", "status", "--json").Output()
+	if err != nil {
+		return false, fmt.Errorf("unable to get the rpm-ostree status: %v", err)
+	}
+
+	status := rpmOstreeStatus{}
+	if err := json.Unmarshal(out, &status); err != nil {
+		return false, err
+	}
+	return len(status.Deployments) > 0 && !status.Deployments[0].Booted, nil
+}
@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+)
+
+const (
+	selinuxEnforcePath = "/host/sys/fs/selinux/enforce"
+	hostSemodulePath   = "/host/usr/sbin/semodule"
+
+	// kataSELinuxPolicy is shipped by the payload, the path is relative to the chroot into the host
+	kataSELinuxPolicy     = "/usr/local/kata/latest/selinux/kata.pp"
+	kataSELinuxModule     = "kata"
+	kataSELinuxShimDomain = "container_kvm_t"
+
+	selinuxModeDisabled = "disabled"
+)
+
+// getSELinuxMode returns the SELinux mode of the node: enforcing, permissive or disabled
+func getSELinuxMode() (string, error) {
+	content, err := ioutil.ReadFile(selinuxEnforcePath)
+	if os.IsNotExist(err) {
+		return selinuxModeDisabled, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(string(content)) == "1" {
+		return string(kataTypes.SELinuxEnforcing), nil
+	}
+	return string(kataTypes.SELinuxPermissive), nil
+}
+
+// checkSELinuxSupport makes sure the kata policy can be loaded on nodes running SELinux
+func checkSELinuxSupport() error {
+	mode, err := getSELinuxMode()
+	if err != nil {
+		return err
+	}
+	log.Println("Node SELinux mode: " + mode)
+
+	if mode == selinuxModeDisabled {
+		return nil
+	}
+
+	if _, err := os.Stat(hostSemodulePath); err != nil {
+		return fmt.Errorf("SELinux is %s on the node but %s is not available to load the kata policy: %v", mode, hostSemodulePath, err)
+	}
+	return nil
+}
+
+// installSELinuxPolicy loads the kata policy module from the payload and applies the
+// requested mode to the shim domain. It must be called after chrooting into the host
+func installSELinuxPolicy(shimMode kataTypes.SELinuxMode, selinuxMode string) error {
+	if selinuxMode == selinuxModeDisabled {
+		log.Println("SELinux is disabled on the node, skipping the kata policy")
+		return nil
+	}
+
+	if _, err := os.Stat(kataSELinuxPolicy); err != nil {
+		if os.IsNotExist(err) && selinuxMode == string(kataTypes.SELinuxEnforcing) {
+			return fmt.Errorf("the payload doesn't ship the kata SELinux policy, sandboxes would fail with AVC denials on this enforcing node")
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		log.Println("No kata SELinux policy in the payload, skipping it")
+	} else {
+		if err := doCmd(exec.Command("semodule", "-i", kataSELinuxPolicy)); err != nil {
+			return err
+		}
+	}
+
+	if shimMode == kataTypes.SELinuxPermissive {
+		return doCmd(exec.Command("semanage", "permissive", "-a", kataSELinuxShimDomain))
+	}
+
+	// The domain may not be permissive, nothing to undo then
+	if err := doCmd(exec.Command("semanage", "permissive", "-d", kataSELinuxShimDomain)); err != nil {
+		log.Println("kata shim domain was not permissive")
+	}
+	return nil
+}
+
+// uninstallSELinuxPolicy removes what installSELinuxPolicy added. It must be called after
+// chrooting into the host
+func uninstallSELinuxPolicy() {
+	if err := doCmd(exec.Command("semanage", "permissive", "-d", kataSELinuxShimDomain)); err != nil {
+		log.Println("kata shim domain was not permissive")
+	}
+	if err := doCmd(exec.Command("semodule", "-r", kataSELinuxModule)); err != nil {
+		log.Println("kata SELinux module was not installed")
+	}
+}
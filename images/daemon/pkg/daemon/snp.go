@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kvmAMDSNPParameter is set by kvm_amd once it runs the SEV-SNP guests
+	kvmAMDSNPParameter = "/sys/module/kvm_amd/parameters/sev_snp"
+
+	// miscCapacityPath is the capacity of the misc cgroup controller, with the encryption
+	// ASIDs of the processor. The SEV-SNP guests take the ASIDs of the SEV-ES ones
+	miscCapacityPath = "/sys/fs/cgroup/misc.capacity"
+
+	// minSNPFirmwareMajor and minSNPFirmwareMinor are the first SEV firmware API version
+	// supporting the SEV-SNP guests kata starts
+	minSNPFirmwareMajor = 1
+	minSNPFirmwareMinor = 51
+)
+
+// snpFirmwareLog is logged by the ccp driver once it initialized the SEV-SNP firmware
+var snpFirmwareLog = regexp.MustCompile(`SEV-SNP API:(\d+)\.(\d+)`)
+
+// probeSNP returns the SEV-SNP capabilities of the node
+func probeSNP() nodeprogress.TEECapabilities {
+	capabilities := nodeprogress.TEECapabilities{}
+
+	if enabled, err := ioutil.ReadFile(kvmAMDSNPParameter); err == nil {
+		value := strings.TrimSpace(string(enabled))
+		capabilities.SNP = value == "Y" || value == "1"
+	}
+
+	out, err := exec.Command("chroot", hostRoot, "journalctl", "-k", "-b", "-o", "cat", "--no-pager", "--grep", "SEV-SNP API").Output()
+	if err == nil {
+		if match := snpFirmwareLog.FindSubmatch(out); match != nil {
+			capabilities.Firmware = string(match[1]) + "." + string(match[2])
+		}
+	}
+
+	if capacity, err := ioutil.ReadFile(miscCapacityPath); err == nil {
+		for _, line := range strings.Split(string(capacity), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "sev_es" {
+				if asids, err := strconv.ParseInt(fields[1], 10, 32); err == nil {
+					capabilities.MaxGuests = int32(asids)
+				}
+			}
+		}
+	}
+	return capabilities
+}
+
+// recordTEECapabilities records the SEV-SNP capabilities of the node when the KataConfig asks
+// for SEV-SNP guests, the operator publishes them for the capacity planning
+func recordTEECapabilities(kataClient client.Client, kataConfig *kataTypes.KataConfig) error {
+	conf := kataConfig.Spec.Confidential
+	if conf == nil || !conf.Enabled || conf.TEE != kataTypes.TEESNP || runtime.GOARCH != "amd64" {
+		return nil
+	}
+
+	capabilities := probeSNP()
+	log.Printf("SEV-SNP capabilities of the node: %+v", capabilities)
+	patch, err := nodeprogress.TEEPatch(capabilities)
+	if err != nil {
+		return err
+	}
+	return patchNode(kataClient, patch)
+}
+
+// checkSNPSupport verifies that the node runs the SEV-SNP guests: kvm_amd has SEV-SNP enabled,
+// the SEV firmware is recent enough and the processor has encryption ASIDs left for them
+func checkSNPSupport() error {
+	if runtime.GOARCH != "amd64" {
+		return fmt.Errorf("SEV-SNP requires an x86_64 node, this node is %s", runtime.GOARCH)
+	}
+
+	if _, err := os.Stat(kvmAMDSNPParameter); os.IsNotExist(err) {
+		return fmt.Errorf("kvm_amd doesn't support SEV-SNP on the node, %s not found", kvmAMDSNPParameter)
+	}
+	capabilities := probeSNP()
+	if !capabilities.SNP {
+		return fmt.Errorf("SEV-SNP is disabled in kvm_amd, enable it in the firmware and with the kvm_amd.sev_snp=1 kernel argument")
+	}
+
+	if capabilities.Firmware == "" {
+		return fmt.Errorf("the SEV-SNP firmware is not initialized, no SEV-SNP API version in the kernel log")
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(capabilities.Firmware, "%d.%d", &major, &minor); err != nil {
+		return err
+	}
+	if major < minSNPFirmwareMajor || (major == minSNPFirmwareMajor && minor < minSNPFirmwareMinor) {
+		return fmt.Errorf("the SEV firmware API %s of the node is older than %d.%d, the first supporting the SEV-SNP guests",
+			capabilities.Firmware, minSNPFirmwareMajor, minSNPFirmwareMinor)
+	}
+
+	// the capacity is unknown without the misc cgroup controller
+	if _, err := os.Stat(miscCapacityPath); err == nil && capabilities.MaxGuests == 0 {
+		return fmt.Errorf("the processor of the node has no SEV-ES ASID for the SEV-SNP guests, raise the SEV-ES ASID limit in the firmware")
+	}
+	return nil
+}
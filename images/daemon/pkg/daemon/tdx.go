@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	kataTypes "github.com/openshift/kata-operator/api/v1"
+)
+
+const (
+	// kvmIntelTDXParameter is set by kvm_intel once it runs the TDX guests
+	kvmIntelTDXParameter = "/sys/module/kvm_intel/parameters/tdx"
+
+	// qgsUnit is the quote generation service of the host, when the operator doesn't run it
+	qgsUnit = "qgsd.service"
+)
+
+// checkTDXSupport verifies that kvm_intel runs the TDX guests on the node
+func checkTDXSupport() error {
+	if runtime.GOARCH != "amd64" {
+		return fmt.Errorf("TDX requires an x86_64 node, this node is %s", runtime.GOARCH)
+	}
+
+	enabled, err := ioutil.ReadFile(kvmIntelTDXParameter)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("kvm_intel doesn't support TDX on the node, %s not found", kvmIntelTDXParameter)
+	} else if err != nil {
+		return err
+	}
+	if value := strings.TrimSpace(string(enabled)); value != "Y" && value != "1" {
+		return fmt.Errorf("TDX is disabled in kvm_intel, enable it in the firmware and with the kvm_intel.tdx=1 kernel argument")
+	}
+	return nil
+}
+
+// checkQuoteGeneration verifies that the TDX guests of the node can get their quotes: the host
+// runs the QGS unless the operator does, and the node reaches the PCCS the QGS gets the
+// certificates of the platform from
+func checkQuoteGeneration(q *kataTypes.KataQuoteGenerationConfig) error {
+	if q.Image == "" {
+		if err := exec.Command("chroot", hostRoot, "systemctl", "is-active", "--quiet", qgsUnit).Run(); err != nil {
+			return &attestationUnavailableError{service: "quote generation service", url: qgsUnit,
+				err: fmt.Errorf("not running on the host: %v", err)}
+		}
+	}
+	return checkConnectivity("PCCS", q.PCCSURL)
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+)
+
+const (
+	// hostRoot is where the host root filesystem is mounted in the daemon pod
+	hostRoot = "/host"
+
+	hostCrioDropinDir = "/etc/crio/crio.conf.d"
+
+	// kataCCConfigDir holds the kata configuration of the confidential handler, copied from the
+	// default configuration on boot
+	kataCCConfigDir = "/etc/kata-containers/cc"
+)
+
+// kataLeftoverPaths are the host paths that must be gone once kata is uninstalled
+var kataLeftoverPaths = []string{
+	"/etc/crio/crio.conf.d/50-kata.conf",
+	"/etc/crio/crio.conf.d/51-kata-reloadable.conf",
+	"/usr/bin/containerd-shim-kata-v2",
+	"/usr/bin/kata-runtime",
+	"/opt/kata-install",
+	"/usr/local/kata",
+	kataCCConfigDir,
+	kataNodeDropinPath,
+	payloadCacheDir,
+	payloadTreeDir,
+	payloadTreeIndex,
+}
+
+// findKataLeftovers returns the kata files left on the host and the CRI-O drop-ins still
+// defining a kata handler, whatever its name
+func findKataLeftovers() ([]string, error) {
+	var leftovers []string
+	for _, path := range kataLeftoverPaths {
+		if _, err := os.Stat(filepath.Join(hostRoot, path)); err == nil {
+			leftovers = append(leftovers, path)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	dropins, err := ioutil.ReadDir(filepath.Join(hostRoot, hostCrioDropinDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, dropin := range dropins {
+		path := filepath.Join(hostCrioDropinDir, dropin.Name())
+		if dropin.IsDir() || contains(leftovers, path) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(hostRoot, path))
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(string(content), "containerd-shim-kata-v2") {
+			leftovers = append(leftovers, path+" (kata handler)")
+		}
+	}
+	return leftovers, nil
+}
+
+// VerifyUninstall checks that kata is gone from the uninstalled node and reports the leftovers
+func (k *KataOpenShift) VerifyUninstall(kataConfigResourceName string) error {
+	leftovers, err := findKataLeftovers()
+	if err != nil {
+		return err
+	}
+
+	if len(leftovers) == 0 {
+		log.Println("No kata leftover found on the node")
+		return reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.UninstallVerified, nil, "")
+	}
+
+	log.Println("Kata leftovers found on the node: " + strings.Join(leftovers, ", "))
+	return reportProgress(k.KataClient, kataConfigResourceName, nodeprogress.UninstallLeftovers,
+		fmt.Errorf("leftovers found: %s", strings.Join(leftovers, ", ")), "")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
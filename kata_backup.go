@@ -0,0 +1,159 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/controllers"
+	"github.com/openshift/kata-operator/pkg/katabackup"
+)
+
+const (
+	// exportKataStateCommand is the sub-command writing the backup of the kata installation
+	exportKataStateCommand = "export-kata-state"
+
+	// restoreKataStateCommand is the sub-command restoring a backup
+	restoreKataStateCommand = "restore-kata-state"
+)
+
+// newCLIClient returns a client of the cluster of the kubeconfig, not backed by a cache
+func newCLIClient() (client.Client, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// exportKataState writes the backup of the KataConfig, its rendered configuration and the
+// state of the nodes
+func exportKataState(args []string, _ io.Reader, stdout io.Writer) error {
+	var name string
+	flags := flag.NewFlagSet(exportKataStateCommand, flag.ContinueOnError)
+	flags.StringVar(&name, "name", "example-kataconfig", "The name of the KataConfig.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := newCLIClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, kataConfig); err != nil {
+		return err
+	}
+	renderedConfig := &corev1.ConfigMap{}
+	if err := c.Get(ctx, controllers.RenderedConfigMap(), renderedConfig); errors.IsNotFound(err) {
+		renderedConfig = nil
+	} else if err != nil {
+		return err
+	}
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(katabackup.New(kataConfig, renderedConfig, nodes.Items))
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(out)
+	return err
+}
+
+// restoreKataState creates the KataConfig of a backup back and re-adopts the nodes whose
+// installation is intact. The KataConfig must not exist
+func restoreKataState(args []string, stdin io.Reader, stdout io.Writer) error {
+	var file string
+	flags := flag.NewFlagSet(restoreKataStateCommand, flag.ContinueOnError)
+	flags.StringVar(&file, "f", "-", "The backup file written by "+exportKataStateCommand+", - for the standard input.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var raw []byte
+	var err error
+	if file == "-" {
+		raw, err = ioutil.ReadAll(stdin)
+	} else {
+		raw, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	backup := &katabackup.Backup{}
+	if err := yaml.UnmarshalStrict(raw, backup); err != nil {
+		return fmt.Errorf("unable to read the backup: %v", err)
+	}
+	if err := backup.Validate(); err != nil {
+		return err
+	}
+
+	c, err := newCLIClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	err = c.Get(ctx, types.NamespacedName{Name: backup.Name}, &kataconfigurationv1.KataConfig{})
+	if err == nil {
+		return fmt.Errorf("kataconfig %s exists, there is nothing to restore", backup.Name)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	// the nodes are adopted first, the operator finds them installed when it sees the KataConfig
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return err
+	}
+	adoptions, err := backup.Adoptions(nodes.Items)
+	if err != nil {
+		return err
+	}
+	for _, adoption := range adoptions {
+		node := &corev1.Node{}
+		node.Name = adoption.Node
+		for _, patch := range adoption.Patches {
+			if err := c.Patch(ctx, node, client.RawPatch(types.MergePatchType, patch)); err != nil {
+				return fmt.Errorf("unable to adopt node %s: %v", adoption.Node, err)
+			}
+		}
+		fmt.Fprintf(stdout, "node %s adopted\n", adoption.Node)
+	}
+
+	if err := c.Create(ctx, backup.KataConfig()); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "kataconfig %s restored from the backup of %s, %d of %d nodes adopted\n",
+		backup.Name, backup.Time.UTC().Format("2006-01-02 15:04:05"), len(adoptions), len(backup.Nodes))
+	return nil
+}
@@ -19,18 +19,29 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
+	securityv1 "github.com/openshift/api/security/v1"
 	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	nodeapi "k8s.io/kubernetes/pkg/apis/node/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	kataconfigurationv2 "github.com/openshift/kata-operator/api/v2"
 	"github.com/openshift/kata-operator/controllers"
+	"github.com/openshift/kata-operator/pkg/operatorconfig"
+	"github.com/openshift/kata-operator/webhooks"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -43,35 +54,127 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(nodeapi.AddToScheme(scheme))
+	utilruntime.Must(securityv1.Install(scheme))
+	utilruntime.Must(configv1.Install(scheme))
 
 	utilruntime.Must(mcfgapi.Install(scheme))
 
 	utilruntime.Must(kataconfigurationv1.AddToScheme(scheme))
+	utilruntime.Must(kataconfigurationv2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
+	runSubCommand()
+
 	var metricsAddr string
 	var enableLeaderElection bool
+	var leaderElectionNamespace string
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
+	var intervals controllers.Intervals
+	var probeAddr string
+	var failureThreshold time.Duration
+	var diagnostics controllers.Diagnostics
+	var configFile string
+	var scopedCache bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"Namespace of the leader election lock. Defaults to the namespace the operator runs in.")
+	flag.DurationVar(&leaseDuration, "leader-election-lease-duration", 15*time.Second,
+		"Duration non-leader replicas wait before trying to acquire the leadership.")
+	flag.DurationVar(&renewDeadline, "leader-election-renew-deadline", 10*time.Second,
+		"Duration the leader retries refreshing the leadership before giving it up.")
+	flag.DurationVar(&retryPeriod, "leader-election-retry-period", 2*time.Second,
+		"Duration the replicas wait between tries of the leader election actions.")
+	flag.DurationVar(&intervals.Requeue, "requeue-interval", controllers.DefaultRequeueInterval,
+		"Duration the controller waits before checking again on the kata runtime pods and the failed steps.")
+	flag.DurationVar(&intervals.MCPPoll, "mcp-poll-interval", controllers.DefaultMCPPollInterval,
+		"Initial duration the controller waits for a machine config pool to become ready, doubled on every check.")
+	flag.DurationVar(&intervals.MCPPollMax, "mcp-poll-max-interval", controllers.DefaultMCPPollMaxInterval,
+		"Maximum duration the controller waits between two checks of a machine config pool.")
+	flag.DurationVar(&intervals.MCPSyncDelay, "mcp-sync-delay", controllers.DefaultMCPSyncDelay,
+		"Duration the controller waits for the machine config operator to start updating a pool after deleting a machine config.")
+	flag.DurationVar(&intervals.MCDebounce, "mc-debounce-window", controllers.DefaultMCDebounceWindow,
+		"Duration the KataConfig spec must stay unchanged before the machine config is updated, so that successive edits reboot the nodes once.")
+	flag.DurationVar(&intervals.NodeEventCoalescing, "node-event-coalescing-window", controllers.DefaultNodeEventCoalescingWindow,
+		"Duration the node events wait before they trigger a reconcile, the events of the window are coalesced into one.")
+	flag.DurationVar(&intervals.MCPStallThreshold, "mcp-stall-threshold", controllers.DefaultMCPStallThreshold,
+		"Duration a machine config pool may stay unready before the KataConfig gets the RolloutStalled condition.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the healthz and readyz endpoints bind to.")
+	flag.DurationVar(&failureThreshold, "failure-threshold", controllers.DefaultFailureThreshold,
+		"Duration the reconciles, or the sync of the node and machine config pool informers, must keep failing before the liveness probe fails.")
+	flag.StringVar(&configFile, "config", "",
+		"The operator configuration file. The settings it sets take precedence over the flags, the intervals and the images are reloaded when it changes.")
+	flag.BoolVar(&scopedCache, "scoped-cache", false,
+		"Only cache the pods, daemonsets, configmaps and secrets of the operator namespace, the ones of the other namespaces are read from the API server. "+
+			"Cuts the memory usage on clusters with many pods.")
+	flag.StringVar(&diagnostics.PprofAddr, "pprof-addr", "",
+		"The address the pprof endpoints bind to, e.g. 127.0.0.1:6060. Disabled if empty.")
+	flag.DurationVar(&diagnostics.StatsInterval, "runtime-stats-interval", 0,
+		"Interval of the memory and goroutine stats lines of the operator. Disabled if zero.")
+	// The logs are JSON lines by default, --zap-log-level sets the verbosity and --zap-devel
+	// switches to the human readable development logs
+	logOpts := zap.Options{}
+	logOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&logOpts)))
+
+	settings := &controllers.LiveSettings{}
+	var operatorConfig *operatorconfig.Config
+	var configWatcher *controllers.ConfigWatcher
+	if configFile != "" {
+		var err error
+		configWatcher, operatorConfig, err = controllers.NewConfigWatcher(configFile, controllers.Settings{Intervals: intervals},
+			settings, ctrl.Log.WithName("config"))
+		if err != nil {
+			setupLog.Error(err, "unable to load the operator configuration", "path", configFile)
+			os.Exit(1)
+		}
+		if operatorConfig.LeaderElectionNamespace != "" {
+			leaderElectionNamespace = operatorConfig.LeaderElectionNamespace
+		}
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	var operatorCache cache.Cache
+	var newClient manager.NewClientFunc
+	if scopedCache {
+		var err error
+		if operatorCache, err = controllers.NewOperatorCache(restConfig, scheme); err != nil {
+			setupLog.Error(err, "unable to create the cache of the operator namespace")
+			os.Exit(1)
+		}
+		newClient = controllers.NewScopedClientFunc(operatorCache)
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "290f4947.kataconfiguration.openshift.io",
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "290f4947.kataconfiguration.openshift.io",
+		// Only the leader runs the reconcilers, the other replicas keep serving the webhooks
+		LeaderElectionNamespace: leaderElectionNamespace,
+		LeaseDuration:           &leaseDuration,
+		RenewDeadline:           &renewDeadline,
+		RetryPeriod:             &retryPeriod,
+		NewClient:               newClient,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	if operatorCache != nil {
+		if err = mgr.Add(operatorCache); err != nil {
+			setupLog.Error(err, "unable to add the cache of the operator namespace")
+			os.Exit(1)
+		}
+	}
 
 	isOpenshift, err := controllers.IsOpenShift()
 	if err != nil {
@@ -79,25 +182,111 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The operator is restarted when the reconciles keep failing or the informers can't sync,
+	// and not ready until they are synced
+	reconcileHealth := &controllers.ReconcileHealth{FailureThreshold: failureThreshold}
+	informerHealth := &controllers.InformerHealth{
+		Cache:            mgr.GetCache(),
+		Objects:          []runtime.Object{&corev1.Node{}},
+		FailureThreshold: failureThreshold,
+	}
+	if isOpenshift {
+		informerHealth.Objects = append(informerHealth.Objects, &mcfgv1.MachineConfigPool{})
+	}
+	if err = mgr.AddHealthzCheck("reconcile", reconcileHealth.Check); err != nil {
+		setupLog.Error(err, "unable to add the reconcile health check")
+		os.Exit(1)
+	}
+	if err = mgr.AddHealthzCheck("informers", informerHealth.Healthy); err != nil {
+		setupLog.Error(err, "unable to add the informers health check")
+		os.Exit(1)
+	}
+	if err = mgr.AddReadyzCheck("informers", informerHealth.Ready); err != nil {
+		setupLog.Error(err, "unable to add the informers readiness check")
+		os.Exit(1)
+	}
+
+	if configWatcher != nil {
+		if err = mgr.Add(configWatcher); err != nil {
+			setupLog.Error(err, "unable to add the reload of the operator configuration")
+			os.Exit(1)
+		}
+	}
+	if diagnostics.PprofAddr != "" || diagnostics.StatsInterval > 0 {
+		diagnostics.Log = ctrl.Log.WithName("diagnostics")
+		if err = mgr.Add(&diagnostics); err != nil {
+			setupLog.Error(err, "unable to add the diagnostics")
+			os.Exit(1)
+		}
+	}
+
 	if isOpenshift {
 		if err = (&controllers.KataConfigOpenShiftReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
-			Scheme: mgr.GetScheme(),
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("KataConfig"),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      mgr.GetEventRecorderFor("kataconfig-controller"),
+			APIReader:     mgr.GetAPIReader(),
+			Intervals:     intervals,
+			Health:        reconcileHealth,
+			Settings:      settings,
+			OperatorCache: operatorCache,
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for OpenShift cluster", "controller", "KataConfig")
 			os.Exit(1)
 		}
+		if operatorConfig.FeatureEnabled("OrphanSweep", true) {
+			if err = mgr.Add(&controllers.OrphanSweeper{
+				Reader: mgr.GetAPIReader(),
+				Client: mgr.GetClient(),
+				Log:    ctrl.Log.WithName("controllers").WithName("OrphanSweeper"),
+			}); err != nil {
+				setupLog.Error(err, "unable to add the sweeper of the orphaned kata objects")
+				os.Exit(1)
+			}
+		}
+		if operatorConfig.FeatureEnabled("MetricsMonitoring", true) {
+			if err = mgr.Add(&controllers.MetricsMonitoring{
+				Client: mgr.GetClient(),
+				Log:    ctrl.Log.WithName("controllers").WithName("MetricsMonitoring"),
+			}); err != nil {
+				setupLog.Error(err, "unable to add the monitoring of the operator metrics")
+				os.Exit(1)
+			}
+		}
 	} else {
 		if err = (&controllers.KataConfigKubernetesReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
-			Scheme: mgr.GetScheme(),
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("controllers").WithName("KataConfig"),
+			Scheme:    mgr.GetScheme(),
+			APIReader: mgr.GetAPIReader(),
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for Kubernetes cluster", "controller", "KataConfig")
 			os.Exit(1)
 		}
 	}
+	// Webhooks need the serving certificates, disable them when running the manager locally
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&kataconfigurationv1.KataConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KataConfig")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register("/mutate-v1-pod-runtimeclass", &webhook.Admission{
+			Handler: &webhooks.PodRuntimeClassDefaulter{Client: mgr.GetClient()},
+		})
+		mgr.GetWebhookServer().Register("/mutate-v1-pod-peerpods", &webhook.Admission{
+			Handler: &webhooks.PodPeerPodsMutator{},
+		})
+		mgr.GetWebhookServer().Register("/mutate-v1-pod-initdata", &webhook.Admission{
+			Handler: &webhooks.PodInitdataMutator{Client: mgr.GetClient()},
+		})
+		mgr.GetWebhookServer().Register("/validate-v1-pod-kata-annotations", &webhook.Admission{
+			Handler: &webhooks.PodKataAnnotationsValidator{Client: mgr.GetClient()},
+		})
+		mgr.GetWebhookServer().Register("/validate-kataconfig-controlplane", &webhook.Admission{
+			Handler: &webhooks.KataConfigControlPlaneGuard{Client: mgr.GetClient()},
+		})
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,166 @@
+// Package acmpolicy wraps a KataConfig into the Red Hat Advanced Cluster Management policy
+// pushing it from the hub to the managed clusters, so that a fleet gets the same kata rollout.
+package acmpolicy
+
+import (
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	policyAPIVersion    = "policy.open-cluster-management.io/v1"
+	placementAPIVersion = "apps.open-cluster-management.io/v1"
+)
+
+// Options are the settings of the generated policy
+type Options struct {
+	// Name of the Policy, the PlacementRule and the PlacementBinding, kata-<KataConfig name> if empty
+	Name string
+
+	// Namespace of the policy on the hub
+	Namespace string
+
+	// ClusterSelector selects the managed clusters by their labels, all the available clusters
+	// when empty
+	ClusterSelector map[string]string
+
+	// Inform only reports the clusters without the KataConfig instead of creating it there
+	Inform bool
+}
+
+// Generate returns the Policy creating the KataConfig on the managed clusters, and the
+// PlacementRule and PlacementBinding selecting the clusters. Only the name, labels and spec of
+// the KataConfig are propagated
+func Generate(kataConfig *kataconfigurationv1.KataConfig, opts Options) ([]*unstructured.Unstructured, error) {
+	if kataConfig.Name == "" {
+		return nil, fmt.Errorf("the KataConfig has no name")
+	}
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("the namespace of the policy is required")
+	}
+	name := opts.Name
+	if name == "" {
+		name = "kata-" + kataConfig.Name
+	}
+	remediation := "enforce"
+	if opts.Inform {
+		remediation = "inform"
+	}
+
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&kataConfig.Spec)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range spec {
+		if v == nil {
+			delete(spec, k)
+		}
+	}
+	metadata := map[string]interface{}{"name": kataConfig.Name}
+	if len(kataConfig.Labels) > 0 {
+		metadata["labels"] = stringMap(kataConfig.Labels)
+	}
+	wrapped := map[string]interface{}{
+		"apiVersion": kataconfigurationv1.GroupVersion.String(),
+		"kind":       "KataConfig",
+		"metadata":   metadata,
+		"spec":       spec,
+	}
+
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": policyAPIVersion,
+		"kind":       "Policy",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": opts.Namespace,
+			"annotations": map[string]interface{}{
+				"policy.open-cluster-management.io/standards":  "NIST SP 800-53",
+				"policy.open-cluster-management.io/categories": "CM Configuration Management",
+				"policy.open-cluster-management.io/controls":   "CM-2 Baseline Configuration",
+			},
+		},
+		"spec": map[string]interface{}{
+			"remediationAction": remediation,
+			"disabled":          false,
+			"policy-templates": []interface{}{
+				map[string]interface{}{
+					"objectDefinition": map[string]interface{}{
+						"apiVersion": policyAPIVersion,
+						"kind":       "ConfigurationPolicy",
+						"metadata": map[string]interface{}{
+							"name": name + "-kataconfig",
+						},
+						"spec": map[string]interface{}{
+							"remediationAction": remediation,
+							"severity":          "medium",
+							"object-templates": []interface{}{
+								map[string]interface{}{
+									"complianceType":   "musthave",
+									"objectDefinition": wrapped,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	placementSpec := map[string]interface{}{
+		"clusterConditions": []interface{}{
+			map[string]interface{}{
+				"type":   "ManagedClusterConditionAvailable",
+				"status": "True",
+			},
+		},
+	}
+	if len(opts.ClusterSelector) > 0 {
+		placementSpec["clusterSelector"] = map[string]interface{}{
+			"matchLabels": stringMap(opts.ClusterSelector),
+		}
+	}
+	placement := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": placementAPIVersion,
+		"kind":       "PlacementRule",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": opts.Namespace,
+		},
+		"spec": placementSpec,
+	}}
+
+	binding := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": policyAPIVersion,
+		"kind":       "PlacementBinding",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": opts.Namespace,
+		},
+		"placementRef": map[string]interface{}{
+			"name":     name,
+			"kind":     "PlacementRule",
+			"apiGroup": "apps.open-cluster-management.io",
+		},
+		"subjects": []interface{}{
+			map[string]interface{}{
+				"name":     name,
+				"kind":     "Policy",
+				"apiGroup": "policy.open-cluster-management.io",
+			},
+		},
+	}}
+
+	return []*unstructured.Unstructured{policy, placement, binding}, nil
+}
+
+// stringMap converts labels to the map type of the unstructured objects
+func stringMap(labels map[string]string) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range labels {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,56 @@
+package acmpolicy
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerate(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", ResourceVersion: "42"},
+		Spec:       kataconfigurationv1.KataConfigSpec{AllowControlPlaneNodes: true},
+	}
+	objects, err := Generate(kataConfig, Options{Namespace: "fleet", ClusterSelector: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected a Policy, a PlacementRule and a PlacementBinding, got %d objects", len(objects))
+	}
+	for _, obj := range objects {
+		if obj.GetName() != "kata-example-kataconfig" || obj.GetNamespace() != "fleet" {
+			t.Errorf("unexpected %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		}
+	}
+
+	policy := objects[0]
+	if action, _, _ := unstructured.NestedString(policy.Object, "spec", "remediationAction"); action != "enforce" {
+		t.Errorf("expected the policy to be enforced, got %q", action)
+	}
+	templates, _, _ := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
+	objectTemplates, _, _ := unstructured.NestedSlice(templates[0].(map[string]interface{}), "objectDefinition", "spec", "object-templates")
+	wrapped := objectTemplates[0].(map[string]interface{})["objectDefinition"].(map[string]interface{})
+	if _, found, _ := unstructured.NestedString(wrapped, "metadata", "resourceVersion"); found {
+		t.Error("expected the resourceVersion of the KataConfig not to be propagated")
+	}
+	if allowed, _, _ := unstructured.NestedBool(wrapped, "spec", "allowControlPlaneNodes"); !allowed {
+		t.Error("expected the spec of the KataConfig to be propagated")
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(wrapped, "spec", "kataConfigPoolSelector"); found {
+		t.Error("expected the unset fields of the spec to be left out")
+	}
+
+	selector, _, _ := unstructured.NestedStringMap(objects[1].Object, "spec", "clusterSelector", "matchLabels")
+	if selector["env"] != "prod" {
+		t.Errorf("expected the clusters to be selected by env=prod, got %v", selector)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	if _, err := Generate(&kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"}}, Options{}); err == nil {
+		t.Error("expected a policy without namespace to be rejected")
+	}
+}
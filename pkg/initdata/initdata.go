@@ -0,0 +1,83 @@
+// Package initdata validates and encodes the initdata documents of the confidential
+// containers, the TOML documents configuring the guest components of a confidential guest,
+// e.g. its attestation agent, which the guest measures before it gets attested.
+package initdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	b64 "encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Annotation is the pod annotation carrying the encoded initdata document of the guest
+const Annotation = "io.katacontainers.config.runtime.cc_init_data"
+
+// Version is the version of the initdata specification the documents must follow
+const Version = "0.1.0"
+
+// Algorithms are the digest algorithms the guests can measure the documents with
+var Algorithms = []string{"sha256", "sha384", "sha512"}
+
+// document is an initdata document
+type document struct {
+	Version   string            `toml:"version"`
+	Algorithm string            `toml:"algorithm"`
+	Data      map[string]string `toml:"data"`
+}
+
+// Validate checks that the document is an initdata document the guests can measure
+func Validate(doc string) error {
+	d := document{}
+	if _, err := toml.Decode(doc, &d); err != nil {
+		return fmt.Errorf("invalid initdata document: %v", err)
+	}
+	if d.Version != Version {
+		return fmt.Errorf("initdata version %q is not supported, only %s is", d.Version, Version)
+	}
+	supported := false
+	for _, algorithm := range Algorithms {
+		supported = supported || d.Algorithm == algorithm
+	}
+	if !supported {
+		return fmt.Errorf("initdata algorithm %q is not supported, only %v are", d.Algorithm, Algorithms)
+	}
+	if len(d.Data) == 0 {
+		return fmt.Errorf("initdata document without data")
+	}
+	return nil
+}
+
+// Encode returns the value of the annotation passing the document to the runtime: the
+// gzipped document in base64
+func Encode(doc string) (string, error) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(doc)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return b64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Decode returns the document the annotation value carries
+func Decode(value string) (string, error) {
+	compressed, err := b64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("initdata is not base64: %v", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", fmt.Errorf("initdata is not gzipped: %v", err)
+	}
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("invalid initdata: %v", err)
+	}
+	return string(doc), nil
+}
@@ -0,0 +1,44 @@
+package initdata
+
+import "testing"
+
+const doc = `version = "0.1.0"
+algorithm = "sha384"
+
+[data]
+"aa.toml" = '''
+[token_configs.kbs]
+url = "http://kbs.trustee.svc:8080"
+'''
+`
+
+func TestEncodeRoundTrip(t *testing.T) {
+	if err := Validate(doc); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := Encode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != doc {
+		t.Errorf("expected %q, got %q", doc, decoded)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]string{
+		"not toml":          "version = ",
+		"unknown version":   "version = \"0.2.0\"\nalgorithm = \"sha384\"\n[data]\n\"aa.toml\" = \"\"\n",
+		"unknown algorithm": "version = \"0.1.0\"\nalgorithm = \"md5\"\n[data]\n\"aa.toml\" = \"\"\n",
+		"no data":           "version = \"0.1.0\"\nalgorithm = \"sha384\"\n",
+	}
+	for name, doc := range tests {
+		if err := Validate(doc); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package katabackup captures the kata installation of a cluster for disaster recovery: the
+// KataConfig, the configuration rendered for the nodes and the state of every node. Restoring
+// the backup recreates the KataConfig and re-adopts the nodes whose installation is intact.
+package katabackup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestoredAnnotation is set on the restored KataConfig, to the time of the backup
+const RestoredAnnotation = "kataconfiguration.openshift.io/restored-from"
+
+// Backup is the kata installation of a cluster at a point in time
+type Backup struct {
+	// Time is when the backup was taken
+	Time metav1.Time `json:"time"`
+
+	// Name, Labels and Spec are the ones of the KataConfig
+	Name   string                             `json:"name"`
+	Labels map[string]string                  `json:"labels,omitempty"`
+	Spec   kataconfigurationv1.KataConfigSpec `json:"spec"`
+
+	// RenderedConfig is the data of the rendered configuration ConfigMap, if it existed
+	RenderedConfig map[string]string `json:"renderedConfig,omitempty"`
+
+	// Nodes are the nodes that reported a state for the KataConfig, ordered by name
+	Nodes []Node `json:"nodes,omitempty"`
+}
+
+// Node is the kata state of a node
+type Node struct {
+	Name string `json:"name"`
+
+	// MachineID identifies the machine, a replaced machine has another one
+	MachineID string `json:"machineID"`
+
+	State nodeprogress.State `json:"state"`
+
+	// Artifacts are the SHA256 checksums of the kata binaries installed on the node
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+}
+
+// New captures the KataConfig, its rendered configuration, nil if missing, and the nodes
+func New(kataConfig *kataconfigurationv1.KataConfig, renderedConfig *corev1.ConfigMap, nodes []corev1.Node) *Backup {
+	backup := &Backup{
+		Time:   metav1.NewTime(time.Now().UTC().Truncate(time.Second)),
+		Name:   kataConfig.Name,
+		Labels: kataConfig.Labels,
+		Spec:   kataConfig.Spec,
+	}
+	if renderedConfig != nil {
+		backup.RenderedConfig = renderedConfig.Data
+	}
+	for i := range nodes {
+		p := nodeprogress.Get(&nodes[i], kataConfig.Name)
+		if p.State == "" {
+			continue
+		}
+		backup.Nodes = append(backup.Nodes, Node{
+			Name:      nodes[i].Name,
+			MachineID: nodes[i].Status.NodeInfo.MachineID,
+			State:     p.State,
+			Artifacts: p.Artifacts,
+		})
+	}
+	sort.Slice(backup.Nodes, func(i, j int) bool { return backup.Nodes[i].Name < backup.Nodes[j].Name })
+	return backup
+}
+
+// KataConfig returns the KataConfig to create back
+func (b *Backup) KataConfig() *kataconfigurationv1.KataConfig {
+	return &kataconfigurationv1.KataConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: kataconfigurationv1.GroupVersion.String(),
+			Kind:       "KataConfig",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   b.Name,
+			Labels: b.Labels,
+			Annotations: map[string]string{
+				RestoredAnnotation: b.Time.UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: *b.Spec.DeepCopy(),
+	}
+}
+
+// Adoption is a node re-adopted by the restored KataConfig, with the patches reporting it
+// installed again
+type Adoption struct {
+	Node    string
+	Patches [][]byte
+}
+
+// Adoptions returns the nodes installed at the time of the backup whose installation is intact:
+// the machine is the same and the node reports no other state. The other nodes are installed
+// again by the operator
+func (b *Backup) Adoptions(nodes []corev1.Node) ([]Adoption, error) {
+	current := map[string]*corev1.Node{}
+	for i := range nodes {
+		current[nodes[i].Name] = &nodes[i]
+	}
+
+	var adoptions []Adoption
+	for _, backedUp := range b.Nodes {
+		node, ok := current[backedUp.Name]
+		if !ok || backedUp.State != nodeprogress.Installed {
+			continue
+		}
+		if backedUp.MachineID == "" || node.Status.NodeInfo.MachineID != backedUp.MachineID {
+			continue
+		}
+		if annotated := node.GetAnnotations()[nodeprogress.KataConfigAnnotation]; annotated != "" && annotated != b.Name {
+			continue
+		}
+		if p := nodeprogress.Get(node, b.Name); p.State != "" && p.State != nodeprogress.Installed {
+			continue
+		}
+
+		progress, err := nodeprogress.Patch(nodeprogress.Progress{KataConfig: b.Name, State: nodeprogress.Installed})
+		if err != nil {
+			return nil, err
+		}
+		artifacts, err := nodeprogress.ArtifactsPatch(backedUp.Artifacts)
+		if err != nil {
+			return nil, err
+		}
+		adoptions = append(adoptions, Adoption{Node: node.Name, Patches: [][]byte{progress, artifacts}})
+	}
+	return adoptions, nil
+}
+
+// Validate checks that the backup can be restored
+func (b *Backup) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("the backup has no KataConfig name")
+	}
+	if b.Time.IsZero() {
+		return fmt.Errorf("the backup has no time")
+	}
+	return nil
+}
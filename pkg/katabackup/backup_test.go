@@ -0,0 +1,64 @@
+package katabackup
+
+import (
+	"strings"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/nodeprogress"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func node(name, machineID string, state nodeprogress.State) corev1.Node {
+	n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}}}
+	n.Status.NodeInfo.MachineID = machineID
+	if state != "" {
+		n.Annotations[nodeprogress.KataConfigAnnotation] = "example-kataconfig"
+		n.Annotations[nodeprogress.StateAnnotation] = string(state)
+	}
+	return n
+}
+
+func TestBackupRestore(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", UID: "1234"},
+		Spec:       kataconfigurationv1.KataConfigSpec{AllowControlPlaneNodes: true},
+	}
+	backup := New(kataConfig, nil, []corev1.Node{
+		node("worker-2", "m2", nodeprogress.Installed),
+		node("worker-0", "m0", nodeprogress.Installed),
+		node("worker-1", "m1", nodeprogress.Installing),
+		node("worker-3", "m3", ""),
+	})
+	if len(backup.Nodes) != 3 || backup.Nodes[0].Name != "worker-0" {
+		t.Fatalf("expected the 3 reporting nodes ordered by name, got %+v", backup.Nodes)
+	}
+	if err := backup.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := backup.KataConfig()
+	if restored.Name != "example-kataconfig" || restored.UID != "" || !restored.Spec.AllowControlPlaneNodes {
+		t.Errorf("unexpected restored KataConfig %+v", restored.ObjectMeta)
+	}
+	if _, ok := restored.Annotations[RestoredAnnotation]; !ok {
+		t.Error("expected the restored KataConfig to be annotated with the time of the backup")
+	}
+
+	// worker-0 lost its annotations with the KataConfig, worker-2 was replaced
+	adoptions, err := backup.Adoptions([]corev1.Node{
+		node("worker-0", "m0", ""),
+		node("worker-1", "m1", ""),
+		node("worker-2", "m2-replaced", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adoptions) != 1 || adoptions[0].Node != "worker-0" {
+		t.Fatalf("expected worker-0 only to be adopted, got %+v", adoptions)
+	}
+	if !strings.Contains(string(adoptions[0].Patches[0]), string(nodeprogress.Installed)) {
+		t.Errorf("expected the node to be reported installed, got %s", adoptions[0].Patches[0])
+	}
+}
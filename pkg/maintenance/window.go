@@ -0,0 +1,48 @@
+// Package maintenance computes the recurring maintenance windows in which the operator
+// may start the disruptive changes, the ones rebooting the nodes.
+package maintenance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring maintenance window opening on a cron schedule
+type Window struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// NewWindow parses a window opening on the standard cron expression start, in the time zone,
+// UTC if empty, and lasting duration
+func NewWindow(start string, duration time.Duration, timeZone string) (*Window, error) {
+	schedule, err := cron.ParseStandard(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window start %q: %v", start, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid maintenance window duration %s", duration)
+	}
+	location := time.UTC
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window time zone %q: %v", timeZone, err)
+		}
+	}
+	return &Window{schedule: schedule, duration: duration, location: location}, nil
+}
+
+// Open tells whether the window is open at now. When it is, the time the window closes is
+// returned, otherwise the time it opens next
+func (w *Window) Open(now time.Time) (bool, time.Time) {
+	now = now.In(w.location)
+	// the last opening, if any, after now - duration is the one of the ongoing window
+	opening := w.schedule.Next(now.Add(-w.duration))
+	if !opening.After(now) {
+		return true, opening.Add(w.duration)
+	}
+	return false, opening
+}
@@ -0,0 +1,57 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowOpen(t *testing.T) {
+	// Saturdays from 2:00 to 6:00
+	w, err := NewWindow("0 2 * * 6", 4*time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saturday := time.Date(2020, time.October, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now  time.Time
+		open bool
+		at   time.Time
+	}{
+		{saturday.Add(time.Hour), false, saturday.Add(2 * time.Hour)},
+		{saturday.Add(2 * time.Hour), true, saturday.Add(6 * time.Hour)},
+		{saturday.Add(5 * time.Hour), true, saturday.Add(6 * time.Hour)},
+		{saturday.Add(6 * time.Hour), false, saturday.Add(7*24*time.Hour + 2*time.Hour)},
+		{saturday.Add(-24 * time.Hour), false, saturday.Add(2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		open, at := w.Open(tt.now)
+		if open != tt.open || !at.Equal(tt.at) {
+			t.Errorf("at %s: expected open=%t until/from %s, got open=%t %s", tt.now, tt.open, tt.at, open, at)
+		}
+	}
+}
+
+func TestWindowTimeZone(t *testing.T) {
+	w, err := NewWindow("0 2 * * *", time.Hour, "Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2:30 in Tokyo
+	if open, _ := w.Open(time.Date(2020, time.October, 2, 17, 30, 0, 0, time.UTC)); !open {
+		t.Error("expected the window to be open at 2:30 in Tokyo")
+	}
+}
+
+func TestNewWindowInvalid(t *testing.T) {
+	if _, err := NewWindow("every saturday", time.Hour, ""); err == nil {
+		t.Error("expected an invalid cron expression to be rejected")
+	}
+	if _, err := NewWindow("0 2 * * 6", 0, ""); err == nil {
+		t.Error("expected an empty window to be rejected")
+	}
+	if _, err := NewWindow("0 2 * * 6", time.Hour, "Mars/Olympus"); err == nil {
+		t.Error("expected an unknown time zone to be rejected")
+	}
+}
@@ -0,0 +1,144 @@
+package nodeprogress
+
+import (
+	"sort"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Aggregate rebuilds the per-node parts of the KataConfig status out of the progress reported
+// on the nodes. The installation status is rebuilt unless the KataConfig is being deleted, in
+// which case the uninstallation status is, except for the completed nodes the operator itself
+// tracks. The smoke test outcome of the installed nodes is kept. It returns false when no node
+// reported anything for the KataConfig, the status is left untouched then
+func Aggregate(status *kataconfigurationv1.KataConfigStatus, nodes []corev1.Node, kataConfigName string, deleting bool) bool {
+	type nodeProgress struct {
+		name string
+		Progress
+	}
+
+	var reported []nodeProgress
+	for i := range nodes {
+		p := Get(&nodes[i], kataConfigName)
+		if p.State != "" {
+			reported = append(reported, nodeProgress{name: nodes[i].Name, Progress: p})
+		}
+	}
+	if len(reported) == 0 {
+		return false
+	}
+
+	// nodes are listed in a stable order so that the status only changes with the progress
+	sort.Slice(reported, func(i, j int) bool { return reported[i].name < reported[j].name })
+
+	if !deleting {
+		installation := kataconfigurationv1.KataInstallationStatus{}
+		for _, p := range reported {
+			name := p.name
+			switch p.State {
+			case Installing:
+				installation.InProgress.InProgressNodesCount++
+			case BinariesInstalled:
+				installation.InProgress.InProgressNodesCount++
+				installation.InProgress.BinariesInstalledNodesList = append(installation.InProgress.BinariesInstalledNodesList, name)
+			case Installed:
+				installation.Completed.CompletedNodesList = append(installation.Completed.CompletedNodesList, name)
+				if p.Health != "" {
+					installation.Degraded.FailedNodesList = append(installation.Degraded.FailedNodesList,
+						kataconfigurationv1.FailedNodeStatus{Name: name, Error: p.Health})
+				}
+				if len(p.Artifacts) > 0 {
+					installation.Artifacts = append(installation.Artifacts,
+						kataconfigurationv1.KataNodeArtifacts{Name: name, Checksums: p.Artifacts})
+				}
+				if duration, ok := p.InstallDuration(); ok {
+					installation.InstallDurations = append(installation.InstallDurations,
+						kataconfigurationv1.KataNodeInstallDuration{Name: name, Duration: metav1.Duration{Duration: duration}})
+				}
+			case InstallFailed:
+				installation.Failed.FailedNodesList = append(installation.Failed.FailedNodesList,
+					kataconfigurationv1.FailedNodeStatus{Name: name, Error: p.Error})
+			}
+		}
+		installation.Completed.CompletedNodesCount = len(installation.Completed.CompletedNodesList)
+		installation.Failed.FailedNodesCount = len(installation.Failed.FailedNodesList)
+		installation.Degraded.FailedNodesCount = len(installation.Degraded.FailedNodesList)
+		installation.SmokeTest = status.InstallationStatus.SmokeTest
+		status.InstallationStatus = installation
+		return true
+	}
+
+	completed := map[string]bool{}
+	for _, name := range status.UnInstallationStatus.Completed.CompletedNodesList {
+		completed[name] = true
+	}
+
+	inProgress := kataconfigurationv1.KataUnInstallationInProgressStatus{}
+	failed := kataconfigurationv1.KataFailedNodeStatus{}
+	for _, p := range reported {
+		name := p.name
+		switch p.State {
+		case Uninstalling:
+			inProgress.InProgressNodesCount++
+		case BinariesUninstalled, UninstallFailed, UninstallVerified, UninstallLeftovers:
+			// a failed uninstallation doesn't block the deletion, the verification reports the leftovers
+			inProgress.BinariesUnInstalledNodesList = append(inProgress.BinariesUnInstalledNodesList, name)
+			if !completed[name] {
+				inProgress.InProgressNodesCount++
+			}
+			if p.State == UninstallFailed {
+				failed.FailedNodesList = append(failed.FailedNodesList,
+					kataconfigurationv1.FailedNodeStatus{Name: name, Error: p.Error})
+			}
+		}
+	}
+	failed.FailedNodesCount = len(failed.FailedNodesList)
+	status.UnInstallationStatus.InProgress = inProgress
+	status.UnInstallationStatus.Failed = failed
+	return true
+}
+
+// AggregateVerification fills the node lists of the uninstallation report with the outcome
+// of the verification reported by the given uninstalled nodes. It returns the nodes that
+// have not reported it yet
+func AggregateVerification(report *kataconfigurationv1.KataUninstallReport, nodes []corev1.Node, kataConfigName string) []string {
+	var verified, pending []string
+	var leftovers []kataconfigurationv1.FailedNodeStatus
+	for i := range nodes {
+		name := nodes[i].Name
+		p := Get(&nodes[i], kataConfigName)
+		switch p.State {
+		case UninstallVerified:
+			verified = append(verified, name)
+		case UninstallLeftovers:
+			leftovers = append(leftovers, kataconfigurationv1.FailedNodeStatus{Name: name, Error: p.Error})
+		default:
+			pending = append(pending, name)
+		}
+	}
+
+	sort.Strings(verified)
+	sort.Slice(leftovers, func(i, j int) bool { return leftovers[i].Name < leftovers[j].Name })
+	report.VerifiedNodesList = verified
+	report.LeftoverNodesList = leftovers
+	return pending
+}
+
+// Pending returns the nodes that haven't reported one of the states for the KataConfig, in the
+// order of the nodes
+func Pending(nodes []corev1.Node, kataConfigName string, states ...State) []string {
+	var pending []string
+	for i := range nodes {
+		state := Get(&nodes[i], kataConfigName).State
+		reached := false
+		for _, s := range states {
+			reached = reached || state == s
+		}
+		if !reached {
+			pending = append(pending, nodes[i].Name)
+		}
+	}
+	return pending
+}
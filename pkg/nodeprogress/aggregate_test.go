@@ -0,0 +1,163 @@
+package nodeprogress
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func node(name, kataConfig string, state State, errMsg string) corev1.Node {
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name: name,
+		Annotations: map[string]string{
+			KataConfigAnnotation: kataConfig,
+			StateAnnotation:      string(state),
+			ErrorAnnotation:      errMsg,
+		},
+	}}
+}
+
+func TestAggregateInstallation(t *testing.T) {
+	nodes := []corev1.Node{
+		node("worker-2", "example", InstallFailed, "boom"),
+		node("worker-1", "example", BinariesInstalled, ""),
+		node("worker-0", "example", Installed, ""),
+		node("worker-3", "example", Installing, ""),
+		node("worker-4", "other", Installed, ""),
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-5"}},
+		node("worker-6", "example", Installed, ""),
+	}
+	nodes[6].Annotations[HealthAnnotation] = "/dev/kvm not found"
+	nodes[2].Annotations[ArtifactsAnnotation] = `{"/usr/bin/kata-runtime":"abc"}`
+	nodes[2].Annotations[StartedAnnotation] = "2020-11-10T10:00:00Z"
+	nodes[2].Annotations[SinceAnnotation] = "2020-11-10T10:12:30Z"
+
+	status := &kataconfigurationv1.KataConfigStatus{}
+	status.InstallationStatus.SmokeTest.VerifiedNodesList = []string{"worker-0"}
+	if !Aggregate(status, nodes, "example", false) {
+		t.Fatal("expected the progress of the nodes to be aggregated")
+	}
+
+	expected := kataconfigurationv1.KataInstallationStatus{}
+	expected.SmokeTest.VerifiedNodesList = []string{"worker-0"}
+	expected.InProgress.InProgressNodesCount = 2
+	expected.InProgress.BinariesInstalledNodesList = []string{"worker-1"}
+	expected.Completed.CompletedNodesCount = 2
+	expected.Completed.CompletedNodesList = []string{"worker-0", "worker-6"}
+	expected.Failed.FailedNodesCount = 1
+	expected.Failed.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{{Name: "worker-2", Error: "boom"}}
+	expected.Degraded.FailedNodesCount = 1
+	expected.Degraded.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{{Name: "worker-6", Error: "/dev/kvm not found"}}
+	expected.Artifacts = []kataconfigurationv1.KataNodeArtifacts{
+		{Name: "worker-0", Checksums: map[string]string{"/usr/bin/kata-runtime": "abc"}},
+	}
+	expected.InstallDurations = []kataconfigurationv1.KataNodeInstallDuration{
+		{Name: "worker-0", Duration: metav1.Duration{Duration: 12*time.Minute + 30*time.Second}},
+	}
+	if !reflect.DeepEqual(expected, status.InstallationStatus) {
+		t.Errorf("unexpected installation status %+v", status.InstallationStatus)
+	}
+}
+
+func TestAggregateUninstallation(t *testing.T) {
+	nodes := []corev1.Node{
+		node("worker-0", "example", BinariesUninstalled, ""),
+		node("worker-1", "example", UninstallFailed, "boom"),
+		node("worker-2", "example", Uninstalling, ""),
+	}
+
+	status := &kataconfigurationv1.KataConfigStatus{}
+	status.UnInstallationStatus.Completed.CompletedNodesList = []string{"worker-0"}
+	if !Aggregate(status, nodes, "example", true) {
+		t.Fatal("expected the progress of the nodes to be aggregated")
+	}
+
+	inProgress := status.UnInstallationStatus.InProgress
+	if inProgress.InProgressNodesCount != 2 ||
+		!reflect.DeepEqual(inProgress.BinariesUnInstalledNodesList, []string{"worker-0", "worker-1"}) {
+		t.Errorf("unexpected uninstallation progress %+v", inProgress)
+	}
+	if status.UnInstallationStatus.Failed.FailedNodesCount != 1 {
+		t.Errorf("unexpected failed nodes %+v", status.UnInstallationStatus.Failed)
+	}
+	if !reflect.DeepEqual(status.UnInstallationStatus.Completed.CompletedNodesList, []string{"worker-0"}) {
+		t.Errorf("the completed nodes must be left to the operator, got %+v", status.UnInstallationStatus.Completed)
+	}
+}
+
+func TestAggregateNothingReported(t *testing.T) {
+	status := &kataconfigurationv1.KataConfigStatus{TotalNodesCount: 1}
+	status.InstallationStatus.Completed.CompletedNodesList = []string{"worker-0"}
+	if Aggregate(status, []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}}}, "example", false) {
+		t.Error("no node reported anything")
+	}
+	if len(status.InstallationStatus.Completed.CompletedNodesList) != 1 {
+		t.Error("the status must be left untouched")
+	}
+}
+
+func TestPending(t *testing.T) {
+	nodes := []corev1.Node{
+		node("worker-0", "example", Installed, ""),
+		node("worker-1", "example", BinariesInstalled, ""),
+		node("worker-2", "other", Installed, ""),
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-3"}},
+	}
+	if pending := Pending(nodes, "example", Installed); !reflect.DeepEqual(pending, []string{"worker-1", "worker-2", "worker-3"}) {
+		t.Errorf("unexpected pending nodes %v", pending)
+	}
+	if pending := Pending(nodes[:2], "example", BinariesInstalled, Installed); len(pending) != 0 {
+		t.Errorf("unexpected pending nodes %v", pending)
+	}
+}
+
+func TestAggregateVerification(t *testing.T) {
+	nodes := []corev1.Node{
+		node("worker-1", "example", UninstallLeftovers, "/etc/crio/crio.conf.d/50-kata.conf"),
+		node("worker-0", "example", UninstallVerified, ""),
+		node("worker-2", "example", BinariesUninstalled, ""),
+	}
+
+	report := &kataconfigurationv1.KataUninstallReport{RuntimeClassRemoved: true}
+	pending := AggregateVerification(report, nodes, "example")
+
+	if !reflect.DeepEqual(pending, []string{"worker-2"}) {
+		t.Errorf("unexpected pending nodes %v", pending)
+	}
+	expected := &kataconfigurationv1.KataUninstallReport{
+		RuntimeClassRemoved: true,
+		VerifiedNodesList:   []string{"worker-0"},
+		LeftoverNodesList: []kataconfigurationv1.FailedNodeStatus{
+			{Name: "worker-1", Error: "/etc/crio/crio.conf.d/50-kata.conf"},
+		},
+	}
+	if !reflect.DeepEqual(expected, report) {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+// BenchmarkAggregate aggregates the progress of a 1000 nodes pool, half of it installed
+func BenchmarkAggregate(b *testing.B) {
+	nodes := make([]corev1.Node, 1000)
+	for i := range nodes {
+		state := Installing
+		if i%2 == 0 {
+			state = Installed
+		}
+		nodes[i] = node(fmt.Sprintf("worker-%04d", i), "example", state, "")
+		nodes[i].Annotations[ArtifactsAnnotation] = `{"/usr/bin/kata-runtime":"abc"}`
+		nodes[i].Annotations[StartedAnnotation] = "2020-11-10T10:00:00Z"
+		nodes[i].Annotations[SinceAnnotation] = "2020-11-10T10:12:30Z"
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Aggregate(&kataconfigurationv1.KataConfigStatus{}, nodes, "example", false)
+	}
+}
@@ -0,0 +1,330 @@
+// Package nodeprogress defines how the kata daemon reports the progress of the
+// installation on its node. Every daemon annotates its own node, and the operator
+// aggregates the annotations of the nodes into the KataConfig status, so the daemons
+// never write the shared KataConfig.
+package nodeprogress
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// KataConfigAnnotation is the name of the KataConfig the node reports its state for
+	KataConfigAnnotation = "kataconfiguration.openshift.io/kataconfig"
+
+	// StateAnnotation is the State of the node
+	StateAnnotation = "kataconfiguration.openshift.io/state"
+
+	// ErrorAnnotation is the error of a failed node
+	ErrorAnnotation = "kataconfiguration.openshift.io/error"
+
+	// ReasonAnnotation is a machine readable cause of the failure of a node, e.g. ReasonFIPSIncompatible
+	ReasonAnnotation = "kataconfiguration.openshift.io/reason"
+
+	// SinceAnnotation is the RFC 3339 time the node entered its state
+	SinceAnnotation = "kataconfiguration.openshift.io/since"
+
+	// StartedAnnotation is the RFC 3339 time the daemon installing the node started, it is
+	// kept until the next installation
+	StartedAnnotation = "kataconfiguration.openshift.io/started"
+
+	// HealthAnnotation is why the last health probe of an installed node failed, it is absent
+	// while the node is healthy
+	HealthAnnotation = "kataconfiguration.openshift.io/health"
+
+	// RepairAnnotation describes the drift the daemon repaired on the node, it is removed by
+	// the operator once reported in an event
+	RepairAnnotation = "kataconfiguration.openshift.io/repair"
+
+	// ArtifactsAnnotation is the JSON object of the SHA256 checksums of the kata binaries
+	// installed on the node, by path
+	ArtifactsAnnotation = "kataconfiguration.openshift.io/artifacts"
+
+	// MachineAnnotation is the machine the installed node was seen running by the operator, as
+	// <machine ID>/<boot ID>
+	MachineAnnotation = "kataconfiguration.openshift.io/machine"
+
+	// CgroupAnnotation is the cgroup version the node runs, CgroupV1 or CgroupV2
+	CgroupAnnotation = "kataconfiguration.openshift.io/cgroup"
+
+	// TEEAnnotation is the JSON object of the TEECapabilities of the node
+	TEEAnnotation = "kataconfiguration.openshift.io/tee"
+)
+
+const (
+	// CgroupV1 is reported by the nodes mounting the legacy or hybrid cgroup hierarchies
+	CgroupV1 = "v1"
+	// CgroupV2 is reported by the nodes mounting the unified cgroup hierarchy
+	CgroupV2 = "v2"
+)
+
+// Annotations are all the annotations of the protocol
+var Annotations = []string{KataConfigAnnotation, StateAnnotation, ErrorAnnotation, ReasonAnnotation, SinceAnnotation,
+	StartedAnnotation, HealthAnnotation, RepairAnnotation, ArtifactsAnnotation, MachineAnnotation, CgroupAnnotation, TEEAnnotation}
+
+// State is the step of the kata lifecycle a node is at
+type State string
+
+const (
+	// Installing is reported while the kata binaries are being installed
+	Installing State = "Installing"
+	// BinariesInstalled is reported once the binaries are installed, the node then waits for the CRI-O drop-in
+	BinariesInstalled State = "BinariesInstalled"
+	// Installed is reported once the CRI-O drop-in is in place
+	Installed State = "Installed"
+	// InstallFailed is reported when the installation failed
+	InstallFailed State = "InstallFailed"
+	// Uninstalling is reported while the kata binaries are being removed
+	Uninstalling State = "Uninstalling"
+	// BinariesUninstalled is reported once the binaries are removed
+	BinariesUninstalled State = "BinariesUninstalled"
+	// UninstallFailed is reported when the removal of the binaries failed
+	UninstallFailed State = "UninstallFailed"
+	// UninstallVerified is reported once no kata leftover is found on the uninstalled node
+	UninstallVerified State = "UninstallVerified"
+	// UninstallLeftovers is reported when kata leftovers are found on the uninstalled node,
+	// the error lists them
+	UninstallLeftovers State = "UninstallLeftovers"
+)
+
+// ReasonFIPSIncompatible is reported by a node running in FIPS mode asked to install a
+// payload that is not FIPS compliant
+const ReasonFIPSIncompatible = "FIPSIncompatible"
+
+// ReasonVirtualizationDisabled is reported by a node running in a VM that doesn't expose the
+// hardware assisted virtualization, e.g. a vSphere VM without VHV
+const ReasonVirtualizationDisabled = "VirtualizationDisabled"
+
+// ReasonIOMMUDisabled is reported by a node asked to pass devices through to the kata guests
+// without its IOMMU enabled
+const ReasonIOMMUDisabled = "IOMMUDisabled"
+
+// ReasonAttestationUnavailable is reported by a node that can't reach the key broker service
+// of the confidential guests, or the quote generation service of the TDX ones
+const ReasonAttestationUnavailable = "AttestationUnavailable"
+
+// ReasonUnsupportedInstanceType is reported by the operator on a node whose cloud instance type
+// doesn't expose the hardware virtualization, the daemon doesn't install kata on it
+const ReasonUnsupportedInstanceType = "UnsupportedInstanceType"
+
+// ReasonDaemonCrashed is reported by the operator on a node whose daemon keeps crashing before
+// it reported the outcome of its operation
+const ReasonDaemonCrashed = "DaemonCrashed"
+
+// ReasonUpgrade is reported by the operator on an installed node it reports as installing
+// again, for the daemon to stage the new payload of the channel until the node reboots
+const ReasonUpgrade = "Upgrade"
+
+// Progress is the state reported by a node
+type Progress struct {
+	KataConfig string
+	State      State
+	Error      string
+	Reason     string
+	Since      time.Time
+	// Started is when the daemon installing the node started
+	Started time.Time
+	// Health is the failure of the last health probe, empty while the node is healthy
+	Health string
+	// Repair is the drift repaired on the node and not reported yet
+	Repair string
+	// Artifacts are the SHA256 checksums of the kata binaries installed on the node, by path
+	Artifacts map[string]string
+	// MachineID and BootID are the machine the installed node was seen running
+	MachineID string
+	BootID    string
+	// Cgroup is the cgroup version of the node, empty until the daemon reported it
+	Cgroup string
+	// TEE are the trusted execution environment capabilities of the node, nil until the
+	// daemon checked them
+	TEE *TEECapabilities
+}
+
+// TEECapabilities are the trusted execution environment capabilities the daemon found on its
+// node, checking it for the confidential sandboxes
+type TEECapabilities struct {
+	// SNP is whether kvm_amd runs the SEV-SNP guests
+	SNP bool `json:"snp"`
+	// Firmware is the version of the SEV firmware API, empty if unknown
+	Firmware string `json:"firmware,omitempty"`
+	// MaxGuests is the number of the encryption ASIDs of the processor
+	MaxGuests int32 `json:"maxGuests,omitempty"`
+}
+
+// InstallDuration returns the wall time the installation of an installed node took, from the
+// start of the daemon to the node reporting Installed, reboot included
+func (p Progress) InstallDuration() (time.Duration, bool) {
+	if p.State != Installed || p.Started.IsZero() || p.Since.Before(p.Started) {
+		return 0, false
+	}
+	return p.Since.Sub(p.Started), true
+}
+
+// Replaced returns whether the machine of the installed node was replaced since it was seen
+// installed, e.g. by the machine API under the same node name. The node booted since, with
+// another machine ID, and the kata binaries installed on the previous machine are gone. A
+// reboot of the same machine keeps its machine ID
+func (p Progress) Replaced(node *corev1.Node) bool {
+	if p.State != Installed || p.MachineID == "" || p.BootID == "" {
+		return false
+	}
+	info := node.Status.NodeInfo
+	return info.BootID != p.BootID && info.MachineID != p.MachineID
+}
+
+// Get returns the state the node reported for the given KataConfig, the zero Progress if
+// it didn't report anything for it
+func Get(node *corev1.Node, kataConfigName string) Progress {
+	annotations := node.GetAnnotations()
+	if annotations[KataConfigAnnotation] != kataConfigName {
+		return Progress{}
+	}
+	p := Progress{
+		KataConfig: kataConfigName,
+		State:      State(annotations[StateAnnotation]),
+		Error:      annotations[ErrorAnnotation],
+		Reason:     annotations[ReasonAnnotation],
+		Health:     annotations[HealthAnnotation],
+		Repair:     annotations[RepairAnnotation],
+		Cgroup:     annotations[CgroupAnnotation],
+	}
+	if since, err := time.Parse(time.RFC3339, annotations[SinceAnnotation]); err == nil {
+		p.Since = since
+	}
+	if started, err := time.Parse(time.RFC3339, annotations[StartedAnnotation]); err == nil {
+		p.Started = started
+	}
+	if artifacts := annotations[ArtifactsAnnotation]; artifacts != "" {
+		// a malformed record is ignored, as if the checksums were never recorded
+		_ = json.Unmarshal([]byte(artifacts), &p.Artifacts)
+	}
+	if tee := annotations[TEEAnnotation]; tee != "" {
+		// a malformed record is ignored, as if the capabilities were never checked
+		capabilities := &TEECapabilities{}
+		if err := json.Unmarshal([]byte(tee), capabilities); err == nil {
+			p.TEE = capabilities
+		}
+	}
+	if machine := strings.SplitN(annotations[MachineAnnotation], "/", 2); len(machine) == 2 {
+		p.MachineID, p.BootID = machine[0], machine[1]
+	}
+	return p
+}
+
+// Patch returns the merge patch reporting the progress on a node. The error and reason
+// of a previous failure are cleared when not set, Since defaults to now. The health is
+// cleared too, it is probed again once the node is installed, and so is the machine unless
+// the node reports Installed, it is recorded again once the node is installed. A repair not
+// reported yet, and the start of the installation, are kept when not set
+func Patch(p Progress) ([]byte, error) {
+	if p.Since.IsZero() {
+		p.Since = time.Now()
+	}
+
+	annotations := map[string]interface{}{
+		KataConfigAnnotation: p.KataConfig,
+		StateAnnotation:      string(p.State),
+		ErrorAnnotation:      nil,
+		ReasonAnnotation:     nil,
+		SinceAnnotation:      p.Since.UTC().Format(time.RFC3339),
+		HealthAnnotation:     nil,
+	}
+	if p.Error != "" {
+		annotations[ErrorAnnotation] = p.Error
+	}
+	if p.Reason != "" {
+		annotations[ReasonAnnotation] = p.Reason
+	}
+	if p.Repair != "" {
+		annotations[RepairAnnotation] = p.Repair
+	}
+	if p.State != Installed {
+		annotations[MachineAnnotation] = nil
+	}
+	if !p.Started.IsZero() {
+		annotations[StartedAnnotation] = p.Started.UTC().Format(time.RFC3339)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+}
+
+// HealthPatch returns the merge patch reporting the outcome of a health probe on a node, an
+// empty failure reports the node as healthy
+func HealthPatch(failure string) ([]byte, error) {
+	return annotationPatch(HealthAnnotation, failure)
+}
+
+// ArtifactsPatch returns the merge patch recording the checksums of the kata binaries installed
+// on a node, no checksum removes the record
+func ArtifactsPatch(artifacts map[string]string) ([]byte, error) {
+	if len(artifacts) == 0 {
+		return annotationPatch(ArtifactsAnnotation, "")
+	}
+	record, err := json.Marshal(artifacts)
+	if err != nil {
+		return nil, err
+	}
+	return annotationPatch(ArtifactsAnnotation, string(record))
+}
+
+// MachinePatch returns the merge patch recording the machine an installed node runs
+func MachinePatch(info corev1.NodeSystemInfo) ([]byte, error) {
+	return annotationPatch(MachineAnnotation, info.MachineID+"/"+info.BootID)
+}
+
+// RepairPatch returns the merge patch reporting a repair on a node, an empty repair removes
+// the one reported
+func RepairPatch(repair string) ([]byte, error) {
+	return annotationPatch(RepairAnnotation, repair)
+}
+
+// CgroupPatch returns the merge patch recording the cgroup version of a node
+func CgroupPatch(version string) ([]byte, error) {
+	return annotationPatch(CgroupAnnotation, version)
+}
+
+// TEEPatch returns the merge patch recording the trusted execution environment capabilities
+// of a node
+func TEEPatch(capabilities TEECapabilities) ([]byte, error) {
+	record, err := json.Marshal(capabilities)
+	if err != nil {
+		return nil, err
+	}
+	return annotationPatch(TEEAnnotation, string(record))
+}
+
+// annotationPatch returns the merge patch setting a single annotation, removing it when empty
+func annotationPatch(name, value string) ([]byte, error) {
+	var annotation interface{}
+	if value != "" {
+		annotation = value
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{name: annotation},
+		},
+	})
+}
+
+// ClearPatch returns the merge patch removing the progress annotations from a node
+func ClearPatch() ([]byte, error) {
+	annotations := map[string]interface{}{}
+	for _, a := range Annotations {
+		annotations[a] = nil
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+}
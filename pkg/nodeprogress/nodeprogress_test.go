@@ -0,0 +1,54 @@
+package nodeprogress
+
+import (
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReplaced(t *testing.T) {
+	installed := node("worker-0", "example", Installed, "")
+	installed.Status.NodeInfo = corev1.NodeSystemInfo{MachineID: "m0", BootID: "b0"}
+	if Get(&installed, "example").Replaced(&installed) {
+		t.Error("a node whose machine was never recorded can't be told replaced")
+	}
+
+	installed.Annotations[MachineAnnotation] = "m0/b0"
+	rebooted := *installed.DeepCopy()
+	rebooted.Status.NodeInfo.BootID = "b1"
+	replaced := *installed.DeepCopy()
+	replaced.Status.NodeInfo = corev1.NodeSystemInfo{MachineID: "m1", BootID: "b1"}
+
+	p := Get(&installed, "example")
+	if p.MachineID != "m0" || p.BootID != "b0" {
+		t.Fatalf("unexpected machine %s/%s", p.MachineID, p.BootID)
+	}
+	if p.Replaced(&installed) || p.Replaced(&rebooted) {
+		t.Error("a node running the same machine is not replaced")
+	}
+	if !p.Replaced(&replaced) {
+		t.Error("expected the node booting another machine to be replaced")
+	}
+}
+
+func TestPatchClearsMachine(t *testing.T) {
+	for state, cleared := range map[State]bool{Installing: true, Installed: false} {
+		patch, err := Patch(Progress{KataConfig: "example", State: state})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded struct {
+			Metadata struct {
+				Annotations map[string]interface{} `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(patch, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		value, ok := decoded.Metadata.Annotations[MachineAnnotation]
+		if cleared != (ok && value == nil) {
+			t.Errorf("%s: unexpected machine annotation in %s", state, patch)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package operatorconfig parses the configuration file of the operator, mounted from a ConfigMap,
+// which holds the settings otherwise given by flags or built in.
+package operatorconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration file of the operator. The unset fields keep the value of the flag
+// or the built-in default
+type Config struct {
+	// Intervals are the wait intervals of the controller, applied without restart
+	// +optional
+	Intervals Intervals `json:"intervals,omitempty"`
+
+	// Images replace the built-in images, applied without restart
+	// +optional
+	Images Images `json:"images,omitempty"`
+
+	// LeaderElectionNamespace is the namespace of the leader election lock, read at startup
+	// +optional
+	LeaderElectionNamespace string `json:"leaderElectionNamespace,omitempty"`
+
+	// FeatureGates turn the optional components of the operator on or off, read at startup
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// Intervals are the wait intervals of the controller, see the flags of the same name
+type Intervals struct {
+	Requeue      metav1.Duration `json:"requeue,omitempty"`
+	MCPPoll      metav1.Duration `json:"mcpPoll,omitempty"`
+	MCPPollMax   metav1.Duration `json:"mcpPollMax,omitempty"`
+	MCPSyncDelay metav1.Duration `json:"mcpSyncDelay,omitempty"`
+	MCDebounce   metav1.Duration `json:"mcDebounce,omitempty"`
+	// NodeEventCoalescing is the --node-event-coalescing-window flag
+	NodeEventCoalescing metav1.Duration `json:"nodeEventCoalescing,omitempty"`
+	// MCPStallThreshold is the --mcp-stall-threshold flag
+	MCPStallThreshold metav1.Duration `json:"mcpStallThreshold,omitempty"`
+}
+
+// Images are the images the operator runs besides the payload
+type Images struct {
+	// Daemon is the image of the kata daemonsets
+	Daemon string `json:"daemon,omitempty"`
+
+	// SmokeTest is the default image of the smoke test pod
+	SmokeTest string `json:"smokeTest,omitempty"`
+
+	// PodVMBuilder is the image of the pods building the images of the peer pods VMs
+	PodVMBuilder string `json:"podVMBuilder,omitempty"`
+
+	// CloudAPIAdaptor is the image of the cloud-api-adaptor creating the peer pods VMs
+	CloudAPIAdaptor string `json:"cloudAPIAdaptor,omitempty"`
+}
+
+// Parse parses and validates a configuration file
+func Parse(raw []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(raw, config); err != nil {
+		return nil, fmt.Errorf("invalid operator configuration: %v", err)
+	}
+	for name, d := range map[string]metav1.Duration{
+		"requeue":             config.Intervals.Requeue,
+		"mcpPoll":             config.Intervals.MCPPoll,
+		"mcpPollMax":          config.Intervals.MCPPollMax,
+		"mcpSyncDelay":        config.Intervals.MCPSyncDelay,
+		"mcDebounce":          config.Intervals.MCDebounce,
+		"nodeEventCoalescing": config.Intervals.NodeEventCoalescing,
+		"mcpStallThreshold":   config.Intervals.MCPStallThreshold,
+	} {
+		if d.Duration < 0 {
+			return nil, fmt.Errorf("invalid operator configuration: negative %s interval %s", name, d.Duration)
+		}
+	}
+	return config, nil
+}
+
+// Load reads and parses the configuration file at path
+func Load(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(raw)
+}
+
+// FeatureEnabled returns the value of the feature gate, or enabled if the file doesn't set it
+func (c *Config) FeatureEnabled(name string, enabled bool) bool {
+	if c == nil {
+		return enabled
+	}
+	if value, ok := c.FeatureGates[name]; ok {
+		return value
+	}
+	return enabled
+}
@@ -0,0 +1,49 @@
+package operatorconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+intervals:
+  requeue: 30s
+  mcpPollMax: 10m
+images:
+  daemon: quay.io/example/kata-operator-daemon:latest
+featureGates:
+  OrphanSweep: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Intervals.Requeue.Duration != 30*time.Second || config.Intervals.MCPPollMax.Duration != 10*time.Minute {
+		t.Errorf("unexpected intervals %+v", config.Intervals)
+	}
+	if config.Intervals.MCPPoll.Duration != 0 {
+		t.Errorf("expected the unset intervals to stay zero, got %s", config.Intervals.MCPPoll.Duration)
+	}
+	if config.Images.Daemon != "quay.io/example/kata-operator-daemon:latest" {
+		t.Errorf("unexpected daemon image %q", config.Images.Daemon)
+	}
+	if config.FeatureEnabled("OrphanSweep", true) {
+		t.Error("expected the OrphanSweep gate to be disabled")
+	}
+	if !config.FeatureEnabled("MetricsMonitoring", true) {
+		t.Error("expected the unset gates to keep their default")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"intervals:\n  requeue: soon\n",
+		"intervals:\n  requeue: -1s\n",
+		"intervals:\n  nodeEventCoalescing: -5s\n",
+		"requeueInterval: 30s\n",
+	} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
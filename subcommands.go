@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// subCommands are the commands of the operator binary besides running the manager
+var subCommands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	generateACMPolicyCommand: generateACMPolicy,
+	exportKataStateCommand:   exportKataState,
+	restoreKataStateCommand:  restoreKataState,
+}
+
+// runSubCommand runs the sub-command named by the first argument, if any, and exits
+func runSubCommand() {
+	if len(os.Args) < 2 {
+		return
+	}
+	command, ok := subCommands[os.Args[1]]
+	if !ok {
+		return
+	}
+	if err := command(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	nodeRolePrefix = "node-role.kubernetes.io/"
+
+	workerRole = "worker"
+
+	// excludeLabel keeps a node out of the kata pool when set to true
+	excludeLabel = "kata.openshift.io/exclude"
+)
+
+// controlPlaneRoles are the node roles of the control plane nodes
+var controlPlaneRoles = []string{"master", "control-plane"}
+
+// +kubebuilder:webhook:webhookVersions=v1beta1,path=/validate-kataconfig-controlplane,mutating=false,failurePolicy=ignore,sideEffects=None,groups=kataconfiguration.openshift.io,resources=kataconfigs,verbs=create;update,versions=v1;v2,name=vkataconfig-controlplane.kataconfiguration.openshift.io
+
+// KataConfigControlPlaneGuard rejects the KataConfigs whose pool selector matches control plane
+// nodes, unless they allow it: installing kata reboots the nodes of the kata pool, rebooting the
+// control plane can take the cluster down. Compact clusters, where every node is a control plane
+// node, are let through
+type KataConfigControlPlaneGuard struct {
+	Client client.Client
+}
+
+// kataConfigSelection is the part of the KataConfig the guard looks at, the same in every
+// served version
+type kataConfigSelection struct {
+	Spec struct {
+		KataConfigPoolSelector  *metav1.LabelSelector  `json:"kataConfigPoolSelector,omitempty"`
+		KataConfigPoolSelectors []metav1.LabelSelector `json:"kataConfigPoolSelectors,omitempty"`
+		AllowControlPlaneNodes  bool                   `json:"allowControlPlaneNodes,omitempty"`
+	} `json:"spec"`
+}
+
+// Handle checks the nodes matched by the pool selectors of the KataConfig
+func (g *KataConfigControlPlaneGuard) Handle(ctx context.Context, req admission.Request) admission.Response {
+	kataConfig := kataConfigSelection{}
+	if err := json.Unmarshal(req.Object.Raw, &kataConfig); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// the selection of an existing KataConfig was checked when it was made, e.g. the removal
+	// of the finalizer must go through
+	if req.Operation == admissionv1beta1.Update {
+		old := kataConfigSelection{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, kataConfig.Spec) {
+			return admission.Allowed("pool selector unchanged")
+		}
+	}
+
+	if kataConfig.Spec.AllowControlPlaneNodes {
+		return admission.Allowed("control plane nodes allowed")
+	}
+
+	poolSelectors := kataConfig.Spec.KataConfigPoolSelectors
+	if kataConfig.Spec.KataConfigPoolSelector != nil {
+		poolSelectors = append(poolSelectors, *kataConfig.Spec.KataConfigPoolSelector)
+	}
+	selectors := []labels.Selector{labels.SelectorFromSet(labels.Set{nodeRolePrefix + workerRole: ""})}
+	if len(poolSelectors) > 0 {
+		selectors = nil
+	}
+	for i := range poolSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&poolSelectors[i])
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		selectors = append(selectors, selector)
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := g.Client.List(ctx, nodes); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	matched := controlPlaneMatches(nodes.Items, selectors)
+	if len(matched) == 0 {
+		return admission.Allowed("no control plane node selected")
+	}
+	return admission.Denied(fmt.Sprintf("the kataConfigPoolSelector matches the control plane nodes %s, rebooting them "+
+		"to install kata can take the cluster down. Set spec.allowControlPlaneNodes to select them anyway",
+		strings.Join(matched, ", ")))
+}
+
+// controlPlaneMatches returns the control plane nodes matched by any of the selectors and not
+// excluded from the kata pool, with their roles, unless every node of the cluster is a control
+// plane node
+func controlPlaneMatches(nodes []corev1.Node, selectors []labels.Selector) []string {
+	var matched []string
+	compact := true
+	for _, node := range nodes {
+		roles := nodeRoles(&node)
+		controlPlane := false
+		for _, role := range controlPlaneRoles {
+			if containsString(roles, role) {
+				controlPlane = true
+			}
+		}
+		if !controlPlane {
+			compact = false
+			continue
+		}
+		if node.GetLabels()[excludeLabel] == "true" {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(node.GetLabels())) {
+				matched = append(matched, fmt.Sprintf("%s (%s)", node.Name, strings.Join(roles, ", ")))
+				break
+			}
+		}
+	}
+	if compact {
+		return nil
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// nodeRoles returns the sorted roles of the node
+func nodeRoles(node *corev1.Node) []string {
+	var roles []string
+	for label := range node.GetLabels() {
+		if strings.HasPrefix(label, nodeRolePrefix) {
+			roles = append(roles, strings.TrimPrefix(label, nodeRolePrefix))
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
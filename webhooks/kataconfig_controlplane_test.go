@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func testNode(name string, roles ...string) corev1.Node {
+	node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"custom-kata1": "test"}}}
+	for _, role := range roles {
+		node.Labels[nodeRolePrefix+role] = ""
+	}
+	return node
+}
+
+func TestControlPlaneMatches(t *testing.T) {
+	nodes := []corev1.Node{
+		testNode("master-0", "master"),
+		testNode("master-1", "master", "worker"),
+		testNode("worker-0", "worker"),
+	}
+
+	workers := labels.SelectorFromSet(labels.Set{nodeRolePrefix + workerRole: ""})
+	if got := controlPlaneMatches(nodes, []labels.Selector{workers}); !reflect.DeepEqual(got, []string{"master-1 (master, worker)"}) {
+		t.Errorf("unexpected matches for the workers: %v", got)
+	}
+
+	custom := labels.SelectorFromSet(labels.Set{"custom-kata1": "test"})
+	if got := controlPlaneMatches(nodes, []labels.Selector{custom}); len(got) != 2 {
+		t.Errorf("expected both masters to match, got %v", got)
+	}
+
+	infra := labels.SelectorFromSet(labels.Set{nodeRolePrefix + "infra": ""})
+	if got := controlPlaneMatches(nodes, []labels.Selector{infra, workers}); !reflect.DeepEqual(got, []string{"master-1 (master, worker)"}) {
+		t.Errorf("unexpected matches for the infra or worker nodes: %v", got)
+	}
+
+	excluded := testNode("master-2", "master", "worker")
+	excluded.Labels[excludeLabel] = "true"
+	if got := controlPlaneMatches(append(nodes, excluded), []labels.Selector{workers}); len(got) != 1 {
+		t.Errorf("expected the excluded master not to match, got %v", got)
+	}
+
+	compact := []corev1.Node{testNode("master-0", "master", "worker"), testNode("master-1", "master", "worker")}
+	if got := controlPlaneMatches(compact, []labels.Selector{workers}); len(got) != 0 {
+		t.Errorf("compact cluster rejected: %v", got)
+	}
+}
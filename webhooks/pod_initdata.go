@@ -0,0 +1,113 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/initdata"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ConfidentialRuntimeClass is the runtime class of the confidential kata sandboxes
+const ConfidentialRuntimeClass = "kata-cc"
+
+// +kubebuilder:webhook:webhookVersions=v1beta1,path=/mutate-v1-pod-initdata,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-initdata.kataconfiguration.openshift.io
+// +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataconfigs,verbs=get;list;watch
+
+// PodInitdataMutator gives the confidential pods the default initdata document of the
+// KataConfig, and validates the documents the pods bring when the KataConfig allows them
+type PodInitdataMutator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle sets or checks the initdata annotation of the confidential pods
+func (m *PodInitdataMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != ConfidentialRuntimeClass {
+		return admission.Allowed("not a confidential pod")
+	}
+
+	kataConfigs := &kataconfigurationv1.KataConfigList{}
+	if err := m.Client.List(ctx, kataConfigs); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	var conf *kataconfigurationv1.KataConfidentialConfig
+	for i := range kataConfigs.Items {
+		if c := kataConfigs.Items[i].Spec.Confidential; c != nil && c.Enabled {
+			conf = c
+			break
+		}
+	}
+	if conf == nil {
+		return admission.Allowed("confidential sandboxes disabled")
+	}
+
+	value, err := podInitdata(conf, pod.GetAnnotations())
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if value == "" {
+		return admission.Allowed("initdata of the pod kept")
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[initdata.Annotation] = value
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// InjectDecoder injects the decoder
+func (m *PodInitdataMutator) InjectDecoder(decoder *admission.Decoder) error {
+	m.decoder = decoder
+	return nil
+}
+
+// podInitdata returns the initdata annotation to give to a confidential pod with the given
+// annotations, none when the pod keeps its own or there is no default. The initdata the pod
+// brings is checked
+func podInitdata(conf *kataconfigurationv1.KataConfidentialConfig, annotations map[string]string) (string, error) {
+	if value, ok := annotations[initdata.Annotation]; ok {
+		if !conf.AllowPodInitdata {
+			return "", fmt.Errorf("the KataConfig doesn't allow the pods to bring their own initdata in the %s annotation", initdata.Annotation)
+		}
+		doc, err := initdata.Decode(value)
+		if err != nil {
+			return "", err
+		}
+		return "", initdata.Validate(doc)
+	}
+
+	if conf.Initdata == "" {
+		return "", nil
+	}
+	return initdata.Encode(conf.Initdata)
+}
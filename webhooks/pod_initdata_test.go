@@ -0,0 +1,36 @@
+package webhooks
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/initdata"
+)
+
+func TestPodInitdata(t *testing.T) {
+	doc := "version = \"0.1.0\"\nalgorithm = \"sha384\"\n[data]\n\"aa.toml\" = \"\"\n"
+	encoded, err := initdata.Encode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid, err := initdata.Encode("version = \"0.1.0\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &kataconfigurationv1.KataConfidentialConfig{Enabled: true, Initdata: doc}
+	if value, err := podInitdata(conf, nil); err != nil || value != encoded {
+		t.Errorf("expected the default initdata, got %q, %v", value, err)
+	}
+	if _, err := podInitdata(conf, map[string]string{initdata.Annotation: encoded}); err == nil {
+		t.Error("expected the initdata of the pod to be denied")
+	}
+
+	conf.AllowPodInitdata = true
+	if value, err := podInitdata(conf, map[string]string{initdata.Annotation: encoded}); err != nil || value != "" {
+		t.Errorf("expected the initdata of the pod to be kept, got %q, %v", value, err)
+	}
+	if _, err := podInitdata(conf, map[string]string{initdata.Annotation: invalid}); err == nil {
+		t.Error("expected the invalid initdata of the pod to be denied")
+	}
+}
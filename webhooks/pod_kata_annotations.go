@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	"github.com/openshift/kata-operator/pkg/initdata"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:webhookVersions=v1beta1,path=/validate-v1-pod-kata-annotations,mutating=false,failurePolicy=fail,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=vpod-kataannotations.kataconfiguration.openshift.io
+// +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataannotationpolicies,verbs=get;list;watch
+
+// PodKataAnnotationsValidator rejects the pods setting kata annotations that no
+// KataAnnotationPolicy allows to their namespace. Nothing is enforced while there is no policy.
+// CRI-O allows the annotations of all the policies, the webhook fails closed so that a namespace
+// never gets the ones of another while it is down, see config/webhook/webhook_selectors_patch.yaml
+type PodKataAnnotationsValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle checks the kata annotations of the pod against the policies of its namespace
+func (v *PodKataAnnotationsValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := v.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if len(deniedKataAnnotations(pod.GetAnnotations(), nil)) == 0 {
+		return admission.Allowed("no kata annotation")
+	}
+	// the peer pods get their VM size annotations from the operator webhooks
+	if pod.Spec.RuntimeClassName != nil && *pod.Spec.RuntimeClassName == PeerPodsRuntimeClass {
+		return admission.Allowed("peer pod")
+	}
+
+	policies := &kataconfigurationv1.KataAnnotationPolicyList{}
+	if err := v.Client.List(ctx, policies); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(policies.Items) == 0 {
+		return admission.Allowed("no kata annotation policy")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: req.Namespace}, ns); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	allowed, err := namespaceAllowedAnnotations(policies.Items, ns)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if denied := deniedKataAnnotations(pod.GetAnnotations(), allowed); len(denied) > 0 {
+		return admission.Denied(fmt.Sprintf("kata annotations not allowed in namespace %s by any KataAnnotationPolicy: %s",
+			req.Namespace, strings.Join(denied, ", ")))
+	}
+	return admission.Allowed("kata annotations allowed")
+}
+
+// InjectDecoder injects the decoder
+func (v *PodKataAnnotationsValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// namespaceAllowedAnnotations returns the kata annotations the policies selecting the namespace allow
+func namespaceAllowedAnnotations(policies []kataconfigurationv1.KataAnnotationPolicy, ns *corev1.Namespace) ([]string, error) {
+	var allowed []string
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Spec.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+			if err != nil {
+				return nil, fmt.Errorf("KataAnnotationPolicy %s: %v", policy.Name, err)
+			}
+			if !selector.Matches(labels.Set(ns.GetLabels())) {
+				continue
+			}
+		}
+		for _, annotation := range policy.Spec.AllowedAnnotations {
+			allowed = append(allowed, string(annotation))
+		}
+	}
+	return allowed, nil
+}
+
+// deniedKataAnnotations returns the kata annotations that none of the allowed ones is a prefix of
+func deniedKataAnnotations(annotations map[string]string, allowed []string) []string {
+	var denied []string
+	for annotation := range annotations {
+		// the initdata of the confidential pods is up to the KataConfig, see PodInitdataMutator
+		if !strings.HasPrefix(annotation, kataconfigurationv1.KataAnnotationPrefix) || annotation == initdata.Annotation {
+			continue
+		}
+		ok := false
+		for _, prefix := range allowed {
+			if strings.HasPrefix(annotation, prefix) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			denied = append(denied, annotation)
+		}
+	}
+	sort.Strings(denied)
+	return denied
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/kata-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceKataAnnotations(t *testing.T) {
+	policies := []kataconfigurationv1.KataAnnotationPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "everywhere"},
+			Spec: kataconfigurationv1.KataAnnotationPolicySpec{
+				AllowedAnnotations: []kataconfigurationv1.KataAnnotation{memoryAnnotation},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "tuning"},
+			Spec: kataconfigurationv1.KataAnnotationPolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tuning": "true"}},
+				AllowedAnnotations: []kataconfigurationv1.KataAnnotation{
+					"io.katacontainers.config.hypervisor.",
+				},
+			},
+		},
+	}
+	annotations := map[string]string{
+		memoryAnnotation: "4096",
+		vcpusAnnotation:  "4",
+		"io.katacontainers.config.agent.debug_console": "true",
+		"example.com/unrelated":                        "true",
+	}
+
+	plain := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}}
+	allowed, err := namespaceAllowedAnnotations(policies, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"io.katacontainers.config.agent.debug_console", vcpusAnnotation}
+	if got := deniedKataAnnotations(annotations, allowed); !reflect.DeepEqual(got, want) {
+		t.Errorf("plain namespace: expected %v denied, got %v", want, got)
+	}
+
+	tuning := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tuning", Labels: map[string]string{"tuning": "true"}}}
+	allowed, err = namespaceAllowedAnnotations(policies, tuning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"io.katacontainers.config.agent.debug_console"}
+	if got := deniedKataAnnotations(annotations, allowed); !reflect.DeepEqual(got, want) {
+		t.Errorf("tuning namespace: expected %v denied, got %v", want, got)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// PeerPodsRuntimeClass is the runtime class of the pods running in a remote VM
+	PeerPodsRuntimeClass = "kata-remote"
+
+	// PeerPodsVMResource is the extended resource accounting for the remote VMs a node can run
+	PeerPodsVMResource corev1.ResourceName = "kata.peerpods.io/vm"
+
+	vcpusAnnotation  = "io.katacontainers.config.hypervisor.default_vcpus"
+	memoryAnnotation = "io.katacontainers.config.hypervisor.default_memory"
+)
+
+// +kubebuilder:webhook:webhookVersions=v1beta1,path=/mutate-v1-pod-peerpods,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-peerpods.kataconfiguration.openshift.io
+
+// PodPeerPodsMutator rewrites the resources of the peer pods. Their containers run in a
+// remote VM, so the CPU and memory they request must not be accounted on the worker node
+type PodPeerPodsMutator struct {
+	decoder *admission.Decoder
+}
+
+// Handle replaces the CPU and memory resources of a peer pod with the VM extended resource
+func (m *PodPeerPodsMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != PeerPodsRuntimeClass {
+		return admission.Allowed("not a peer pod")
+	}
+
+	rewritePeerPodResources(pod)
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// InjectDecoder injects the decoder
+func (m *PodPeerPodsMutator) InjectDecoder(decoder *admission.Decoder) error {
+	m.decoder = decoder
+	return nil
+}
+
+// rewritePeerPodResources strips the CPU and memory resources of the containers and makes
+// the pod request one remote VM instead. The stripped resources size the VM through the
+// kata annotations, unless they are already set
+func rewritePeerPodResources(pod *corev1.Pod) {
+	cpu := resource.Quantity{}
+	memory := resource.Quantity{}
+
+	strip := func(c *corev1.Container) {
+		if q, ok := c.Resources.Limits[corev1.ResourceCPU]; ok {
+			cpu.Add(q)
+		} else if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+			cpu.Add(q)
+		}
+		if q, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+			memory.Add(q)
+		} else if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+			memory.Add(q)
+		}
+
+		for _, list := range []corev1.ResourceList{c.Resources.Requests, c.Resources.Limits} {
+			delete(list, corev1.ResourceCPU)
+			delete(list, corev1.ResourceMemory)
+		}
+	}
+
+	for i := range pod.Spec.InitContainers {
+		strip(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.Containers {
+		strip(&pod.Spec.Containers[i])
+	}
+
+	if len(pod.Spec.Containers) > 0 {
+		c := &pod.Spec.Containers[0]
+		if c.Resources.Requests == nil {
+			c.Resources.Requests = corev1.ResourceList{}
+		}
+		if c.Resources.Limits == nil {
+			c.Resources.Limits = corev1.ResourceList{}
+		}
+		// Extended resources can't be overcommitted, requests must equal the limits
+		c.Resources.Requests[PeerPodsVMResource] = resource.MustParse("1")
+		c.Resources.Limits[PeerPodsVMResource] = resource.MustParse("1")
+	}
+
+	annotations := pod.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if _, ok := annotations[vcpusAnnotation]; !ok && !cpu.IsZero() {
+		// Round up to whole vCPUs
+		vcpus := (cpu.MilliValue() + 999) / 1000
+		annotations[vcpusAnnotation] = strconv.FormatInt(vcpus, 10)
+	}
+	if _, ok := annotations[memoryAnnotation]; !ok && !memory.IsZero() {
+		// In MiB, rounded up
+		mib := (memory.Value() + (1<<20 - 1)) >> 20
+		annotations[memoryAnnotation] = strconv.FormatInt(mib, 10)
+	}
+	pod.SetAnnotations(annotations)
+}
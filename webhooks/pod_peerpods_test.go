@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRewritePeerPodResources(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("1500m"),
+							corev1.ResourceMemory: resource.MustParse("1Gi"),
+						},
+					},
+				},
+				{
+					Name: "sidecar",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("100Mi")},
+					},
+				},
+			},
+		},
+	}
+
+	rewritePeerPodResources(pod)
+
+	for _, c := range pod.Spec.Containers {
+		for _, list := range []corev1.ResourceList{c.Resources.Requests, c.Resources.Limits} {
+			if _, ok := list[corev1.ResourceCPU]; ok {
+				t.Errorf("cpu left on container %s", c.Name)
+			}
+			if _, ok := list[corev1.ResourceMemory]; ok {
+				t.Errorf("memory left on container %s", c.Name)
+			}
+		}
+	}
+
+	vm := pod.Spec.Containers[0].Resources.Limits[PeerPodsVMResource]
+	if vm.Value() != 1 {
+		t.Errorf("expected one %s, got %s", PeerPodsVMResource, vm.String())
+	}
+	if _, ok := pod.Spec.Containers[1].Resources.Limits[PeerPodsVMResource]; ok {
+		t.Errorf("%s requested more than once", PeerPodsVMResource)
+	}
+
+	if got := pod.Annotations[vcpusAnnotation]; got != "2" {
+		t.Errorf("expected 2 vcpus, got %q", got)
+	}
+	if got := pod.Annotations[memoryAnnotation]; got != "1124" {
+		t.Errorf("expected 1124 MiB, got %q", got)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultRuntimeAnnotation on a namespace names the runtime class given to the pods created
+// in it that don't ask for one. The webhook gets the pods of all the namespaces but the control
+// plane ones, see config/webhook/webhook_selectors_patch.yaml
+const DefaultRuntimeAnnotation = "kata.openshift.io/default-runtime"
+
+var podlog = logf.Log.WithName("pod-runtimeclass-webhook")
+
+// +kubebuilder:webhook:webhookVersions=v1beta1,path=/mutate-v1-pod-runtimeclass,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-runtimeclass.kataconfiguration.openshift.io
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// PodRuntimeClassDefaulter sets the runtime class of the pods created in the namespaces
+// annotated with kata.openshift.io/default-runtime
+type PodRuntimeClassDefaulter struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle injects the default runtime class of the namespace into the pod
+func (d *PodRuntimeClassDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := d.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Spec.RuntimeClassName != nil {
+		return admission.Allowed("runtime class already set")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := d.Client.Get(ctx, types.NamespacedName{Name: req.Namespace}, ns); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	runtimeClassName, ok := ns.GetAnnotations()[DefaultRuntimeAnnotation]
+	if !ok || runtimeClassName == "" {
+		return admission.Allowed("no default runtime class for the namespace")
+	}
+
+	// Don't make the pods unschedulable before kata is installed
+	rc := &nodeapi.RuntimeClass{}
+	if err := d.Client.Get(ctx, types.NamespacedName{Name: runtimeClassName}, rc); err != nil {
+		if errors.IsNotFound(err) {
+			podlog.Info("default runtime class of the namespace doesn't exist", "namespace", req.Namespace, "runtimeClass", runtimeClassName)
+			return admission.Allowed("default runtime class not installed")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	pod.Spec.RuntimeClassName = &runtimeClassName
+	// The webhooks don't see each other's changes, rewrite the peer pods resources here too
+	if runtimeClassName == PeerPodsRuntimeClass {
+		rewritePeerPodResources(pod)
+	}
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// InjectDecoder injects the decoder
+func (d *PodRuntimeClassDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
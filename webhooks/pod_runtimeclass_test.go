@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPodRuntimeClassDefaulter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "annotated",
+			Annotations: map[string]string{DefaultRuntimeAnnotation: "kata"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "confidential",
+			Annotations: map[string]string{DefaultRuntimeAnnotation: "kata-cc"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+		// a label doesn't opt the namespace in
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "labeled",
+			Labels: map[string]string{DefaultRuntimeAnnotation: "kata"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "missing",
+			Annotations: map[string]string{DefaultRuntimeAnnotation: "kata-remote"}}},
+		&nodeapi.RuntimeClass{ObjectMeta: metav1.ObjectMeta{Name: "kata"}, Handler: "kata"},
+		&nodeapi.RuntimeClass{ObjectMeta: metav1.ObjectMeta{Name: "kata-cc"}, Handler: "kata-cc"},
+	}
+	defaulter := &PodRuntimeClassDefaulter{Client: fake.NewFakeClientWithScheme(scheme, namespaces...)}
+	if err := defaulter.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	runc := "runc"
+	tests := []struct {
+		name             string
+		namespace        string
+		runtimeClassName *string
+		expected         string
+	}{
+		{name: "annotated namespace", namespace: "annotated", expected: "kata"},
+		{name: "other runtime class", namespace: "confidential", expected: "kata-cc"},
+		{name: "namespace without default", namespace: "plain"},
+		{name: "labeled namespace", namespace: "labeled"},
+		{name: "missing runtime class", namespace: "missing"},
+		{name: "runtime class already set", namespace: "annotated", runtimeClassName: &runc},
+	}
+
+	for _, test := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: test.namespace},
+			Spec: corev1.PodSpec{
+				RuntimeClassName: test.runtimeClassName,
+				Containers:       []corev1.Container{{Name: "app", Image: "registry.example.com/app"}},
+			},
+		}
+		raw, err := json.Marshal(pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := defaulter.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Namespace: test.namespace,
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+
+		if !resp.Allowed {
+			t.Errorf("%s: pod denied: %v", test.name, resp.Result)
+			continue
+		}
+		if test.expected == "" {
+			if len(resp.Patches) != 0 {
+				t.Errorf("%s: expected no patch, got %v", test.name, resp.Patches)
+			}
+			continue
+		}
+		if len(resp.Patches) != 1 || resp.Patches[0].Path != "/spec/runtimeClassName" || resp.Patches[0].Value != test.expected {
+			t.Errorf("%s: expected the runtime class %s, got %v", test.name, test.expected, resp.Patches)
+		}
+	}
+}